@@ -0,0 +1,107 @@
+// Package apperror defines the errors the wallet reports to its callers. Storage errors are
+// translated into these, and handlers return them unchanged or wrapped, so a transport can
+// decide how to present a failure with errors.Is instead of matching on message strings.
+package apperror
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidRequest is returned when a request fails validation
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrUnauthenticated is returned when the caller's identity is missing or cannot be verified
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden is returned when the caller may not act on the wallet a request names
+	ErrForbidden = errors.New("forbidden")
+	// ErrAccountNotFound is returned when a request names a wallet that does not exist
+	ErrAccountNotFound = errors.New("account not found")
+	// ErrNotFound is returned when a request names a record other than a wallet, such as an
+	// adjustment, that does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrInsufficientFunds is returned when an operation would take a wallet below zero or spend
+	// money reserved by its holds
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrLimitExceeded is returned when an operation would move more money out of a wallet than
+	// the limits of its tier allow
+	ErrLimitExceeded = errors.New("limit exceeded")
+	// ErrAccountFrozen is returned when an operation moves money in or out of a frozen wallet
+	ErrAccountFrozen = errors.New("account frozen")
+	// ErrAccountClosed is returned when an operation moves money in or out of a closed wallet
+	ErrAccountClosed = errors.New("account closed")
+	// ErrConflict is returned when a request clashes with the current state, such as a balance
+	// that kept changing concurrently, an idempotency key reused for a different request, an
+	// account status change that is not allowed from the current status, an adjustment that
+	// was already reviewed, an exchange quote that was already used or has expired, or a hold
+	// that is no longer authorized
+	ErrConflict = errors.New("conflict")
+	// ErrRateUnavailable is returned when no exchange rate can be found for a pair of
+	// currencies. Like ErrStorage, the request may succeed later.
+	ErrRateUnavailable = errors.New("exchange rate unavailable")
+	// ErrStorage is returned when the database fails, e.g. because it is unreachable. Unlike the
+	// errors above it says nothing about the request, which may succeed if retried.
+	ErrStorage = errors.New("storage failure")
+)
+
+// AccountNotFoundError reports which wallet, or which currency of it, does not exist. It
+// matches ErrAccountNotFound.
+type AccountNotFoundError struct {
+	UserID   uint
+	Currency string // Empty when the user has no wallet at all
+}
+
+func (e *AccountNotFoundError) Error() string {
+	if e.Currency != "" {
+		return fmt.Sprintf("%s account for user %d not found", e.Currency, e.UserID)
+	}
+	return fmt.Sprintf("account for user %d not found", e.UserID)
+}
+
+func (e *AccountNotFoundError) Is(target error) bool {
+	return target == ErrAccountNotFound
+}
+
+// InsufficientFundsError reports which wallet lacks the funds, and in which currency. It
+// matches ErrInsufficientFunds.
+type InsufficientFundsError struct {
+	UserID   uint
+	Currency string
+}
+
+func (e *InsufficientFundsError) Error() string {
+	if e.Currency != "" {
+		return fmt.Sprintf("insufficient %s funds for user %d", e.Currency, e.UserID)
+	}
+	return fmt.Sprintf("insufficient funds for user %d", e.UserID)
+}
+
+func (e *InsufficientFundsError) Is(target error) bool {
+	return target == ErrInsufficientFunds
+}
+
+// LimitExceededError reports which limit an operation would break and how much of it the wallet
+// may still use. It matches ErrLimitExceeded.
+type LimitExceededError struct {
+	UserID    uint
+	Operation string // The transaction type limited, e.g. "Withdraw"
+	Period    string // "transaction", "daily" or "monthly"
+	Currency  string
+	// Limit and Remaining are in hundredths of the currency's major unit, like model.Money
+	Limit     int64
+	Remaining int64 // What may still be moved in the period, zero once it is used up
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s would exceed the %s %s limit of %s for user %d, %s remaining",
+		e.Operation, e.Period, e.Currency, formatMinorUnits(e.Limit), e.UserID, formatMinorUnits(e.Remaining))
+}
+
+func (e *LimitExceededError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// formatMinorUnits formats a non-negative amount of hundredths such as 1250 as "12.50"
+func formatMinorUnits(amount int64) string {
+	return fmt.Sprintf("%d.%02d", amount/100, amount%100)
+}
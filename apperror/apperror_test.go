@@ -0,0 +1,58 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedErrorsMatchSentinels(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		sentinel error
+		message  string
+	}{
+		{
+			name:     "Account not found",
+			err:      &AccountNotFoundError{UserID: 7},
+			sentinel: ErrAccountNotFound,
+			message:  "account for user 7 not found",
+		},
+		{
+			name:     "Currency account not found",
+			err:      &AccountNotFoundError{UserID: 7, Currency: "EUR"},
+			sentinel: ErrAccountNotFound,
+			message:  "EUR account for user 7 not found",
+		},
+		{
+			name:     "Insufficient funds",
+			err:      &InsufficientFundsError{UserID: 3},
+			sentinel: ErrInsufficientFunds,
+			message:  "insufficient funds for user 3",
+		},
+		{
+			name:     "Insufficient funds in a currency",
+			err:      &InsufficientFundsError{UserID: 3, Currency: "JPY"},
+			sentinel: ErrInsufficientFunds,
+			message:  "insufficient JPY funds for user 3",
+		},
+		{
+			name:     "Limit exceeded",
+			err:      &LimitExceededError{UserID: 3, Operation: "Withdraw", Period: "daily", Currency: "USD", Limit: 200000, Remaining: 12505},
+			sentinel: ErrLimitExceeded,
+			message:  "Withdraw would exceed the daily USD limit of 2000.00 for user 3, 125.05 remaining",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := fmt.Errorf("transfer failed: %w", tt.err)
+			assert.ErrorIs(t, wrapped, tt.sentinel)
+			assert.False(t, errors.Is(wrapped, ErrStorage))
+			assert.Equal(t, tt.message, tt.err.Error())
+		})
+	}
+}
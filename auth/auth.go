@@ -0,0 +1,62 @@
+// Package auth identifies the caller of a request and decides what it may do. Transports
+// authenticate the caller (a CLI login or a bearer token) and attach a Principal to the
+// request context; handlers then call Authorize before touching a wallet.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"walletApp/apperror"
+	"walletApp/model"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID uint
+	Role   model.UserRole
+}
+
+// IsAdmin reports whether the principal may act on any wallet
+func (p Principal) IsAdmin() bool {
+	return p.Role == model.UserRoleAdmin
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the caller attached to ctx, or apperror.ErrUnauthenticated if there is none
+func FromContext(ctx context.Context) (Principal, error) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	if !ok {
+		return Principal{}, fmt.Errorf("%w: no caller identity on the request", apperror.ErrUnauthenticated)
+	}
+	return principal, nil
+}
+
+// Authorize checks that the caller may act on userID's wallet: its own, or any wallet for an admin
+func Authorize(ctx context.Context, userID uint) error {
+	principal, err := FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if principal.IsAdmin() || principal.UserID == userID {
+		return nil
+	}
+	return fmt.Errorf("%w: user %d may not act on the wallet of user %d", apperror.ErrForbidden, principal.UserID, userID)
+}
+
+// AuthorizeAdmin checks that the caller is an admin
+func AuthorizeAdmin(ctx context.Context) error {
+	principal, err := FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if principal.IsAdmin() {
+		return nil
+	}
+	return fmt.Errorf("%w: user %d is not an admin", apperror.ErrForbidden, principal.UserID)
+}
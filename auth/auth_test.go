@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		userID        uint
+		expectedError error
+	}{
+		{
+			name:   "Own wallet",
+			ctx:    WithPrincipal(context.Background(), Principal{UserID: 1}),
+			userID: 1,
+		},
+		{
+			name:          "Another user's wallet",
+			ctx:           WithPrincipal(context.Background(), Principal{UserID: 2}),
+			userID:        1,
+			expectedError: apperror.ErrForbidden,
+		},
+		{
+			name:   "Admin on any wallet",
+			ctx:    WithPrincipal(context.Background(), Principal{UserID: 2, Role: model.UserRoleAdmin}),
+			userID: 1,
+		},
+		{
+			name:          "Anonymous caller",
+			ctx:           context.Background(),
+			userID:        1,
+			expectedError: apperror.ErrUnauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Authorize(tt.ctx, tt.userID)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestAuthorizeAdmin(t *testing.T) {
+	assert.NoError(t, AuthorizeAdmin(WithPrincipal(context.Background(), Principal{UserID: 1, Role: model.UserRoleAdmin})))
+	assert.ErrorIs(t, AuthorizeAdmin(WithPrincipal(context.Background(), Principal{UserID: 1})), apperror.ErrForbidden)
+	assert.ErrorIs(t, AuthorizeAdmin(context.Background()), apperror.ErrUnauthenticated)
+}
+
+func TestTokenManager(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	manager := NewTokenManager([]byte("secret"))
+	manager.Now = func() time.Time { return now }
+	principal := Principal{UserID: 42, Role: model.UserRoleAdmin}
+
+	token, expiresAt, err := manager.Issue(principal)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(DefaultTokenTTL), expiresAt)
+
+	verified, err := manager.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, principal, verified)
+
+	tests := []struct {
+		name    string
+		token   string
+		manager *TokenManager
+	}{
+		{
+			name:    "Tampered claims",
+			token:   "eyJ1aWQiOjEsInJvbGUiOjEsImV4cCI6OTk5OTk5OTk5OX0" + token[len(token)-44:],
+			manager: manager,
+		},
+		{
+			name:    "Signed with another secret",
+			token:   token,
+			manager: &TokenManager{Secret: []byte("other secret"), Now: manager.Now},
+		},
+		{
+			name:    "Expired",
+			token:   token,
+			manager: &TokenManager{Secret: []byte("secret"), Now: func() time.Time { return expiresAt }},
+		},
+		{
+			name:    "Malformed",
+			token:   "not-a-token",
+			manager: manager,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.manager.Verify(tt.token)
+			assert.ErrorIs(t, err, apperror.ErrUnauthenticated)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+)
+
+// DefaultTokenTTL is how long an issued token stays valid
+const DefaultTokenTTL = 24 * time.Hour
+
+// TokenManager issues and verifies bearer tokens. A token is a base64url encoded JSON claim set
+// followed by its HMAC-SHA256 signature, so verifying one needs no database lookup.
+type TokenManager struct {
+	Secret []byte
+	TTL    time.Duration
+	Now    func() time.Time // Defaults to time.Now, replaced in tests
+}
+
+// NewTokenManager creates a TokenManager signing with secret and issuing DefaultTokenTTL tokens
+func NewTokenManager(secret []byte) *TokenManager {
+	return &TokenManager{Secret: secret, TTL: DefaultTokenTTL, Now: time.Now}
+}
+
+type tokenClaims struct {
+	UserID    uint           `json:"uid"`
+	Role      model.UserRole `json:"role"`
+	ExpiresAt int64          `json:"exp"` // Unix seconds
+}
+
+// Issue returns a token identifying principal and the time it expires
+func (m *TokenManager) Issue(principal Principal) (string, time.Time, error) {
+	expiresAt := m.now().Add(m.TTL)
+	payload, err := json.Marshal(tokenClaims{UserID: principal.UserID, Role: principal.Role, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to encode token claims: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + m.sign(encoded), expiresAt, nil
+}
+
+// Verify checks the signature and expiry of token and returns the principal it identifies.
+// Any failure is reported as apperror.ErrUnauthenticated.
+func (m *TokenManager) Verify(token string) (Principal, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(m.sign(encoded))) {
+		return Principal{}, fmt.Errorf("%w: invalid token", apperror.ErrUnauthenticated)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: invalid token", apperror.ErrUnauthenticated)
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Principal{}, fmt.Errorf("%w: invalid token", apperror.ErrUnauthenticated)
+	}
+	if m.now().Unix() >= claims.ExpiresAt {
+		return Principal{}, fmt.Errorf("%w: token expired", apperror.ErrUnauthenticated)
+	}
+	return Principal{UserID: claims.UserID, Role: claims.Role}, nil
+}
+
+func (m *TokenManager) sign(encoded string) string {
+	mac := hmac.New(sha256.New, m.Secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (m *TokenManager) now() time.Time {
+	if m.Now == nil {
+		return time.Now()
+	}
+	return m.Now()
+}
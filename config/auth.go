@@ -0,0 +1,21 @@
+package config
+
+import (
+	"crypto/rand"
+	"log"
+	"os"
+)
+
+// TokenSecret returns the key API tokens are signed with, taken from WALLET_TOKEN_SECRET. Without
+// it a random key is generated, so tokens stop working when the process restarts.
+func TokenSecret() []byte {
+	if secret := os.Getenv("WALLET_TOKEN_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	log.Println("WALLET_TOKEN_SECRET is not set, signing tokens with a random key")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatal("Failed to generate token secret:", err)
+	}
+	return secret
+}
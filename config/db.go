@@ -4,6 +4,7 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"log"
+	"os"
 )
 
 var DB *gorm.DB
@@ -12,10 +13,25 @@ func InitDB() {
 	var err error
 	// TODO: move it to config file to be more formal and all username and password should be encrypted
 	dsn := "host=postgres port=5432 user=postgres password=yourpassword dbname=wallet_db sslmode=disable"
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError reports unique constraint violations as gorm.ErrDuplicatedKey
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
 	log.Println("Database initialized successfully.")
 }
+
+// OptimisticLocking reports whether balances are protected by version checks rather than row
+// locks, taken from WALLET_LOCKING, which is either "pessimistic", the default, or "optimistic"
+func OptimisticLocking() bool {
+	switch text := os.Getenv("WALLET_LOCKING"); text {
+	case "", "pessimistic":
+		return false
+	case "optimistic":
+		return true
+	default:
+		log.Fatalf("WALLET_LOCKING must be \"pessimistic\" or \"optimistic\", got %q", text)
+		return false
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+	"walletApp/model"
+)
+
+// DefaultExchangeSpread is the spread, in basis points, used when WALLET_EXCHANGE_SPREAD_BPS is not set
+const DefaultExchangeSpread = 50
+
+// QuoteLifetime is how long an exchange quote can be used after it was given
+const QuoteLifetime = 30 * time.Second
+
+// RatesFile returns the path of the JSON file exchange rates are read from, taken from
+// WALLET_RATES_FILE, or "rates.json" in the working directory when it is not set
+func RatesFile() string {
+	if path := os.Getenv("WALLET_RATES_FILE"); path != "" {
+		return path
+	}
+	return "rates.json"
+}
+
+// ExchangeSpread returns the share of every currency conversion kept by the house in basis
+// points (hundredths of a percent), taken from WALLET_EXCHANGE_SPREAD_BPS
+func ExchangeSpread() int64 {
+	text := os.Getenv("WALLET_EXCHANGE_SPREAD_BPS")
+	if text == "" {
+		return DefaultExchangeSpread
+	}
+	spread, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || spread < 0 || spread >= model.BasisPoints {
+		log.Fatalf("WALLET_EXCHANGE_SPREAD_BPS must be a whole number of basis points from 0 to 9999, got %q", text)
+	}
+	return spread
+}
@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// DefaultHoldLifetime is how long a hold reserves money when it is authorized without an expiry
+const DefaultHoldLifetime = 7 * 24 * time.Hour
+
+// MaxHoldLifetime is the latest a hold may be set to expire after it is authorized
+const MaxHoldLifetime = 30 * 24 * time.Hour
+
+// HoldExpiryInterval is how often expired holds are looked for and released
+const HoldExpiryInterval = time.Minute
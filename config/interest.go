@@ -0,0 +1,12 @@
+package config
+
+import "time"
+
+// InterestInterval is how often savings wallets are checked for days to accrue and months to pay
+// out. Accrual is idempotent, so checking more often than daily only makes interest show up
+// sooner after midnight.
+const InterestInterval = time.Hour
+
+// InterestMaxCatchUpDays bounds how many past days a savings wallet accrues at once after the
+// job did not run, or after the wallet became a savings wallet again
+const InterestMaxCatchUpDays = 31
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"walletApp/fees"
+	"walletApp/interest"
+	"walletApp/limits"
+	"walletApp/overdraft"
+)
+
+// Fees returns the withdrawal and transfer fees read from the file named by WALLET_FEES_FILE,
+// "fees.json" by default. Without the file nothing is charged.
+func Fees() fees.Schedule {
+	return loadPolicy[fees.Schedule]("WALLET_FEES_FILE", "fees.json", "fees")
+}
+
+// Limits returns the transaction limits read from the file named by WALLET_LIMITS_FILE,
+// "limits.json" by default. Without the file nothing is limited.
+func Limits() limits.Policy {
+	return loadPolicy[limits.Policy]("WALLET_LIMITS_FILE", "limits.json", "transaction limits")
+}
+
+// InterestRates returns the savings interest rates read from the file named by
+// WALLET_INTEREST_FILE, "interest.json" by default. Without the file no wallet earns interest.
+func InterestRates() interest.Rates {
+	return loadPolicy[interest.Rates]("WALLET_INTEREST_FILE", "interest.json", "interest rates")
+}
+
+// Overdraft returns the credit line terms read from the file named by WALLET_OVERDRAFT_FILE,
+// "overdraft.json" by default. Without the file going below zero is free.
+func Overdraft() overdraft.Policy {
+	return loadPolicy[overdraft.Policy]("WALLET_OVERDRAFT_FILE", "overdraft.json", "overdraft terms")
+}
+
+// loadPolicy reads the policy in the JSON file named by the environment variable env, or file in
+// the working directory when it is not set, and stops the program when the file cannot be read
+func loadPolicy[T any](env, file, name string) T {
+	path := os.Getenv(env)
+	if path == "" {
+		path = file
+	}
+	policy, err := readPolicy[T](path)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", name, err)
+	}
+	return policy
+}
+
+// readPolicy decodes the JSON file at path into a T, or returns the zero T, an empty policy, when
+// there is no such file
+func readPolicy[T any](path string) (T, error) {
+	var policy T
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return policy, fmt.Errorf("%s: %w", path, err)
+	}
+	return policy, nil
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"walletApp/limits"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	policy, err := readPolicy[limits.Policy](filepath.Join(dir, "missing.json"))
+	require.NoError(t, err)
+	assert.Empty(t, policy, "a missing file limits nothing")
+
+	path := filepath.Join(dir, "limits.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"Standard": {"Withdraw": {"USD": {"daily": "500.00"}}}}`), 0o600))
+	policy, err = readPolicy[limits.Policy](path)
+	require.NoError(t, err)
+	assert.Equal(t, limits.Policy{model.AccountTierStandard: {model.TransactionTypeWithdraw: {model.USD: {Daily: 50000}}}}, policy)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"Gold": {}}`), 0o600))
+	_, err = readPolicy[limits.Policy](path)
+	assert.ErrorContains(t, err, path)
+	assert.ErrorContains(t, err, `unknown account tier "Gold"`)
+}
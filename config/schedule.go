@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+// ScheduleInterval is how often schedules are checked for due transfers
+const ScheduleInterval = time.Minute
+
+// ScheduleRetryInterval is how long a schedule waits before running a failed transfer again
+const ScheduleRetryInterval = time.Hour
+
+// ScheduleMaxAttempts is how many times a scheduled transfer is run before its schedule's
+// failure policy applies
+const ScheduleMaxAttempts = 3
@@ -1,48 +1,416 @@
 package dto
 
-import "walletApp/model"
+import (
+	"time"
+	"walletApp/model"
+)
 
 type TransferRequest struct {
-	FromUserID uint    `json:"from_user_id"`
-	ToUserID   uint    `json:"to_user_id"`
-	Amount     float64 `json:"amount"`
+	FromUserID        uint           `json:"from_user_id"`
+	ToUserID          uint           `json:"to_user_id"`
+	Amount            model.Money    `json:"amount"`
+	Currency          model.Currency `json:"currency,omitempty"`           // Optional, model.DefaultCurrency when empty
+	ToCurrency        model.Currency `json:"to_currency,omitempty"`        // Optional, the recipient's currency when it differs
+	Convert           bool           `json:"convert,omitempty"`            // Must be set for a transfer between currencies
+	Memo              string         `json:"memo,omitempty"`               // Optional, shown to both users
+	ExternalReference string         `json:"external_reference,omitempty"` // Optional, e.g. an invoice number
+	IdempotencyKey    string         `json:"idempotency_key,omitempty"`    // Optional, makes retries safe
 }
 
 type TransferResponse struct {
-	Success bool               `json:"success"`
-	Message string             `json:"message"`
-	Data    map[string]float64 `json:"data"` // debug purpose
+	Success    bool                   `json:"success"`
+	Message    string                 `json:"message"`
+	TransferID string                 `json:"transfer_id"` // Shared by the sender's and recipient's transactions
+	Currency   model.Currency         `json:"currency"`
+	Fee        model.Money            `json:"fee"`                  // Charged to the sender on top of the amount, in Currency
+	Conversion *ConversionResponse    `json:"conversion,omitempty"` // Set for a transfer between currencies
+	Data       map[string]model.Money `json:"data"`                 // debug purpose
+}
+
+// ConversionResponse describes the price money was converted at
+type ConversionResponse struct {
+	ToCurrency model.Currency `json:"to_currency"`
+	ToAmount   model.Money    `json:"to_amount"` // What arrived in ToCurrency
+	Rate       model.Rate     `json:"rate"`      // Mid-market price of one unit of the sold currency in ToCurrency
+	Spread     model.Money    `json:"spread"`    // Kept by the house, in ToCurrency
+}
+
+// BatchTransferRequest pays out from one wallet to many in a single operation. The items are
+// applied all or nothing unless BestEffort is set, in which case the items that can be applied
+// are and the others are reported as failed.
+type BatchTransferRequest struct {
+	FromUserID     uint                `json:"from_user_id"`
+	Currency       model.Currency      `json:"currency,omitempty"` // Optional, model.DefaultCurrency when empty
+	Items          []BatchTransferItem `json:"items"`
+	BestEffort     bool                `json:"best_effort,omitempty"`
+	IdempotencyKey string              `json:"idempotency_key,omitempty"` // Optional, makes retries safe
+}
+
+// BatchTransferItem is one transfer of a batch
+type BatchTransferItem struct {
+	ToUserID          uint        `json:"to_user_id"`
+	Amount            model.Money `json:"amount"`
+	Memo              string      `json:"memo,omitempty"`               // Optional, shown to both users
+	ExternalReference string      `json:"external_reference,omitempty"` // Optional, e.g. an invoice number
+}
+
+type BatchTransferResponse struct {
+	Success   bool                  `json:"success"` // Every item was applied
+	Message   string                `json:"message"`
+	BatchID   string                `json:"batch_id"` // Shared by the transactions of every item applied
+	Currency  model.Currency        `json:"currency"`
+	Total     model.Money           `json:"total"` // Sent by the items applied, fees included
+	Fees      model.Money           `json:"fees"`
+	Succeeded int                   `json:"succeeded"`
+	Failed    int                   `json:"failed"`
+	Items     []BatchTransferResult `json:"items"` // In the order of the request
+}
+
+// BatchTransferResult is the outcome of one item of a batch
+type BatchTransferResult struct {
+	Index      int         `json:"index"`
+	ToUserID   uint        `json:"to_user_id"`
+	Amount     model.Money `json:"amount"`
+	TransferID string      `json:"transfer_id,omitempty"` // Set when the item was applied
+	Fee        model.Money `json:"fee"`
+	Error      string      `json:"error,omitempty"` // Why the item was not applied
+}
+
+// ExchangeQuoteRequest asks for the price of selling Amount of FromCurrency for ToCurrency
+type ExchangeQuoteRequest struct {
+	UserID       uint           `json:"user_id"`
+	FromCurrency model.Currency `json:"from_currency"`
+	ToCurrency   model.Currency `json:"to_currency"`
+	Amount       model.Money    `json:"amount"`
+}
+
+type ExchangeQuoteResponse struct {
+	QuoteID      string         `json:"quote_id"`
+	UserID       uint           `json:"user_id"`
+	FromCurrency model.Currency `json:"from_currency"`
+	Amount       model.Money    `json:"amount"`
+	ConversionResponse
+	ExpiresAt time.Time `json:"expires_at"` // The quote cannot be used from then on
+}
+
+// ExchangeRequest converts money between two currencies of the user's wallet, either at the
+// price of an earlier quote named by QuoteID or, without one, at the current price of selling
+// Amount of FromCurrency for ToCurrency
+type ExchangeRequest struct {
+	UserID         uint           `json:"user_id"`
+	QuoteID        string         `json:"quote_id,omitempty"`
+	FromCurrency   model.Currency `json:"from_currency,omitempty"`
+	ToCurrency     model.Currency `json:"to_currency,omitempty"`
+	Amount         model.Money    `json:"amount,omitempty"`
+	IdempotencyKey string         `json:"idempotency_key,omitempty"` // Optional, makes retries safe
+}
+
+type ExchangeResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	ExchangeQuoteResponse
+	JournalEntryID uint        `json:"journal_entry_id"`
+	FromBalance    model.Money `json:"from_balance"` // New balance in FromCurrency
+	ToBalance      model.Money `json:"to_balance"`   // New balance in ToCurrency
+}
+
+type ReverseRequest struct {
+	TransactionID  uint         `json:"transaction_id"`   // Either transaction of a transfer
+	Amount         *model.Money `json:"amount,omitempty"` // Refund only this much, reverse the whole transaction when omitted
+	Reason         string       `json:"reason"`
+	IdempotencyKey string       `json:"idempotency_key,omitempty"` // Optional, makes retries safe
+}
+
+type ReverseResponse struct {
+	Success        bool                 `json:"success"`
+	Message        string               `json:"message"`
+	Type           string               `json:"type"` // Reversal or Refund
+	Amount         model.Money          `json:"amount"`
+	Currency       model.Currency       `json:"currency"`
+	JournalEntryID uint                 `json:"journal_entry_id"`
+	Refundable     model.Money          `json:"refundable"` // What is left of the original transaction to refund
+	Balances       map[uint]model.Money `json:"balances"`   // New balance of each wallet involved
 }
 
 type DepositRequest struct {
-	UserID uint    `json:"user_id"`
-	Amount float64 `json:"amount"`
+	UserID         uint           `json:"user_id"`
+	Amount         model.Money    `json:"amount"`
+	Currency       model.Currency `json:"currency,omitempty"`        // Optional, model.DefaultCurrency when empty
+	IdempotencyKey string         `json:"idempotency_key,omitempty"` // Optional, makes retries safe
 }
 type DepositResponse struct {
-	Success bool    `json:"success"`
-	Message string  `json:"message"`
-	Balance float64 `json:"balance"`
+	Success  bool           `json:"success"`
+	Message  string         `json:"message"`
+	Currency model.Currency `json:"currency"`
+	Balance  model.Money    `json:"balance"`
 }
 
 type WithdrawRequest struct {
-	UserID uint    `json:"user_id"`
-	Amount float64 `json:"amount"`
+	UserID         uint           `json:"user_id"`
+	Amount         model.Money    `json:"amount"`
+	Currency       model.Currency `json:"currency,omitempty"`        // Optional, model.DefaultCurrency when empty
+	IdempotencyKey string         `json:"idempotency_key,omitempty"` // Optional, makes retries safe
 }
 
 type WithdrawResponse struct {
-	Success bool    `json:"success"`
-	Message string  `json:"message"`
-	Balance float64 `json:"balance"`
+	Success  bool           `json:"success"`
+	Message  string         `json:"message"`
+	Currency model.Currency `json:"currency"`
+	Fee      model.Money    `json:"fee"` // Charged on top of the amount withdrawn
+	Balance  model.Money    `json:"balance"`
+}
+
+// FeeQuoteRequest asks what a withdrawal or transfer of Amount would cost without making it
+type FeeQuoteRequest struct {
+	Operation string         `json:"operation"` // Withdraw or Transfer, ignoring case
+	Amount    model.Money    `json:"amount"`
+	Currency  model.Currency `json:"currency,omitempty"` // Optional, model.DefaultCurrency when empty
+}
+
+type FeeQuoteResponse struct {
+	Operation string         `json:"operation"` // The transaction type charged, Withdraw or TransferSend
+	Amount    model.Money    `json:"amount"`
+	Currency  model.Currency `json:"currency"`
+	Fee       model.Money    `json:"fee"`
+	Total     model.Money    `json:"total"` // What leaves the wallet, the amount and the fee
 }
 
 type CheckBalanceRequest struct {
-	UserID uint `json:"user_id"`
+	UserID   uint           `json:"user_id"`
+	Currency model.Currency `json:"currency,omitempty"` // Optional, model.DefaultCurrency when empty
+}
+
+type CheckBalanceResponse struct {
+	UserID    uint           `json:"user_id"`
+	Currency  model.Currency `json:"currency"`
+	Balance   model.Money    `json:"balance"`   // Ledger balance, including money reserved by holds
+	Available model.Money    `json:"available"` // What can be spent: the balance less the holds, plus the credit line
+	// OverdraftLimit is how far below zero the balance may be spent, zero without a credit line
+	OverdraftLimit model.Money `json:"overdraft_limit,omitempty"`
+}
+
+// AuthorizeHoldRequest reserves Amount of the user's wallet for the merchant's wallet
+type AuthorizeHoldRequest struct {
+	UserID            uint           `json:"user_id"`
+	MerchantID        uint           `json:"merchant_id"`
+	Amount            model.Money    `json:"amount"`
+	Currency          model.Currency `json:"currency,omitempty"`           // Optional, model.DefaultCurrency when empty
+	Memo              string         `json:"memo,omitempty"`               // Optional, shown to both users on capture
+	ExternalReference string         `json:"external_reference,omitempty"` // Optional, e.g. the merchant's order number
+	ExpiresAt         time.Time      `json:"expires_at,omitzero"`          // Optional, config.DefaultHoldLifetime from now when unset
+	IdempotencyKey    string         `json:"idempotency_key,omitempty"`    // Optional, makes retries safe
+}
+
+type CaptureHoldRequest struct {
+	HoldID         uint         `json:"hold_id"`
+	Amount         *model.Money `json:"amount,omitempty"`          // Capture only this much, the whole hold when omitted
+	IdempotencyKey string       `json:"idempotency_key,omitempty"` // Optional, makes retries safe
+}
+
+// HoldRequest names a hold to read or void
+type HoldRequest struct {
+	HoldID uint `json:"hold_id"`
+}
+
+type HoldResponse struct {
+	ID                uint           `json:"id"`
+	UserID            uint           `json:"user_id"`
+	MerchantID        uint           `json:"merchant_id"`
+	Amount            model.Money    `json:"amount"`
+	Currency          model.Currency `json:"currency"`
+	CapturedAmount    model.Money    `json:"captured_amount"`
+	Status            string         `json:"status"` // Authorized, Captured, Voided or Expired
+	Memo              string         `json:"memo,omitempty"`
+	ExternalReference string         `json:"external_reference,omitempty"`
+	ExpiresAt         time.Time      `json:"expires_at"`
+	JournalEntryID    uint           `json:"journal_entry_id,omitempty"` // The capture's entry
 }
 
 type TransactionHistoryRequest struct {
-	UserID uint `json:"user_id"`
+	UserID    uint           `json:"user_id"`
+	Currency  model.Currency `json:"currency,omitempty"` // Every currency when empty
+	Types     []string       `json:"types,omitempty"`    // Transaction type names, e.g. "Deposit"
+	From      time.Time      `json:"from,omitzero"`      // Inclusive
+	To        time.Time      `json:"to,omitzero"`        // Exclusive
+	MinAmount *model.Money   `json:"min_amount,omitempty"`
+	MaxAmount *model.Money   `json:"max_amount,omitempty"`
+	Cursor    string         `json:"cursor,omitempty"` // NextCursor or PrevCursor of an earlier page
+	Limit     int            `json:"limit,omitempty"`  // Page size, DefaultPageSize when zero
 }
 
 type TransactionHistoryResponse struct {
-	Transactions []model.Transaction `json:"transactions"`
+	Transactions []model.Transaction `json:"transactions"`          // Newest first
+	NextCursor   string              `json:"next_cursor,omitempty"` // Older transactions, empty on the last page
+	PrevCursor   string              `json:"prev_cursor,omitempty"` // Newer transactions, empty on the first page
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// LimitError describes the limit a refused withdrawal or transfer would have exceeded
+type LimitError struct {
+	Operation string         `json:"operation"` // Withdraw or TransferSend
+	Period    string         `json:"period"`    // transaction, daily or monthly
+	Currency  model.Currency `json:"currency"`
+	Limit     model.Money    `json:"limit"`
+	Remaining model.Money    `json:"remaining"` // What may still be spent before the period ends
+}
+
+type ErrorResponse struct {
+	Code    string       `json:"code"`    // Stable, machine readable, e.g. "insufficient_funds"
+	Message string       `json:"message"` // Human readable detail
+	Fields  []FieldError `json:"fields,omitempty"`
+	Limit   *LimitError  `json:"limit,omitempty"` // limit_exceeded only
+}
+
+type AccountRequest struct {
+	UserID   uint           `json:"user_id"`
+	Currency model.Currency `json:"currency,omitempty"` // Opening only, model.DefaultCurrency when empty
+}
+
+// CurrencyBalance is the money a wallet holds in one currency
+type CurrencyBalance struct {
+	Currency       model.Currency `json:"currency"`
+	Balance        model.Money    `json:"balance"`
+	OverdraftLimit model.Money    `json:"overdraft_limit,omitempty"` // Zero without a credit line
+}
+
+type AccountResponse struct {
+	UserID   uint              `json:"user_id"`
+	Status   string            `json:"status"`   // Active, Frozen or Closed, shared by every currency
+	Tier     string            `json:"tier"`     // Standard, Verified or Premium, shared by every currency
+	Product  string            `json:"product"`  // Checking, Savings or Merchant, shared by every currency
+	Balances []CurrencyBalance `json:"balances"` // Ordered by currency
+}
+
+// SetTierRequest moves a wallet to another tier, which decides its transaction limits
+type SetTierRequest struct {
+	UserID uint   `json:"user_id"`
+	Tier   string `json:"tier"` // Standard, Verified or Premium, ignoring case
+}
+
+// SetProductRequest moves a wallet to another product, which decides the interest it earns
+type SetProductRequest struct {
+	UserID  uint   `json:"user_id"`
+	Product string `json:"product"` // Checking, Savings or Merchant, ignoring case
+}
+
+// SetOverdraftLimitRequest gives the wallet's balance in Currency a credit line of Limit, or takes
+// it away with a zero limit
+type SetOverdraftLimitRequest struct {
+	UserID   uint           `json:"user_id"`
+	Currency model.Currency `json:"currency,omitempty"` // Optional, model.DefaultCurrency when empty
+	Limit    model.Money    `json:"limit"`
+}
+
+// OverdrawnBalance is a user balance below zero
+type OverdrawnBalance struct {
+	UserID         uint           `json:"user_id"`
+	Currency       model.Currency `json:"currency"`
+	Balance        model.Money    `json:"balance"`
+	OverdraftLimit model.Money    `json:"overdraft_limit"`
+	Available      model.Money    `json:"available"` // Negative once the balance is beyond the limit
+}
+
+// OverdraftReportResponse lists every user balance currently below zero
+type OverdraftReportResponse struct {
+	Balances []OverdrawnBalance             `json:"balances"` // Ordered by user and currency
+	Totals   map[model.Currency]model.Money `json:"totals"`   // What the balances owe in each currency
+}
+
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type RegisterResponse struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type LoginResponse struct {
+	Token     string    `json:"token"` // Sent back as "Authorization: Bearer <token>"
+	ExpiresAt time.Time `json:"expires_at"`
+	UserID    uint      `json:"user_id"`
+	Role      string    `json:"role"` // Customer or Admin
+}
+
+type CreateAdjustmentRequest struct {
+	UserID   uint           `json:"user_id"`
+	Amount   model.Money    `json:"amount"`             // Signed, negative to take money out of the wallet
+	Currency model.Currency `json:"currency,omitempty"` // Optional, model.DefaultCurrency when empty
+	Reason   string         `json:"reason"`
+}
+
+type ReviewAdjustmentRequest struct {
+	AdjustmentID uint `json:"adjustment_id"`
+}
+
+type AdjustmentResponse struct {
+	ID             uint           `json:"id"`
+	UserID         uint           `json:"user_id"`
+	Amount         model.Money    `json:"amount"`
+	Currency       model.Currency `json:"currency"`
+	Reason         string         `json:"reason"`
+	Status         string         `json:"status"` // Pending, Approved or Rejected
+	CreatedBy      uint           `json:"created_by"`
+	ReviewedBy     uint           `json:"reviewed_by,omitempty"`
+	JournalEntryID uint           `json:"journal_entry_id,omitempty"`
+}
+
+type AdjustmentListResponse struct {
+	Adjustments []AdjustmentResponse `json:"adjustments"`
+}
+
+// CreateScheduleRequest sets up a standing order transferring Amount from one wallet to another
+type CreateScheduleRequest struct {
+	FromUserID        uint           `json:"from_user_id"`
+	ToUserID          uint           `json:"to_user_id"`
+	Amount            model.Money    `json:"amount"`
+	Currency          model.Currency `json:"currency,omitempty"`           // Optional, model.DefaultCurrency when empty
+	Memo              string         `json:"memo,omitempty"`               // Optional, copied to every transfer
+	ExternalReference string         `json:"external_reference,omitempty"` // Optional, copied to every transfer
+	Recurrence        string         `json:"recurrence,omitempty"`         // Once, Daily, Weekly or Monthly; Once when empty
+	StartAt           time.Time      `json:"start_at,omitzero"`            // The first transfer, now when unset
+	EndAt             time.Time      `json:"end_at,omitzero"`              // Optional, no transfer is made after it
+	FailurePolicy     string         `json:"failure_policy,omitempty"`     // Skip or Stop once retries fail; Skip when empty
+}
+
+// ScheduleRequest names a schedule to cancel
+type ScheduleRequest struct {
+	ScheduleID uint `json:"schedule_id"`
+}
+
+type ListSchedulesRequest struct {
+	UserID uint `json:"user_id"` // The paying wallet
+}
+
+type ScheduleResponse struct {
+	ID                uint           `json:"id"`
+	FromUserID        uint           `json:"from_user_id"`
+	ToUserID          uint           `json:"to_user_id"`
+	Amount            model.Money    `json:"amount"`
+	Currency          model.Currency `json:"currency"`
+	Memo              string         `json:"memo,omitempty"`
+	ExternalReference string         `json:"external_reference,omitempty"`
+	Recurrence        string         `json:"recurrence"`
+	StartAt           time.Time      `json:"start_at"`
+	EndAt             time.Time      `json:"end_at,omitzero"`
+	FailurePolicy     string         `json:"failure_policy"`
+	Status            string         `json:"status"`      // Active, Completed, Cancelled or Failed
+	NextRunAt         time.Time      `json:"next_run_at"` // Meaningful while active
+	Attempts          uint           `json:"attempts,omitempty"`
+	LastTransferID    string         `json:"last_transfer_id,omitempty"`
+	LastError         string         `json:"last_error,omitempty"`
+}
+
+type ScheduleListResponse struct {
+	Schedules []ScheduleResponse `json:"schedules"`
 }
@@ -0,0 +1,111 @@
+// Package exchange provides the rates that conversions between currencies are priced at. A
+// RateProvider gives mid-market rates only; the handlers add the wallet's spread on top.
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+)
+
+// RateProvider gives the current mid-market price of one currency in another
+type RateProvider interface {
+	// Rate returns how much of to one unit of from is worth. An error matching
+	// apperror.ErrRateUnavailable is returned when there is no rate for the pair.
+	Rate(ctx context.Context, from, to model.Currency) (model.Rate, error)
+}
+
+// StaticRates prices every currency in one base currency and derives the rate between any two
+// currencies from their prices in the base. It never changes, which makes it suitable for tests.
+type StaticRates struct {
+	Base  model.Currency                `json:"base"`
+	Rates map[model.Currency]model.Rate `json:"rates"` // Price of one unit of each currency in Base
+}
+
+// Rate returns the price of from in to
+func (s *StaticRates) Rate(_ context.Context, from, to model.Currency) (model.Rate, error) {
+	fromPrice, err := s.price(from)
+	if err != nil {
+		return 0, err
+	}
+	toPrice, err := s.price(to)
+	if err != nil {
+		return 0, err
+	}
+	rate := fromPrice.Div(toPrice)
+	if rate <= 0 {
+		return 0, fmt.Errorf("%w: %s is worth less than %s of %s", apperror.ErrRateUnavailable, from, model.Rate(1), to)
+	}
+	return rate, nil
+}
+
+// price returns the price of currency in the base currency
+func (s *StaticRates) price(currency model.Currency) (model.Rate, error) {
+	if currency == s.Base {
+		return model.Parity, nil
+	}
+	price, ok := s.Rates[currency]
+	if !ok || price <= 0 {
+		return 0, fmt.Errorf("%w: no %s rate for %s", apperror.ErrRateUnavailable, s.Base, currency)
+	}
+	return price, nil
+}
+
+// FileRates reads StaticRates from a JSON file such as
+//
+//	{"base": "USD", "rates": {"EUR": "1.085", "JPY": "0.00667"}}
+//
+// and reads it again whenever it is modified, so rates can be updated without a restart.
+type FileRates struct {
+	Path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	rates   *StaticRates
+}
+
+// NewFileRates creates a FileRates reading path. The file is not read until a rate is needed.
+func NewFileRates(path string) *FileRates {
+	return &FileRates{Path: path}
+}
+
+// Rate returns the price of from in to according to the current contents of the file
+func (f *FileRates) Rate(ctx context.Context, from, to model.Currency) (model.Rate, error) {
+	rates, err := f.load()
+	if err != nil {
+		return 0, err
+	}
+	return rates.Rate(ctx, from, to)
+}
+
+// load returns the rates in the file, reading it only when it changed since the last read
+func (f *FileRates) load() (*StaticRates, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", apperror.ErrRateUnavailable, err)
+	}
+	if f.rates != nil && info.ModTime().Equal(f.modTime) {
+		return f.rates, nil
+	}
+
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", apperror.ErrRateUnavailable, err)
+	}
+	var rates StaticRates
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("%w: rates file %s: %w", apperror.ErrRateUnavailable, f.Path, err)
+	}
+	if !rates.Base.IsSupported() {
+		return nil, fmt.Errorf("%w: rates file %s has unsupported base currency %q", apperror.ErrRateUnavailable, f.Path, rates.Base)
+	}
+	f.rates, f.modTime = &rates, info.ModTime()
+	return f.rates, nil
+}
@@ -0,0 +1,71 @@
+package exchange
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticRates(t *testing.T) {
+	rates := &StaticRates{Base: model.USD, Rates: map[model.Currency]model.Rate{
+		model.EUR: 108500000,
+		model.JPY: 667000,
+	}}
+
+	tests := []struct {
+		name          string
+		from, to      model.Currency
+		expectedRate  string
+		expectedError error
+	}{
+		{name: "Into the base", from: model.EUR, to: model.USD, expectedRate: "1.085"},
+		{name: "Out of the base", from: model.USD, to: model.EUR, expectedRate: "0.92165898"},
+		{name: "Cross rate", from: model.EUR, to: model.JPY, expectedRate: "162.66866566"},
+		{name: "Unknown currency", from: model.GBP, to: model.USD, expectedError: apperror.ErrRateUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := rates.Rate(context.Background(), tt.from, tt.to)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedRate, rate.String())
+		})
+	}
+}
+
+func TestFileRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	rates := NewFileRates(path)
+	ctx := context.Background()
+
+	_, err := rates.Rate(ctx, model.EUR, model.USD)
+	assert.ErrorIs(t, err, apperror.ErrRateUnavailable, "a missing file has no rates")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"base": "USD", "rates": {"EUR": "1.085"}}`), 0o600))
+	rate, err := rates.Rate(ctx, model.EUR, model.USD)
+	require.NoError(t, err)
+	assert.Equal(t, "1.085", rate.String())
+
+	// A rewritten file is read again
+	require.NoError(t, os.WriteFile(path, []byte(`{"base": "USD", "rates": {"EUR": "1.1"}}`), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	rate, err = rates.Rate(ctx, model.EUR, model.USD)
+	require.NoError(t, err)
+	assert.Equal(t, "1.1", rate.String())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"base": "XYZ", "rates": {}}`), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+	_, err = rates.Rate(ctx, model.EUR, model.USD)
+	assert.ErrorIs(t, err, apperror.ErrRateUnavailable)
+}
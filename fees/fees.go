@@ -0,0 +1,135 @@
+// Package fees prices withdrawals, transfers and captured holds. The balance handler charges the
+// fee of a Schedule on top of the amount moved and pays it to the house account in the same
+// journal entry as the operation.
+package fees
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"walletApp/model"
+)
+
+// Band prices the amounts of a tiered fee up to UpTo
+type Band struct {
+	UpTo        model.Money `json:"up_to"` // Inclusive, zero for no upper bound
+	Flat        model.Money `json:"flat"`
+	BasisPoints int64       `json:"basis_points"` // Share of the amount, in hundredths of a percent
+}
+
+// Fee prices one operation in one currency: a flat charge plus a share of the amount, or, for
+// a tiered fee, those of the first band the amount fits in, kept between Min and Max. A fee
+// with no field set is free.
+type Fee struct {
+	Flat        model.Money `json:"flat"`
+	BasisPoints int64       `json:"basis_points"`    // Share of the amount, in hundredths of a percent
+	Bands       []Band      `json:"bands,omitempty"` // Ordered by UpTo, replacing Flat and BasisPoints
+	Min         model.Money `json:"min"`
+	Max         model.Money `json:"max"` // Zero for no cap
+}
+
+// Of returns the fee for moving amount of currency, rounded up to the decimal places of
+// currency. An amount beyond the last band is free, which a last band without UpTo avoids.
+func (f Fee) Of(amount model.Money, currency model.Currency) model.Money {
+	flat, share := f.Flat, f.BasisPoints
+	if len(f.Bands) > 0 {
+		flat, share = 0, 0
+		for _, band := range f.Bands {
+			if band.UpTo == 0 || amount <= band.UpTo {
+				flat, share = band.Flat, band.BasisPoints
+				break
+			}
+		}
+	}
+	fee := currency.RoundUp(flat + amount.Share(share))
+	if fee < f.Min {
+		fee = f.Min
+	}
+	if f.Max != 0 && fee > f.Max {
+		fee = f.Max
+	}
+	return fee
+}
+
+// validate reports fees that would charge a negative amount, or amounts currency cannot hold
+func (f Fee) validate(currency model.Currency) error {
+	amounts := []model.Money{f.Flat, f.Min, f.Max}
+	shares := []int64{f.BasisPoints}
+	for i, band := range f.Bands {
+		if i > 0 && (f.Bands[i-1].UpTo == 0 || band.UpTo != 0 && band.UpTo <= f.Bands[i-1].UpTo) {
+			return errors.New("bands must be ordered by up_to, with only the last one unbounded")
+		}
+		amounts = append(amounts, band.UpTo, band.Flat)
+		shares = append(shares, band.BasisPoints)
+	}
+	for _, amount := range amounts {
+		if amount.IsNegative() || !currency.Allows(amount) {
+			return fmt.Errorf("amounts must not be negative and have at most %d decimal places", currency.Decimals())
+		}
+	}
+	if slices.ContainsFunc(shares, func(share int64) bool { return share < 0 || share > model.BasisPoints }) {
+		return fmt.Errorf("basis_points must be between 0 and %d", model.BasisPoints)
+	}
+	if f.Max != 0 && f.Max < f.Min {
+		return errors.New("max must not be less than min")
+	}
+	return nil
+}
+
+// operations names the operations that are charged fees by the transaction type of the money
+// leaving the wallet
+var operations = map[string]model.TransactionType{
+	"withdraw": model.TransactionTypeWithdraw,
+	"transfer": model.TransactionTypeTransferSend,
+}
+
+// ParseOperation returns the transaction type charged for the operation named name, ignoring
+// case: TransactionTypeWithdraw for "Withdraw" and TransactionTypeTransferSend for "Transfer"
+func ParseOperation(name string) (model.TransactionType, bool) {
+	operation, ok := operations[strings.ToLower(name)]
+	return operation, ok
+}
+
+// Schedule holds the fee of every operation, the transaction type of the money leaving the
+// wallet, in each currency. Operations and currencies without a fee are free, so an empty
+// schedule charges nothing.
+type Schedule map[model.TransactionType]map[model.Currency]Fee
+
+// Fee returns the fee for moving amount of currency out with operation
+func (s Schedule) Fee(operation model.TransactionType, currency model.Currency, amount model.Money) model.Money {
+	fee, ok := s[operation][currency]
+	if !ok {
+		return 0
+	}
+	return fee.Of(amount, currency)
+}
+
+// UnmarshalJSON decodes a schedule keyed by names, such as
+//
+//	{"TransferSend": {"USD": {"basis_points": 50, "min": "0.50", "max": "10.00"}}}
+func (s *Schedule) UnmarshalJSON(data []byte) error {
+	var named map[string]map[model.Currency]Fee
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	schedule := Schedule{}
+	for operationName, currencies := range named {
+		operation, ok := model.ParseTransactionType(operationName)
+		if !ok {
+			return fmt.Errorf("unknown transaction type %q", operationName)
+		}
+		for currency, fee := range currencies {
+			if !currency.IsSupported() {
+				return fmt.Errorf("%w: %q", model.ErrUnsupportedCurrency, currency)
+			}
+			if err := fee.validate(currency); err != nil {
+				return fmt.Errorf("%s %s fee: %w", currency, operation, err)
+			}
+		}
+		schedule[operation] = currencies
+	}
+	*s = schedule
+	return nil
+}
@@ -0,0 +1,78 @@
+package fees
+
+import (
+	"encoding/json"
+	"testing"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeOf(t *testing.T) {
+	tiered := Fee{Bands: []Band{
+		{UpTo: 10000, Flat: 100},
+		{UpTo: 100000, Flat: 50, BasisPoints: 100},
+		{BasisPoints: 50},
+	}, Max: 2500}
+	tests := []struct {
+		name     string
+		fee      Fee
+		amount   model.Money
+		currency model.Currency
+		expected model.Money
+	}{
+		{name: "Free", fee: Fee{}, amount: 10000, currency: model.USD, expected: 0},
+		{name: "Flat", fee: Fee{Flat: 150}, amount: 10000, currency: model.USD, expected: 150},
+		{name: "Percentage rounds up", fee: Fee{BasisPoints: 25}, amount: 10001, currency: model.USD, expected: 26},
+		{name: "Flat and percentage", fee: Fee{Flat: 30, BasisPoints: 290}, amount: 10000, currency: model.USD, expected: 320},
+		{name: "Below the minimum", fee: Fee{BasisPoints: 25, Min: 50}, amount: 1000, currency: model.USD, expected: 50},
+		{name: "Above the maximum", fee: Fee{BasisPoints: 25, Max: 1000}, amount: 10000000, currency: model.USD, expected: 1000},
+		{name: "First band", fee: tiered, amount: 10000, currency: model.USD, expected: 100},
+		{name: "Second band", fee: tiered, amount: 10001, currency: model.USD, expected: 151},
+		{name: "Unbounded band", fee: tiered, amount: 400000, currency: model.USD, expected: 2000},
+		{name: "Unbounded band capped", fee: tiered, amount: 1000000, currency: model.USD, expected: 2500},
+		{name: "Beyond the last band", fee: Fee{Bands: []Band{{UpTo: 10000, Flat: 100}}}, amount: 10001, currency: model.USD, expected: 0},
+		{name: "Whole yen", fee: Fee{BasisPoints: 25}, amount: 100000, currency: model.JPY, expected: 300},
+		{name: "Large amounts do not overflow", fee: Fee{BasisPoints: 10000}, amount: 1 << 60, currency: model.USD, expected: 1 << 60},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.fee.Of(tt.amount, tt.currency))
+		})
+	}
+}
+
+func TestScheduleFee(t *testing.T) {
+	schedule := Schedule{model.TransactionTypeWithdraw: {model.USD: {Flat: 100}}}
+	assert.Equal(t, model.Money(100), schedule.Fee(model.TransactionTypeWithdraw, model.USD, 5000))
+	assert.Zero(t, schedule.Fee(model.TransactionTypeWithdraw, model.EUR, 5000))
+	assert.Zero(t, schedule.Fee(model.TransactionTypeTransferSend, model.USD, 5000))
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var schedule Schedule
+	require.NoError(t, json.Unmarshal([]byte(`{"withdraw": {"EUR": {"bands": [{"up_to": "100.00", "flat": "1.00"}, {"basis_points": 50}], "max": "20.00"}}}`), &schedule))
+	assert.Equal(t, Schedule{model.TransactionTypeWithdraw: {model.EUR: {
+		Bands: []Band{{UpTo: 10000, Flat: 100}, {BasisPoints: 50}},
+		Max:   2000,
+	}}}, schedule)
+
+	invalid := map[string]string{
+		"Unknown operation":    `{"Loan": {"USD": {"flat": "1.00"}}}`,
+		"Unsupported currency": `{"Withdraw": {"XYZ": {"flat": "1.00"}}}`,
+		"Negative flat fee":    `{"Withdraw": {"USD": {"flat": "-1.00"}}}`,
+		"Fractional yen":       `{"Withdraw": {"JPY": {"flat": "1.50"}}}`,
+		"Over a whole":         `{"Withdraw": {"USD": {"basis_points": 10001}}}`,
+		"Max below min":        `{"Withdraw": {"USD": {"min": "2.00", "max": "1.00"}}}`,
+		"Unordered bands":      `{"Withdraw": {"USD": {"bands": [{"up_to": "100.00"}, {"up_to": "50.00"}]}}}`,
+		"Unbounded band first": `{"Withdraw": {"USD": {"bands": [{"flat": "1.00"}, {"up_to": "50.00"}]}}}`,
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var schedule Schedule
+			assert.Error(t, json.Unmarshal([]byte(content), &schedule))
+		})
+	}
+}
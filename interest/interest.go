@@ -0,0 +1,69 @@
+// Package interest prices the interest wallets earn by account product. Savings wallets accrue
+// interest daily on their end-of-day balance at the annual rate of their product and currency,
+// and are paid the accrued interest once a month.
+package interest
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"walletApp/model"
+)
+
+// DaysPerYear is the day count a year's interest is spread over, whether or not it is a leap year
+const DaysPerYear = 365
+
+// Rates holds the annual interest rate, in basis points, of every account product in each
+// currency. Products and currencies without a rate earn nothing, so empty rates pay no interest.
+type Rates map[model.AccountProduct]map[model.Currency]int64
+
+// Rate returns the annual rate in basis points earned by wallets of product in currency
+func (r Rates) Rate(product model.AccountProduct, currency model.Currency) int64 {
+	return r[product][currency]
+}
+
+// UnmarshalJSON decodes rates keyed by names, such as
+//
+//	{"Savings": {"USD": 200, "EUR": 150}}
+func (r *Rates) UnmarshalJSON(data []byte) error {
+	var named map[string]map[model.Currency]int64
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	rates := Rates{}
+	for productName, currencies := range named {
+		product, ok := model.ParseAccountProduct(productName)
+		if !ok {
+			return fmt.Errorf("unknown account product %q", productName)
+		}
+		for currency, rate := range currencies {
+			if !currency.IsSupported() {
+				return fmt.Errorf("%w: %q", model.ErrUnsupportedCurrency, currency)
+			}
+			if rate < 0 || rate > model.BasisPoints {
+				return fmt.Errorf("%s %s rate must be between 0 and %d basis points", currency, product, model.BasisPoints)
+			}
+		}
+		rates[product] = currencies
+	}
+	*r = rates
+	return nil
+}
+
+// Daily returns the interest a day earns on balance at the annual rate in basis points, in
+// 1/model.AccrualScale of a minor unit and rounded down. Balances that are not positive earn
+// nothing. The balance is split so that balances up to thirty trillion units cannot overflow.
+func Daily(balance model.Money, rate int64) int64 {
+	if balance <= 0 {
+		return 0
+	}
+	const scale = model.AccrualScale / model.BasisPoints
+	whole, rest := int64(balance)/DaysPerYear, int64(balance)%DaysPerYear
+	return whole*rate*scale + rest*rate*scale/DaysPerYear
+}
+
+// DayStart returns midnight UTC starting the day of t, which is how accrual days are identified
+func DayStart(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
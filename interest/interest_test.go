@@ -0,0 +1,63 @@
+package interest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaily(t *testing.T) {
+	tests := []struct {
+		name     string
+		balance  model.Money
+		rate     int64
+		expected int64
+	}{
+		{name: "A thousand at 2%", balance: 100000, rate: 200, expected: 5479452},
+		{name: "A year of days adds up to the rate", balance: 36500, rate: 10000, expected: 100 * model.AccrualScale},
+		{name: "A cent still earns", balance: 1, rate: 200, expected: 54},
+		{name: "No rate", balance: 100000, rate: 0, expected: 0},
+		{name: "Empty balance", balance: 0, rate: 200, expected: 0},
+		{name: "Negative balance", balance: -100000, rate: 200, expected: 0},
+		{name: "Large balances do not overflow", balance: 3_000_000_000_000_000, rate: 10000, expected: 8219178082191780821},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Daily(tt.balance, tt.rate))
+		})
+	}
+}
+
+func TestDayStart(t *testing.T) {
+	local := time.FixedZone("UTC+2", 2*60*60)
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), DayStart(time.Date(2024, 2, 29, 23, 59, 59, 0, time.UTC)))
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), DayStart(time.Date(2024, 3, 1, 1, 0, 0, 0, local)))
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var rates Rates
+	require.NoError(t, json.Unmarshal([]byte(`{"savings": {"EUR": 150}, "Checking": {"USD": 10}}`), &rates))
+	assert.Equal(t, Rates{
+		model.AccountProductSavings:  {model.EUR: 150},
+		model.AccountProductChecking: {model.USD: 10},
+	}, rates)
+
+	invalid := map[string]string{
+		"Unknown product":      `{"Loan": {"USD": 200}}`,
+		"Unsupported currency": `{"Savings": {"XYZ": 200}}`,
+		"Negative rate":        `{"Savings": {"USD": -1}}`,
+		"Over a whole":         `{"Savings": {"USD": 10001}}`,
+		"Fractional rate":      `{"Savings": {"USD": 1.5}}`,
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var rates Rates
+			assert.Error(t, json.Unmarshal([]byte(content), &rates))
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Package limits caps how much money a wallet may move out with each kind of operation,
+// depending on the wallet's tier. The ledger checks every posting that takes money out of a
+// user wallet against a Policy before applying it.
+package limits
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+)
+
+// The periods a Limit caps, as named in the errors it causes
+const (
+	PeriodTransaction = "transaction"
+	PeriodDaily       = "daily"
+	PeriodMonthly     = "monthly"
+)
+
+// Limit caps the money a wallet may move out with one operation in one currency. A zero cap
+// does not apply.
+type Limit struct {
+	PerTransaction model.Money `json:"per_transaction"`
+	Daily          model.Money `json:"daily"`   // Over the last 24 hours
+	Monthly        model.Money `json:"monthly"` // Since the start of the calendar month in UTC
+}
+
+// Usage is what a wallet already moved out with one operation in each period of a limit
+type Usage struct {
+	Daily   model.Money
+	Monthly model.Money
+}
+
+// Check returns a *apperror.LimitExceededError for the first cap of l, from the shortest period
+// to the longest, that moving amount out on top of usage would break
+func (l Limit) Check(userID uint, operation model.TransactionType, currency model.Currency, amount model.Money, usage Usage) error {
+	for _, period := range []struct {
+		name string
+		cap  model.Money
+		used model.Money
+	}{
+		{PeriodTransaction, l.PerTransaction, 0},
+		{PeriodDaily, l.Daily, usage.Daily},
+		{PeriodMonthly, l.Monthly, usage.Monthly},
+	} {
+		if period.cap == 0 || period.used.Add(amount) <= period.cap {
+			continue
+		}
+		return &apperror.LimitExceededError{
+			UserID:    userID,
+			Operation: operation.String(),
+			Period:    period.name,
+			Currency:  string(currency),
+			Limit:     int64(period.cap),
+			Remaining: int64(max(period.cap.Sub(period.used), 0)),
+		}
+	}
+	return nil
+}
+
+// Operation returns the operation whose limit caps money leaving a wallet with a posting of type
+// t. Captured holds pay a merchant, so they are limited as transfers.
+func Operation(t model.TransactionType) model.TransactionType {
+	if t == model.TransactionTypeCapture {
+		return model.TransactionTypeTransferSend
+	}
+	return t
+}
+
+// Types returns the transaction types whose outflows count towards the limit of operation
+func Types(operation model.TransactionType) []model.TransactionType {
+	if operation == model.TransactionTypeTransferSend {
+		return []model.TransactionType{model.TransactionTypeTransferSend, model.TransactionTypeCapture}
+	}
+	return []model.TransactionType{operation}
+}
+
+// WindowStart returns when the rolling day ending at now began, which a daily cap looks back to
+func WindowStart(now time.Time) time.Time {
+	return now.Add(-24 * time.Hour)
+}
+
+// MonthStart returns when the calendar month of now began in UTC
+func MonthStart(now time.Time) time.Time {
+	year, month, _ := now.UTC().Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Policy holds the limits of every account tier by operation, the transaction type of the money
+// leaving the wallet as mapped by Operation, and currency. Operations and currencies without a
+// limit are not limited, so an empty policy limits nothing.
+type Policy map[model.AccountTier]map[model.TransactionType]map[model.Currency]Limit
+
+// Limit returns the limit of operation in currency for wallets of tier, and false if there is none
+func (p Policy) Limit(tier model.AccountTier, operation model.TransactionType, currency model.Currency) (Limit, bool) {
+	limit, ok := p[tier][operation][currency]
+	return limit, ok
+}
+
+// UnmarshalJSON decodes a policy keyed by names, such as
+//
+//	{"Standard": {"Withdraw": {"USD": {"per_transaction": "1000.00", "daily": "2000.00", "monthly": "10000.00"}}}}
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	var named map[string]map[string]map[model.Currency]Limit
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+	policy := Policy{}
+	for tierName, operations := range named {
+		tier, ok := model.ParseAccountTier(tierName)
+		if !ok {
+			return fmt.Errorf("unknown account tier %q", tierName)
+		}
+		policy[tier] = map[model.TransactionType]map[model.Currency]Limit{}
+		for operationName, currencies := range operations {
+			operation, ok := model.ParseTransactionType(operationName)
+			if !ok {
+				return fmt.Errorf("unknown transaction type %q", operationName)
+			}
+			if Operation(operation) != operation {
+				return fmt.Errorf("%s is limited as %s", operation, Operation(operation))
+			}
+			for currency, limit := range currencies {
+				if !currency.IsSupported() {
+					return fmt.Errorf("%w: %q", model.ErrUnsupportedCurrency, currency)
+				}
+				if limit.PerTransaction.IsNegative() || limit.Daily.IsNegative() || limit.Monthly.IsNegative() {
+					return fmt.Errorf("negative %s %s limit for %s wallets", currency, operation, tier)
+				}
+			}
+			policy[tier][operation] = currencies
+		}
+	}
+	*p = policy
+	return nil
+}
@@ -0,0 +1,94 @@
+package limits
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitCheck(t *testing.T) {
+	limit := Limit{PerTransaction: 50000, Daily: 100000, Monthly: 300000}
+	tests := []struct {
+		name              string
+		amount            model.Money
+		usage             Usage
+		expectedPeriod    string
+		expectedRemaining int64
+	}{
+		{name: "Within every cap", amount: 50000, usage: Usage{Daily: 50000, Monthly: 250000}},
+		{name: "Over the per-transaction cap", amount: 50001, expectedPeriod: PeriodTransaction, expectedRemaining: 50000},
+		{name: "Over the daily cap", amount: 20000, usage: Usage{Daily: 90000, Monthly: 90000}, expectedPeriod: PeriodDaily, expectedRemaining: 10000},
+		{name: "Over the monthly cap", amount: 20000, usage: Usage{Daily: 0, Monthly: 290000}, expectedPeriod: PeriodMonthly, expectedRemaining: 10000},
+		{name: "Daily cap used up", amount: 100, usage: Usage{Daily: 120000, Monthly: 120000}, expectedPeriod: PeriodDaily, expectedRemaining: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := limit.Check(1, model.TransactionTypeWithdraw, model.EUR, tt.amount, tt.usage)
+			if tt.expectedPeriod == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, apperror.ErrLimitExceeded)
+			var exceeded *apperror.LimitExceededError
+			require.ErrorAs(t, err, &exceeded)
+			assert.Equal(t, &apperror.LimitExceededError{
+				UserID:    1,
+				Operation: "Withdraw",
+				Period:    tt.expectedPeriod,
+				Currency:  "EUR",
+				Limit:     map[string]int64{PeriodTransaction: 50000, PeriodDaily: 100000, PeriodMonthly: 300000}[tt.expectedPeriod],
+				Remaining: tt.expectedRemaining,
+			}, exceeded)
+		})
+	}
+}
+
+func TestZeroCapsDoNotApply(t *testing.T) {
+	limit := Limit{Monthly: 1000}
+	assert.NoError(t, limit.Check(1, model.TransactionTypeWithdraw, model.USD, 1000, Usage{Daily: 1_000_000}))
+}
+
+func TestPeriodStarts(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 30, 0, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 30, 0, 0, now.Location()), WindowStart(now))
+	// 00:30 in CET is still February in UTC
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), MonthStart(now))
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name          string
+		contents      string
+		expected      Policy
+		expectedError string
+	}{
+		{
+			name:     "Named tiers and operations",
+			contents: `{"premium": {"TransferSend": {"EUR": {"daily": "500.00"}}}}`,
+			expected: Policy{model.AccountTierPremium: {model.TransactionTypeTransferSend: {model.EUR: {Daily: 50000}}}},
+		},
+		{name: "Unknown tier", contents: `{"Gold": {}}`, expectedError: `unknown account tier "Gold"`},
+		{name: "Unknown operation", contents: `{"Standard": {"Send": {}}}`, expectedError: `unknown transaction type "Send"`},
+		{name: "Captures", contents: `{"Standard": {"Capture": {}}}`, expectedError: "Capture is limited as TransferSend"},
+		{name: "Unsupported currency", contents: `{"Standard": {"Withdraw": {"XYZ": {}}}}`, expectedError: "unsupported currency"},
+		{name: "Negative cap", contents: `{"Standard": {"Withdraw": {"USD": {"monthly": "-1"}}}}`, expectedError: "negative USD Withdraw limit for Standard wallets"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var policy Policy
+			err := json.Unmarshal([]byte(tt.contents), &policy)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, policy)
+		})
+	}
+}
@@ -1,10 +1,48 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"walletApp/config"
 	"walletApp/server"
 )
 
 func main() {
+	mode := flag.String("mode", "cli", "how to serve the wallet: \"cli\" for the interactive menu, \"http\" for the REST API or \"grpc\" for the gRPC API")
+	addr := flag.String("addr", ":8080", "address the REST API listens on in http mode")
+	grpcAddr := flag.String("grpc-addr", ":9090", "address the gRPC API listens on in grpc mode")
+	flag.Parse()
+
 	app := server.NewApp()
-	app.Start()
+	switch *mode {
+	case "cli":
+		go app.ExpireHolds(context.Background(), config.HoldExpiryInterval)
+		go app.RunSchedules(context.Background(), config.ScheduleInterval)
+		go app.RunInterest(context.Background(), config.InterestInterval)
+		app.Start()
+	case "http":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go app.ExpireHolds(ctx, config.HoldExpiryInterval)
+		go app.RunSchedules(ctx, config.ScheduleInterval)
+		go app.RunInterest(ctx, config.InterestInterval)
+		if err := app.ListenAndServe(ctx, *addr); err != nil {
+			log.Fatal("HTTP server stopped:", err)
+		}
+	case "grpc":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go app.ExpireHolds(ctx, config.HoldExpiryInterval)
+		go app.RunSchedules(ctx, config.ScheduleInterval)
+		go app.RunInterest(ctx, config.InterestInterval)
+		if err := app.ServeGRPC(ctx, *grpcAddr); err != nil {
+			log.Fatal("gRPC server stopped:", err)
+		}
+	default:
+		log.Fatalf("Unknown mode %q, expected \"cli\", \"http\" or \"grpc\"", *mode)
+	}
 }
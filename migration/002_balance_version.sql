@@ -0,0 +1,2 @@
+-- Add a version column to support optimistic locking on balance updates
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0;
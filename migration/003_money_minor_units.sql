@@ -0,0 +1,4 @@
+-- Store money as integer minor units (cents) instead of FLOAT to keep arithmetic exact
+ALTER TABLE balances ALTER COLUMN balance TYPE BIGINT USING ROUND(balance * 100)::BIGINT;
+ALTER TABLE balances ALTER COLUMN balance SET DEFAULT 0;
+ALTER TABLE transactions ALTER COLUMN amount TYPE BIGINT USING ROUND(amount * 100)::BIGINT;
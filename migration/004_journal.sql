@@ -0,0 +1,34 @@
+-- Double-entry journal: every balance change is a journal entry whose postings sum to zero
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id SERIAL PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS postings (
+    id SERIAL PRIMARY KEY,
+    journal_entry_id INT NOT NULL REFERENCES journal_entries(id),
+    user_id INT NOT NULL,
+    type INT NOT NULL,
+    amount BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_postings_journal_entry_id ON postings(journal_entry_id);
+CREATE INDEX IF NOT EXISTS idx_postings_user_id ON postings(user_id);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS journal_entry_id INT;
+CREATE INDEX IF NOT EXISTS idx_transactions_journal_entry_id ON transactions(journal_entry_id);
+
+-- System accounts, see model.SystemAccountCashIn and model.SystemAccountCashOut
+INSERT INTO balances (user_id, balance) VALUES (1000000001, 0), (1000000002, 0) ON CONFLICT (user_id) DO NOTHING;
+
+-- Post the existing balances as one opening entry funded from the cash-in account, so that
+-- every balance equals the sum of its postings
+WITH opening AS (
+    INSERT INTO journal_entries (description) VALUES ('opening balances') RETURNING id
+)
+INSERT INTO postings (journal_entry_id, user_id, type, amount)
+SELECT opening.id, b.user_id, 0, b.balance FROM opening, balances b WHERE b.user_id < 1000000000 AND b.balance <> 0
+UNION ALL
+SELECT opening.id, 1000000001, 0, -COALESCE(SUM(b.balance), 0) FROM opening, balances b WHERE b.user_id < 1000000000 GROUP BY opening.id;
+
+UPDATE balances SET balance = -(SELECT COALESCE(SUM(balance), 0) FROM balances WHERE user_id < 1000000000) WHERE user_id = 1000000001;
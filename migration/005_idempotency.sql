@@ -0,0 +1,9 @@
+-- Responses of requests made with an idempotency key, replayed when the key is retried
+CREATE TABLE IF NOT EXISTS idempotency_records (
+    id SERIAL PRIMARY KEY,
+    key VARCHAR(255) UNIQUE NOT NULL,
+    operation VARCHAR(50) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    response TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+-- Track the lifecycle of each wallet: 0 = active, 1 = frozen, 2 = closed
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS status SMALLINT NOT NULL DEFAULT 0;
@@ -0,0 +1,20 @@
+-- Users who can log in; a user's wallet is the balance row whose user_id is the user's id.
+-- Role 0 is a customer, role 1 an admin.
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(64) UNIQUE NOT NULL,
+    password_hash TEXT NOT NULL,
+    role SMALLINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Sample users owning the sample wallets, with password "password123", and an admin with
+-- password "admin-password". Change these passwords before using the data anywhere real.
+INSERT INTO users (id, username, password_hash, role) VALUES
+    (1, 'alice', '$2a$10$/c5Ei8QASra78n29Z5UYoOwK5TuPEr5GpsEesttsozL0q718rMaQ.', 0),
+    (2, 'bob', '$2a$10$/c5Ei8QASra78n29Z5UYoOwK5TuPEr5GpsEesttsozL0q718rMaQ.', 0),
+    (3, 'carol', '$2a$10$/c5Ei8QASra78n29Z5UYoOwK5TuPEr5GpsEesttsozL0q718rMaQ.', 0),
+    (4, 'admin', '$2a$10$dtU5AaTq/ObxGvm6M4dLVOauL.TvKcymGn5XuYySMko.P.iRt0Nxi', 1)
+ON CONFLICT (id) DO NOTHING;
+
+SELECT setval('users_id_seq', (SELECT MAX(id) FROM users));
@@ -0,0 +1,25 @@
+-- Manual balance adjustments, created pending by one admin and reviewed by another:
+-- status 0 = pending, 1 = approved, 2 = rejected
+CREATE TABLE IF NOT EXISTS adjustments (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    amount BIGINT NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    status SMALLINT NOT NULL DEFAULT 0,
+    created_by INT NOT NULL REFERENCES users(id),
+    reviewed_by INT REFERENCES users(id),
+    journal_entry_id INT REFERENCES journal_entries(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_adjustments_user_id ON adjustments(user_id);
+CREATE INDEX IF NOT EXISTS idx_adjustments_status ON adjustments(status);
+
+-- System account on the other side of every approved adjustment, see model.SystemAccountAdjustments
+INSERT INTO balances (user_id, balance) VALUES (1000000003, 0) ON CONFLICT (user_id) DO NOTHING;
+
+-- A second admin, so that adjustments can be approved (password: admin-password)
+INSERT INTO users (id, username, password_hash, role) VALUES
+    (5, 'auditor', '$2a$10$dtU5AaTq/ObxGvm6M4dLVOauL.TvKcymGn5XuYySMko.P.iRt0Nxi', 1)
+ON CONFLICT (id) DO NOTHING;
+SELECT setval('users_id_seq', (SELECT MAX(id) FROM users));
@@ -0,0 +1,2 @@
+-- Serve keyset pagination of a user's history, which orders by (timestamp, id) newest first
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id_timestamp_id ON transactions(user_id, timestamp DESC, id DESC);
@@ -0,0 +1,7 @@
+-- Record the other wallet of a transfer, an ID shared by both of its legs, and a memo and
+-- external reference supplied by the sender
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS counterparty_id INT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS transfer_id VARCHAR(36);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS memo VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS external_reference VARCHAR(255) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_transactions_transfer_id ON transactions(transfer_id);
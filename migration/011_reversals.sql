@@ -0,0 +1,9 @@
+-- Reversals and refunds: a compensating journal entry points at the entry it reverses, which
+-- keeps how much of it has been reversed so far, and each history row of the compensation
+-- points at the row of the same wallet it undoes
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS reverses_entry_id INT REFERENCES journal_entries(id);
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS reversed_amount BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_journal_entries_reverses_entry_id ON journal_entries(reverses_entry_id);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS original_transaction_id INT REFERENCES transactions(id);
+CREATE INDEX IF NOT EXISTS idx_transactions_original_transaction_id ON transactions(original_transaction_id);
@@ -0,0 +1,20 @@
+-- Multi-currency wallets: a user holds one balance row per ISO 4217 currency, and every
+-- posting, transaction and adjustment names the currency it moves. Existing rows are USD.
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE balances DROP CONSTRAINT IF EXISTS balances_user_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_balances_user_currency ON balances(user_id, currency);
+
+ALTER TABLE postings ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE adjustments ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+
+-- Verifying a balance sums the postings of one user in one currency
+DROP INDEX IF EXISTS idx_postings_user_id;
+CREATE INDEX IF NOT EXISTS idx_postings_user_id_currency ON postings(user_id, currency);
+
+-- The system accounts hold every supported currency, see model.Currencies
+INSERT INTO balances (user_id, currency, balance)
+SELECT account.user_id, currency.code, 0
+FROM (VALUES (1000000001), (1000000002), (1000000003)) AS account(user_id),
+     (VALUES ('CHF'), ('EUR'), ('GBP'), ('JPY'), ('USD')) AS currency(code)
+ON CONFLICT (user_id, currency) DO NOTHING;
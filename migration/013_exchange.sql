@@ -0,0 +1,23 @@
+-- Currency exchange: every price quoted to a user is kept, and points at the journal entry that
+-- posted it once it is used. A quote can be used once, before it expires.
+CREATE TABLE IF NOT EXISTS exchange_quotes (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id INT NOT NULL,
+    from_currency VARCHAR(3) NOT NULL,
+    to_currency VARCHAR(3) NOT NULL,
+    amount BIGINT NOT NULL,
+    rate BIGINT NOT NULL,
+    to_amount BIGINT NOT NULL,
+    spread BIGINT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    journal_entry_id INT REFERENCES journal_entries(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_exchange_quotes_user_id ON exchange_quotes(user_id);
+
+-- The exchange desk and the house account hold every supported currency, see model.Currencies
+INSERT INTO balances (user_id, currency, balance)
+SELECT account.user_id, currency.code, 0
+FROM (VALUES (1000000004), (1000000005)) AS account(user_id),
+     (VALUES ('CHF'), ('EUR'), ('GBP'), ('JPY'), ('USD')) AS currency(code)
+ON CONFLICT (user_id, currency) DO NOTHING;
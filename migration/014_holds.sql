@@ -0,0 +1,24 @@
+-- Holds reserve part of a wallet's balance for a merchant until they are captured, voided or
+-- expire: status 0 = authorized, 1 = captured, 2 = voided, 3 = expired. The money reserved by
+-- the authorized holds of each balance is kept on the balance row as held, so it is read under
+-- the same lock or version as the balance.
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS held BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS holds (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    merchant_id INT NOT NULL,
+    amount BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    captured_amount BIGINT NOT NULL DEFAULT 0,
+    status SMALLINT NOT NULL DEFAULT 0,
+    memo VARCHAR(255),
+    external_reference VARCHAR(255),
+    expires_at TIMESTAMP NOT NULL,
+    journal_entry_id INT REFERENCES journal_entries(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_holds_user_id ON holds(user_id);
+-- Expired holds are found by status and expiry
+CREATE INDEX IF NOT EXISTS idx_holds_status_expires_at ON holds(status, expires_at);
@@ -0,0 +1,28 @@
+-- Schedules are standing orders transferring money between two wallets, once or repeatedly:
+-- recurrence 0 = once, 1 = daily, 2 = weekly, 3 = monthly; failure_policy 0 = skip the
+-- occurrence, 1 = stop the schedule; status 0 = active, 1 = completed, 2 = cancelled, 3 = failed.
+CREATE TABLE IF NOT EXISTS schedules (
+    id SERIAL PRIMARY KEY,
+    from_user_id INT NOT NULL,
+    to_user_id INT NOT NULL,
+    amount BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    memo VARCHAR(255),
+    external_reference VARCHAR(255),
+    recurrence SMALLINT NOT NULL DEFAULT 0,
+    start_at TIMESTAMP NOT NULL,
+    end_at TIMESTAMP,
+    failure_policy SMALLINT NOT NULL DEFAULT 0,
+    status SMALLINT NOT NULL DEFAULT 0,
+    occurrence INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMP NOT NULL,
+    last_transfer_id VARCHAR(36),
+    last_error VARCHAR(255),
+    version INT NOT NULL DEFAULT 0,
+    created_by INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_schedules_from_user_id ON schedules(from_user_id);
+-- Due schedules are found by status and next run
+CREATE INDEX IF NOT EXISTS idx_schedules_status_next_run_at ON schedules(status, next_run_at);
@@ -0,0 +1,5 @@
+-- The tier of each wallet decides which limits apply to it: 0 = standard, 1 = verified,
+-- 2 = premium. Like the status, it is kept on every balance row of the wallet.
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS tier SMALLINT NOT NULL DEFAULT 0;
+-- What a wallet already moved in a limit's period is summed from its transactions, which are
+-- found through idx_transactions_user_id_timestamp_id
@@ -0,0 +1,24 @@
+-- The product of each wallet decides the interest it earns: 0 = checking, 1 = savings. Like the
+-- tier, it is kept on every balance row of the wallet, and savings balances are found by it.
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS product SMALLINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_balances_product_user_id_currency ON balances(product, user_id, currency);
+
+-- Interest accrues daily on the end-of-day balance of savings wallets and is paid out monthly.
+-- amount is in millionths of a minor unit; paid_at and journal_entry_id stay empty until the
+-- accrual's month is paid out, and journal_entry_id also when the month paid less than a minor
+-- unit. Each balance accrues a day once.
+CREATE TABLE IF NOT EXISTS interest_accruals (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    day DATE NOT NULL,
+    balance BIGINT NOT NULL,
+    rate_basis_points INT NOT NULL,
+    amount BIGINT NOT NULL,
+    journal_entry_id INT REFERENCES journal_entries(id),
+    paid_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_interest_accruals_user_currency_day ON interest_accruals(user_id, currency, day);
+-- Accruals waiting to be paid out are found by day
+CREATE INDEX IF NOT EXISTS idx_interest_accruals_unpaid_day ON interest_accruals(day) WHERE paid_at IS NULL;
@@ -0,0 +1,4 @@
+-- A balance may be spent down to minus its overdraft limit, zero for balances without a credit
+-- line. Overdrawn balances are found for reporting and for charging interest.
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS overdraft_limit BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_balances_overdraft_user_id_currency ON balances(user_id, currency) WHERE overdraft_limit > 0 OR balance < 0;
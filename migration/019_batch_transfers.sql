@@ -0,0 +1,3 @@
+-- Record the batch a transfer was paid out in, an ID shared by every transaction of the batch
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS batch_id VARCHAR(36);
+CREATE INDEX IF NOT EXISTS idx_transactions_batch_id ON transactions(batch_id);
@@ -0,0 +1,5 @@
+-- Idempotency keys belong to the caller that sent them: the same key sent by another user is a
+-- different request. Records stored before keys were scoped belong to no user.
+ALTER TABLE idempotency_records ADD COLUMN IF NOT EXISTS user_id INT NOT NULL DEFAULT 0;
+ALTER TABLE idempotency_records DROP CONSTRAINT IF EXISTS idempotency_records_key_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_records_user_id_key ON idempotency_records(user_id, key);
@@ -0,0 +1,3 @@
+-- Merchant wallets, the only ones holds can pay, are a third product besides those of
+-- 017_interest.sql: 0 = checking, 1 = savings, 2 = merchant. Only savings wallets earn interest.
+COMMENT ON COLUMN balances.product IS '0 = checking, 1 = savings, 2 = merchant';
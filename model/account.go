@@ -0,0 +1,103 @@
+package model
+
+import "strings"
+
+// AccountStatus is the lifecycle state of a wallet. Only active wallets can send or receive money.
+type AccountStatus uint16
+
+const (
+	// AccountStatusActive is the zero value, so wallets created before statuses existed are active
+	AccountStatusActive AccountStatus = iota
+	// AccountStatusFrozen blocks all money movement until the wallet is unfrozen
+	AccountStatusFrozen
+	// AccountStatusClosed is final; a wallet can only be closed once its balance is zero
+	AccountStatusClosed
+)
+
+func (s AccountStatus) String() string {
+	switch s {
+	case AccountStatusActive:
+		return "Active"
+	case AccountStatusFrozen:
+		return "Frozen"
+	case AccountStatusClosed:
+		return "Closed"
+	default:
+		return "Unknown"
+	}
+}
+
+// AccountTier decides which limits apply to a wallet, see the limits package. Like the status,
+// it is shared by every balance of the wallet.
+type AccountTier uint16
+
+const (
+	// AccountTierStandard is the zero value, so wallets created before tiers existed are standard
+	AccountTierStandard AccountTier = iota
+	// AccountTierVerified is a wallet whose owner's identity was checked
+	AccountTierVerified
+	// AccountTierPremium has the highest limits
+	AccountTierPremium
+)
+
+func (t AccountTier) String() string {
+	switch t {
+	case AccountTierStandard:
+		return "Standard"
+	case AccountTierVerified:
+		return "Verified"
+	case AccountTierPremium:
+		return "Premium"
+	default:
+		return "Unknown"
+	}
+}
+
+// ParseAccountTier returns the tier named name, ignoring case, such as "premium" for
+// AccountTierPremium
+func ParseAccountTier(name string) (AccountTier, bool) {
+	for t := AccountTierStandard; t <= AccountTierPremium; t++ {
+		if strings.EqualFold(t.String(), name) {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// AccountProduct is the kind of wallet a user holds, which decides the interest it earns, see
+// the interest package. Like the tier, it is shared by every balance of the wallet.
+type AccountProduct uint16
+
+const (
+	// AccountProductChecking is the zero value, so wallets created before products existed are
+	// checking wallets
+	AccountProductChecking AccountProduct = iota
+	// AccountProductSavings earns interest on its end-of-day balance, paid out monthly
+	AccountProductSavings
+	// AccountProductMerchant is paid by capturing holds on other wallets, and earns no interest
+	AccountProductMerchant
+)
+
+func (p AccountProduct) String() string {
+	switch p {
+	case AccountProductChecking:
+		return "Checking"
+	case AccountProductSavings:
+		return "Savings"
+	case AccountProductMerchant:
+		return "Merchant"
+	default:
+		return "Unknown"
+	}
+}
+
+// ParseAccountProduct returns the product named name, ignoring case, such as "savings" for
+// AccountProductSavings
+func ParseAccountProduct(name string) (AccountProduct, bool) {
+	for p := AccountProductChecking; p <= AccountProductMerchant; p++ {
+		if strings.EqualFold(p.String(), name) {
+			return p, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// Adjustment is a manual correction of a wallet's balance made by an admin. It follows the
+// maker-checker rule: an admin creates it pending and it is only posted to the ledger once a
+// different admin approves it.
+type Adjustment struct {
+	ID             uint             `gorm:"primaryKey" json:"id"`
+	UserID         uint             `gorm:"index" json:"user_id"`
+	Amount         Money            `json:"amount"` // Signed, negative when money is taken out of the wallet
+	Currency       Currency         `gorm:"size:3;not null;default:USD" json:"currency"`
+	Reason         string           `json:"reason"`
+	Status         AdjustmentStatus `gorm:"index" json:"status"`
+	CreatedBy      uint             `json:"created_by"`
+	ReviewedBy     uint             `gorm:"default:null" json:"reviewed_by"`      // Zero while pending
+	JournalEntryID uint             `gorm:"default:null" json:"journal_entry_id"` // Zero unless approved
+	CreatedAt      time.Time        `gorm:"autoCreateTime" json:"created_at"`
+	ReviewedAt     *time.Time       `json:"reviewed_at"`
+}
+
+// AdjustmentStatus is the review state of an adjustment
+type AdjustmentStatus uint16
+
+const (
+	// AdjustmentStatusPending waits for a second admin to approve or reject it
+	AdjustmentStatusPending AdjustmentStatus = iota
+	// AdjustmentStatusApproved has been posted to the wallet
+	AdjustmentStatusApproved
+	// AdjustmentStatusRejected was turned down and never touched the wallet
+	AdjustmentStatusRejected
+)
+
+func (s AdjustmentStatus) String() string {
+	switch s {
+	case AdjustmentStatusPending:
+		return "Pending"
+	case AdjustmentStatusApproved:
+		return "Approved"
+	case AdjustmentStatusRejected:
+		return "Rejected"
+	default:
+		return "Unknown"
+	}
+}
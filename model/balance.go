@@ -2,9 +2,35 @@ package model
 
 import "time"
 
+// Balance is the money a user or system account holds in one currency. A user's wallet is made
+// of one balance per currency it holds, which all share the wallet's status.
 type Balance struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"uniqueIndex" json:"user_id"` // Ensures one balance per user
-	Balance   float64   `json:"balance"`
-	CreatedAt time.Time `json:"created_at"`
+	ID       uint           `gorm:"primaryKey" json:"id"`
+	UserID   uint           `gorm:"uniqueIndex:idx_balances_user_currency" json:"user_id"` // Ensures one balance per user and currency
+	Currency Currency       `gorm:"uniqueIndex:idx_balances_user_currency;size:3;not null;default:USD" json:"currency"`
+	Balance  Money          `json:"balance"`
+	Held     Money          `gorm:"not null;default:0" json:"held"`    // Reserved by authorized holds, part of Balance
+	Version  uint           `gorm:"not null;default:0" json:"version"` // Incremented on every update for optimistic locking
+	Status   AccountStatus  `gorm:"not null;default:0" json:"status"`
+	Tier     AccountTier    `gorm:"not null;default:0" json:"tier"`
+	Product  AccountProduct `gorm:"not null;default:0" json:"product"`
+	// OverdraftLimit is how far below zero the balance may be spent, zero without a credit line
+	OverdraftLimit Money     `gorm:"not null;default:0" json:"overdraft_limit"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Available returns what can be spent: the part of the balance that is not reserved by holds,
+// plus the credit line
+func (b *Balance) Available() Money {
+	return b.Balance - b.Held + b.OverdraftLimit
+}
+
+// IsOverdrawn reports whether the balance is below zero
+func (b *Balance) IsOverdrawn() bool {
+	return b.Balance.IsNegative()
+}
+
+// Key returns the user and currency identifying the balance
+func (b *Balance) Key() BalanceKey {
+	return BalanceKey{UserID: b.UserID, Currency: b.Currency}
 }
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code such as "USD". Money amounts are always in hundredths
+// of the currency's major unit, whatever its own minor unit is.
+type Currency string
+
+const (
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+	CHF Currency = "CHF"
+	JPY Currency = "JPY"
+)
+
+// DefaultCurrency is the currency of wallets and transactions created before currencies
+// existed, and of requests that do not name one
+const DefaultCurrency = USD
+
+// currencyDecimals is the ISO 4217 number of decimal places of each supported currency. Money
+// carries MoneyDecimals places, so currencies with more, such as KWD, cannot be supported.
+var currencyDecimals = map[Currency]int{
+	USD: 2,
+	EUR: 2,
+	GBP: 2,
+	CHF: 2,
+	JPY: 0,
+}
+
+// ErrUnsupportedCurrency is returned when a currency code is unknown or not supported
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// Currencies returns the supported currencies in alphabetical order
+func Currencies() []Currency {
+	currencies := make([]Currency, 0, len(currencyDecimals))
+	for currency := range currencyDecimals {
+		currencies = append(currencies, currency)
+	}
+	slices.Sort(currencies)
+	return currencies
+}
+
+// ParseCurrency returns the supported currency with the given code, ignoring case
+func ParseCurrency(code string) (Currency, error) {
+	currency := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if !currency.IsSupported() {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedCurrency, code)
+	}
+	return currency, nil
+}
+
+// IsSupported reports whether wallets can hold money in c
+func (c Currency) IsSupported() bool {
+	_, ok := currencyDecimals[c]
+	return ok
+}
+
+// Decimals returns the number of decimal places amounts in c may have, e.g. 0 for JPY
+func (c Currency) Decimals() int {
+	return currencyDecimals[c]
+}
+
+// Allows reports whether amount has no more decimal places than c permits, e.g. JPY amounts
+// must be whole
+func (c Currency) Allows(amount Money) bool {
+	return amount%c.step() == 0
+}
+
+// Truncate drops the decimal places of amount that c does not permit, rounding toward zero,
+// e.g. 1234.56 becomes 1234 in JPY
+func (c Currency) Truncate(amount Money) Money {
+	return amount - amount%c.step()
+}
+
+// RoundUp rounds a non-negative amount up to the decimal places c permits, e.g. 1234.01 becomes
+// 1235 in JPY
+func (c Currency) RoundUp(amount Money) Money {
+	step := c.step()
+	return amount + (step-amount%step)%step
+}
+
+// step returns the smallest amount of c, in the hundredths Money carries
+func (c Currency) step() Money {
+	step := Money(1)
+	for i := c.Decimals(); i < MoneyDecimals; i++ {
+		step *= 10
+	}
+	return step
+}
+
+// Format returns amount with the decimal places of c followed by its code, e.g. "12.34 USD"
+// or "1000 JPY"
+func (c Currency) Format(amount Money) string {
+	text := amount.String()
+	if decimals := c.Decimals(); decimals < MoneyDecimals {
+		text = text[:len(text)-MoneyDecimals+decimals]
+		text = strings.TrimSuffix(text, ".")
+	}
+	return text + " " + string(c)
+}
+
+// CurrencyOrDefault returns c, or DefaultCurrency when c is empty
+func CurrencyOrDefault(c Currency) Currency {
+	if c == "" {
+		return DefaultCurrency
+	}
+	return c
+}
+
+// BalanceKey identifies one balance: the money a user or system account holds in one currency
+type BalanceKey struct {
+	UserID   uint
+	Currency Currency
+}
+
+// Compare orders balance keys by user ID and then currency, which is the order balance rows
+// are locked in
+func (k BalanceKey) Compare(other BalanceKey) int {
+	switch {
+	case k.UserID < other.UserID:
+		return -1
+	case k.UserID > other.UserID:
+		return 1
+	}
+	return strings.Compare(string(k.Currency), string(other.Currency))
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    Currency
+		expectError bool
+	}{
+		{name: "Upper case", input: "EUR", expected: EUR},
+		{name: "Lower case", input: "jpy", expected: JPY},
+		{name: "Unknown code", input: "XYZ", expectError: true},
+		{name: "More decimals than Money carries", input: "KWD", expectError: true},
+		{name: "Empty", input: "", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			currency, err := ParseCurrency(tt.input)
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, currency)
+			}
+		})
+	}
+}
+
+func TestCurrencyPrecision(t *testing.T) {
+	tests := []struct {
+		currency  Currency
+		amount    Money
+		allowed   bool
+		truncated Money
+		formatted string
+	}{
+		{currency: USD, amount: 1234, allowed: true, truncated: 1234, formatted: "12.34 USD"},
+		{currency: EUR, amount: -5, allowed: true, truncated: -5, formatted: "-0.05 EUR"},
+		{currency: JPY, amount: 100000, allowed: true, truncated: 100000, formatted: "1000 JPY"},
+		{currency: JPY, amount: -300, allowed: true, truncated: -300, formatted: "-3 JPY"},
+		{currency: JPY, amount: 150, allowed: false, truncated: 100, formatted: "1 JPY"},
+		{currency: JPY, amount: -199, allowed: false, truncated: -100, formatted: "-1 JPY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.formatted, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, tt.currency.Allows(tt.amount))
+			assert.Equal(t, tt.truncated, tt.currency.Truncate(tt.amount))
+			assert.Equal(t, tt.formatted, tt.currency.Format(tt.amount))
+		})
+	}
+}
+
+func TestCurrencyRoundUp(t *testing.T) {
+	assert.Equal(t, Money(1234), USD.RoundUp(1234))
+	assert.Equal(t, Money(0), JPY.RoundUp(0))
+	assert.Equal(t, Money(100), JPY.RoundUp(1))
+	assert.Equal(t, Money(123500), JPY.RoundUp(123401))
+}
+
+func TestBalanceKeyCompare(t *testing.T) {
+	assert.Equal(t, -1, BalanceKey{UserID: 1, Currency: USD}.Compare(BalanceKey{UserID: 2, Currency: EUR}))
+	assert.Equal(t, -1, BalanceKey{UserID: 1, Currency: EUR}.Compare(BalanceKey{UserID: 1, Currency: USD}))
+	assert.Equal(t, 0, BalanceKey{UserID: 1, Currency: USD}.Compare(BalanceKey{UserID: 1, Currency: USD}))
+	assert.Equal(t, 1, BalanceKey{UserID: 3, Currency: EUR}.Compare(BalanceKey{UserID: 1, Currency: USD}))
+}
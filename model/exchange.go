@@ -0,0 +1,151 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateDecimals is the number of decimal places carried by a Rate
+const RateDecimals = 8
+
+// rateUnit is the number of Rate units in one
+const rateUnit = 100_000_000
+
+// Parity is the rate of a currency in itself
+const Parity Rate = rateUnit
+
+// ErrInvalidRate is returned when a string cannot be parsed as an exchange rate
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// Rate is the price of one unit of a currency in another, in integer units of 10^-RateDecimals,
+// e.g. 108500000 for 1.085 USD per EUR. Like Money it is an integer so conversions are exact.
+type Rate int64
+
+// ParseRate parses a positive decimal string such as "1.085" into a Rate. Rates with more than
+// RateDecimals decimal places are rejected rather than rounded.
+func ParseRate(s string) (Rate, error) {
+	text := strings.TrimSpace(s)
+	whole, fraction, hasPoint := strings.Cut(text, ".")
+	if whole == "" && fraction == "" || hasPoint && fraction == "" || len(fraction) > RateDecimals {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidRate, s)
+	}
+
+	var units uint64
+	if whole != "" {
+		var err error
+		units, err = strconv.ParseUint(whole, 10, 63)
+		if err != nil || units > math.MaxInt64/rateUnit {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidRate, s)
+		}
+	}
+	var fractionUnits uint64
+	if fraction != "" {
+		var err error
+		fractionUnits, err = strconv.ParseUint(fraction+strings.Repeat("0", RateDecimals-len(fraction)), 10, 63)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidRate, s)
+		}
+	}
+
+	rate := Rate(units*rateUnit + fractionUnits)
+	if rate <= 0 {
+		return 0, fmt.Errorf("%w: %q is not positive", ErrInvalidRate, s)
+	}
+	return rate, nil
+}
+
+// String formats the rate without trailing zeros, e.g. "1.085" or "150"
+func (r Rate) String() string {
+	text := fmt.Sprintf("%d.%0*d", r/rateUnit, RateDecimals, r%rateUnit)
+	return strings.TrimSuffix(strings.TrimRight(text, "0"), ".")
+}
+
+// Convert returns amount converted at r, rounded toward zero to a whole minor unit.
+// ErrInvalidMoney is returned when the result does not fit in Money.
+func (r Rate) Convert(amount Money) (Money, error) {
+	converted := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(int64(r)))
+	converted.Quo(converted, big.NewInt(rateUnit))
+	if !converted.IsInt64() {
+		return 0, fmt.Errorf("%w: %s at %s is out of range", ErrInvalidMoney, amount, r)
+	}
+	return Money(converted.Int64()), nil
+}
+
+// Div returns r divided by other rounded down, which is the cross rate between two currencies
+// when r and other are their prices in the same third currency
+func (r Rate) Div(other Rate) Rate {
+	quotient := new(big.Int).Mul(big.NewInt(int64(r)), big.NewInt(rateUnit))
+	quotient.Quo(quotient, big.NewInt(int64(other)))
+	return Rate(quotient.Int64())
+}
+
+// MarshalJSON encodes the rate as a JSON string, so clients do not round it through float64
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(r.String())), nil
+}
+
+// UnmarshalJSON decodes a JSON string or number without passing through float64
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	rate, err := ParseRate(text)
+	if err != nil {
+		return err
+	}
+	*r = rate
+	return nil
+}
+
+// Value implements driver.Valuer, storing the rate as a BIGINT of 10^-RateDecimals units
+func (r Rate) Value() (driver.Value, error) {
+	return int64(r), nil
+}
+
+// Scan implements sql.Scanner for BIGINT columns holding 10^-RateDecimals units
+func (r *Rate) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		*r = Rate(v)
+	case nil:
+		*r = 0
+	default:
+		return fmt.Errorf("cannot scan %T into Rate", src)
+	}
+	return nil
+}
+
+// ExchangeQuote is a price offered to a user for selling Amount of FromCurrency for ToAmount of
+// ToCurrency. The price holds until ExpiresAt and can be used for one exchange, after which
+// JournalEntryID is the entry that posted it.
+type ExchangeQuote struct {
+	ID           string   `gorm:"primaryKey;size:36" json:"id"`
+	UserID       uint     `gorm:"index" json:"user_id"`
+	FromCurrency Currency `gorm:"size:3;not null" json:"from_currency"`
+	ToCurrency   Currency `gorm:"size:3;not null" json:"to_currency"`
+	Amount       Money    `json:"amount"` // Sold, in FromCurrency
+	// Rate is the mid-market price of FromCurrency in ToCurrency given by the rate provider
+	Rate Rate `json:"rate"`
+	// ToAmount is what the user receives in ToCurrency: Amount at Rate, less Spread
+	ToAmount Money `json:"to_amount"`
+	// Spread is the part of Amount at Rate kept by the house, in ToCurrency
+	Spread         Money     `json:"spread"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	JournalEntryID uint      `gorm:"default:null" json:"journal_entry_id"` // Zero until used
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Expired reports whether the quote can no longer be used at now
+func (q *ExchangeQuote) Expired(now time.Time) bool {
+	return !now.Before(q.ExpiresAt)
+}
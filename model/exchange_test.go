@@ -0,0 +1,96 @@
+package model
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    Rate
+		formatted   string
+		expectError bool
+	}{
+		{name: "Decimal", input: "1.085", expected: 108500000, formatted: "1.085"},
+		{name: "Whole", input: "150", expected: 15000000000, formatted: "150"},
+		{name: "Eight decimals", input: "0.00671234", expected: 671234, formatted: "0.00671234"},
+		{name: "Leading point", input: ".5", expected: 50000000, formatted: "0.5"},
+		{name: "Too many decimals", input: "1.000000001", expectError: true},
+		{name: "Zero", input: "0", expectError: true},
+		{name: "Negative", input: "-1.2", expectError: true},
+		{name: "Empty", input: "", expectError: true},
+		{name: "Out of range", input: "100000000000", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := ParseRate(tt.input)
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrInvalidRate)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, rate)
+			assert.Equal(t, tt.formatted, rate.String())
+		})
+	}
+}
+
+func TestRateConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     Rate
+		amount   Money
+		expected Money
+	}{
+		{name: "Exact", rate: 108500000, amount: 10000, expected: 10850},
+		{name: "Rounded toward zero", rate: 108500000, amount: 999, expected: 1083},
+		{name: "Into a cheaper currency", rate: 16250000000, amount: 1050, expected: 170625},
+		{name: "Large amount", rate: 15000000000, amount: NewMoney(1_000_000_000, 0), expected: NewMoney(150_000_000_000, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converted, err := tt.rate.Convert(tt.amount)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, converted)
+		})
+	}
+
+	_, err := Rate(15000000000).Convert(math.MaxInt64)
+	assert.ErrorIs(t, err, ErrInvalidMoney)
+}
+
+func TestRateDiv(t *testing.T) {
+	// EUR and JPY priced in USD give the price of a euro in yen
+	eur, jpy := Rate(108500000), Rate(667000)
+	assert.Equal(t, "162.66866566", eur.Div(jpy).String())
+	assert.Equal(t, "0.00614746", jpy.Div(eur).String())
+}
+
+func TestRateJSON(t *testing.T) {
+	data, err := json.Marshal(Rate(108500000))
+	assert.NoError(t, err)
+	assert.Equal(t, `"1.085"`, string(data))
+
+	var rates map[Currency]Rate
+	assert.NoError(t, json.Unmarshal([]byte(`{"EUR": "1.085", "JPY": 0.00667}`), &rates))
+	assert.Equal(t, map[Currency]Rate{EUR: 108500000, JPY: 667000}, rates)
+
+	var rate Rate
+	assert.ErrorIs(t, json.Unmarshal([]byte(`"-1"`), &rate), ErrInvalidRate)
+}
+
+func TestExchangeQuoteExpired(t *testing.T) {
+	expiresAt := time.Date(2024, 3, 1, 12, 0, 30, 0, time.UTC)
+	quote := &ExchangeQuote{ExpiresAt: expiresAt}
+
+	assert.False(t, quote.Expired(expiresAt.Add(-time.Nanosecond)))
+	assert.True(t, quote.Expired(expiresAt))
+}
@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// Hold reserves part of a wallet's balance for a merchant until the final amount is known. While
+// authorized, its amount counts towards the balance's Held, so it cannot be spent but is still
+// part of the balance. Capturing pays up to Amount to the merchant's wallet and releases the
+// rest; voiding or expiring releases all of it.
+type Hold struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	UserID            uint       `gorm:"index" json:"user_id"` // The wallet the money is held in
+	MerchantID        uint       `json:"merchant_id"`          // The wallet paid on capture
+	Amount            Money      `json:"amount"`
+	Currency          Currency   `gorm:"size:3;not null;default:USD" json:"currency"`
+	CapturedAmount    Money      `json:"captured_amount"` // Zero unless captured
+	Status            HoldStatus `gorm:"index:idx_holds_status_expires_at" json:"status"`
+	Memo              string     `gorm:"size:255" json:"memo,omitempty"`               // Copied to the capture's transactions
+	ExternalReference string     `gorm:"size:255" json:"external_reference,omitempty"` // e.g. the merchant's order number
+	ExpiresAt         time.Time  `gorm:"index:idx_holds_status_expires_at" json:"expires_at"`
+	JournalEntryID    uint       `gorm:"default:null" json:"journal_entry_id"` // Zero unless captured
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	ResolvedAt        *time.Time `json:"resolved_at"` // When it stopped being authorized
+}
+
+// Expired reports whether an authorized hold can no longer be captured at now. It keeps
+// reserving its amount until it is released as HoldStatusExpired.
+func (h *Hold) Expired(now time.Time) bool {
+	return !now.Before(h.ExpiresAt)
+}
+
+// HoldStatus is the lifecycle state of a hold. Only authorized holds reserve money.
+type HoldStatus uint16
+
+const (
+	// HoldStatusAuthorized reserves the hold's amount until it is captured, voided or expires
+	HoldStatusAuthorized HoldStatus = iota
+	// HoldStatusCaptured paid CapturedAmount to the merchant and released the rest
+	HoldStatusCaptured
+	// HoldStatusVoided was cancelled and released without paying anything
+	HoldStatusVoided
+	// HoldStatusExpired was released without paying anything once ExpiresAt passed
+	HoldStatusExpired
+)
+
+func (s HoldStatus) String() string {
+	switch s {
+	case HoldStatusAuthorized:
+		return "Authorized"
+	case HoldStatusCaptured:
+		return "Captured"
+	case HoldStatusVoided:
+		return "Voided"
+	case HoldStatusExpired:
+		return "Expired"
+	default:
+		return "Unknown"
+	}
+}
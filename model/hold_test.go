@@ -0,0 +1,21 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHoldExpired(t *testing.T) {
+	expiresAt := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	hold := &Hold{ExpiresAt: expiresAt}
+
+	assert.False(t, hold.Expired(expiresAt.Add(-time.Nanosecond)))
+	assert.True(t, hold.Expired(expiresAt))
+}
+
+func TestBalanceAvailable(t *testing.T) {
+	balance := &Balance{Balance: 10000, Held: 2500}
+	assert.Equal(t, Money(7500), balance.Available())
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// InternalIdempotencyKeyPrefix starts the idempotency keys the wallet makes for the requests it
+// sends on its own, such as scheduled transfers. Clients may not send keys starting with it, so
+// they cannot claim a key the wallet is going to use.
+const InternalIdempotencyKeyPrefix = "internal:"
+
+// IdempotencyRecord remembers the response of a request made with an idempotency key, so
+// that a retry with the same key returns that response instead of executing again. Keys belong
+// to the caller that sent them, so two callers may use the same key for different requests.
+type IdempotencyRecord struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_idempotency_records_user_id_key;not null" json:"user_id"` // The caller
+	Key         string    `gorm:"uniqueIndex:idx_idempotency_records_user_id_key;size:255" json:"key"`
+	Operation   string    `gorm:"size:50" json:"operation"`
+	Fingerprint string    `gorm:"size:64" json:"fingerprint"` // SHA-256 of the request payload
+	Response    string    `gorm:"type:text" json:"response"`  // JSON encoded response
+	CreatedAt   time.Time `json:"created_at"`
+}
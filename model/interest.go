@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// AccrualScale is how many units of InterestAccrual.Amount make a minor unit of Money. Interest
+// is accrued that much more precisely than balances are kept, so that a day's interest on a
+// small balance is not lost to rounding before the month's accruals are added up.
+const AccrualScale = 1_000_000
+
+// InterestAccrual is the interest a savings wallet earned on its balance in one currency over
+// one day, or, when negative, the interest an overdrawn wallet owed on it. Accruals are recorded
+// daily and settled together once their month is over, by a journal entry of
+// TransactionTypeInterest from the house account or of TransactionTypeOverdraftInterest to it.
+type InterestAccrual struct {
+	ID       uint     `gorm:"primaryKey" json:"id"`
+	UserID   uint     `gorm:"uniqueIndex:idx_interest_accruals_user_currency_day" json:"user_id"`
+	Currency Currency `gorm:"uniqueIndex:idx_interest_accruals_user_currency_day;size:3;not null" json:"currency"`
+	// Day is midnight UTC starting the day, which is accrued at most once per balance
+	Day             time.Time `gorm:"uniqueIndex:idx_interest_accruals_user_currency_day;type:date" json:"day"`
+	Balance         Money     `json:"balance"`           // At the end of the day
+	RateBasisPoints int64     `json:"rate_basis_points"` // Annual rate the day was accrued at
+	// Amount is the interest earned, or owed when negative, in 1/AccrualScale of a minor unit,
+	// rounded towards zero
+	Amount         int64      `json:"amount"`
+	JournalEntryID uint       `gorm:"default:null" json:"journal_entry_id"` // The payout, zero until paid or when it paid nothing
+	PaidAt         *time.Time `json:"paid_at"`                              // Nil until paid out
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// AccruedMoney returns the whole minor units of an amount of accrued interest, rounding towards
+// zero
+func AccruedMoney(amount int64) Money {
+	return Money(amount / AccrualScale)
+}
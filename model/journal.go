@@ -0,0 +1,92 @@
+package model
+
+import "time"
+
+// System accounts are balances owned by the wallet itself rather than by a user. They live in
+// the balances table under reserved user IDs so they can be posted to like any other wallet,
+// but unlike user wallets they may go negative.
+const (
+	systemAccountBase uint = 1_000_000_000
+
+	// SystemAccountCashIn is debited by every deposit, so its balance is minus the money
+	// that ever entered the wallet
+	SystemAccountCashIn = systemAccountBase + 1
+	// SystemAccountCashOut is credited by every withdrawal, so its balance is the money
+	// that ever left the wallet
+	SystemAccountCashOut = systemAccountBase + 2
+	// SystemAccountAdjustments is the other side of every approved adjustment, so its balance
+	// is minus the net amount ever credited to wallets by hand
+	SystemAccountAdjustments = systemAccountBase + 3
+	// SystemAccountExchange is the other side of every conversion between currencies: it is
+	// credited with the currency a user sells and debited with the currency they buy, so its
+	// balances are the wallet's open position in each currency
+	SystemAccountExchange = systemAccountBase + 4
+	// SystemAccountHouse is credited with the wallet's own revenue, such as exchange spreads,
+	// fees and overdraft interest, and debited with the interest it pays on savings wallets
+	SystemAccountHouse = systemAccountBase + 5
+)
+
+// MaxUserID is the largest user ID that is not reserved for a system account
+const MaxUserID = systemAccountBase
+
+// IsSystemAccount reports whether userID is reserved for a system account
+func IsSystemAccount(userID uint) bool {
+	return userID > systemAccountBase
+}
+
+// JournalEntry records one business operation as a set of postings that sum to zero in each
+// currency
+type JournalEntry struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Description string    `json:"description"`
+	Postings    []Posting `json:"postings"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	// ReversesEntryID is the entry that this entry reverses or refunds, zero for other entries
+	ReversesEntryID uint `gorm:"index;default:null" json:"reverses_entry_id,omitempty"`
+	// ReversedAmount is how much of this entry has been reversed or refunded so far
+	ReversedAmount Money `json:"reversed_amount"`
+	// Reference is copied to the transaction history rows the entry creates
+	Reference TransactionReference `gorm:"-" json:"-"`
+}
+
+// Posting credits (positive amount) or debits (negative amount) one account's balance in one
+// currency as part of a journal entry. Type is the transaction type shown in the account's history.
+type Posting struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	JournalEntryID uint            `gorm:"index" json:"journal_entry_id"`
+	UserID         uint            `gorm:"index" json:"user_id"`
+	Type           TransactionType `json:"type"`
+	Amount         Money           `json:"amount"`
+	Currency       Currency        `gorm:"size:3;not null;default:USD" json:"currency"`
+	// CounterpartyID is shown in the account's history as the other side of a transfer
+	CounterpartyID uint `gorm:"-" json:"-"`
+	// OriginalTransactionID links the account's history row to the transaction it reverses
+	OriginalTransactionID uint `gorm:"-" json:"-"`
+}
+
+// Totals returns the sum of the entry's postings in each currency, which is zero for every
+// currency of a balanced entry
+func (e *JournalEntry) Totals() map[Currency]Money {
+	totals := make(map[Currency]Money)
+	for _, posting := range e.Postings {
+		totals[posting.Currency] = totals[posting.Currency].Add(posting.Amount)
+	}
+	return totals
+}
+
+// Key returns the balance the posting applies to
+func (p *Posting) Key() BalanceKey {
+	return BalanceKey{UserID: p.UserID, Currency: p.Currency}
+}
+
+// Amount returns the money moved by the entry, which is the sum of its credits other than the
+// fees charged for moving it
+func (e *JournalEntry) Amount() Money {
+	var amount Money
+	for _, posting := range e.Postings {
+		if posting.Amount > 0 && !posting.Type.IsFee() {
+			amount = amount.Add(posting.Amount)
+		}
+	}
+	return amount
+}
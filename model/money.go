@@ -0,0 +1,192 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BasisPoints is the number of basis points, hundredths of a percent, in a whole
+const BasisPoints = 10_000
+
+// MoneyDecimals is the number of decimal places carried by a Money amount
+const MoneyDecimals = 2
+
+// minorUnitsPerMajor is the number of minor units (cents) in one major unit
+const minorUnitsPerMajor = 100
+
+// ErrInvalidMoney is returned when a string cannot be parsed as an amount of money
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// Money is an amount of money in integer minor units (cents). Keeping amounts as integers
+// makes arithmetic exact, so repeated deposits of 0.10 never drift the way float64 does.
+type Money int64
+
+// NewMoney returns the amount made of the given major and minor units, e.g. NewMoney(12, 34) is 12.34
+func NewMoney(major, minor int64) Money {
+	return Money(major*minorUnitsPerMajor + minor)
+}
+
+// ParseMoney parses a decimal string such as "12.34", "-0.5" or "100" into Money. Amounts
+// with more than MoneyDecimals decimal places are rejected rather than rounded.
+func ParseMoney(s string) (Money, error) {
+	text := strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(text, "-") {
+		negative = true
+		text = text[1:]
+	} else if strings.HasPrefix(text, "+") {
+		text = text[1:]
+	}
+
+	// strconv.ParseUint rejects signs, spaces and underscores, so only digits get through
+	whole, fraction, hasPoint := strings.Cut(text, ".")
+	if whole == "" && fraction == "" || hasPoint && fraction == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	if len(fraction) > MoneyDecimals {
+		return 0, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidMoney, s, MoneyDecimals)
+	}
+
+	var major uint64
+	if whole != "" {
+		var err error
+		major, err = strconv.ParseUint(whole, 10, 63)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+		}
+	}
+	var minor uint64
+	if fraction != "" {
+		var err error
+		minor, err = strconv.ParseUint(fraction+strings.Repeat("0", MoneyDecimals-len(fraction)), 10, 63)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+		}
+	}
+	if major > (math.MaxInt64-minor)/minorUnitsPerMajor {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidMoney, s)
+	}
+
+	amount := Money(major*minorUnitsPerMajor + minor)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// String formats the amount with exactly MoneyDecimals decimal places, e.g. "-12.30"
+func (m Money) String() string {
+	sign := ""
+	abs := uint64(m)
+	if m < 0 {
+		sign = "-"
+		abs = uint64(-m)
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, abs/minorUnitsPerMajor, MoneyDecimals, abs%minorUnitsPerMajor)
+}
+
+// Add returns m + other
+func (m Money) Add(other Money) Money {
+	return m + other
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) Money {
+	return m - other
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return -m
+}
+
+// Abs returns the absolute value of m
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Share returns basisPoints hundredths of a percent of m, rounded up to a whole minor unit. The
+// amount is split so that multiplying by the share cannot overflow.
+func (m Money) Share(basisPoints int64) Money {
+	whole, rest := m/BasisPoints, m%BasisPoints
+	return whole*Money(basisPoints) + (rest*Money(basisPoints)+BasisPoints-1)/BasisPoints
+}
+
+// IsPositive reports whether m is greater than zero
+func (m Money) IsPositive() bool {
+	return m > 0
+}
+
+// IsNegative reports whether m is less than zero
+func (m Money) IsNegative() bool {
+	return m < 0
+}
+
+// MinorUnits returns the amount in minor units (cents)
+func (m Money) MinorUnits() int64 {
+	return int64(m)
+}
+
+// MarshalJSON encodes the amount as a JSON number with MoneyDecimals decimal places
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number or string without passing through float64
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	// Accept exponent-free numbers only, so that 1e-3 cannot sneak past the decimals check
+	if strings.ContainsAny(text, "eE") {
+		return fmt.Errorf("%w: %s", ErrInvalidMoney, data)
+	}
+	amount, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+// Value implements driver.Valuer, storing the amount as a BIGINT of minor units
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+// Scan implements sql.Scanner for BIGINT and NUMERIC columns holding minor units
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		*m = Money(v)
+	case []byte:
+		return m.scanText(string(v))
+	case string:
+		return m.scanText(v)
+	case nil:
+		*m = 0
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+func (m *Money) scanText(text string) error {
+	minor, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into Money: %w", text, err)
+	}
+	*m = Money(minor)
+	return nil
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    Money
+		expectError bool
+	}{
+		{name: "Whole amount", input: "100", expected: 10000},
+		{name: "Two decimals", input: "12.34", expected: 1234},
+		{name: "One decimal", input: "0.5", expected: 50},
+		{name: "Leading point", input: ".05", expected: 5},
+		{name: "Negative", input: "-7.25", expected: -725},
+		{name: "Explicit plus", input: "+3", expected: 300},
+		{name: "Surrounding spaces", input: " 1.10 ", expected: 110},
+		{name: "Too many decimals", input: "1.005", expectError: true},
+		{name: "Empty", input: "", expectError: true},
+		{name: "Trailing point", input: "5.", expectError: true},
+		{name: "Double sign", input: "--5", expectError: true},
+		{name: "Not a number", input: "abc", expectError: true},
+		{name: "Exponent", input: "1e3", expectError: true},
+		{name: "Out of range", input: "92233720368547758.08", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := ParseMoney(tt.input)
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrInvalidMoney)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, amount)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount   Money
+		expected string
+	}{
+		{amount: 0, expected: "0.00"},
+		{amount: 5, expected: "0.05"},
+		{amount: 1234, expected: "12.34"},
+		{amount: -1230, expected: "-12.30"},
+		{amount: NewMoney(1000, 0), expected: "1000.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.amount.String())
+		})
+	}
+}
+
+func TestMoneyDoesNotDrift(t *testing.T) {
+	tenCents, err := ParseMoney("0.1")
+	assert.NoError(t, err)
+
+	var total Money
+	for i := 0; i < 1000; i++ {
+		total = total.Add(tenCents)
+	}
+	assert.Equal(t, NewMoney(100, 0), total)
+	assert.Equal(t, "100.00", total.String())
+}
+
+func TestMoneyShare(t *testing.T) {
+	assert.Equal(t, Money(25), Money(10000).Share(25))
+	assert.Equal(t, Money(1), Money(1).Share(1), "a share is rounded up to a whole minor unit")
+	assert.Equal(t, Money(10000), Money(10000).Share(BasisPoints))
+	assert.Equal(t, Money(4611686018427387904), Money(9223372036854775807).Share(BasisPoints/2), "the share of a large amount does not overflow")
+}
+
+func TestMoneyJSON(t *testing.T) {
+	type payload struct {
+		Amount Money `json:"amount"`
+	}
+
+	encoded, err := json.Marshal(payload{Amount: 1050})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount": 10.50}`, string(encoded))
+
+	var decoded payload
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": 0.1}`), &decoded))
+	assert.Equal(t, Money(10), decoded.Amount)
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": "25.99"}`), &decoded))
+	assert.Equal(t, Money(2599), decoded.Amount)
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 0.001}`), &decoded))
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 1e2}`), &decoded))
+}
+
+func TestMoneySQL(t *testing.T) {
+	value, err := Money(1234).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1234), value)
+
+	var amount Money
+	assert.NoError(t, amount.Scan(int64(-50)))
+	assert.Equal(t, Money(-50), amount)
+	assert.NoError(t, amount.Scan([]byte("999")))
+	assert.Equal(t, Money(999), amount)
+	assert.Error(t, amount.Scan(1.5))
+}
@@ -0,0 +1,177 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Schedule is a standing order to transfer Amount from one wallet to another, once at StartAt
+// or repeatedly from StartAt on. Each occurrence is run by the scheduler once it is due; a
+// failed run is retried a few times before FailurePolicy decides whether the schedule skips the
+// occurrence or stops.
+type Schedule struct {
+	ID                uint               `gorm:"primaryKey" json:"id"`
+	FromUserID        uint               `gorm:"index" json:"from_user_id"`
+	ToUserID          uint               `json:"to_user_id"`
+	Amount            Money              `json:"amount"`
+	Currency          Currency           `gorm:"size:3;not null;default:USD" json:"currency"`
+	Memo              string             `gorm:"size:255" json:"memo,omitempty"`               // Copied to every transfer
+	ExternalReference string             `gorm:"size:255" json:"external_reference,omitempty"` // Copied to every transfer
+	Recurrence        Recurrence         `json:"recurrence"`
+	StartAt           time.Time          `json:"start_at"`         // The first occurrence
+	EndAt             *time.Time         `json:"end_at,omitempty"` // No occurrence is run after it, unlimited when nil
+	FailurePolicy     ScheduleFailPolicy `json:"failure_policy"`
+	Status            ScheduleStatus     `gorm:"index:idx_schedules_status_next_run_at" json:"status"`
+	// Occurrence counts the occurrences that were run or skipped; the next one is Occurrence
+	Occurrence uint `json:"occurrence"`
+	// Attempts counts the failed runs of the current occurrence
+	Attempts       uint      `json:"attempts"`
+	NextRunAt      time.Time `gorm:"index:idx_schedules_status_next_run_at" json:"next_run_at"` // When the scheduler runs it next, which is later than its occurrence while retrying
+	LastTransferID string    `gorm:"size:36" json:"last_transfer_id,omitempty"`
+	LastError      string    `gorm:"size:255" json:"last_error,omitempty"` // Why the last run failed, empty after a success
+	Version        uint      `gorm:"not null;default:0" json:"-"`
+	CreatedBy      uint      `json:"created_by"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// OccurrenceAt returns when occurrence n of the schedule is due, and false if there is no such
+// occurrence. Monthly occurrences keep the day of the month of StartAt, falling back to the last
+// day of shorter months.
+func (s *Schedule) OccurrenceAt(n uint) (time.Time, bool) {
+	var at time.Time
+	switch s.Recurrence {
+	case RecurrenceOnce:
+		if n > 0 {
+			return time.Time{}, false
+		}
+		at = s.StartAt
+	case RecurrenceDaily:
+		at = s.StartAt.AddDate(0, 0, int(n))
+	case RecurrenceWeekly:
+		at = s.StartAt.AddDate(0, 0, 7*int(n))
+	case RecurrenceMonthly:
+		year, month, day := s.StartAt.Date()
+		// The first of the target month never overflows, unlike the start's day
+		first := time.Date(year, month+time.Month(n), 1, 0, 0, 0, 0, s.StartAt.Location())
+		day = min(day, first.AddDate(0, 1, -1).Day())
+		hour, minute, second := s.StartAt.Clock()
+		at = time.Date(first.Year(), first.Month(), day, hour, minute, second, s.StartAt.Nanosecond(), s.StartAt.Location())
+	default:
+		return time.Time{}, false
+	}
+	if s.EndAt != nil && at.After(*s.EndAt) {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// Advance moves the schedule past its current occurrence, completing it when there is no
+// other occurrence
+func (s *Schedule) Advance() {
+	s.Occurrence++
+	s.Attempts = 0
+	next, ok := s.OccurrenceAt(s.Occurrence)
+	if !ok {
+		s.Status = ScheduleStatusCompleted
+		return
+	}
+	s.NextRunAt = next
+}
+
+// Recurrence is how often a schedule repeats
+type Recurrence uint16
+
+const (
+	RecurrenceOnce Recurrence = iota
+	RecurrenceDaily
+	RecurrenceWeekly
+	RecurrenceMonthly
+)
+
+func (r Recurrence) String() string {
+	switch r {
+	case RecurrenceOnce:
+		return "Once"
+	case RecurrenceDaily:
+		return "Daily"
+	case RecurrenceWeekly:
+		return "Weekly"
+	case RecurrenceMonthly:
+		return "Monthly"
+	default:
+		return "Unknown"
+	}
+}
+
+// ParseRecurrence returns the recurrence named name, ignoring case, such as "monthly" for
+// RecurrenceMonthly
+func ParseRecurrence(name string) (Recurrence, bool) {
+	for r := RecurrenceOnce; r <= RecurrenceMonthly; r++ {
+		if strings.EqualFold(r.String(), name) {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// ScheduleFailPolicy decides what happens to a schedule when an occurrence still fails after
+// its last retry
+type ScheduleFailPolicy uint16
+
+const (
+	// ScheduleFailSkip gives up on the occurrence and waits for the next one
+	ScheduleFailSkip ScheduleFailPolicy = iota
+	// ScheduleFailStop stops the schedule as ScheduleStatusFailed
+	ScheduleFailStop
+)
+
+func (p ScheduleFailPolicy) String() string {
+	switch p {
+	case ScheduleFailSkip:
+		return "Skip"
+	case ScheduleFailStop:
+		return "Stop"
+	default:
+		return "Unknown"
+	}
+}
+
+// ParseScheduleFailPolicy returns the failure policy named name, ignoring case, such as "skip"
+// for ScheduleFailSkip
+func ParseScheduleFailPolicy(name string) (ScheduleFailPolicy, bool) {
+	for p := ScheduleFailSkip; p <= ScheduleFailStop; p++ {
+		if strings.EqualFold(p.String(), name) {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// ScheduleStatus is the lifecycle state of a schedule. Only active schedules are run.
+type ScheduleStatus uint16
+
+const (
+	// ScheduleStatusActive runs its next occurrence at NextRunAt
+	ScheduleStatusActive ScheduleStatus = iota
+	// ScheduleStatusCompleted ran or skipped every occurrence
+	ScheduleStatusCompleted
+	// ScheduleStatusCancelled was cancelled by its owner or an admin
+	ScheduleStatusCancelled
+	// ScheduleStatusFailed was stopped by a failed run, see LastError
+	ScheduleStatusFailed
+)
+
+func (s ScheduleStatus) String() string {
+	switch s {
+	case ScheduleStatusActive:
+		return "Active"
+	case ScheduleStatusCompleted:
+		return "Completed"
+	case ScheduleStatusCancelled:
+		return "Cancelled"
+	case ScheduleStatusFailed:
+		return "Failed"
+	default:
+		return "Unknown"
+	}
+}
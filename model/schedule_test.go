@@ -0,0 +1,72 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleOccurrenceAt(t *testing.T) {
+	start := time.Date(2024, 1, 31, 9, 30, 0, 0, time.UTC)
+	end := time.Date(2024, 4, 30, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		schedule   Schedule
+		n          uint
+		expected   time.Time
+		expectedOK bool
+	}{
+		{name: "Once", schedule: Schedule{Recurrence: RecurrenceOnce, StartAt: start}, n: 0, expected: start, expectedOK: true},
+		{name: "Once has no second occurrence", schedule: Schedule{Recurrence: RecurrenceOnce, StartAt: start}, n: 1},
+		{name: "Daily", schedule: Schedule{Recurrence: RecurrenceDaily, StartAt: start}, n: 1, expected: time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC), expectedOK: true},
+		{name: "Weekly", schedule: Schedule{Recurrence: RecurrenceWeekly, StartAt: start}, n: 2, expected: time.Date(2024, 2, 14, 9, 30, 0, 0, time.UTC), expectedOK: true},
+		{name: "Monthly in a leap February", schedule: Schedule{Recurrence: RecurrenceMonthly, StartAt: start}, n: 1, expected: time.Date(2024, 2, 29, 9, 30, 0, 0, time.UTC), expectedOK: true},
+		{name: "Monthly keeps the start's day", schedule: Schedule{Recurrence: RecurrenceMonthly, StartAt: start}, n: 2, expected: time.Date(2024, 3, 31, 9, 30, 0, 0, time.UTC), expectedOK: true},
+		{name: "Monthly across the year", schedule: Schedule{Recurrence: RecurrenceMonthly, StartAt: start}, n: 13, expected: time.Date(2025, 2, 28, 9, 30, 0, 0, time.UTC), expectedOK: true},
+		{name: "On the end", schedule: Schedule{Recurrence: RecurrenceMonthly, StartAt: start, EndAt: &end}, n: 3, expected: end, expectedOK: true},
+		{name: "After the end", schedule: Schedule{Recurrence: RecurrenceMonthly, StartAt: start, EndAt: &end}, n: 4},
+		{name: "Unknown recurrence", schedule: Schedule{Recurrence: Recurrence(9), StartAt: start}, n: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, ok := tt.schedule.OccurrenceAt(tt.n)
+			assert.Equal(t, tt.expectedOK, ok)
+			assert.Equal(t, tt.expected, at)
+		})
+	}
+}
+
+func TestScheduleAdvance(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+	schedule := &Schedule{Recurrence: RecurrenceDaily, StartAt: start, EndAt: &end, NextRunAt: start.Add(time.Hour), Attempts: 2}
+
+	schedule.Advance()
+	assert.Equal(t, ScheduleStatusActive, schedule.Status)
+	assert.Equal(t, uint(1), schedule.Occurrence)
+	assert.Zero(t, schedule.Attempts)
+	assert.Equal(t, end, schedule.NextRunAt)
+
+	schedule.Advance()
+	assert.Equal(t, ScheduleStatusCompleted, schedule.Status)
+}
+
+func TestParseRecurrence(t *testing.T) {
+	recurrence, ok := ParseRecurrence("monthly")
+	assert.True(t, ok)
+	assert.Equal(t, RecurrenceMonthly, recurrence)
+
+	_, ok = ParseRecurrence("Hourly")
+	assert.False(t, ok)
+}
+
+func TestParseScheduleFailPolicy(t *testing.T) {
+	policy, ok := ParseScheduleFailPolicy("STOP")
+	assert.True(t, ok)
+	assert.Equal(t, ScheduleFailStop, policy)
+
+	_, ok = ParseScheduleFailPolicy("Retry")
+	assert.False(t, ok)
+}
@@ -1,13 +1,32 @@
 package model
 
-import "time"
+import (
+	"slices"
+	"strings"
+	"time"
+)
 
 type Transaction struct {
-	ID        uint            `gorm:"primaryKey" json:"id"`
-	UserID    uint            `json:"user_id"`
-	Type      TransactionType `json:"type"` // Deposit, Withdraw, Transfer
-	Amount    float64         `json:"amount"`
-	Timestamp time.Time       `gorm:"autoCreateTime" json:"timestamp"`
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	UserID         uint            `json:"user_id"`
+	Type           TransactionType `json:"type"`   // Deposit, Withdraw, Transfer, Correction, Reversal, Refund, Exchange, Capture, Fee, Interest, OverdraftFee, OverdraftInterest
+	Amount         Money           `json:"amount"` // Signed, negative when money leaves the wallet
+	Currency       Currency        `gorm:"size:3;not null;default:USD" json:"currency"`
+	JournalEntryID uint            `gorm:"index" json:"journal_entry_id"`
+	CounterpartyID uint            `json:"counterparty_id,omitempty"` // The other wallet of a transfer
+	// OriginalTransactionID is the transaction of the same wallet that a reversal or refund undoes
+	OriginalTransactionID uint `gorm:"index;default:null" json:"original_transaction_id,omitempty"`
+	TransactionReference
+	Timestamp time.Time `gorm:"autoCreateTime" json:"timestamp"`
+}
+
+// TransactionReference describes the business operation behind a transaction. Every history row
+// created by one journal entry carries the entry's reference.
+type TransactionReference struct {
+	TransferID        string `gorm:"index;size:36" json:"transfer_id,omitempty"` // Shared by both legs of a transfer
+	BatchID           string `gorm:"index;size:36" json:"batch_id,omitempty"`    // Shared by every transfer of a batch
+	Memo              string `gorm:"size:255" json:"memo,omitempty"`
+	ExternalReference string `gorm:"size:255" json:"external_reference,omitempty"` // e.g. an invoice number
 }
 
 type TransactionType uint16
@@ -17,6 +36,30 @@ const (
 	TransactionTypeWithdraw
 	TransactionTypeTransferSend
 	TransactionTypeTransferReceive
+	// TransactionTypeCorrection is an approved manual adjustment, see Adjustment
+	TransactionTypeCorrection
+	// TransactionTypeReversal undoes the whole of an earlier transaction
+	TransactionTypeReversal
+	// TransactionTypeRefund gives back part or all of an earlier transaction
+	TransactionTypeRefund
+	// TransactionTypeExchange is either leg of a conversion between two currencies of a wallet,
+	// see ExchangeQuote
+	TransactionTypeExchange
+	// TransactionTypeCapture is either leg of a payment to a merchant out of a hold, see Hold
+	TransactionTypeCapture
+	// TransactionTypeFee is a charge for a withdrawal or transfer, paid to the house account in
+	// the same journal entry as the operation, see the fees package
+	TransactionTypeFee
+	// TransactionTypeInterest is the interest a savings wallet earned over a month, paid by the
+	// house account, see InterestAccrual
+	TransactionTypeInterest
+	// TransactionTypeOverdraftFee is a charge for taking a wallet below zero on its credit line,
+	// paid to the house account in the same journal entry as the posting that did, see the
+	// overdraft package
+	TransactionTypeOverdraftFee
+	// TransactionTypeOverdraftInterest is the interest a wallet owed over a month for being
+	// overdrawn, paid to the house account, see InterestAccrual
+	TransactionTypeOverdraftInterest
 )
 
 func (t TransactionType) String() string {
@@ -29,7 +72,70 @@ func (t TransactionType) String() string {
 		return "TransferSend"
 	case TransactionTypeTransferReceive:
 		return "TransferReceive"
+	case TransactionTypeCorrection:
+		return "Correction"
+	case TransactionTypeReversal:
+		return "Reversal"
+	case TransactionTypeRefund:
+		return "Refund"
+	case TransactionTypeExchange:
+		return "Exchange"
+	case TransactionTypeCapture:
+		return "Capture"
+	case TransactionTypeFee:
+		return "Fee"
+	case TransactionTypeInterest:
+		return "Interest"
+	case TransactionTypeOverdraftFee:
+		return "OverdraftFee"
+	case TransactionTypeOverdraftInterest:
+		return "OverdraftInterest"
 	default:
 		return "Unknown"
 	}
 }
+
+// IsFee reports whether t charges for an operation rather than being the operation itself.
+// Refunds give back only what the operation moved, leaving its fees with the house account.
+func (t TransactionType) IsFee() bool {
+	return slices.Contains(FeeTypes(), t)
+}
+
+// FeeTypes returns the transaction types that IsFee reports as fees
+func FeeTypes() []TransactionType {
+	return []TransactionType{TransactionTypeFee, TransactionTypeOverdraftFee}
+}
+
+// ParseTransactionType returns the transaction type named name, ignoring case, such as
+// "deposit" for TransactionTypeDeposit
+func ParseTransactionType(name string) (TransactionType, bool) {
+	for t := TransactionTypeDeposit; t <= TransactionTypeOverdraftInterest; t++ {
+		if strings.EqualFold(t.String(), name) {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// TransactionQuery selects a page of a user's transaction history. Filters left at their zero
+// value do not restrict the result.
+type TransactionQuery struct {
+	UserID    uint
+	Currency  Currency // Every currency when empty
+	Types     []TransactionType
+	From      time.Time // Inclusive
+	To        time.Time // Exclusive
+	MinAmount *Money    // Inclusive, compared with the signed amount
+	MaxAmount *Money    // Inclusive, compared with the signed amount
+	Cursor    *TransactionCursor
+	Limit     int // Zero returns every matching transaction
+}
+
+// TransactionCursor is a position in a history ordered newest first, by timestamp and then by
+// ID so that transactions sharing a timestamp still have a stable order
+type TransactionCursor struct {
+	Timestamp time.Time
+	ID        uint
+	// Backward selects the transactions newer than the position instead of those older than it
+	Backward bool
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// User is someone who can log in. A user's wallet is the balance row with the same ID as its
+// user_id, so User.ID doubles as the wallet's user ID.
+type User struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Username     string    `gorm:"uniqueIndex;size:64;not null" json:"username"`
+	PasswordHash string    `gorm:"not null" json:"-"` // bcrypt hash, never the password itself
+	Role         UserRole  `gorm:"not null;default:0" json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UserRole decides which wallets a user may act on
+type UserRole uint16
+
+const (
+	// UserRoleCustomer may only act on its own wallet
+	UserRoleCustomer UserRole = iota
+	// UserRoleAdmin may act on every wallet and change account statuses
+	UserRoleAdmin
+)
+
+func (r UserRole) String() string {
+	switch r {
+	case UserRoleCustomer:
+		return "Customer"
+	case UserRoleAdmin:
+		return "Admin"
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,49 @@
+// Package overdraft prices the credit lines that let wallets spend below zero. A wallet whose
+// balance has an overdraft limit may go that far below zero; the ledger charges the Terms fee
+// whenever a posting takes it there, and the interest job accrues interest on the overdrawn
+// balance daily at the Terms rate and charges it once a month.
+package overdraft
+
+import (
+	"encoding/json"
+	"fmt"
+	"walletApp/model"
+)
+
+// Terms prices borrowing on a credit line in one currency. Terms with no field set are free.
+type Terms struct {
+	Fee             model.Money `json:"fee"`               // Charged each time a wallet goes below zero
+	RateBasisPoints int64       `json:"rate_basis_points"` // Annual interest on the overdrawn balance
+}
+
+// Policy holds the terms of credit lines in each currency. Currencies without terms are free, so
+// an empty policy charges nothing for going below zero.
+type Policy map[model.Currency]Terms
+
+// Terms returns the terms of credit lines in currency
+func (p Policy) Terms(currency model.Currency) Terms {
+	return p[currency]
+}
+
+// UnmarshalJSON decodes a policy keyed by currency, such as
+//
+//	{"USD": {"fee": "15.00", "rate_basis_points": 1800}}
+func (p *Policy) UnmarshalJSON(data []byte) error {
+	var currencies map[model.Currency]Terms
+	if err := json.Unmarshal(data, &currencies); err != nil {
+		return err
+	}
+	for currency, terms := range currencies {
+		if !currency.IsSupported() {
+			return fmt.Errorf("%w: %q", model.ErrUnsupportedCurrency, currency)
+		}
+		if terms.Fee.IsNegative() || !currency.Allows(terms.Fee) {
+			return fmt.Errorf("%s overdraft fee must not be negative and have at most %d decimal places", currency, currency.Decimals())
+		}
+		if terms.RateBasisPoints < 0 || terms.RateBasisPoints > model.BasisPoints {
+			return fmt.Errorf("%s overdraft rate must be between 0 and %d basis points", currency, model.BasisPoints)
+		}
+	}
+	*p = currencies
+	return nil
+}
@@ -0,0 +1,33 @@
+package overdraft
+
+import (
+	"encoding/json"
+	"testing"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalJSON(t *testing.T) {
+	var policy Policy
+	require.NoError(t, json.Unmarshal([]byte(`{"USD": {"fee": "5.00", "rate_basis_points": 2000}, "JPY": {"rate_basis_points": 1500}}`), &policy))
+	assert.Equal(t, Policy{
+		model.USD: {Fee: 500, RateBasisPoints: 2000},
+		model.JPY: {RateBasisPoints: 1500},
+	}, policy)
+
+	invalid := map[string]string{
+		"Unsupported currency": `{"XYZ": {"fee": "5.00"}}`,
+		"Negative fee":         `{"USD": {"fee": "-5.00"}}`,
+		"Fractional yen":       `{"JPY": {"fee": "5.50"}}`,
+		"Negative rate":        `{"USD": {"rate_basis_points": -1}}`,
+		"Over a whole":         `{"USD": {"rate_basis_points": 10001}}`,
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var policy Policy
+			assert.Error(t, json.Unmarshal([]byte(content), &policy))
+		})
+	}
+}
@@ -0,0 +1,686 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/proto/walletpb"
+	"walletApp/validation"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// walletServer implements walletpb.WalletServiceServer by delegating to the App's handlers
+type walletServer struct {
+	walletpb.UnimplementedWalletServiceServer
+	app *App
+}
+
+// NewGRPCServer returns a gRPC server with the wallet service registered. Calls other than
+// Register and Login are authenticated by the token in their "authorization" metadata.
+func (a *App) NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(a.authenticateUnary),
+		grpc.ChainStreamInterceptor(a.authenticateStream),
+	)
+	srv := grpc.NewServer(opts...)
+	walletpb.RegisterWalletServiceServer(srv, &walletServer{app: a})
+	return srv
+}
+
+// ServeGRPC serves the gRPC API on addr until ctx is cancelled, then stops gracefully
+func (a *App) ServeGRPC(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := a.NewGRPCServer()
+
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+	log.Printf("gRPC API listening on %s\n", addr)
+	return srv.Serve(listener)
+}
+
+// publicMethods can be called without a token
+var publicMethods = map[string]bool{
+	walletpb.WalletService_Register_FullMethodName: true,
+	walletpb.WalletService_Login_FullMethodName:    true,
+}
+
+// authenticateContext returns ctx with the caller identified by its bearer token attached
+func (a *App) authenticateContext(ctx context.Context) (context.Context, error) {
+	var token string
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+	if token == "" {
+		return nil, grpcError(fmt.Errorf("%w: missing bearer token", apperror.ErrUnauthenticated))
+	}
+	principal, err := a.UserHandler.Authenticate(token)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+func (a *App) authenticateUnary(ctx context.Context, request any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	if publicMethods[info.FullMethod] {
+		return next(ctx, request)
+	}
+	ctx, err := a.authenticateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return next(ctx, request)
+}
+
+func (a *App) authenticateStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, next grpc.StreamHandler) error {
+	ctx, err := a.authenticateContext(stream.Context())
+	if err != nil {
+		return err
+	}
+	return next(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticatedStream is a grpc.ServerStream whose context carries the caller
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *walletServer) Register(ctx context.Context, request *walletpb.RegisterRequest) (*walletpb.RegisterResponse, error) {
+	response, err := s.app.UserHandler.Register(ctx, &dto.RegisterRequest{
+		Username: request.GetUsername(),
+		Password: request.GetPassword(),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &walletpb.RegisterResponse{UserId: uint64(response.UserID), Username: response.Username}, nil
+}
+
+func (s *walletServer) Login(ctx context.Context, request *walletpb.LoginRequest) (*walletpb.LoginResponse, error) {
+	response, err := s.app.UserHandler.Login(ctx, &dto.LoginRequest{
+		Username: request.GetUsername(),
+		Password: request.GetPassword(),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &walletpb.LoginResponse{
+		Token:     response.Token,
+		ExpiresAt: timestamppb.New(response.ExpiresAt),
+		UserId:    uint64(response.UserID),
+		Role:      response.Role,
+	}, nil
+}
+
+func (s *walletServer) Deposit(ctx context.Context, request *walletpb.DepositRequest) (*walletpb.DepositResponse, error) {
+	amount, err := validation.ParseAmount("amount", request.GetAmount())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response, err := s.app.BalanceHandler.Deposit(ctx, &dto.DepositRequest{
+		UserID:         uint(request.GetUserId()),
+		Amount:         amount,
+		Currency:       currencyCode(request.GetCurrency()),
+		IdempotencyKey: request.GetIdempotencyKey(),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &walletpb.DepositResponse{Message: response.Message, Balance: response.Balance.String(), Currency: string(response.Currency)}, nil
+}
+
+func (s *walletServer) Withdraw(ctx context.Context, request *walletpb.WithdrawRequest) (*walletpb.WithdrawResponse, error) {
+	amount, err := validation.ParseAmount("amount", request.GetAmount())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response, err := s.app.BalanceHandler.Withdraw(ctx, &dto.WithdrawRequest{
+		UserID:         uint(request.GetUserId()),
+		Amount:         amount,
+		Currency:       currencyCode(request.GetCurrency()),
+		IdempotencyKey: request.GetIdempotencyKey(),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &walletpb.WithdrawResponse{Message: response.Message, Balance: response.Balance.String(), Currency: string(response.Currency), Fee: response.Fee.String()}, nil
+}
+
+func (s *walletServer) Transfer(ctx context.Context, request *walletpb.TransferRequest) (*walletpb.TransferResponse, error) {
+	amount, err := validation.ParseAmount("amount", request.GetAmount())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response, err := s.app.BalanceHandler.Transfer(ctx, &dto.TransferRequest{
+		FromUserID:        uint(request.GetFromUserId()),
+		ToUserID:          uint(request.GetToUserId()),
+		Amount:            amount,
+		Currency:          currencyCode(request.GetCurrency()),
+		ToCurrency:        currencyCode(request.GetToCurrency()),
+		Convert:           request.GetConvert(),
+		Memo:              request.GetMemo(),
+		ExternalReference: request.GetExternalReference(),
+		IdempotencyKey:    request.GetIdempotencyKey(),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &walletpb.TransferResponse{
+		Message:          response.Message,
+		SenderBalance:    response.Data["sender_balance"].String(),
+		RecipientBalance: response.Data["recipient_balance"].String(),
+		TransferId:       response.TransferID,
+		Currency:         string(response.Currency),
+		Conversion:       conversionToProto(response.Conversion),
+		Fee:              response.Fee.String(),
+	}, nil
+}
+
+func (s *walletServer) BatchTransfer(ctx context.Context, request *walletpb.BatchTransferRequest) (*walletpb.BatchTransferResponse, error) {
+	items := make([]dto.BatchTransferItem, 0, len(request.GetItems()))
+	for i, item := range request.GetItems() {
+		amount, err := validation.ParseAmount(fmt.Sprintf("items[%d].amount", i), item.GetAmount())
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		items = append(items, dto.BatchTransferItem{
+			ToUserID:          uint(item.GetToUserId()),
+			Amount:            amount,
+			Memo:              item.GetMemo(),
+			ExternalReference: item.GetExternalReference(),
+		})
+	}
+	response, err := s.app.BalanceHandler.BatchTransfer(ctx, &dto.BatchTransferRequest{
+		FromUserID:     uint(request.GetFromUserId()),
+		Currency:       currencyCode(request.GetCurrency()),
+		Items:          items,
+		BestEffort:     request.GetBestEffort(),
+		IdempotencyKey: request.GetIdempotencyKey(),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	results := make([]*walletpb.BatchTransferResult, 0, len(response.Items))
+	for _, item := range response.Items {
+		results = append(results, &walletpb.BatchTransferResult{
+			Index:      int32(item.Index),
+			ToUserId:   uint64(item.ToUserID),
+			Amount:     item.Amount.String(),
+			TransferId: item.TransferID,
+			Fee:        item.Fee.String(),
+			Error:      item.Error,
+		})
+	}
+	return &walletpb.BatchTransferResponse{
+		Success:   response.Success,
+		Message:   response.Message,
+		BatchId:   response.BatchID,
+		Currency:  string(response.Currency),
+		Total:     response.Total.String(),
+		Fees:      response.Fees.String(),
+		Succeeded: int32(response.Succeeded),
+		Failed:    int32(response.Failed),
+		Items:     results,
+	}, nil
+}
+
+func (s *walletServer) QuoteFee(ctx context.Context, request *walletpb.QuoteFeeRequest) (*walletpb.FeeQuote, error) {
+	amount, err := validation.ParseAmount("amount", request.GetAmount())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response, err := s.app.BalanceHandler.QuoteFee(ctx, &dto.FeeQuoteRequest{
+		Operation: request.GetOperation(),
+		Amount:    amount,
+		Currency:  currencyCode(request.GetCurrency()),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &walletpb.FeeQuote{
+		Operation: response.Operation,
+		Amount:    response.Amount.String(),
+		Currency:  string(response.Currency),
+		Fee:       response.Fee.String(),
+		Total:     response.Total.String(),
+	}, nil
+}
+
+func (s *walletServer) QuoteExchange(ctx context.Context, request *walletpb.QuoteExchangeRequest) (*walletpb.ExchangeQuote, error) {
+	amount, err := validation.ParseAmount("amount", request.GetAmount())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response, err := s.app.BalanceHandler.QuoteExchange(ctx, &dto.ExchangeQuoteRequest{
+		UserID:       uint(request.GetUserId()),
+		FromCurrency: currencyCode(request.GetFromCurrency()),
+		ToCurrency:   currencyCode(request.GetToCurrency()),
+		Amount:       amount,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return quoteToProto(response), nil
+}
+
+func (s *walletServer) Exchange(ctx context.Context, request *walletpb.ExchangeRequest) (*walletpb.ExchangeResponse, error) {
+	exchangeRequest := &dto.ExchangeRequest{
+		UserID:         uint(request.GetUserId()),
+		QuoteID:        request.GetQuoteId(),
+		FromCurrency:   currencyCode(request.GetFromCurrency()),
+		ToCurrency:     currencyCode(request.GetToCurrency()),
+		IdempotencyKey: request.GetIdempotencyKey(),
+	}
+	// An exchange at a quote takes its amount from the quote
+	if request.GetQuoteId() == "" || request.GetAmount() != "" {
+		amount, err := validation.ParseAmount("amount", request.GetAmount())
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		exchangeRequest.Amount = amount
+	}
+	response, err := s.app.BalanceHandler.Exchange(ctx, exchangeRequest)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &walletpb.ExchangeResponse{
+		Message:        response.Message,
+		Quote:          quoteToProto(&response.ExchangeQuoteResponse),
+		JournalEntryId: uint64(response.JournalEntryID),
+		FromBalance:    response.FromBalance.String(),
+		ToBalance:      response.ToBalance.String(),
+	}, nil
+}
+
+// quoteToProto converts an exchange quote to its gRPC representation
+func quoteToProto(quote *dto.ExchangeQuoteResponse) *walletpb.ExchangeQuote {
+	return &walletpb.ExchangeQuote{
+		QuoteId:      quote.QuoteID,
+		UserId:       uint64(quote.UserID),
+		FromCurrency: string(quote.FromCurrency),
+		Amount:       quote.Amount.String(),
+		Conversion:   conversionToProto(&quote.ConversionResponse),
+		ExpiresAt:    timestamppb.New(quote.ExpiresAt),
+	}
+}
+
+// conversionToProto converts the price of a conversion to its gRPC representation, nil for none
+func conversionToProto(conversion *dto.ConversionResponse) *walletpb.Conversion {
+	if conversion == nil {
+		return nil
+	}
+	return &walletpb.Conversion{
+		ToCurrency: string(conversion.ToCurrency),
+		ToAmount:   conversion.ToAmount.String(),
+		Rate:       conversion.Rate.String(),
+		Spread:     conversion.Spread.String(),
+	}
+}
+
+func (s *walletServer) ReverseTransaction(ctx context.Context, request *walletpb.ReverseTransactionRequest) (*walletpb.ReverseTransactionResponse, error) {
+	reverseRequest := &dto.ReverseRequest{
+		TransactionID:  uint(request.GetTransactionId()),
+		Reason:         request.GetReason(),
+		IdempotencyKey: request.GetIdempotencyKey(),
+	}
+	if request.GetAmount() != "" {
+		amount, err := validation.ParseAmount("amount", request.GetAmount())
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		reverseRequest.Amount = &amount
+	}
+	response, err := s.app.BalanceHandler.Reverse(ctx, reverseRequest)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	balances := make(map[uint64]string, len(response.Balances))
+	for userID, balance := range response.Balances {
+		balances[uint64(userID)] = balance.String()
+	}
+	return &walletpb.ReverseTransactionResponse{
+		Message:        response.Message,
+		Type:           response.Type,
+		Amount:         response.Amount.String(),
+		JournalEntryId: uint64(response.JournalEntryID),
+		Refundable:     response.Refundable.String(),
+		Balances:       balances,
+		Currency:       string(response.Currency),
+	}, nil
+}
+
+func (s *walletServer) AuthorizeHold(ctx context.Context, request *walletpb.AuthorizeHoldRequest) (*walletpb.Hold, error) {
+	amount, err := validation.ParseAmount("amount", request.GetAmount())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	authorizeRequest := &dto.AuthorizeHoldRequest{
+		UserID:            uint(request.GetUserId()),
+		MerchantID:        uint(request.GetMerchantId()),
+		Amount:            amount,
+		Currency:          currencyCode(request.GetCurrency()),
+		Memo:              request.GetMemo(),
+		ExternalReference: request.GetExternalReference(),
+		IdempotencyKey:    request.GetIdempotencyKey(),
+	}
+	if request.GetExpiresAt() != nil {
+		authorizeRequest.ExpiresAt = request.GetExpiresAt().AsTime()
+	}
+	response, err := s.app.BalanceHandler.AuthorizeHold(ctx, authorizeRequest)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return holdToProto(response), nil
+}
+
+func (s *walletServer) CaptureHold(ctx context.Context, request *walletpb.CaptureHoldRequest) (*walletpb.Hold, error) {
+	captureRequest := &dto.CaptureHoldRequest{
+		HoldID:         uint(request.GetHoldId()),
+		IdempotencyKey: request.GetIdempotencyKey(),
+	}
+	if request.GetAmount() != "" {
+		amount, err := validation.ParseAmount("amount", request.GetAmount())
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		captureRequest.Amount = &amount
+	}
+	response, err := s.app.BalanceHandler.CaptureHold(ctx, captureRequest)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return holdToProto(response), nil
+}
+
+func (s *walletServer) VoidHold(ctx context.Context, request *walletpb.HoldRequest) (*walletpb.Hold, error) {
+	response, err := s.app.BalanceHandler.VoidHold(ctx, &dto.HoldRequest{HoldID: uint(request.GetHoldId())})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return holdToProto(response), nil
+}
+
+func (s *walletServer) GetHold(ctx context.Context, request *walletpb.HoldRequest) (*walletpb.Hold, error) {
+	response, err := s.app.BalanceHandler.GetHold(ctx, &dto.HoldRequest{HoldID: uint(request.GetHoldId())})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return holdToProto(response), nil
+}
+
+// holdToProto converts a hold to its gRPC representation
+func holdToProto(hold *dto.HoldResponse) *walletpb.Hold {
+	return &walletpb.Hold{
+		Id:                uint64(hold.ID),
+		UserId:            uint64(hold.UserID),
+		MerchantId:        uint64(hold.MerchantID),
+		Amount:            hold.Amount.String(),
+		Currency:          string(hold.Currency),
+		CapturedAmount:    hold.CapturedAmount.String(),
+		Status:            hold.Status,
+		Memo:              hold.Memo,
+		ExternalReference: hold.ExternalReference,
+		ExpiresAt:         timestamppb.New(hold.ExpiresAt),
+		JournalEntryId:    uint64(hold.JournalEntryID),
+	}
+}
+
+func (s *walletServer) CreateSchedule(ctx context.Context, request *walletpb.CreateScheduleRequest) (*walletpb.Schedule, error) {
+	amount, err := validation.ParseAmount("amount", request.GetAmount())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	createRequest := &dto.CreateScheduleRequest{
+		FromUserID:        uint(request.GetFromUserId()),
+		ToUserID:          uint(request.GetToUserId()),
+		Amount:            amount,
+		Currency:          currencyCode(request.GetCurrency()),
+		Memo:              request.GetMemo(),
+		ExternalReference: request.GetExternalReference(),
+		Recurrence:        request.GetRecurrence(),
+		FailurePolicy:     request.GetFailurePolicy(),
+	}
+	if request.GetStartAt() != nil {
+		createRequest.StartAt = request.GetStartAt().AsTime()
+	}
+	if request.GetEndAt() != nil {
+		createRequest.EndAt = request.GetEndAt().AsTime()
+	}
+	response, err := s.app.ScheduleHandler.CreateSchedule(ctx, createRequest)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return scheduleToProto(response), nil
+}
+
+func (s *walletServer) ListSchedules(ctx context.Context, request *walletpb.ListSchedulesRequest) (*walletpb.ListSchedulesResponse, error) {
+	response, err := s.app.ScheduleHandler.ListSchedules(ctx, &dto.ListSchedulesRequest{UserID: uint(request.GetUserId())})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	schedules := make([]*walletpb.Schedule, 0, len(response.Schedules))
+	for _, schedule := range response.Schedules {
+		schedules = append(schedules, scheduleToProto(&schedule))
+	}
+	return &walletpb.ListSchedulesResponse{Schedules: schedules}, nil
+}
+
+func (s *walletServer) CancelSchedule(ctx context.Context, request *walletpb.ScheduleRequest) (*walletpb.Schedule, error) {
+	response, err := s.app.ScheduleHandler.CancelSchedule(ctx, &dto.ScheduleRequest{ScheduleID: uint(request.GetScheduleId())})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return scheduleToProto(response), nil
+}
+
+// scheduleToProto converts a schedule to its gRPC representation
+func scheduleToProto(schedule *dto.ScheduleResponse) *walletpb.Schedule {
+	converted := &walletpb.Schedule{
+		Id:                uint64(schedule.ID),
+		FromUserId:        uint64(schedule.FromUserID),
+		ToUserId:          uint64(schedule.ToUserID),
+		Amount:            schedule.Amount.String(),
+		Currency:          string(schedule.Currency),
+		Memo:              schedule.Memo,
+		ExternalReference: schedule.ExternalReference,
+		Recurrence:        schedule.Recurrence,
+		StartAt:           timestamppb.New(schedule.StartAt),
+		FailurePolicy:     schedule.FailurePolicy,
+		Status:            schedule.Status,
+		NextRunAt:         timestamppb.New(schedule.NextRunAt),
+		Attempts:          uint32(schedule.Attempts),
+		LastTransferId:    schedule.LastTransferID,
+		LastError:         schedule.LastError,
+	}
+	if !schedule.EndAt.IsZero() {
+		converted.EndAt = timestamppb.New(schedule.EndAt)
+	}
+	return converted
+}
+
+func (s *walletServer) GetBalance(ctx context.Context, request *walletpb.GetBalanceRequest) (*walletpb.GetBalanceResponse, error) {
+	balance, err := s.app.BalanceHandler.CheckBalance(ctx, uint(request.GetUserId()), currencyCode(request.GetCurrency()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &walletpb.GetBalanceResponse{
+		UserId:         uint64(balance.UserID),
+		Balance:        balance.Balance.String(),
+		Currency:       string(balance.Currency),
+		Available:      balance.Available.String(),
+		OverdraftLimit: balance.OverdraftLimit.String(),
+	}, nil
+}
+
+func (s *walletServer) ListTransactions(ctx context.Context, request *walletpb.ListTransactionsRequest) (*walletpb.ListTransactionsResponse, error) {
+	historyRequest, err := historyRequestFromProto(request)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	response, err := s.app.TransactionHandler.ViewTransactionHistory(ctx, historyRequest)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	transactions := make([]*walletpb.Transaction, 0, len(response.Transactions))
+	for _, transaction := range response.Transactions {
+		transactions = append(transactions, transactionToProto(transaction))
+	}
+	return &walletpb.ListTransactionsResponse{
+		Transactions:  transactions,
+		NextPageToken: response.NextCursor,
+		PrevPageToken: response.PrevCursor,
+	}, nil
+}
+
+func (s *walletServer) StreamTransactions(request *walletpb.ListTransactionsRequest, stream walletpb.WalletService_StreamTransactionsServer) error {
+	historyRequest, err := historyRequestFromProto(request)
+	if err != nil {
+		return grpcError(err)
+	}
+	// Page through the history so that only one page is held in memory at a time
+	for {
+		response, err := s.app.TransactionHandler.ViewTransactionHistory(stream.Context(), historyRequest)
+		if err != nil {
+			return grpcError(err)
+		}
+		for _, transaction := range response.Transactions {
+			if err := stream.Send(transactionToProto(transaction)); err != nil {
+				return err
+			}
+		}
+		if response.NextCursor == "" {
+			return nil
+		}
+		historyRequest.Cursor = response.NextCursor
+	}
+}
+
+// historyRequestFromProto converts the filters and page of a ListTransactionsRequest
+func historyRequestFromProto(request *walletpb.ListTransactionsRequest) (*dto.TransactionHistoryRequest, error) {
+	historyRequest := &dto.TransactionHistoryRequest{
+		UserID:   uint(request.GetUserId()),
+		Currency: currencyCode(request.GetCurrency()),
+		Types:    request.GetTypes(),
+		Cursor:   request.GetPageToken(),
+		Limit:    int(request.GetPageSize()),
+	}
+	if request.From != nil {
+		historyRequest.From = request.GetFrom().AsTime()
+	}
+	if request.To != nil {
+		historyRequest.To = request.GetTo().AsTime()
+	}
+	for _, bound := range []struct {
+		field string
+		text  string
+		into  **model.Money
+	}{
+		{"min_amount", request.GetMinAmount(), &historyRequest.MinAmount},
+		{"max_amount", request.GetMaxAmount(), &historyRequest.MaxAmount},
+	} {
+		if bound.text == "" {
+			continue
+		}
+		amount, err := validation.ParseSignedAmount(bound.field, bound.text)
+		if err != nil {
+			return nil, err
+		}
+		*bound.into = &amount
+	}
+	return historyRequest, nil
+}
+
+func transactionToProto(transaction model.Transaction) *walletpb.Transaction {
+	return &walletpb.Transaction{
+		Id:                    uint64(transaction.ID),
+		UserId:                uint64(transaction.UserID),
+		Type:                  transaction.Type.String(),
+		Amount:                transaction.Amount.String(),
+		JournalEntryId:        uint64(transaction.JournalEntryID),
+		Timestamp:             timestamppb.New(transaction.Timestamp),
+		CounterpartyUserId:    uint64(transaction.CounterpartyID),
+		TransferId:            transaction.TransferID,
+		BatchId:               transaction.BatchID,
+		Memo:                  transaction.Memo,
+		ExternalReference:     transaction.ExternalReference,
+		OriginalTransactionId: uint64(transaction.OriginalTransactionID),
+		Currency:              string(transaction.Currency),
+	}
+}
+
+// grpcError maps an error returned by a handler to a gRPC status, the counterpart of
+// errorResponse in the REST API. Validation errors carry their fields as BadRequest details.
+func grpcError(err error) error {
+	var fieldErrors validation.Errors
+	if errors.As(err, &fieldErrors) {
+		st := status.New(codes.InvalidArgument, err.Error())
+		details := &errdetails.BadRequest{}
+		for _, fieldError := range fieldErrors {
+			details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fieldError.Field,
+				Description: fieldError.Message,
+			})
+		}
+		if withDetails, detailsErr := st.WithDetails(details); detailsErr == nil {
+			st = withDetails
+		}
+		return st.Err()
+	}
+	var limitErr *apperror.LimitExceededError
+	if errors.As(err, &limitErr) {
+		st := status.New(codes.ResourceExhausted, err.Error())
+		details := &errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     fmt.Sprintf("user:%d", limitErr.UserID),
+			Description: fmt.Sprintf("%s %s %s limit, %s remaining", limitErr.Period, limitErr.Currency, limitErr.Operation, model.Money(limitErr.Remaining)),
+		}}}
+		if withDetails, detailsErr := st.WithDetails(details); detailsErr == nil {
+			st = withDetails
+		}
+		return st.Err()
+	}
+
+	switch {
+	case errors.Is(err, apperror.ErrInvalidRequest):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, apperror.ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, apperror.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, apperror.ErrAccountNotFound), errors.Is(err, apperror.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, apperror.ErrInsufficientFunds), errors.Is(err, apperror.ErrAccountFrozen), errors.Is(err, apperror.ErrAccountClosed):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, apperror.ErrConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, apperror.ErrRateUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, apperror.ErrStorage):
+		log.Printf("Storage error serving gRPC request: %v\n", err)
+		return status.Error(codes.Unavailable, "the wallet service is temporarily unavailable")
+	default:
+		log.Printf("Unexpected error serving gRPC request: %v\n", err)
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
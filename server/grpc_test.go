@@ -0,0 +1,468 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/fees"
+	"walletApp/limits"
+	"walletApp/model"
+	"walletApp/proto/walletpb"
+	"walletApp/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newBufconnClient serves app's gRPC API over an in-memory listener and returns a client for it
+func newBufconnClient(t *testing.T, app *App) walletpb.WalletServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	srv := app.NewGRPCServer()
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return walletpb.NewWalletServiceClient(conn)
+}
+
+// callerContext returns a context whose calls carry a token for principal, as returned by Login
+func callerContext(t *testing.T, app *App, principal auth.Principal) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+bearerToken(t, app, principal))
+}
+
+func TestGRPCDeposit(t *testing.T) {
+	tests := []struct {
+		name            string
+		request         *walletpb.DepositRequest
+		balanceMocks    func(m *mock.Mock)
+		expectedCode    codes.Code
+		expectedBalance string
+	}{
+		{
+			name:    "Successful deposit",
+			request: &walletpb.DepositRequest{UserId: 1, Amount: "12.50"},
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Balance: 1000, Version: 1}, nil)
+				m.On("GetBalanceForUpdate", mock.Anything, model.SystemAccountCashIn, model.USD).Return(&model.Balance{UserID: model.SystemAccountCashIn}, nil)
+				m.On("UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			expectedCode:    codes.OK,
+			expectedBalance: "22.50",
+		},
+		{
+			name:         "Amount with too many decimals",
+			request:      &walletpb.DepositRequest{UserId: 1, Amount: "1.005"},
+			balanceMocks: func(m *mock.Mock) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:    "Unknown account",
+			request: &walletpb.DepositRequest{UserId: 9, Amount: "1"},
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceForUpdate", mock.Anything, uint(9), model.USD).Return(nil, &apperror.AccountNotFoundError{UserID: 9})
+			},
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(tt.balanceMocks, func(m *mock.Mock) {
+				m.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil)
+			})
+			client := newBufconnClient(t, app)
+
+			response, err := client.Deposit(callerContext(t, app, testAdmin), tt.request)
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expectedCode == codes.OK {
+				assert.Equal(t, tt.expectedBalance, response.GetBalance())
+			}
+		})
+	}
+}
+
+func TestGRPCTransferErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      *walletpb.TransferRequest
+		balanceMocks func(m *mock.Mock)
+		expectedCode codes.Code
+	}{
+		{
+			name:    "Insufficient funds",
+			request: &walletpb.TransferRequest{FromUserId: 1, ToUserId: 2, Amount: "50"},
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Balance: 1000, Version: 1}, nil)
+				m.On("GetBalanceForUpdate", mock.Anything, uint(2), model.USD).Return(&model.Balance{UserID: 2, Version: 1}, nil)
+			},
+			expectedCode: codes.FailedPrecondition,
+		},
+		{
+			name:    "Database unavailable",
+			request: &walletpb.TransferRequest{FromUserId: 1, ToUserId: 2, Amount: "5"},
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(nil, errors.Join(apperror.ErrStorage, errors.New("connection refused")))
+			},
+			expectedCode: codes.Unavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(tt.balanceMocks, func(m *mock.Mock) {})
+			client := newBufconnClient(t, app)
+
+			_, err := client.Transfer(callerContext(t, app, testAdmin), tt.request)
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestGRPCBatchTransfer(t *testing.T) {
+	app := newTestApp(func(m *mock.Mock) {
+		m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Balance: 10000, Version: 1}, nil)
+		m.On("GetBalanceForUpdate", mock.Anything, uint(2), model.USD).Return(&model.Balance{UserID: 2, Version: 1}, nil)
+		m.On("GetBalanceForUpdate", mock.Anything, uint(3), model.USD).Return(&model.Balance{UserID: 3, Status: model.AccountStatusFrozen, Version: 1}, nil)
+		m.On("GetBalanceForUpdate", mock.Anything, model.SystemAccountHouse, model.USD).Return(&model.Balance{UserID: model.SystemAccountHouse}, nil)
+		m.On("UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	}, func(m *mock.Mock) {
+		m.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil)
+	})
+	client := newBufconnClient(t, app)
+	ctx := callerContext(t, app, auth.Principal{UserID: 1})
+
+	response, err := client.BatchTransfer(ctx, &walletpb.BatchTransferRequest{FromUserId: 1, BestEffort: true, Items: []*walletpb.BatchTransferItem{
+		{ToUserId: 2, Amount: "20", Memo: "March payout"},
+		{ToUserId: 3, Amount: "10"},
+	}})
+	require.NoError(t, err)
+	assert.False(t, response.GetSuccess())
+	assert.Equal(t, int32(1), response.GetSucceeded())
+	assert.Equal(t, int32(1), response.GetFailed())
+	assert.Equal(t, "20.00", response.GetTotal())
+	assert.NotEmpty(t, response.GetBatchId())
+	require.Len(t, response.GetItems(), 2)
+	assert.NotEmpty(t, response.GetItems()[0].GetTransferId())
+	assert.Equal(t, int32(1), response.GetItems()[1].GetIndex())
+	assert.Contains(t, response.GetItems()[1].GetError(), "frozen")
+
+	_, err = client.BatchTransfer(ctx, &walletpb.BatchTransferRequest{FromUserId: 1, Items: []*walletpb.BatchTransferItem{{ToUserId: 2, Amount: "ten"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCReverseTransactionErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		request          *walletpb.ReverseTransactionRequest
+		transactionMocks func(m *mock.Mock)
+		expectedCode     codes.Code
+	}{
+		{
+			name:    "Unknown transaction",
+			request: &walletpb.ReverseTransactionRequest{TransactionId: 9, Reason: "sent to the wrong wallet"},
+			transactionMocks: func(m *mock.Mock) {
+				m.On("GetTransaction", mock.Anything, uint(9)).Return(nil, fmt.Errorf("%w: transaction 9", apperror.ErrNotFound))
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:             "Refund of malformed amount",
+			request:          &walletpb.ReverseTransactionRequest{TransactionId: 9, Amount: "1.234", Reason: "returned"},
+			transactionMocks: func(m *mock.Mock) {},
+			expectedCode:     codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(func(m *mock.Mock) {}, tt.transactionMocks)
+			client := newBufconnClient(t, app)
+
+			_, err := client.ReverseTransaction(callerContext(t, app, testAdmin), tt.request)
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestGRPCFieldViolations(t *testing.T) {
+	app := newTestApp(func(m *mock.Mock) {}, func(m *mock.Mock) {})
+	client := newBufconnClient(t, app)
+
+	_, err := client.Transfer(callerContext(t, app, testAdmin), &walletpb.TransferRequest{FromUserId: 1, ToUserId: 1, Amount: "5"})
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	assert.Equal(t, "to_user_id", badRequest.GetFieldViolations()[0].GetField())
+}
+
+func TestGRPCLimitExceeded(t *testing.T) {
+	app := newTestApp(func(m *mock.Mock) {
+		m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Balance: 10000, Version: 1}, nil)
+		m.On("GetBalanceForUpdate", mock.Anything, model.SystemAccountCashOut, model.USD).Return(&model.Balance{UserID: model.SystemAccountCashOut}, nil)
+	}, func(m *mock.Mock) {})
+	app.BalanceHandler.Limits = limits.Policy{model.AccountTierStandard: {model.TransactionTypeWithdraw: {model.USD: {PerTransaction: 500}}}}
+	client := newBufconnClient(t, app)
+
+	_, err := client.Withdraw(callerContext(t, app, testAdmin), &walletpb.WithdrawRequest{UserId: 1, Amount: "10"})
+	st := status.Convert(err)
+	require.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Len(t, st.Details(), 1)
+	quotaFailure, ok := st.Details()[0].(*errdetails.QuotaFailure)
+	require.True(t, ok)
+	assert.Equal(t, "user:1", quotaFailure.GetViolations()[0].GetSubject())
+	assert.Equal(t, "transaction USD Withdraw limit, 5.00 remaining", quotaFailure.GetViolations()[0].GetDescription())
+}
+
+func TestGRPCFees(t *testing.T) {
+	app := newTestApp(func(m *mock.Mock) {
+		m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Balance: 10000, Version: 1}, nil)
+		m.On("GetBalanceForUpdate", mock.Anything, model.SystemAccountCashOut, model.USD).Return(&model.Balance{UserID: model.SystemAccountCashOut}, nil)
+		m.On("GetBalanceForUpdate", mock.Anything, model.SystemAccountHouse, model.USD).Return(&model.Balance{UserID: model.SystemAccountHouse}, nil)
+		m.On("UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	}, func(m *mock.Mock) {
+		m.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil)
+	})
+	app.BalanceHandler.Fees = fees.Schedule{model.TransactionTypeWithdraw: {model.USD: {Flat: 100, BasisPoints: 100}}}
+	client := newBufconnClient(t, app)
+	ctx := callerContext(t, app, testAdmin)
+
+	quote, err := client.QuoteFee(ctx, &walletpb.QuoteFeeRequest{Operation: "Withdraw", Amount: "50"})
+	require.NoError(t, err)
+	assert.Equal(t, "Withdraw", quote.GetOperation())
+	assert.Equal(t, "1.50", quote.GetFee())
+	assert.Equal(t, "51.50", quote.GetTotal())
+
+	withdrawn, err := client.Withdraw(ctx, &walletpb.WithdrawRequest{UserId: 1, Amount: "50"})
+	require.NoError(t, err)
+	assert.Equal(t, "1.50", withdrawn.GetFee())
+	assert.Equal(t, "48.50", withdrawn.GetBalance())
+}
+
+func TestGRPCExchange(t *testing.T) {
+	app := newTestApp(func(m *mock.Mock) {
+		m.On("GetBalanceRecord", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Currency: model.USD}, nil)
+		m.On("GetBalanceRecord", mock.Anything, uint(1), model.EUR).Return(&model.Balance{UserID: 1, Currency: model.EUR}, nil)
+	}, func(m *mock.Mock) {})
+	app.BalanceHandler.ExchangeRepo = storage.NewMockExchangeRepository(func(m *mock.Mock) {
+		m.On("CreateQuote", mock.Anything, mock.Anything).Return(nil)
+		m.On("GetQuote", mock.Anything, "2f1c6a3e-8b7d-4c1e-9a0b-5d6e7f8a9b0c").Return(&model.ExchangeQuote{ID: "2f1c6a3e-8b7d-4c1e-9a0b-5d6e7f8a9b0c", UserID: 2}, nil)
+	})
+	client := newBufconnClient(t, app)
+	ctx := callerContext(t, app, testAdmin)
+
+	quote, err := client.QuoteExchange(ctx, &walletpb.QuoteExchangeRequest{UserId: 1, FromCurrency: "usd", ToCurrency: "EUR", Amount: "11"})
+	require.NoError(t, err)
+	assert.Equal(t, "11.00", quote.GetAmount())
+	assert.Equal(t, "9.99", quote.GetConversion().GetToAmount())
+	assert.Equal(t, "0.9090909", quote.GetConversion().GetRate())
+	assert.NotEmpty(t, quote.GetQuoteId())
+
+	// The amount of an exchange at a quote comes from the quote, which belongs to another user
+	_, err = client.Exchange(ctx, &walletpb.ExchangeRequest{UserId: 1, QuoteId: "2f1c6a3e-8b7d-4c1e-9a0b-5d6e7f8a9b0c"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCHolds(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	app := newTestApp(func(m *mock.Mock) {
+		m.On("GetBalanceRecord", mock.Anything, uint(2), model.USD).Return(&model.Balance{UserID: 2, Currency: model.USD, Product: model.AccountProductMerchant}, nil)
+		m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Currency: model.USD, Balance: 5000, Version: 1}, nil).Once()
+		m.On("UpdateHeld", mock.Anything, uint(1), model.USD, model.Money(1000), uint(1)).Return(nil)
+		// Capturing part of the hold releases all of it as it pays the merchant
+		m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Currency: model.USD, Balance: 5000, Held: 1000, Version: 2}, nil)
+		m.On("GetBalanceForUpdate", mock.Anything, uint(2), model.USD).Return(&model.Balance{UserID: 2, Currency: model.USD, Version: 1}, nil)
+		m.On("UpdateHeld", mock.Anything, uint(1), model.USD, model.Money(0), uint(2)).Return(nil)
+		m.On("UpdateBalance", mock.Anything, uint(1), model.USD, model.Money(4500), uint(3)).Return(nil)
+		m.On("UpdateBalance", mock.Anything, uint(2), model.USD, model.Money(500), uint(1)).Return(nil)
+	}, func(m *mock.Mock) {
+		m.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil)
+	})
+	hold := &model.Hold{ID: 9, UserID: 1, MerchantID: 2, Amount: 1000, Currency: model.USD, Status: model.HoldStatusAuthorized, ExpiresAt: expiresAt}
+	app.BalanceHandler.HoldRepo = storage.NewMockHoldRepository(func(m *mock.Mock) {
+		m.On("CreateHold", mock.Anything, mock.Anything).Run(func(args mock.Arguments) { args.Get(1).(*model.Hold).ID = 9 }).Return(nil)
+		m.On("GetHold", mock.Anything, uint(9)).Return(hold, nil)
+		m.On("ResolveHold", mock.Anything, mock.Anything).Return(nil)
+	})
+	client := newBufconnClient(t, app)
+
+	authorized, err := client.AuthorizeHold(callerContext(t, app, auth.Principal{UserID: 1}), &walletpb.AuthorizeHoldRequest{UserId: 1, MerchantId: 2, Amount: "10", ExpiresAt: timestamppb.New(expiresAt)})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(9), authorized.GetId())
+	assert.Equal(t, "10.00", authorized.GetAmount())
+	assert.Equal(t, "Authorized", authorized.GetStatus())
+	assert.Equal(t, expiresAt, authorized.GetExpiresAt().AsTime())
+
+	_, err = client.CaptureHold(callerContext(t, app, auth.Principal{UserID: 1}), &walletpb.CaptureHoldRequest{HoldId: 9})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.CaptureHold(callerContext(t, app, auth.Principal{UserID: 2}), &walletpb.CaptureHoldRequest{HoldId: 9, Amount: "abc"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	captured, err := client.CaptureHold(callerContext(t, app, auth.Principal{UserID: 2}), &walletpb.CaptureHoldRequest{HoldId: 9, Amount: "5"})
+	require.NoError(t, err)
+	assert.Equal(t, "Captured", captured.GetStatus())
+	assert.Equal(t, "5.00", captured.GetCapturedAmount())
+}
+
+func TestGRPCSchedules(t *testing.T) {
+	startAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	app := newTestApp(func(m *mock.Mock) {
+		m.On("GetBalanceRecord", mock.Anything, mock.Anything, model.USD).Return(&model.Balance{Currency: model.USD}, nil)
+	}, func(m *mock.Mock) {})
+	schedule := &model.Schedule{ID: 4, FromUserID: 1, ToUserID: 2, Amount: 1500, Currency: model.USD, Recurrence: model.RecurrenceWeekly, StartAt: startAt, NextRunAt: startAt}
+	app.ScheduleHandler.ScheduleRepo = storage.NewMockScheduleRepository(func(m *mock.Mock) {
+		m.On("CreateSchedule", mock.Anything, mock.Anything).Run(func(args mock.Arguments) { args.Get(1).(*model.Schedule).ID = 4 }).Return(nil)
+		m.On("GetSchedulesByUserID", mock.Anything, uint(1)).Return([]model.Schedule{*schedule}, nil)
+		m.On("GetSchedule", mock.Anything, uint(4)).Return(schedule, nil)
+		m.On("UpdateSchedule", mock.Anything, mock.Anything).Return(nil)
+	})
+	client := newBufconnClient(t, app)
+	ctx := callerContext(t, app, auth.Principal{UserID: 1})
+
+	created, err := client.CreateSchedule(ctx, &walletpb.CreateScheduleRequest{FromUserId: 1, ToUserId: 2, Amount: "15", Recurrence: "weekly", StartAt: timestamppb.New(startAt)})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), created.GetId())
+	assert.Equal(t, "15.00", created.GetAmount())
+	assert.Equal(t, "Weekly", created.GetRecurrence())
+	assert.Equal(t, startAt, created.GetNextRunAt().AsTime())
+	assert.Nil(t, created.GetEndAt())
+	_, err = client.CreateSchedule(ctx, &walletpb.CreateScheduleRequest{FromUserId: 1, ToUserId: 2, Amount: "15", Recurrence: "Hourly"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	list, err := client.ListSchedules(ctx, &walletpb.ListSchedulesRequest{UserId: 1})
+	require.NoError(t, err)
+	require.Len(t, list.GetSchedules(), 1)
+	assert.Equal(t, "Active", list.GetSchedules()[0].GetStatus())
+
+	_, err = client.CancelSchedule(callerContext(t, app, auth.Principal{UserID: 2}), &walletpb.ScheduleRequest{ScheduleId: 4})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	cancelled, err := client.CancelSchedule(ctx, &walletpb.ScheduleRequest{ScheduleId: 4})
+	require.NoError(t, err)
+	assert.Equal(t, "Cancelled", cancelled.GetStatus())
+}
+
+func TestGRPCGetBalance(t *testing.T) {
+	app := newTestApp(func(m *mock.Mock) {
+		m.On("GetBalanceRecord", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Currency: model.USD, Balance: 1234, Held: 234}, nil)
+		m.On("GetBalanceRecord", mock.Anything, uint(1), model.EUR).Return(&model.Balance{UserID: 1, Currency: model.EUR, Balance: 99}, nil)
+	}, func(m *mock.Mock) {})
+	client := newBufconnClient(t, app)
+
+	response, err := client.GetBalance(callerContext(t, app, testAdmin), &walletpb.GetBalanceRequest{UserId: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "12.34", response.GetBalance())
+	assert.Equal(t, "10.00", response.GetAvailable())
+	assert.Equal(t, "USD", response.GetCurrency())
+
+	response, err = client.GetBalance(callerContext(t, app, testAdmin), &walletpb.GetBalanceRequest{UserId: 1, Currency: "eur"})
+	require.NoError(t, err)
+	assert.Equal(t, "0.99", response.GetBalance())
+	assert.Equal(t, "EUR", response.GetCurrency())
+}
+
+func TestGRPCTransactionHistory(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	transactions := []model.Transaction{
+		{ID: 2, UserID: 1, Type: model.TransactionTypeWithdraw, Amount: -500, JournalEntryID: 8, Timestamp: timestamp},
+		{ID: 1, UserID: 1, Type: model.TransactionTypeDeposit, Amount: 1000, JournalEntryID: 7, Timestamp: timestamp},
+	}
+	minAmount := model.Money(-1000)
+	app := newTestApp(func(m *mock.Mock) {}, func(m *mock.Mock) {
+		m.On("ListTransactions", mock.Anything, model.TransactionQuery{
+			UserID:    1,
+			Types:     []model.TransactionType{model.TransactionTypeWithdraw, model.TransactionTypeDeposit},
+			From:      timestamp.Add(-time.Hour),
+			MinAmount: &minAmount,
+			Limit:     21,
+		}).Return(transactions, nil)
+		// Streaming one transaction per page follows the cursor after the first page
+		m.On("ListTransactions", mock.Anything, mock.MatchedBy(func(query model.TransactionQuery) bool {
+			return query.Limit == 2 && query.Cursor == nil
+		})).Return(transactions, nil)
+		m.On("ListTransactions", mock.Anything, mock.MatchedBy(func(query model.TransactionQuery) bool {
+			return query.Limit == 2 && query.Cursor != nil && query.Cursor.ID == 2 && !query.Cursor.Backward
+		})).Return(transactions[1:], nil)
+	})
+	client := newBufconnClient(t, app)
+
+	listed, err := client.ListTransactions(callerContext(t, app, testAdmin), &walletpb.ListTransactionsRequest{
+		UserId:    1,
+		Types:     []string{"Withdraw", "Deposit"},
+		From:      timestamppb.New(timestamp.Add(-time.Hour)),
+		MinAmount: "-10",
+	})
+	require.NoError(t, err)
+	require.Len(t, listed.GetTransactions(), 2)
+	assert.Empty(t, listed.GetNextPageToken())
+	assert.Equal(t, "-5.00", listed.GetTransactions()[0].GetAmount())
+	assert.Equal(t, "Withdraw", listed.GetTransactions()[0].GetType())
+	assert.Equal(t, timestamp, listed.GetTransactions()[0].GetTimestamp().AsTime())
+
+	stream, err := client.StreamTransactions(callerContext(t, app, testAdmin), &walletpb.ListTransactionsRequest{UserId: 1, PageSize: 1})
+	require.NoError(t, err)
+	var streamed []uint64
+	for {
+		transaction, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		streamed = append(streamed, transaction.GetId())
+	}
+	assert.Equal(t, []uint64{2, 1}, streamed)
+}
+
+func TestGRPCStreamTransactionsError(t *testing.T) {
+	app := newTestApp(func(m *mock.Mock) {}, func(m *mock.Mock) {})
+	client := newBufconnClient(t, app)
+
+	stream, err := client.StreamTransactions(callerContext(t, app, testAdmin), &walletpb.ListTransactionsRequest{UserId: 0})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCAuthentication(t *testing.T) {
+	app := newTestApp(func(m *mock.Mock) {}, func(m *mock.Mock) {})
+	app.UserHandler.UserRepo = storage.NewMockUserRepository(func(m *mock.Mock) {
+		m.On("GetUserByUsername", mock.Anything, "mallory").Return(nil, nil)
+	})
+	client := newBufconnClient(t, app)
+
+	_, err := client.GetBalance(context.Background(), &walletpb.GetBalanceRequest{UserId: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "call without a token")
+
+	stream, err := client.StreamTransactions(context.Background(), &walletpb.ListTransactionsRequest{UserId: 1})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "stream without a token")
+
+	_, err = client.GetBalance(callerContext(t, app, auth.Principal{UserID: 2}), &walletpb.GetBalanceRequest{UserId: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "customer reading another wallet")
+
+	_, err = client.Login(context.Background(), &walletpb.LoginRequest{Username: "mallory", Password: "password123"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "login is public but checks the password")
+}
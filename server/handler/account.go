@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/config"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/storage"
+	"walletApp/validation"
+)
+
+type AccountHandler struct {
+	BalanceRepo storage.BalanceRepository
+	UnitOfWork  storage.UnitOfWork
+	// InterestRepo and ScheduleRepo are read when closing a wallet, which must not leave interest
+	// to settle or transfers to run
+	InterestRepo storage.InterestRepository
+	ScheduleRepo storage.ScheduleRepository
+	// Now returns the current time, which decides which interest has accrued. time.Now is used
+	// when nil.
+	Now func() time.Time
+}
+
+// NewAccountHandler creates a new instance of AccountHandler
+func NewAccountHandler() *AccountHandler {
+	return &AccountHandler{
+		BalanceRepo:  storage.NewBalanceRepository(config.DB),
+		UnitOfWork:   storage.NewUnitOfWork(config.DB),
+		InterestRepo: storage.NewInterestRepository(config.DB),
+		ScheduleRepo: storage.NewScheduleRepository(config.DB),
+	}
+}
+
+// now returns the current time according to the handler's clock
+func (c *AccountHandler) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// GetAccount returns the status of the user's wallet and its balance in each currency
+func (c *AccountHandler) GetAccount(ctx context.Context, request *dto.AccountRequest) (*dto.AccountResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.Authorize(ctx, request.UserID); err != nil {
+		return nil, err
+	}
+	balances, err := c.BalanceRepo.GetBalances(ctx, request.UserID)
+	if err != nil {
+		log.Printf("Error fetching account for user %d: %v\n", request.UserID, err)
+		return nil, fmt.Errorf("failed to fetch account for user %d: %w", request.UserID, err)
+	}
+	return accountResponse(balances), nil
+}
+
+// OpenAccount opens the user's wallet in the requested currency with a zero balance. For a user
+// without a wallet this creates an active one; otherwise it adds the currency to the existing
+// wallet, which keeps its status. Registering a user already opens its wallet in
+// model.DefaultCurrency, so only admins open accounts.
+func (c *AccountHandler) OpenAccount(ctx context.Context, request *dto.AccountRequest) (*dto.AccountResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	currency := model.CurrencyOrDefault(request.Currency)
+	var balances []model.Balance
+	err := c.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		var err error
+		// Lock the existing currencies so that the wallet status cannot change meanwhile
+		balances, err = c.BalanceRepo.GetBalancesForUpdate(ctx, request.UserID)
+		if err != nil && !errors.Is(err, apperror.ErrAccountNotFound) {
+			return err
+		}
+		balance := model.Balance{UserID: request.UserID, Currency: currency, Status: model.AccountStatusActive}
+		for _, existing := range balances {
+			if existing.Currency == currency {
+				return fmt.Errorf("%w: user %d already has a %s account", apperror.ErrConflict, request.UserID, currency)
+			}
+			if existing.Status == model.AccountStatusClosed {
+				return fmt.Errorf("%w: user %d's account is closed", apperror.ErrConflict, request.UserID)
+			}
+			balance.Status, balance.Tier, balance.Product = existing.Status, existing.Tier, existing.Product
+		}
+		if err := c.BalanceRepo.CreateBalance(ctx, &balance); err != nil {
+			return err
+		}
+		balances = append(balances, balance)
+		slices.SortFunc(balances, func(a, b model.Balance) int { return a.Key().Compare(b.Key()) })
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error opening %s account for user %d: %v\n", currency, request.UserID, err)
+		return nil, fmt.Errorf("failed to open %s account for user %d: %w", currency, request.UserID, err)
+	}
+	return accountResponse(balances), nil
+}
+
+// FreezeAccount stops all money movement in and out of an active wallet. Only admins may freeze.
+func (c *AccountHandler) FreezeAccount(ctx context.Context, request *dto.AccountRequest) (*dto.AccountResponse, error) {
+	return c.changeStatus(ctx, request, auth.AuthorizeAdmin, model.AccountStatusFrozen, model.AccountStatusActive)
+}
+
+// UnfreezeAccount makes a frozen wallet active again. Only admins may unfreeze.
+func (c *AccountHandler) UnfreezeAccount(ctx context.Context, request *dto.AccountRequest) (*dto.AccountResponse, error) {
+	return c.changeStatus(ctx, request, auth.AuthorizeAdmin, model.AccountStatusActive, model.AccountStatusFrozen)
+}
+
+// CloseAccount closes an active or frozen wallet for good. Every balance must be zero, with no
+// money on hold, no interest left to settle and no scheduled transfers paying out of it, so that
+// closing never makes money disappear nor leaves anything that would move money later. Users may
+// close their own wallet.
+func (c *AccountHandler) CloseAccount(ctx context.Context, request *dto.AccountRequest) (*dto.AccountResponse, error) {
+	authorize := func(ctx context.Context) error { return auth.Authorize(ctx, request.UserID) }
+	return c.changeStatus(ctx, request, authorize, model.AccountStatusClosed, model.AccountStatusActive, model.AccountStatusFrozen)
+}
+
+// SetTier moves the user's wallet to another tier in every currency, which changes the limits
+// its withdrawals and transfers are held to from the next one on. Only admins may change tiers.
+func (c *AccountHandler) SetTier(ctx context.Context, request *dto.SetTierRequest) (*dto.AccountResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	tier, _ := model.ParseAccountTier(request.Tier)
+	balances, err := c.updateWallet(ctx, request.UserID, func(ctx context.Context, balance *model.Balance) error {
+		if err := c.BalanceRepo.UpdateBalanceTier(ctx, balance.UserID, balance.Currency, tier, balance.Version); err != nil {
+			return err
+		}
+		balance.Tier = tier
+		balance.Version++
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error changing account tier for user %d to %s: %v\n", request.UserID, tier, err)
+		return nil, fmt.Errorf("failed to change account tier for user %d: %w", request.UserID, err)
+	}
+	return accountResponse(balances), nil
+}
+
+// SetProduct moves the user's wallet to another product in every currency. A savings wallet
+// starts accruing interest from the day it became one; one that stops being a savings wallet
+// accrues no more, but is still paid what it already accrued. Only admins may change products.
+func (c *AccountHandler) SetProduct(ctx context.Context, request *dto.SetProductRequest) (*dto.AccountResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	product, _ := model.ParseAccountProduct(request.Product)
+	balances, err := c.updateWallet(ctx, request.UserID, func(ctx context.Context, balance *model.Balance) error {
+		if err := c.BalanceRepo.UpdateBalanceProduct(ctx, balance.UserID, balance.Currency, product, balance.Version); err != nil {
+			return err
+		}
+		balance.Product = product
+		balance.Version++
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error changing account product for user %d to %s: %v\n", request.UserID, product, err)
+		return nil, fmt.Errorf("failed to change account product for user %d: %w", request.UserID, err)
+	}
+	return accountResponse(balances), nil
+}
+
+// changeStatus moves the user's wallet to status in every currency, provided the caller passes
+// authorize and the wallet's current status is one of from
+func (c *AccountHandler) changeStatus(ctx context.Context, request *dto.AccountRequest, authorize func(ctx context.Context) error, status model.AccountStatus, from ...model.AccountStatus) (*dto.AccountResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+	balances, err := c.updateWallet(ctx, request.UserID, func(ctx context.Context, balance *model.Balance) error {
+		if !slices.Contains(from, balance.Status) {
+			return fmt.Errorf("%w: cannot change a %s account to %s", apperror.ErrConflict, balance.Status, status)
+		}
+		if status == model.AccountStatusClosed {
+			if err := c.checkClosable(ctx, balance); err != nil {
+				return err
+			}
+		}
+		if err := c.BalanceRepo.UpdateBalanceStatus(ctx, balance.UserID, balance.Currency, status, balance.Version); err != nil {
+			return err
+		}
+		balance.Status = status
+		balance.Version++
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error changing account status for user %d to %s: %v\n", request.UserID, status, err)
+		return nil, fmt.Errorf("failed to change account status for user %d: %w", request.UserID, err)
+	}
+	return accountResponse(balances), nil
+}
+
+// checkClosable returns an apperror.ErrConflict when closing balance would strand money: it is not
+// zero, has money on hold, has accrued interest that was not settled yet, which is only paid out
+// once its month is over, or pays out an active schedule
+func (c *AccountHandler) checkClosable(ctx context.Context, balance *model.Balance) error {
+	if balance.Balance != 0 {
+		return fmt.Errorf("%w: cannot close an account holding %s", apperror.ErrConflict, balance.Currency.Format(balance.Balance))
+	}
+	if balance.Held != 0 {
+		return fmt.Errorf("%w: cannot close an account with %s on hold", apperror.ErrConflict, balance.Currency.Format(balance.Held))
+	}
+	accruals, err := c.InterestRepo.GetUnpaidAccruals(ctx, balance.UserID, balance.Currency, c.now())
+	if err != nil {
+		return err
+	}
+	var accrued int64
+	for _, accrual := range accruals {
+		accrued += accrual.Amount
+	}
+	// Accruals adding up to less than a minor unit are settled without a payment, which a
+	// closed wallet does not stop
+	if unsettled := balance.Currency.Truncate(model.AccruedMoney(accrued)); unsettled != 0 {
+		return fmt.Errorf("%w: cannot close an account with %s of interest not settled yet", apperror.ErrConflict, balance.Currency.Format(unsettled))
+	}
+	schedules, err := c.ScheduleRepo.GetSchedulesByUserID(ctx, balance.UserID)
+	if err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		if schedule.Status == model.ScheduleStatusActive && schedule.Currency == balance.Currency {
+			return fmt.Errorf("%w: cannot close an account paying out scheduled transfer %d", apperror.ErrConflict, schedule.ID)
+		}
+	}
+	return nil
+}
+
+// updateWallet calls update on the user's balance in every currency in one unit of work, with
+// the rows locked so that every currency changes together and none changes under a posting that
+// already checked it. update saves its change at the balance's version and bumps the version.
+func (c *AccountHandler) updateWallet(ctx context.Context, userID uint, update func(ctx context.Context, balance *model.Balance) error) ([]model.Balance, error) {
+	var balances []model.Balance
+	err := c.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		var err error
+		balances, err = c.BalanceRepo.GetBalancesForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for i := range balances {
+			if err := update(ctx, &balances[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return balances, err
+}
+
+// accountResponse describes a wallet by its balances, which all share one status, tier and
+// product
+func accountResponse(balances []model.Balance) *dto.AccountResponse {
+	response := &dto.AccountResponse{
+		UserID:   balances[0].UserID,
+		Status:   balances[0].Status.String(),
+		Tier:     balances[0].Tier.String(),
+		Product:  balances[0].Product.String(),
+		Balances: make([]dto.CurrencyBalance, 0, len(balances)),
+	}
+	for _, balance := range balances {
+		response.Balances = append(response.Balances, dto.CurrencyBalance{Currency: balance.Currency, Balance: balance.Balance, OverdraftLimit: balance.OverdraftLimit})
+	}
+	return response
+}
@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAccountHandler(t *testing.T) {
+	handler := NewAccountHandler()
+
+	assert.NotNil(t, handler, "Expected non-nil handler, got nil")
+	assert.NotNil(t, handler.BalanceRepo, "Expected non-nil BalanceRepo, got nil")
+	assert.NotNil(t, handler.UnitOfWork, "Expected non-nil UnitOfWork, got nil")
+	assert.NotNil(t, handler.InterestRepo, "Expected non-nil InterestRepo, got nil")
+	assert.NotNil(t, handler.ScheduleRepo, "Expected non-nil ScheduleRepo, got nil")
+}
+
+func newMemoryAccountHandler(store *memoryStore) *AccountHandler {
+	return &AccountHandler{BalanceRepo: store, UnitOfWork: store, InterestRepo: store, ScheduleRepo: store}
+}
+
+func TestAccountLifecycle(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000})
+	accounts := newMemoryAccountHandler(store)
+	balances := newMemoryBalanceHandler(store)
+	ctx := adminContext()
+
+	opened, err := accounts.OpenAccount(ctx, &dto.AccountRequest{UserID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, &dto.AccountResponse{UserID: 2, Status: "Active", Tier: "Standard", Product: "Checking", Balances: []dto.CurrencyBalance{{Currency: model.USD, Balance: 0}}}, opened)
+
+	_, err = accounts.OpenAccount(ctx, &dto.AccountRequest{UserID: 2})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+
+	_, err = balances.Transfer(ctx, &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 2500})
+	require.NoError(t, err)
+
+	// A frozen wallet can neither send nor receive money
+	frozen, err := accounts.FreezeAccount(ctx, &dto.AccountRequest{UserID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "Frozen", frozen.Status)
+
+	_, err = balances.Transfer(ctx, &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100})
+	assert.ErrorIs(t, err, apperror.ErrAccountFrozen)
+	_, err = balances.Withdraw(ctx, &dto.WithdrawRequest{UserID: 2, Amount: 100})
+	assert.ErrorIs(t, err, apperror.ErrAccountFrozen)
+	_, err = accounts.FreezeAccount(ctx, &dto.AccountRequest{UserID: 2})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+
+	// A wallet holding money cannot be closed
+	_, err = accounts.CloseAccount(ctx, &dto.AccountRequest{UserID: 2})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+
+	_, err = accounts.UnfreezeAccount(ctx, &dto.AccountRequest{UserID: 2})
+	require.NoError(t, err)
+	_, err = balances.Withdraw(ctx, &dto.WithdrawRequest{UserID: 2, Amount: 2500})
+	require.NoError(t, err)
+
+	closed, err := accounts.CloseAccount(ctx, &dto.AccountRequest{UserID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "Closed", closed.Status)
+
+	_, err = balances.Deposit(ctx, &dto.DepositRequest{UserID: 2, Amount: 100})
+	assert.ErrorIs(t, err, apperror.ErrAccountClosed)
+	_, err = accounts.UnfreezeAccount(ctx, &dto.AccountRequest{UserID: 2})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+
+	account, err := accounts.GetAccount(ctx, &dto.AccountRequest{UserID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "Closed", account.Status)
+	assert.Equal(t, model.AccountStatusClosed, store.statuses[model.BalanceKey{UserID: 2, Currency: model.USD}])
+	assert.Equal(t, model.Money(0), store.total())
+}
+
+func TestMultiCurrencyAccount(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000})
+	accounts := newMemoryAccountHandler(store)
+	balances := newMemoryBalanceHandler(store)
+	ctx := adminContext()
+
+	_, err := accounts.FreezeAccount(ctx, &dto.AccountRequest{UserID: 1})
+	require.NoError(t, err)
+
+	// A currency opened on a frozen wallet is frozen too, and unfreezing covers every currency
+	opened, err := accounts.OpenAccount(ctx, &dto.AccountRequest{UserID: 1, Currency: model.EUR})
+	require.NoError(t, err)
+	assert.Equal(t, &dto.AccountResponse{UserID: 1, Status: "Frozen", Tier: "Standard", Product: "Checking", Balances: []dto.CurrencyBalance{
+		{Currency: model.EUR, Balance: 0},
+		{Currency: model.USD, Balance: 10000},
+	}}, opened)
+	_, err = accounts.OpenAccount(ctx, &dto.AccountRequest{UserID: 1, Currency: model.EUR})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	_, err = accounts.UnfreezeAccount(ctx, &dto.AccountRequest{UserID: 1})
+	require.NoError(t, err)
+
+	_, err = balances.Deposit(ctx, &dto.DepositRequest{UserID: 1, Amount: 5000, Currency: model.EUR})
+	require.NoError(t, err)
+	_, err = balances.Withdraw(ctx, &dto.WithdrawRequest{UserID: 1, Amount: 10000})
+	require.NoError(t, err)
+
+	// The euros still in the wallet keep it from being closed
+	_, err = accounts.CloseAccount(ctx, &dto.AccountRequest{UserID: 1})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	_, err = balances.Withdraw(ctx, &dto.WithdrawRequest{UserID: 1, Amount: 5000, Currency: model.EUR})
+	require.NoError(t, err)
+	closed, err := accounts.CloseAccount(ctx, &dto.AccountRequest{UserID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "Closed", closed.Status)
+	assert.Len(t, closed.Balances, 2)
+
+	_, err = accounts.OpenAccount(ctx, &dto.AccountRequest{UserID: 1, Currency: model.GBP})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	assert.Equal(t, model.AccountStatusClosed, store.statuses[model.BalanceKey{UserID: 1, Currency: model.EUR}])
+}
+
+func TestAccountErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		run           func(ctx context.Context, handler *AccountHandler) error
+		expectedError error
+	}{
+		{
+			name: "Freeze unknown account",
+			run: func(ctx context.Context, handler *AccountHandler) error {
+				_, err := handler.FreezeAccount(ctx, &dto.AccountRequest{UserID: 9})
+				return err
+			},
+			expectedError: apperror.ErrAccountNotFound,
+		},
+		{
+			name: "Unfreeze active account",
+			run: func(ctx context.Context, handler *AccountHandler) error {
+				_, err := handler.UnfreezeAccount(ctx, &dto.AccountRequest{UserID: 1})
+				return err
+			},
+			expectedError: apperror.ErrConflict,
+		},
+		{
+			name: "Open system account",
+			run: func(ctx context.Context, handler *AccountHandler) error {
+				_, err := handler.OpenAccount(ctx, &dto.AccountRequest{UserID: model.SystemAccountCashIn})
+				return err
+			},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+		{
+			name: "Open account in unsupported currency",
+			run: func(ctx context.Context, handler *AccountHandler) error {
+				_, err := handler.OpenAccount(ctx, &dto.AccountRequest{UserID: 2, Currency: "XYZ"})
+				return err
+			},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+		{
+			name: "Customer freezes own account",
+			run: func(_ context.Context, handler *AccountHandler) error {
+				_, err := handler.FreezeAccount(customerContext(1), &dto.AccountRequest{UserID: 1})
+				return err
+			},
+			expectedError: apperror.ErrForbidden,
+		},
+		{
+			name: "Customer closes another account",
+			run: func(_ context.Context, handler *AccountHandler) error {
+				_, err := handler.CloseAccount(customerContext(2), &dto.AccountRequest{UserID: 1})
+				return err
+			},
+			expectedError: apperror.ErrForbidden,
+		},
+		{
+			name: "Customer upgrades own tier",
+			run: func(_ context.Context, handler *AccountHandler) error {
+				_, err := handler.SetTier(customerContext(1), &dto.SetTierRequest{UserID: 1, Tier: "Premium"})
+				return err
+			},
+			expectedError: apperror.ErrForbidden,
+		},
+		{
+			name: "Set unknown tier",
+			run: func(ctx context.Context, handler *AccountHandler) error {
+				_, err := handler.SetTier(ctx, &dto.SetTierRequest{UserID: 1, Tier: "Gold"})
+				return err
+			},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+		{
+			name: "Set tier of unknown account",
+			run: func(ctx context.Context, handler *AccountHandler) error {
+				_, err := handler.SetTier(ctx, &dto.SetTierRequest{UserID: 9, Tier: "Verified"})
+				return err
+			},
+			expectedError: apperror.ErrAccountNotFound,
+		},
+		{
+			name: "Customer moves own wallet to savings",
+			run: func(_ context.Context, handler *AccountHandler) error {
+				_, err := handler.SetProduct(customerContext(1), &dto.SetProductRequest{UserID: 1, Product: "Savings"})
+				return err
+			},
+			expectedError: apperror.ErrForbidden,
+		},
+		{
+			name: "Set product of unknown account",
+			run: func(ctx context.Context, handler *AccountHandler) error {
+				_, err := handler.SetProduct(ctx, &dto.SetProductRequest{UserID: 9, Product: "Savings"})
+				return err
+			},
+			expectedError: apperror.ErrAccountNotFound,
+		},
+		{
+			name: "Get account without user ID",
+			run: func(ctx context.Context, handler *AccountHandler) error {
+				_, err := handler.GetAccount(ctx, &dto.AccountRequest{})
+				return err
+			},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore(map[uint]model.Money{1: 10000})
+			err := tt.run(adminContext(), newMemoryAccountHandler(store))
+			assert.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestCloseAccountConflictsWithOptimisticDeposit(t *testing.T) {
+	// An optimistic deposit that read the wallet before it was closed must not land afterwards
+	store := newMemoryStore(map[uint]model.Money{1: 0})
+	accounts := newMemoryAccountHandler(store)
+	ctx := adminContext()
+
+	staleRead, err := store.GetBalanceRecord(ctx, 1, model.USD)
+	require.NoError(t, err)
+	_, err = accounts.CloseAccount(ctx, &dto.AccountRequest{UserID: 1})
+	require.NoError(t, err)
+
+	err = store.Do(ctx, func(ctx context.Context) error {
+		return store.UpdateBalance(ctx, 1, model.USD, 100, staleRead.Version)
+	})
+	assert.ErrorIs(t, err, storage.ErrVersionConflict)
+}
+
+func TestCloseAccountWithInterestOrSchedules(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 0, 2: 0})
+	accounts := newMemoryAccountHandler(store)
+	ctx := adminContext()
+
+	// User 1 owes 1.50 of overdraft interest for this month, which is only charged once the
+	// month is over
+	accrual := &model.InterestAccrual{UserID: 1, Currency: model.USD, Day: time.Now().AddDate(0, 0, -1), Balance: -10000, Amount: -150 * model.AccrualScale}
+	require.NoError(t, store.Do(ctx, func(ctx context.Context) error { return store.CreateAccrual(ctx, accrual) }))
+	_, err := accounts.CloseAccount(ctx, &dto.AccountRequest{UserID: 1})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	assert.ErrorContains(t, err, "-1.50 USD of interest not settled yet")
+	require.NoError(t, store.Do(ctx, func(ctx context.Context) error {
+		return store.MarkAccrualsPaid(ctx, []uint{accrual.ID}, 0, time.Now())
+	}))
+
+	// A schedule paying out of the wallet keeps it open until it is cancelled
+	schedule := &model.Schedule{FromUserID: 1, ToUserID: 2, Amount: 100, Currency: model.USD, Status: model.ScheduleStatusActive}
+	require.NoError(t, store.CreateSchedule(ctx, schedule))
+	_, err = accounts.CloseAccount(ctx, &dto.AccountRequest{UserID: 1})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	assert.ErrorContains(t, err, "scheduled transfer 1")
+	schedule.Status = model.ScheduleStatusCancelled
+	require.NoError(t, store.UpdateSchedule(ctx, schedule))
+
+	closed, err := accounts.CloseAccount(ctx, &dto.AccountRequest{UserID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "Closed", closed.Status)
+}
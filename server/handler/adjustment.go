@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/config"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/storage"
+	"walletApp/validation"
+)
+
+// AdjustmentHandler lets admins correct wallet balances by hand under the maker-checker rule:
+// one admin creates an adjustment, and it only reaches the ledger once a different admin
+// approves it
+type AdjustmentHandler struct {
+	AdjustmentRepo  storage.AdjustmentRepository
+	BalanceRepo     storage.BalanceRepository
+	TransactionRepo storage.TransactionRepository
+	JournalRepo     storage.JournalRepository
+	UnitOfWork      storage.UnitOfWork
+}
+
+// NewAdjustmentHandler creates a new instance of AdjustmentHandler
+func NewAdjustmentHandler() *AdjustmentHandler {
+	return &AdjustmentHandler{
+		AdjustmentRepo:  storage.NewAdjustmentRepository(config.DB),
+		BalanceRepo:     storage.NewBalanceRepository(config.DB),
+		TransactionRepo: storage.NewTransactionRepository(config.DB),
+		JournalRepo:     storage.NewJournalRepository(config.DB),
+		UnitOfWork:      storage.NewUnitOfWork(config.DB),
+	}
+}
+
+// CreateAdjustment records a pending adjustment of the user's wallet made by the calling admin.
+// The balance does not change until another admin approves it.
+func (c *AdjustmentHandler) CreateAdjustment(ctx context.Context, request *dto.CreateAdjustmentRequest) (*dto.AdjustmentResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	maker, err := auth.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	adjustment := &model.Adjustment{
+		UserID:    request.UserID,
+		Amount:    request.Amount,
+		Currency:  model.CurrencyOrDefault(request.Currency),
+		Reason:    request.Reason,
+		Status:    model.AdjustmentStatusPending,
+		CreatedBy: maker.UserID,
+	}
+	err = c.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		// Refuse adjustments of wallets that do not exist now rather than at approval
+		if _, err := c.BalanceRepo.GetBalanceRecord(ctx, adjustment.UserID, adjustment.Currency); err != nil {
+			return err
+		}
+		return c.AdjustmentRepo.CreateAdjustment(ctx, adjustment)
+	})
+	if err != nil {
+		log.Printf("Error creating adjustment for user %d: %v\n", request.UserID, err)
+		return nil, fmt.Errorf("failed to create adjustment for user %d: %w", request.UserID, err)
+	}
+	return adjustmentResponse(adjustment), nil
+}
+
+// ListPendingAdjustments returns the adjustments waiting for approval, oldest first
+func (c *AdjustmentHandler) ListPendingAdjustments(ctx context.Context) (*dto.AdjustmentListResponse, error) {
+	if err := auth.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	adjustments, err := c.AdjustmentRepo.GetAdjustmentsByStatus(ctx, model.AdjustmentStatusPending)
+	if err != nil {
+		log.Printf("Error fetching pending adjustments: %v\n", err)
+		return nil, fmt.Errorf("failed to fetch pending adjustments: %w", err)
+	}
+	response := &dto.AdjustmentListResponse{Adjustments: make([]dto.AdjustmentResponse, 0, len(adjustments))}
+	for _, adjustment := range adjustments {
+		response.Adjustments = append(response.Adjustments, *adjustmentResponse(&adjustment))
+	}
+	return response, nil
+}
+
+// ApproveAdjustment posts a pending adjustment to the wallet as a correction transaction. The
+// approving admin must not be the one who created it.
+func (c *AdjustmentHandler) ApproveAdjustment(ctx context.Context, request *dto.ReviewAdjustmentRequest) (*dto.AdjustmentResponse, error) {
+	return c.review(ctx, request, model.AdjustmentStatusApproved)
+}
+
+// RejectAdjustment closes a pending adjustment without touching the wallet. Any admin may
+// reject, including the one who created it, since rejecting moves no money.
+func (c *AdjustmentHandler) RejectAdjustment(ctx context.Context, request *dto.ReviewAdjustmentRequest) (*dto.AdjustmentResponse, error) {
+	return c.review(ctx, request, model.AdjustmentStatusRejected)
+}
+
+// review moves a pending adjustment to status on behalf of the calling admin, posting it to the
+// ledger when it is approved
+func (c *AdjustmentHandler) review(ctx context.Context, request *dto.ReviewAdjustmentRequest, status model.AdjustmentStatus) (*dto.AdjustmentResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	checker, err := auth.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var adjustment *model.Adjustment
+	err = c.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		var err error
+		adjustment, err = c.AdjustmentRepo.GetAdjustment(ctx, request.AdjustmentID)
+		if err != nil {
+			return err
+		}
+		if adjustment.Status != model.AdjustmentStatusPending {
+			return fmt.Errorf("%w: adjustment %d is already %s", apperror.ErrConflict, adjustment.ID, adjustment.Status)
+		}
+		if status == model.AdjustmentStatusApproved {
+			if adjustment.CreatedBy == checker.UserID {
+				return fmt.Errorf("%w: adjustment %d must be approved by an admin other than the one who created it", apperror.ErrForbidden, adjustment.ID)
+			}
+			entry, err := c.post(ctx, adjustment)
+			if err != nil {
+				return err
+			}
+			adjustment.JournalEntryID = entry.ID
+		}
+		reviewedAt := time.Now()
+		adjustment.Status = status
+		adjustment.ReviewedBy = checker.UserID
+		adjustment.ReviewedAt = &reviewedAt
+		// Only succeeds while the adjustment is still pending, so a concurrent review of the
+		// same adjustment rolls this one back, posting included
+		return c.AdjustmentRepo.ReviewAdjustment(ctx, adjustment)
+	})
+	if err != nil {
+		log.Printf("Error reviewing adjustment %d: %v\n", request.AdjustmentID, err)
+		return nil, fmt.Errorf("failed to review adjustment %d: %w", request.AdjustmentID, err)
+	}
+	return adjustmentResponse(adjustment), nil
+}
+
+// post applies an adjustment to its wallet against the adjustments system account
+func (c *AdjustmentHandler) post(ctx context.Context, adjustment *model.Adjustment) (*model.JournalEntry, error) {
+	ledger := &Ledger{
+		BalanceRepo:     c.BalanceRepo,
+		TransactionRepo: c.TransactionRepo,
+		JournalRepo:     c.JournalRepo,
+	}
+	entry := &model.JournalEntry{
+		Description: fmt.Sprintf("adjustment %d of user %d: %s", adjustment.ID, adjustment.UserID, adjustment.Reason),
+		Postings: []model.Posting{
+			{UserID: model.SystemAccountAdjustments, Type: model.TransactionTypeCorrection, Amount: adjustment.Amount.Neg(), Currency: adjustment.Currency},
+			{UserID: adjustment.UserID, Type: model.TransactionTypeCorrection, Amount: adjustment.Amount, Currency: adjustment.Currency},
+		},
+	}
+	if _, err := ledger.Post(ctx, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func adjustmentResponse(adjustment *model.Adjustment) *dto.AdjustmentResponse {
+	return &dto.AdjustmentResponse{
+		ID:             adjustment.ID,
+		UserID:         adjustment.UserID,
+		Amount:         adjustment.Amount,
+		Currency:       adjustment.Currency,
+		Reason:         adjustment.Reason,
+		Status:         adjustment.Status.String(),
+		CreatedBy:      adjustment.CreatedBy,
+		ReviewedBy:     adjustment.ReviewedBy,
+		JournalEntryID: adjustment.JournalEntryID,
+	}
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/dto"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAdjustmentHandler(t *testing.T) {
+	handler := NewAdjustmentHandler()
+
+	assert.NotNil(t, handler, "Expected non-nil handler, got nil")
+	assert.NotNil(t, handler.AdjustmentRepo, "Expected non-nil AdjustmentRepo, got nil")
+	assert.NotNil(t, handler.BalanceRepo, "Expected non-nil BalanceRepo, got nil")
+	assert.NotNil(t, handler.TransactionRepo, "Expected non-nil TransactionRepo, got nil")
+	assert.NotNil(t, handler.JournalRepo, "Expected non-nil JournalRepo, got nil")
+	assert.NotNil(t, handler.UnitOfWork, "Expected non-nil UnitOfWork, got nil")
+}
+
+func newMemoryAdjustmentHandler(store *memoryStore) *AdjustmentHandler {
+	return &AdjustmentHandler{
+		AdjustmentRepo:  store,
+		BalanceRepo:     store,
+		TransactionRepo: store,
+		JournalRepo:     store,
+		UnitOfWork:      store,
+	}
+}
+
+// secondAdminContext returns a context for a request made by an admin other than adminContext's
+func secondAdminContext() context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{UserID: 101, Role: model.UserRoleAdmin})
+}
+
+func TestAdjustmentMakerChecker(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000})
+	adjustments := newMemoryAdjustmentHandler(store)
+	maker, checker := adminContext(), secondAdminContext()
+
+	created, err := adjustments.CreateAdjustment(maker, &dto.CreateAdjustmentRequest{UserID: 1, Amount: 2500, Reason: "refund for support case 12"})
+	require.NoError(t, err)
+	assert.Equal(t, &dto.AdjustmentResponse{ID: 1, UserID: 1, Amount: 2500, Currency: model.USD, Reason: "refund for support case 12", Status: "Pending", CreatedBy: 100}, created)
+	assert.Equal(t, model.Money(10000), store.balance(1, model.USD), "a pending adjustment does not move money")
+
+	pending, err := adjustments.ListPendingAdjustments(checker)
+	require.NoError(t, err)
+	assert.Equal(t, []dto.AdjustmentResponse{*created}, pending.Adjustments)
+
+	// The maker cannot approve their own adjustment
+	_, err = adjustments.ApproveAdjustment(maker, &dto.ReviewAdjustmentRequest{AdjustmentID: 1})
+	assert.ErrorIs(t, err, apperror.ErrForbidden)
+
+	approved, err := adjustments.ApproveAdjustment(checker, &dto.ReviewAdjustmentRequest{AdjustmentID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "Approved", approved.Status)
+	assert.Equal(t, uint(101), approved.ReviewedBy)
+	assert.NotZero(t, approved.JournalEntryID)
+	assert.Equal(t, model.Money(12500), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(-2500), store.balance(model.SystemAccountAdjustments, model.USD))
+	assert.Equal(t, model.Money(0), store.total())
+
+	history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, model.TransactionTypeCorrection, history[0].Type)
+	assert.Equal(t, model.Money(2500), history[0].Amount)
+
+	// An adjustment is reviewed once
+	_, err = adjustments.ApproveAdjustment(checker, &dto.ReviewAdjustmentRequest{AdjustmentID: 1})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	_, err = adjustments.RejectAdjustment(checker, &dto.ReviewAdjustmentRequest{AdjustmentID: 1})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+
+	// A rejected adjustment never touches the wallet, and the maker may reject their own
+	_, err = adjustments.CreateAdjustment(maker, &dto.CreateAdjustmentRequest{UserID: 1, Amount: -500, Reason: "typo"})
+	require.NoError(t, err)
+	rejected, err := adjustments.RejectAdjustment(maker, &dto.ReviewAdjustmentRequest{AdjustmentID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "Rejected", rejected.Status)
+	assert.Zero(t, rejected.JournalEntryID)
+	assert.Equal(t, model.Money(12500), store.balance(1, model.USD))
+
+	// A debit cannot take the wallet below zero, and the failed approval leaves it pending
+	_, err = adjustments.CreateAdjustment(maker, &dto.CreateAdjustmentRequest{UserID: 1, Amount: -20000, Reason: "reverse duplicate deposit"})
+	require.NoError(t, err)
+	_, err = adjustments.ApproveAdjustment(checker, &dto.ReviewAdjustmentRequest{AdjustmentID: 3})
+	assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	pending, err = adjustments.ListPendingAdjustments(checker)
+	require.NoError(t, err)
+	require.Len(t, pending.Adjustments, 1)
+	assert.Equal(t, uint(3), pending.Adjustments[0].ID)
+	assert.Equal(t, model.Money(12500), store.balance(1, model.USD))
+}
+
+func TestAdjustmentErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		run         func(ctx context.Context, adjustments *AdjustmentHandler) error
+		expectError error
+	}{
+		{
+			name: "Customer creates adjustment",
+			ctx:  customerContext(1),
+			run: func(ctx context.Context, adjustments *AdjustmentHandler) error {
+				_, err := adjustments.CreateAdjustment(ctx, &dto.CreateAdjustmentRequest{UserID: 1, Amount: 100, Reason: "gift"})
+				return err
+			},
+			expectError: apperror.ErrForbidden,
+		},
+		{
+			name: "Customer lists adjustments",
+			ctx:  customerContext(1),
+			run: func(ctx context.Context, adjustments *AdjustmentHandler) error {
+				_, err := adjustments.ListPendingAdjustments(ctx)
+				return err
+			},
+			expectError: apperror.ErrForbidden,
+		},
+		{
+			name: "Adjustment without reason",
+			ctx:  adminContext(),
+			run: func(ctx context.Context, adjustments *AdjustmentHandler) error {
+				_, err := adjustments.CreateAdjustment(ctx, &dto.CreateAdjustmentRequest{UserID: 1, Amount: 100})
+				return err
+			},
+			expectError: apperror.ErrInvalidRequest,
+		},
+		{
+			name: "Adjustment of unknown wallet",
+			ctx:  adminContext(),
+			run: func(ctx context.Context, adjustments *AdjustmentHandler) error {
+				_, err := adjustments.CreateAdjustment(ctx, &dto.CreateAdjustmentRequest{UserID: 9, Amount: 100, Reason: "gift"})
+				return err
+			},
+			expectError: apperror.ErrAccountNotFound,
+		},
+		{
+			name: "Approval of unknown adjustment",
+			ctx:  adminContext(),
+			run: func(ctx context.Context, adjustments *AdjustmentHandler) error {
+				_, err := adjustments.ApproveAdjustment(ctx, &dto.ReviewAdjustmentRequest{AdjustmentID: 9})
+				return err
+			},
+			expectError: apperror.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore(map[uint]model.Money{1: 10000})
+			err := tt.run(tt.ctx, newMemoryAdjustmentHandler(store))
+			assert.ErrorIs(t, err, tt.expectError)
+			assert.Empty(t, store.adjustments)
+		})
+	}
+}
@@ -2,191 +2,300 @@ package handler
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
+	"time"
+	"walletApp/auth"
 	"walletApp/config"
 	"walletApp/dto"
+	"walletApp/exchange"
+	"walletApp/fees"
+	"walletApp/limits"
 	"walletApp/model"
+	"walletApp/overdraft"
 	"walletApp/storage"
+	"walletApp/validation"
 )
 
+// ConcurrencyControl selects how balances are protected from concurrent updates
+type ConcurrencyControl int
+
+const (
+	// PessimisticLocking locks balance rows with SELECT ... FOR UPDATE before changing them
+	PessimisticLocking ConcurrencyControl = iota
+	// OptimisticLocking reads balances without locking and relies on the version check in
+	// UpdateBalance, retrying the whole operation when another writer got there first
+	OptimisticLocking
+)
+
+// maxConflictAttempts bounds how many times an operation is attempted on version conflicts
+const maxConflictAttempts = 3
+
 type BalanceHandler struct {
 	BalanceRepo     storage.BalanceRepository
 	TransactionRepo storage.TransactionRepository
+	JournalRepo     storage.JournalRepository
+	IdempotencyRepo storage.IdempotencyRepository
+	ExchangeRepo    storage.ExchangeRepository
+	HoldRepo        storage.HoldRepository
+	UnitOfWork      storage.UnitOfWork
+	// Concurrency is taken from WALLET_LOCKING by NewBalanceHandler
+	Concurrency ConcurrencyControl
+	// Rates prices conversions between currencies
+	Rates exchange.RateProvider
+	// ExchangeSpread is the share of every conversion kept by the house, in basis points
+	ExchangeSpread int64
+	// Fees prices withdrawals and transfers, which are free when it is empty
+	Fees fees.Schedule
+	// Limits caps the money withdrawals and transfers may take out of a wallet, see the limits
+	// package. Nothing is limited when it is empty.
+	Limits limits.Policy
+	// Overdraft prices the credit lines that let wallets go below zero, see the overdraft
+	// package. Going below zero is free when it is empty.
+	Overdraft overdraft.Policy
+	// Now returns the current time, which decides when quotes and holds expire and which
+	// transactions count towards a limit. time.Now is used when nil.
+	Now func() time.Time
 }
 
 // NewBalanceHandler creates a new instance of BalanceHandler
 func NewBalanceHandler() *BalanceHandler {
-	return &BalanceHandler{BalanceRepo: storage.NewBalanceRepository(config.DB), TransactionRepo: storage.NewTransactionRepository(config.DB)}
-}
-
-func (c *BalanceHandler) CheckBalance(ctx context.Context, userID uint) (float64, error) {
-	balance, err := c.BalanceRepo.GetBalance(ctx, userID)
-	if err != nil {
-		log.Printf("Error fetching balance for user %d: %v\n", userID, err)
-		return 0, fmt.Errorf("failed to fetch balance for user %d: %w", userID, err)
+	handler := &BalanceHandler{
+		BalanceRepo:     storage.NewBalanceRepository(config.DB),
+		TransactionRepo: storage.NewTransactionRepository(config.DB),
+		JournalRepo:     storage.NewJournalRepository(config.DB),
+		IdempotencyRepo: storage.NewIdempotencyRepository(config.DB),
+		ExchangeRepo:    storage.NewExchangeRepository(config.DB),
+		HoldRepo:        storage.NewHoldRepository(config.DB),
+		UnitOfWork:      storage.NewUnitOfWork(config.DB),
+		Rates:           exchange.NewFileRates(config.RatesFile()),
+		ExchangeSpread:  config.ExchangeSpread(),
+		Fees:            config.Fees(),
+		Limits:          config.Limits(),
+		Overdraft:       config.Overdraft(),
 	}
-
-	return balance, nil
+	if config.OptimisticLocking() {
+		handler.Concurrency = OptimisticLocking
+	}
+	return handler
 }
 
-func (c *BalanceHandler) Deposit(ctx context.Context, request *dto.DepositRequest) (*dto.DepositResponse, error) {
-	userID, amount := request.UserID, request.Amount
-	// Fetch balance
-	balance, err := c.BalanceRepo.GetBalance(ctx, userID)
-	if err != nil {
-		log.Printf("Error fetching balance for user %d: %v\n", userID, err)
-		return nil, fmt.Errorf("failed to fetch balance for user %d: %w", userID, err)
+// now returns the current time according to the handler's clock
+func (c *BalanceHandler) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
 	}
+	return c.Now()
+}
 
-	// Update balance
-	newBalance := balance + amount
-	err = c.BalanceRepo.UpdateBalance(ctx, userID, newBalance)
-	if err != nil {
-		log.Printf("Error updating balance for user %d: %v\n", userID, err)
-		return nil, fmt.Errorf("failed to update balance for user %d: %w", userID, err)
+// CheckBalance returns the user's balance in currency, or in model.DefaultCurrency when empty:
+// both the ledger balance and what is available, which excludes money reserved by holds and
+// includes the credit line
+func (c *BalanceHandler) CheckBalance(ctx context.Context, userID uint, currency model.Currency) (*dto.CheckBalanceResponse, error) {
+	if err := validation.Validate(&dto.CheckBalanceRequest{UserID: userID, Currency: currency}); err != nil {
+		return nil, err
 	}
-
-	// Create deposit transaction
-	transaction := &model.Transaction{
-		UserID: userID,
-		Amount: amount,
-		Type:   model.TransactionTypeDeposit,
+	if err := auth.Authorize(ctx, userID); err != nil {
+		return nil, err
 	}
-	err = c.TransactionRepo.CreateTransaction(ctx, transaction)
+	currency = model.CurrencyOrDefault(currency)
+	balance, err := c.BalanceRepo.GetBalanceRecord(ctx, userID, currency)
 	if err != nil {
-		log.Printf("Error creating transaction for user %d: %v\n", userID, err)
-		return nil, fmt.Errorf("failed to create transaction for user %d: %w", userID, err)
+		log.Printf("Error fetching %s balance for user %d: %v\n", currency, userID, err)
+		return nil, fmt.Errorf("failed to fetch %s balance for user %d: %w", currency, userID, err)
 	}
 
-	return &dto.DepositResponse{
-		Success: true,
-		Message: "Success Deposit",
-		Balance: newBalance,
+	return &dto.CheckBalanceResponse{
+		UserID:         userID,
+		Currency:       currency,
+		Balance:        balance.Balance,
+		Available:      balance.Available(),
+		OverdraftLimit: balance.OverdraftLimit,
 	}, nil
 }
 
-func (c *BalanceHandler) Withdraw(ctx context.Context, request *dto.WithdrawRequest) (*dto.WithdrawResponse, error) {
-	userID, amount := request.UserID, request.Amount
-	// Fetch balance
-	balance, err := c.BalanceRepo.GetBalance(ctx, request.UserID)
-	if err != nil {
-		log.Printf("Error fetching balance for user %d: %v\n", userID, err)
-		return nil, fmt.Errorf("failed to fetch balance for user %d: %w", userID, err)
-	}
+// VerifyBalance checks that the user's stored balance matches the sum of their ledger postings
+func (c *BalanceHandler) VerifyBalance(ctx context.Context, userID uint) error {
+	return c.ledger().Verify(ctx, userID)
+}
 
-	// Check if balance is sufficient
-	if balance < amount {
-		log.Printf("Insufficient balance for user %d\n", userID)
-		return nil, fmt.Errorf("insufficient balance for user %d", userID)
+// ledger returns a Ledger that posts through the handler's repositories
+func (c *BalanceHandler) ledger() *Ledger {
+	return &Ledger{
+		BalanceRepo:     c.BalanceRepo,
+		TransactionRepo: c.TransactionRepo,
+		JournalRepo:     c.JournalRepo,
+		Concurrency:     c.Concurrency,
+		Limits:          c.Limits,
+		Overdraft:       c.Overdraft,
+		Now:             c.Now,
 	}
+}
 
-	// Update balance
-	newBalance := balance - amount
-	err = c.BalanceRepo.UpdateBalance(ctx, userID, newBalance)
-	if err != nil {
-		log.Printf("Error updating balance for user %d: %v\n", userID, err)
-		return nil, fmt.Errorf("failed to update balance for user %d: %w", userID, err)
+// runWithRetry runs fn in a unit of work, starting over with fresh reads when it fails
+// because a balance changed underneath it
+func (c *BalanceHandler) runWithRetry(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 1; attempt <= maxConflictAttempts; attempt++ {
+		err = c.UnitOfWork.Do(ctx, fn)
+		if !errors.Is(err, storage.ErrVersionConflict) {
+			return err
+		}
+		log.Printf("Concurrent update detected on attempt %d: %v\n", attempt, err)
 	}
+	return err
+}
 
-	// Create withdraw transaction
-	transaction := &model.Transaction{
-		UserID: userID,
-		Amount: amount,
-		Type:   model.TransactionTypeWithdraw,
+func (c *BalanceHandler) Deposit(ctx context.Context, request *dto.DepositRequest) (*dto.DepositResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
 	}
-	err = c.TransactionRepo.CreateTransaction(ctx, transaction)
-	if err != nil {
-		log.Printf("Error creating transaction for user %d: %v\n", userID, err)
-		return nil, fmt.Errorf("failed to create transaction for user %d: %w", userID, err)
+	// Anyone signed in may pay money into any wallet
+	if _, err := auth.FromContext(ctx); err != nil {
+		return nil, err
 	}
+	userID, amount, currency := request.UserID, request.Amount, model.CurrencyOrDefault(request.Currency)
+	payload := *request
+	payload.IdempotencyKey = ""
+	return runIdempotent(ctx, c, request.IdempotencyKey, "deposit", payload, func(ctx context.Context) (*dto.DepositResponse, error) {
+		// Money enters the wallet from the cash-in system account
+		balances, err := c.ledger().Post(ctx, &model.JournalEntry{
+			Description: fmt.Sprintf("deposit to user %d", userID),
+			Postings: []model.Posting{
+				{UserID: model.SystemAccountCashIn, Type: model.TransactionTypeDeposit, Amount: amount.Neg(), Currency: currency},
+				{UserID: userID, Type: model.TransactionTypeDeposit, Amount: amount, Currency: currency},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
 
-	return &dto.WithdrawResponse{
-		Success: true,
-		Message: "Withdrawal successful",
-		Balance: newBalance,
-	}, nil
+		return &dto.DepositResponse{
+			Success:  true,
+			Message:  "Success Deposit",
+			Currency: currency,
+			Balance:  balances[model.BalanceKey{UserID: userID, Currency: currency}],
+		}, nil
+	})
 }
 
-func (c *BalanceHandler) Transfer(ctx context.Context, request *dto.TransferRequest) (*dto.TransferResponse, error) {
-	fromUserID, toUserID, amount := request.FromUserID, request.ToUserID, request.Amount
-	// Fetch sender's balance
-	senderBalance, err := c.BalanceRepo.GetBalance(ctx, fromUserID)
-	if err != nil {
-		log.Printf("Error fetching balance for sender %d: %v\n", fromUserID, err)
-		return &dto.TransferResponse{
-			Success: false,
-			Message: fmt.Sprintf("Failed to fetch balance for sender %d", fromUserID),
-		}, err
-	}
-
-	if senderBalance < amount {
-		log.Printf("Insufficient balance for sender %d\n", fromUserID)
-		return &dto.TransferResponse{
-			Success: false,
-			Message: "Insufficient balance",
-		}, fmt.Errorf("insufficient balance for sender %d", fromUserID)
+func (c *BalanceHandler) Withdraw(ctx context.Context, request *dto.WithdrawRequest) (*dto.WithdrawResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
 	}
-
-	// Fetch recipient's balance
-	recipientBalance, err := c.BalanceRepo.GetBalance(ctx, toUserID)
-	if err != nil {
-		log.Printf("Error fetching balance for recipient %d: %v\n", toUserID, err)
-		return &dto.TransferResponse{
-			Success: false,
-			Message: fmt.Sprintf("Failed to fetch balance for recipient %d", toUserID),
-		}, err
+	if err := auth.Authorize(ctx, request.UserID); err != nil {
+		return nil, err
 	}
+	userID, amount, currency := request.UserID, request.Amount, model.CurrencyOrDefault(request.Currency)
+	payload := *request
+	payload.IdempotencyKey = ""
+	return runIdempotent(ctx, c, request.IdempotencyKey, "withdraw", payload, func(ctx context.Context) (*dto.WithdrawResponse, error) {
+		// Money leaves the wallet to the cash-out system account, and the fee to the house
+		fee := c.Fees.Fee(model.TransactionTypeWithdraw, currency, amount)
+		balances, err := c.ledger().Post(ctx, &model.JournalEntry{
+			Description: fmt.Sprintf("withdrawal from user %d", userID),
+			Postings: append([]model.Posting{
+				{UserID: userID, Type: model.TransactionTypeWithdraw, Amount: amount.Neg(), Currency: currency},
+				{UserID: model.SystemAccountCashOut, Type: model.TransactionTypeWithdraw, Amount: amount, Currency: currency},
+			}, feePostings(userID, currency, fee)...),
+		})
+		if err != nil {
+			return nil, err
+		}
 
-	// Update balances
-	newSenderBalance := senderBalance - amount
-	newRecipientBalance := recipientBalance + amount
-
-	err = c.BalanceRepo.UpdateBalance(ctx, fromUserID, newSenderBalance)
-	if err != nil {
-		log.Printf("Error updating balance for sender %d: %v\n", fromUserID, err)
-		return &dto.TransferResponse{
-			Success: false,
-			Message: fmt.Sprintf("Failed to update balance for sender %d", fromUserID),
-		}, err
-	}
+		return &dto.WithdrawResponse{
+			Success:  true,
+			Message:  "Withdrawal successful",
+			Currency: currency,
+			Fee:      fee,
+			Balance:  balances[model.BalanceKey{UserID: userID, Currency: currency}],
+		}, nil
+	})
+}
 
-	err = c.BalanceRepo.UpdateBalance(ctx, toUserID, newRecipientBalance)
-	if err != nil {
-		log.Printf("Error updating balance for recipient %d: %v\n", toUserID, err)
-		return &dto.TransferResponse{
-			Success: false,
-			Message: fmt.Sprintf("Failed to update balance for recipient %d", toUserID),
-		}, err
+// Transfer moves money between two wallets, charging the sender the transfer fee on top. A
+// transfer into another currency must be asked for explicitly with Convert: the sender's
+// currency is then sold for the recipient's at the current price, as in Exchange, and the price
+// is kept as a used quote whose ID is the transfer ID.
+func (c *BalanceHandler) Transfer(ctx context.Context, request *dto.TransferRequest) (*dto.TransferResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
 	}
-
-	// Log transactions for both sender and recipient
-	senderTransaction := model.Transaction{
-		UserID: fromUserID,
-		Type:   model.TransactionTypeTransferSend,
-		Amount: -amount,
+	if err := auth.Authorize(ctx, request.FromUserID); err != nil {
+		return nil, err
 	}
-	err = c.TransactionRepo.CreateTransaction(ctx, &senderTransaction)
-	if err != nil {
-		log.Printf("Error logging transaction for sender %d: %v\n", fromUserID, err)
+	fromUserID, toUserID, amount := request.FromUserID, request.ToUserID, request.Amount
+	currency, toCurrency := model.CurrencyOrDefault(request.Currency), request.ToCurrency
+	if toCurrency == "" {
+		toCurrency = currency
 	}
+	payload := *request
+	payload.IdempotencyKey = ""
+	return runIdempotent(ctx, c, request.IdempotencyKey, "transfer", payload, func(ctx context.Context) (*dto.TransferResponse, error) {
+		entry := &model.JournalEntry{
+			Description: fmt.Sprintf("transfer from user %d to user %d", fromUserID, toUserID),
+			Reference: model.TransactionReference{
+				Memo:              payload.Memo,
+				ExternalReference: payload.ExternalReference,
+			},
+		}
+		var quote *model.ExchangeQuote
+		if toCurrency == currency {
+			transferID, err := newTransferID()
+			if err != nil {
+				return nil, err
+			}
+			entry.Reference.TransferID = transferID
+			entry.Postings = []model.Posting{
+				{UserID: fromUserID, Type: model.TransactionTypeTransferSend, Amount: amount.Neg(), Currency: currency, CounterpartyID: toUserID},
+				{UserID: toUserID, Type: model.TransactionTypeTransferReceive, Amount: amount, Currency: currency, CounterpartyID: fromUserID},
+			}
+		} else {
+			var err error
+			quote, err = c.createQuote(ctx, fromUserID, currency, toCurrency, amount)
+			if err != nil {
+				return nil, err
+			}
+			entry.Reference.TransferID = quote.ID
+			entry.Postings = conversionPostings(fromUserID, toUserID, quote, model.TransactionTypeTransferSend, model.TransactionTypeTransferReceive)
+		}
+		fee := c.Fees.Fee(model.TransactionTypeTransferSend, currency, amount)
+		entry.Postings = append(entry.Postings, feePostings(fromUserID, currency, fee)...)
+		balances, err := c.ledger().Post(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
 
-	recipientTransaction := model.Transaction{
-		UserID: toUserID,
-		Type:   model.TransactionTypeTransferReceive,
-		Amount: amount,
-	}
-	err = c.TransactionRepo.CreateTransaction(ctx, &recipientTransaction)
-	if err != nil {
-		log.Printf("Error logging transaction for recipient %d: %v\n", toUserID, err)
-	}
+		response := &dto.TransferResponse{
+			Success:    true,
+			Message:    "Transfer successful",
+			TransferID: entry.Reference.TransferID,
+			Currency:   currency,
+			Fee:        fee,
+			Data: map[string]model.Money{
+				"sender_balance":    balances[model.BalanceKey{UserID: fromUserID, Currency: currency}],
+				"recipient_balance": balances[model.BalanceKey{UserID: toUserID, Currency: toCurrency}],
+			},
+		}
+		if quote != nil {
+			if err := c.useQuote(ctx, quote, entry.ID); err != nil {
+				return nil, err
+			}
+			response.Conversion = &quoteResponse(quote).ConversionResponse
+		}
+		return response, nil
+	})
+}
 
-	return &dto.TransferResponse{
-		Success: true,
-		Message: "Transfer successful",
-		Data: map[string]float64{
-			"sender_balance":    newSenderBalance,
-			"recipient_balance": newRecipientBalance,
-		},
-	}, nil
+// newTransferID returns a random version 4 UUID identifying both legs of a transfer or exchange
+func newTransferID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", fmt.Errorf("failed to generate transfer ID: %w", err)
+	}
+	id[6] = id[6]&0x0f | 0x40 // Version 4
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16]), nil
 }
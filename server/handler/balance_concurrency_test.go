@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+	"walletApp/dto"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentTransfersConserveMoney(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency ConcurrencyControl
+	}{
+		{name: "Pessimistic locking", concurrency: PessimisticLocking},
+		{name: "Optimistic locking", concurrency: OptimisticLocking},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testConcurrentTransfers(t, tt.concurrency)
+		})
+	}
+}
+
+func testConcurrentTransfers(t *testing.T, concurrency ConcurrencyControl) {
+	store := newMemoryStore(map[uint]model.Money{1: 100000, 2: 100000, 3: 100000, 4: 100000})
+	handler := newMemoryBalanceHandler(store)
+	handler.Concurrency = concurrency
+
+	const workers = 32
+	const operationsPerWorker = 50
+	var wg sync.WaitGroup
+	var succeeded sync.Map
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < operationsPerWorker; i++ {
+				from := uint(rng.Intn(4) + 1)
+				to := uint(rng.Intn(3) + 1)
+				if to >= from {
+					to++
+				}
+				amount := model.Money(rng.Intn(20000) + 1)
+				var err error
+				// Mostly transfers, with some deposits and withdrawals moving money in and out
+				switch rng.Intn(10) {
+				case 0:
+					_, err = handler.Deposit(adminContext(), &dto.DepositRequest{UserID: from, Amount: amount})
+				case 1:
+					_, err = handler.Withdraw(adminContext(), &dto.WithdrawRequest{UserID: from, Amount: amount})
+				default:
+					_, err = handler.Transfer(adminContext(), &dto.TransferRequest{
+						FromUserID: from,
+						ToUserID:   to,
+						Amount:     amount,
+					})
+				}
+				if err == nil {
+					succeeded.Store(fmt.Sprintf("%d-%d", seed, i), true)
+				}
+			}
+		}(int64(w))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("concurrent transfers did not finish, row locks are probably taken in an inconsistent order")
+	}
+
+	// Every entry is balanced, so the balances of all accounts, system ones included, sum to zero
+	assert.Equal(t, model.Money(0), store.total(), "money was created or destroyed")
+	for key, balance := range store.balances {
+		if !model.IsSystemAccount(key.UserID) {
+			assert.GreaterOrEqual(t, balance, model.Money(0), "balance for user %d went negative", key.UserID)
+		}
+		assert.NoError(t, handler.VerifyBalance(adminContext(), key.UserID))
+	}
+
+	successes := 0
+	succeeded.Range(func(_, _ any) bool {
+		successes++
+		return true
+	})
+	require.NotZero(t, successes)
+}
@@ -4,12 +4,17 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"walletApp/apperror"
+	"walletApp/auth"
 	"walletApp/dto"
 	"walletApp/model"
 	"walletApp/storage"
+	"walletApp/storage/mocks"
+	"walletApp/validation"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewBalanceHandler(t *testing.T) {
@@ -17,56 +22,94 @@ func TestNewBalanceHandler(t *testing.T) {
 	assert.NotNil(t, handler, "Expected non-nil handler, got nil")
 	assert.NotNil(t, handler.BalanceRepo, "Expected non-nil BalanceRepo, got nil")
 	assert.NotNil(t, handler.TransactionRepo, "Expected non-nil TransactionRepo, got nil")
+	assert.NotNil(t, handler.JournalRepo, "Expected non-nil JournalRepo, got nil")
+	assert.NotNil(t, handler.IdempotencyRepo, "Expected non-nil IdempotencyRepo, got nil")
+	assert.NotNil(t, handler.ExchangeRepo, "Expected non-nil ExchangeRepo, got nil")
+	assert.NotNil(t, handler.HoldRepo, "Expected non-nil HoldRepo, got nil")
+	assert.NotNil(t, handler.UnitOfWork, "Expected non-nil UnitOfWork, got nil")
+	assert.NotNil(t, handler.Rates, "Expected non-nil Rates, got nil")
+}
+
+// newMockJournalRepository returns a mocked JournalRepository that accepts every entry
+func newMockJournalRepository() storage.JournalRepository {
+	return storage.NewMockJournalRepository(func(m *mock.Mock) {
+		m.On("CreateJournalEntry", mock.Anything, mock.Anything).Return(nil)
+	})
+}
+
+// mockSystemAccount mocks an empty system account balance that accepts any update
+func mockSystemAccount(m *mock.Mock, userID uint) {
+	m.On("GetBalanceForUpdate", mock.Anything, userID, model.USD).Return(&model.Balance{UserID: userID}, nil)
+	m.On("UpdateBalance", mock.Anything, userID, model.USD, mock.Anything, uint(0)).Return(nil)
+}
+
+// newPassthroughUnitOfWork returns a mocked UnitOfWork that runs the work directly and
+// returns its error, standing in for a real commit or rollback
+func newPassthroughUnitOfWork() storage.UnitOfWork {
+	return storage.NewMockUnitOfWork(func(m *mock.Mock) {
+		m.On("Do", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		})
+	})
+}
+
+// adminContext returns a context for a request made by an admin, who may act on every wallet
+func adminContext() context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{UserID: 100, Role: model.UserRoleAdmin})
+}
+
+// customerContext returns a context for a request made by the customer owning userID's wallet
+func customerContext(userID uint) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{UserID: userID, Role: model.UserRoleCustomer})
 }
 
 func TestCheckBalance(t *testing.T) {
 	tests := []struct {
-		name          string
-		userID        uint
-		mockBalance   float64
-		mockError     error
-		expectedValue float64
-		expectError   bool
+		name             string
+		userID           uint
+		mockBalance      *model.Balance
+		mockError        error
+		expectedResponse *dto.CheckBalanceResponse
+		expectError      bool
 	}{
 		{
-			name:          "Success",
-			userID:        1,
-			mockBalance:   100.0,
-			mockError:     nil,
-			expectedValue: 100.0,
-			expectError:   false,
+			name:             "Success",
+			userID:           1,
+			mockBalance:      &model.Balance{UserID: 1, Currency: model.USD, Balance: 100},
+			expectedResponse: &dto.CheckBalanceResponse{UserID: 1, Currency: model.USD, Balance: 100, Available: 100},
+		},
+		{
+			name:             "Held money is not available",
+			userID:           1,
+			mockBalance:      &model.Balance{UserID: 1, Currency: model.USD, Balance: 100, Held: 30},
+			expectedResponse: &dto.CheckBalanceResponse{UserID: 1, Currency: model.USD, Balance: 100, Available: 70},
 		},
 		{
-			name:          "Repository Error",
-			userID:        1,
-			mockBalance:   0.0,
-			mockError:     errors.New("database error"),
-			expectedValue: 0.0,
-			expectError:   true,
+			name:        "Repository Error",
+			userID:      1,
+			mockError:   errors.New("database error"),
+			expectError: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockBalanceRepo := storage.NewMockBalanceRepository(func(mocker *mock.Mock) {
-				mocker.On("GetBalance", mock.Anything, tt.userID).Return(tt.mockBalance, tt.mockError)
+				mocker.On("GetBalanceRecord", mock.Anything, tt.userID, model.USD).Return(tt.mockBalance, tt.mockError)
 			})
 
 			handler := &BalanceHandler{
 				BalanceRepo: mockBalanceRepo,
 			}
 
-			balance, err := handler.CheckBalance(context.Background(), tt.userID)
+			response, err := handler.CheckBalance(adminContext(), tt.userID, "")
 
-			if tt.expectError && err == nil {
-				t.Error("Expected error but got nil")
-			}
-			if !tt.expectError && err != nil {
-				t.Errorf("Expected no error but got: %v", err)
-			}
-			if balance != tt.expectedValue {
-				t.Errorf("Expected balance %f, got %f", tt.expectedValue, balance)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
 			}
+			assert.Equal(t, tt.expectedResponse, response)
 		})
 	}
 }
@@ -75,72 +118,73 @@ func TestDeposit(t *testing.T) {
 	tests := []struct {
 		name               string
 		request            *dto.DepositRequest
-		initialBalance     float64
+		initialBalance     model.Money
 		getBalanceError    error
 		updateBalanceError error
 		createTxError      error
 		expectSuccess      bool
-		expectedBalance    float64
+		expectedBalance    model.Money
 	}{
 		{
 			name: "Successful Deposit",
 			request: &dto.DepositRequest{
 				UserID: 1,
-				Amount: 50.0,
+				Amount: 50,
 			},
-			initialBalance:     100.0,
+			initialBalance:     100,
 			getBalanceError:    nil,
 			updateBalanceError: nil,
 			createTxError:      nil,
 			expectSuccess:      true,
-			expectedBalance:    150.0,
+			expectedBalance:    150,
 		},
 		{
 			name: "GetBalance Error",
 			request: &dto.DepositRequest{
 				UserID: 1,
-				Amount: 50.0,
+				Amount: 50,
 			},
-			initialBalance:     0.0,
+			initialBalance:     0,
 			getBalanceError:    errors.New("database error"),
 			updateBalanceError: nil,
 			createTxError:      nil,
 			expectSuccess:      false,
-			expectedBalance:    0.0,
+			expectedBalance:    0,
 		},
 		{
 			name: "UpdateBalance Error",
 			request: &dto.DepositRequest{
 				UserID: 1,
-				Amount: 50.0,
+				Amount: 50,
 			},
-			initialBalance:     100.0,
+			initialBalance:     100,
 			getBalanceError:    nil,
 			updateBalanceError: errors.New("update error"),
 			createTxError:      nil,
 			expectSuccess:      false,
-			expectedBalance:    0.0,
+			expectedBalance:    0,
 		},
 		{
 			name: "CreateTransaction Error",
 			request: &dto.DepositRequest{
 				UserID: 1,
-				Amount: 50.0,
+				Amount: 50,
 			},
-			initialBalance:     100.0,
+			initialBalance:     100,
 			getBalanceError:    nil,
 			updateBalanceError: nil,
 			createTxError:      errors.New("transaction error"),
 			expectSuccess:      false,
-			expectedBalance:    0.0,
+			expectedBalance:    0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockBalanceRepo := storage.NewMockBalanceRepository(func(mocker *mock.Mock) {
-				mocker.On("GetBalance", mock.Anything, tt.request.UserID).Return(tt.initialBalance, tt.getBalanceError)
-				mocker.On("UpdateBalance", mock.Anything, tt.request.UserID, tt.request.Amount+tt.initialBalance).Return(tt.updateBalanceError)
+				mocker.On("GetBalanceForUpdate", mock.Anything, tt.request.UserID, model.USD).Return(&model.Balance{UserID: tt.request.UserID, Balance: tt.initialBalance, Version: 1}, tt.getBalanceError)
+				mocker.On("UpdateBalance", mock.Anything, tt.request.UserID, model.USD, tt.request.Amount+tt.initialBalance, uint(1)).Return(tt.updateBalanceError)
+				mockSystemAccount(mocker, model.SystemAccountCashIn)
 			})
 
 			mockTxRepo := storage.NewMockTransactionRepository(func(mocker *mock.Mock) {
@@ -150,9 +194,11 @@ func TestDeposit(t *testing.T) {
 			handler := &BalanceHandler{
 				BalanceRepo:     mockBalanceRepo,
 				TransactionRepo: mockTxRepo,
+				JournalRepo:     newMockJournalRepository(),
+				UnitOfWork:      newPassthroughUnitOfWork(),
 			}
 
-			response, err := handler.Deposit(context.Background(), tt.request)
+			response, err := handler.Deposit(adminContext(), tt.request)
 
 			if tt.expectSuccess {
 				if err != nil {
@@ -165,7 +211,7 @@ func TestDeposit(t *testing.T) {
 						t.Error("Expected success=true, got false")
 					}
 					if response.Balance != tt.expectedBalance {
-						t.Errorf("Expected balance %f, got %f", tt.expectedBalance, response.Balance)
+						t.Errorf("Expected balance %s, got %s", tt.expectedBalance, response.Balance)
 					}
 				}
 			} else {
@@ -181,85 +227,86 @@ func TestWithdraw(t *testing.T) {
 	tests := []struct {
 		name               string
 		request            *dto.WithdrawRequest
-		initialBalance     float64
+		initialBalance     model.Money
 		getBalanceError    error
 		updateBalanceError error
 		createTxError      error
 		expectSuccess      bool
-		expectedBalance    float64
+		expectedBalance    model.Money
 	}{
 		{
 			name: "Successful Withdrawal",
 			request: &dto.WithdrawRequest{
 				UserID: 1,
-				Amount: 50.0,
+				Amount: 50,
 			},
-			initialBalance:     100.0,
+			initialBalance:     100,
 			getBalanceError:    nil,
 			updateBalanceError: nil,
 			createTxError:      nil,
 			expectSuccess:      true,
-			expectedBalance:    50.0,
+			expectedBalance:    50,
 		},
 		{
 			name: "Insufficient Balance",
 			request: &dto.WithdrawRequest{
 				UserID: 1,
-				Amount: 150.0,
+				Amount: 150,
 			},
-			initialBalance:     100.0,
+			initialBalance:     100,
 			getBalanceError:    nil,
 			updateBalanceError: nil,
 			createTxError:      nil,
 			expectSuccess:      false,
-			expectedBalance:    0.0,
+			expectedBalance:    0,
 		},
 		{
 			name: "GetBalance Error",
 			request: &dto.WithdrawRequest{
 				UserID: 1,
-				Amount: 50.0,
+				Amount: 50,
 			},
-			initialBalance:     0.0,
+			initialBalance:     0,
 			getBalanceError:    errors.New("database error"),
 			updateBalanceError: nil,
 			createTxError:      nil,
 			expectSuccess:      false,
-			expectedBalance:    0.0,
+			expectedBalance:    0,
 		},
 		{
 			name: "UpdateBalance Error",
 			request: &dto.WithdrawRequest{
 				UserID: 1,
-				Amount: 50.0,
+				Amount: 50,
 			},
-			initialBalance:     100.0,
+			initialBalance:     100,
 			getBalanceError:    nil,
 			updateBalanceError: errors.New("update error"),
 			createTxError:      nil,
 			expectSuccess:      false,
-			expectedBalance:    0.0,
+			expectedBalance:    0,
 		},
 		{
 			name: "CreateTransaction Error",
 			request: &dto.WithdrawRequest{
 				UserID: 1,
-				Amount: 50.0,
+				Amount: 50,
 			},
-			initialBalance:     100.0,
+			initialBalance:     100,
 			getBalanceError:    nil,
 			updateBalanceError: nil,
 			createTxError:      errors.New("transaction error"),
 			expectSuccess:      false,
-			expectedBalance:    0.0,
+			expectedBalance:    0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockBalanceRepo := storage.NewMockBalanceRepository(func(mocker *mock.Mock) {
-				mocker.On("GetBalance", mock.Anything, tt.request.UserID).Return(tt.initialBalance, tt.getBalanceError)
-				mocker.On("UpdateBalance", mock.Anything, tt.request.UserID, tt.initialBalance-tt.request.Amount).Return(tt.updateBalanceError)
+				mocker.On("GetBalanceForUpdate", mock.Anything, tt.request.UserID, model.USD).Return(&model.Balance{UserID: tt.request.UserID, Balance: tt.initialBalance, Version: 1}, tt.getBalanceError)
+				mocker.On("UpdateBalance", mock.Anything, tt.request.UserID, model.USD, tt.initialBalance-tt.request.Amount, uint(1)).Return(tt.updateBalanceError)
+				mockSystemAccount(mocker, model.SystemAccountCashOut)
 			})
 
 			mockTxRepo := storage.NewMockTransactionRepository(func(mocker *mock.Mock) {
@@ -269,9 +316,11 @@ func TestWithdraw(t *testing.T) {
 			handler := &BalanceHandler{
 				BalanceRepo:     mockBalanceRepo,
 				TransactionRepo: mockTxRepo,
+				JournalRepo:     newMockJournalRepository(),
+				UnitOfWork:      newPassthroughUnitOfWork(),
 			}
 
-			response, err := handler.Withdraw(context.Background(), tt.request)
+			response, err := handler.Withdraw(adminContext(), tt.request)
 
 			if tt.expectSuccess {
 				if err != nil {
@@ -284,7 +333,7 @@ func TestWithdraw(t *testing.T) {
 						t.Error("Expected success=true, got false")
 					}
 					if response.Balance != tt.expectedBalance {
-						t.Errorf("Expected balance %f, got %f", tt.expectedBalance, response.Balance)
+						t.Errorf("Expected balance %s, got %s", tt.expectedBalance, response.Balance)
 					}
 				}
 			} else {
@@ -300,8 +349,8 @@ func TestTransfer(t *testing.T) {
 	tests := []struct {
 		name                     string
 		request                  *dto.TransferRequest
-		senderBalance            float64
-		recipientBalance         float64
+		senderBalance            model.Money
+		recipientBalance         model.Money
 		getSenderError           error
 		getRecipientError        error
 		updateSenderError        error
@@ -309,18 +358,18 @@ func TestTransfer(t *testing.T) {
 		createSenderTxError      error
 		createRecipientTxError   error
 		expectSuccess            bool
-		expectedSenderBalance    float64
-		expectedRecipientBalance float64
+		expectedSenderBalance    model.Money
+		expectedRecipientBalance model.Money
 	}{
 		{
 			name: "Successful Transfer",
 			request: &dto.TransferRequest{
 				FromUserID: 1,
 				ToUserID:   2,
-				Amount:     50.0,
+				Amount:     50,
 			},
-			senderBalance:            100.0,
-			recipientBalance:         100.0,
+			senderBalance:            100,
+			recipientBalance:         100,
 			getSenderError:           nil,
 			getRecipientError:        nil,
 			updateSenderError:        nil,
@@ -328,18 +377,18 @@ func TestTransfer(t *testing.T) {
 			createSenderTxError:      nil,
 			createRecipientTxError:   nil,
 			expectSuccess:            true,
-			expectedSenderBalance:    50.0,
-			expectedRecipientBalance: 150.0,
+			expectedSenderBalance:    50,
+			expectedRecipientBalance: 150,
 		},
 		{
 			name: "Insufficient Sender Balance",
 			request: &dto.TransferRequest{
 				FromUserID: 1,
 				ToUserID:   2,
-				Amount:     150.0,
+				Amount:     150,
 			},
-			senderBalance:            100.0,
-			recipientBalance:         100.0,
+			senderBalance:            100,
+			recipientBalance:         100,
 			getSenderError:           nil,
 			getRecipientError:        nil,
 			updateSenderError:        nil,
@@ -347,18 +396,18 @@ func TestTransfer(t *testing.T) {
 			createSenderTxError:      nil,
 			createRecipientTxError:   nil,
 			expectSuccess:            false,
-			expectedSenderBalance:    100.0,
-			expectedRecipientBalance: 100.0,
+			expectedSenderBalance:    100,
+			expectedRecipientBalance: 100,
 		},
 		{
 			name: "Get Sender Balance Error",
 			request: &dto.TransferRequest{
 				FromUserID: 1,
 				ToUserID:   2,
-				Amount:     50.0,
+				Amount:     50,
 			},
-			senderBalance:            0.0,
-			recipientBalance:         100.0,
+			senderBalance:            0,
+			recipientBalance:         100,
 			getSenderError:           errors.New("database error"),
 			getRecipientError:        nil,
 			updateSenderError:        nil,
@@ -366,18 +415,18 @@ func TestTransfer(t *testing.T) {
 			createSenderTxError:      nil,
 			createRecipientTxError:   nil,
 			expectSuccess:            false,
-			expectedSenderBalance:    0.0,
-			expectedRecipientBalance: 100.0,
+			expectedSenderBalance:    0,
+			expectedRecipientBalance: 100,
 		},
 		{
 			name: "Get Recipient Balance Error",
 			request: &dto.TransferRequest{
 				FromUserID: 1,
 				ToUserID:   2,
-				Amount:     50.0,
+				Amount:     50,
 			},
-			senderBalance:            100.0,
-			recipientBalance:         0.0,
+			senderBalance:            100,
+			recipientBalance:         0,
 			getSenderError:           nil,
 			getRecipientError:        errors.New("database error"),
 			updateSenderError:        nil,
@@ -385,18 +434,18 @@ func TestTransfer(t *testing.T) {
 			createSenderTxError:      nil,
 			createRecipientTxError:   nil,
 			expectSuccess:            false,
-			expectedSenderBalance:    100.0,
-			expectedRecipientBalance: 0.0,
+			expectedSenderBalance:    100,
+			expectedRecipientBalance: 0,
 		},
 		{
 			name: "Update Sender Balance Error",
 			request: &dto.TransferRequest{
 				FromUserID: 1,
 				ToUserID:   2,
-				Amount:     50.0,
+				Amount:     50,
 			},
-			senderBalance:            100.0,
-			recipientBalance:         100.0,
+			senderBalance:            100,
+			recipientBalance:         100,
 			getSenderError:           nil,
 			getRecipientError:        nil,
 			updateSenderError:        errors.New("update error"),
@@ -404,18 +453,18 @@ func TestTransfer(t *testing.T) {
 			createSenderTxError:      nil,
 			createRecipientTxError:   nil,
 			expectSuccess:            false,
-			expectedSenderBalance:    100.0,
-			expectedRecipientBalance: 100.0,
+			expectedSenderBalance:    100,
+			expectedRecipientBalance: 100,
 		},
 		{
 			name: "Update Recipient Balance Error",
 			request: &dto.TransferRequest{
 				FromUserID: 1,
 				ToUserID:   2,
-				Amount:     50.0,
+				Amount:     50,
 			},
-			senderBalance:            100.0,
-			recipientBalance:         100.0,
+			senderBalance:            100,
+			recipientBalance:         100,
 			getSenderError:           nil,
 			getRecipientError:        nil,
 			updateSenderError:        nil,
@@ -423,8 +472,46 @@ func TestTransfer(t *testing.T) {
 			createSenderTxError:      nil,
 			createRecipientTxError:   nil,
 			expectSuccess:            false,
-			expectedSenderBalance:    100.0,
-			expectedRecipientBalance: 100.0,
+			expectedSenderBalance:    100,
+			expectedRecipientBalance: 100,
+		},
+		{
+			name: "Create Sender Transaction Error",
+			request: &dto.TransferRequest{
+				FromUserID: 1,
+				ToUserID:   2,
+				Amount:     50,
+			},
+			senderBalance:            100,
+			recipientBalance:         100,
+			getSenderError:           nil,
+			getRecipientError:        nil,
+			updateSenderError:        nil,
+			updateRecipientError:     nil,
+			createSenderTxError:      errors.New("transaction error"),
+			createRecipientTxError:   nil,
+			expectSuccess:            false,
+			expectedSenderBalance:    100,
+			expectedRecipientBalance: 100,
+		},
+		{
+			name: "Create Recipient Transaction Error",
+			request: &dto.TransferRequest{
+				FromUserID: 1,
+				ToUserID:   2,
+				Amount:     50,
+			},
+			senderBalance:            100,
+			recipientBalance:         100,
+			getSenderError:           nil,
+			getRecipientError:        nil,
+			updateSenderError:        nil,
+			updateRecipientError:     nil,
+			createSenderTxError:      nil,
+			createRecipientTxError:   errors.New("transaction error"),
+			expectSuccess:            false,
+			expectedSenderBalance:    100,
+			expectedRecipientBalance: 100,
 		},
 	}
 
@@ -433,10 +520,10 @@ func TestTransfer(t *testing.T) {
 			mockBalanceRepo := storage.NewMockBalanceRepository(
 				func(m *mock.Mock) {
 					// Set up expectations for sender balance check
-					m.On("GetBalance", mock.Anything, tt.request.FromUserID).Return(tt.senderBalance, tt.getSenderError)
-					m.On("GetBalance", mock.Anything, tt.request.ToUserID).Return(tt.recipientBalance, tt.getRecipientError)
-					m.On("UpdateBalance", mock.Anything, tt.request.FromUserID, tt.senderBalance-tt.request.Amount).Return(tt.updateSenderError)
-					m.On("UpdateBalance", mock.Anything, tt.request.ToUserID, tt.recipientBalance+tt.request.Amount).Return(tt.updateRecipientError)
+					m.On("GetBalanceForUpdate", mock.Anything, tt.request.FromUserID, model.USD).Return(&model.Balance{UserID: tt.request.FromUserID, Balance: tt.senderBalance, Version: 1}, tt.getSenderError)
+					m.On("GetBalanceForUpdate", mock.Anything, tt.request.ToUserID, model.USD).Return(&model.Balance{UserID: tt.request.ToUserID, Balance: tt.recipientBalance, Version: 1}, tt.getRecipientError)
+					m.On("UpdateBalance", mock.Anything, tt.request.FromUserID, model.USD, tt.senderBalance-tt.request.Amount, uint(1)).Return(tt.updateSenderError)
+					m.On("UpdateBalance", mock.Anything, tt.request.ToUserID, model.USD, tt.recipientBalance+tt.request.Amount, uint(1)).Return(tt.updateRecipientError)
 
 				},
 			)
@@ -465,9 +552,11 @@ func TestTransfer(t *testing.T) {
 			handler := &BalanceHandler{
 				BalanceRepo:     mockBalanceRepo,
 				TransactionRepo: mockTransactionRepo,
+				JournalRepo:     newMockJournalRepository(),
+				UnitOfWork:      newPassthroughUnitOfWork(),
 			}
 
-			response, err := handler.Transfer(context.Background(), tt.request)
+			response, err := handler.Transfer(adminContext(), tt.request)
 
 			if tt.expectSuccess {
 				assert.NoError(t, err)
@@ -481,10 +570,285 @@ func TestTransfer(t *testing.T) {
 				}
 			} else {
 				assert.Error(t, err)
-				if response != nil {
-					assert.False(t, response.Success)
+				assert.Nil(t, response)
+				if tt.getSenderError == nil && tt.senderBalance < tt.request.Amount {
+					assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
 				}
 			}
 		})
 	}
 }
+
+func TestTransferLocksInUserIDOrder(t *testing.T) {
+	var locked []uint
+	mockBalanceRepo := storage.NewMockBalanceRepository(func(m *mock.Mock) {
+		m.On("GetBalanceForUpdate", mock.Anything, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { locked = append(locked, args.Get(1).(uint)) }).
+			Return(&model.Balance{Balance: 100}, nil)
+		m.On("UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	})
+	mockTransactionRepo := storage.NewMockTransactionRepository(func(m *mock.Mock) {
+		m.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil)
+	})
+
+	handler := &BalanceHandler{
+		BalanceRepo:     mockBalanceRepo,
+		TransactionRepo: mockTransactionRepo,
+		JournalRepo:     newMockJournalRepository(),
+		UnitOfWork:      newPassthroughUnitOfWork(),
+	}
+
+	_, err := handler.Transfer(adminContext(), &dto.TransferRequest{FromUserID: 3, ToUserID: 1, Amount: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{1, 3}, locked)
+}
+
+func TestInvalidRequestsAreRejectedBeforeStorage(t *testing.T) {
+	// Mocks without expectations fail the test if any repository is called
+	handler := &BalanceHandler{
+		BalanceRepo:     storage.NewMockBalanceRepository(),
+		TransactionRepo: storage.NewMockTransactionRepository(),
+		JournalRepo:     storage.NewMockJournalRepository(),
+		UnitOfWork:      storage.NewMockUnitOfWork(),
+	}
+	ctx := adminContext()
+
+	_, err := handler.Deposit(ctx, &dto.DepositRequest{UserID: 1, Amount: -50})
+	assert.ErrorIs(t, err, validation.ErrInvalidRequest)
+
+	_, err = handler.Withdraw(ctx, &dto.WithdrawRequest{UserID: 1, Amount: 0})
+	assert.ErrorIs(t, err, validation.ErrInvalidRequest)
+
+	response, err := handler.Transfer(ctx, &dto.TransferRequest{FromUserID: 1, ToUserID: 1, Amount: 50})
+	assert.ErrorIs(t, err, validation.ErrInvalidRequest)
+	assert.Nil(t, response)
+
+	_, err = handler.CheckBalance(ctx, 0, "")
+	assert.ErrorIs(t, err, validation.ErrInvalidRequest)
+}
+
+func TestOptimisticDepositRetriesOnVersionConflict(t *testing.T) {
+	conflict := &storage.VersionConflictError{UserID: 1, Version: 1}
+	tests := []struct {
+		name          string
+		conflicts     int
+		expectSuccess bool
+		expectedCalls int
+	}{
+		{
+			name:          "Succeeds after a conflict",
+			conflicts:     1,
+			expectSuccess: true,
+			expectedCalls: 2,
+		},
+		{
+			name:          "Gives up after too many conflicts",
+			conflicts:     maxConflictAttempts,
+			expectSuccess: false,
+			expectedCalls: maxConflictAttempts,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockBalanceRepo := storage.NewMockBalanceRepository(func(m *mock.Mock) {
+				m.On("GetBalanceRecord", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Balance: 100, Version: 1}, nil)
+				m.On("GetBalanceRecord", mock.Anything, model.SystemAccountCashIn, model.USD).Return(&model.Balance{UserID: model.SystemAccountCashIn}, nil)
+				m.On("UpdateBalance", mock.Anything, model.SystemAccountCashIn, model.USD, model.Money(-50), uint(0)).Return(nil)
+				m.On("UpdateBalance", mock.Anything, uint(1), model.USD, model.Money(150), uint(1)).Return(conflict).Times(tt.conflicts)
+				m.On("UpdateBalance", mock.Anything, uint(1), model.USD, model.Money(150), uint(1)).Return(nil)
+			})
+			mockTxRepo := storage.NewMockTransactionRepository(func(m *mock.Mock) {
+				m.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil)
+			})
+
+			handler := &BalanceHandler{
+				BalanceRepo:     mockBalanceRepo,
+				TransactionRepo: mockTxRepo,
+				JournalRepo:     newMockJournalRepository(),
+				UnitOfWork:      newPassthroughUnitOfWork(),
+				Concurrency:     OptimisticLocking,
+			}
+
+			response, err := handler.Deposit(adminContext(), &dto.DepositRequest{UserID: 1, Amount: 50})
+			if tt.expectSuccess {
+				assert.NoError(t, err)
+				assert.Equal(t, model.Money(150), response.Balance)
+			} else {
+				assert.ErrorIs(t, err, storage.ErrVersionConflict)
+			}
+			// Each attempt reads both the user's wallet and the cash-in account
+			mockBalanceRepo.(*mocks.BalanceRepository).AssertNumberOfCalls(t, "GetBalanceRecord", 2*tt.expectedCalls)
+		})
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		run           func(ctx context.Context, balances *BalanceHandler, transactions *TransactionHandler) error
+		expectedError error
+	}{
+		{
+			name: "Customer withdraws from own wallet",
+			ctx:  customerContext(1),
+			run: func(ctx context.Context, balances *BalanceHandler, _ *TransactionHandler) error {
+				_, err := balances.Withdraw(ctx, &dto.WithdrawRequest{UserID: 1, Amount: 100})
+				return err
+			},
+		},
+		{
+			name: "Customer withdraws from another wallet",
+			ctx:  customerContext(2),
+			run: func(ctx context.Context, balances *BalanceHandler, _ *TransactionHandler) error {
+				_, err := balances.Withdraw(ctx, &dto.WithdrawRequest{UserID: 1, Amount: 100})
+				return err
+			},
+			expectedError: apperror.ErrForbidden,
+		},
+		{
+			name: "Customer transfers out of another wallet",
+			ctx:  customerContext(2),
+			run: func(ctx context.Context, balances *BalanceHandler, _ *TransactionHandler) error {
+				_, err := balances.Transfer(ctx, &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100})
+				return err
+			},
+			expectedError: apperror.ErrForbidden,
+		},
+		{
+			name: "Customer pays into another wallet",
+			ctx:  customerContext(2),
+			run: func(ctx context.Context, balances *BalanceHandler, _ *TransactionHandler) error {
+				_, err := balances.Deposit(ctx, &dto.DepositRequest{UserID: 1, Amount: 100})
+				return err
+			},
+		},
+		{
+			name: "Customer reads another user's balance",
+			ctx:  customerContext(2),
+			run: func(ctx context.Context, balances *BalanceHandler, _ *TransactionHandler) error {
+				_, err := balances.CheckBalance(ctx, 1, model.USD)
+				return err
+			},
+			expectedError: apperror.ErrForbidden,
+		},
+		{
+			name: "Customer reads another user's history",
+			ctx:  customerContext(2),
+			run: func(ctx context.Context, _ *BalanceHandler, transactions *TransactionHandler) error {
+				_, err := transactions.ViewTransactionHistory(ctx, &dto.TransactionHistoryRequest{UserID: 1})
+				return err
+			},
+			expectedError: apperror.ErrForbidden,
+		},
+		{
+			name: "Admin reads another user's history",
+			ctx:  adminContext(),
+			run: func(ctx context.Context, _ *BalanceHandler, transactions *TransactionHandler) error {
+				_, err := transactions.ViewTransactionHistory(ctx, &dto.TransactionHistoryRequest{UserID: 1})
+				return err
+			},
+		},
+		{
+			name: "Anonymous deposit",
+			ctx:  context.Background(),
+			run: func(ctx context.Context, balances *BalanceHandler, _ *TransactionHandler) error {
+				_, err := balances.Deposit(ctx, &dto.DepositRequest{UserID: 1, Amount: 100})
+				return err
+			},
+			expectedError: apperror.ErrUnauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 10000})
+			err := tt.run(tt.ctx, newMemoryBalanceHandler(store), &TransactionHandler{TransactionRepo: store})
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestTransferRecordsCounterpartyAndReference(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 0})
+	balances := newMemoryBalanceHandler(store)
+
+	response, err := balances.Transfer(adminContext(), &dto.TransferRequest{
+		FromUserID:        1,
+		ToUserID:          2,
+		Amount:            2500,
+		Memo:              "dinner on friday",
+		ExternalReference: "INV-2024-001",
+	})
+	require.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, response.TransferID)
+
+	reference := model.TransactionReference{TransferID: response.TransferID, Memo: "dinner on friday", ExternalReference: "INV-2024-001"}
+	sent, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, sent, 1)
+	assert.Equal(t, model.TransactionTypeTransferSend, sent[0].Type)
+	assert.Equal(t, uint(2), sent[0].CounterpartyID)
+	assert.Equal(t, reference, sent[0].TransactionReference)
+
+	received, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 2})
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	assert.Equal(t, model.TransactionTypeTransferReceive, received[0].Type)
+	assert.Equal(t, uint(1), received[0].CounterpartyID)
+	assert.Equal(t, reference, received[0].TransactionReference)
+
+	// Every transfer gets its own ID
+	another, err := balances.Transfer(adminContext(), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100})
+	require.NoError(t, err)
+	assert.NotEqual(t, response.TransferID, another.TransferID)
+}
+
+func TestMultiCurrencyBalances(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 0})
+	store.fund(1, model.EUR, 5000)
+	store.fund(2, model.EUR, 0)
+	store.fund(1, model.JPY, 100000)
+	balances := newMemoryBalanceHandler(store)
+	ctx := adminContext()
+
+	response, err := balances.Transfer(ctx, &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 2000, Currency: model.EUR})
+	require.NoError(t, err)
+	assert.Equal(t, model.EUR, response.Currency)
+	assert.Equal(t, map[string]model.Money{"sender_balance": 3000, "recipient_balance": 2000}, response.Data)
+	assert.Equal(t, model.Money(10000), store.balance(1, model.USD), "other currencies are untouched")
+
+	euros, err := balances.CheckBalance(ctx, 2, model.EUR)
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(2000), euros.Balance)
+	dollars, err := balances.CheckBalance(ctx, 2, "")
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(0), dollars.Balance)
+
+	// Yen have no minor unit
+	_, err = balances.Withdraw(ctx, &dto.WithdrawRequest{UserID: 1, Amount: 150, Currency: model.JPY})
+	assert.ErrorIs(t, err, apperror.ErrInvalidRequest)
+	withdrawn, err := balances.Withdraw(ctx, &dto.WithdrawRequest{UserID: 1, Amount: 30000, Currency: model.JPY})
+	require.NoError(t, err)
+	assert.Equal(t, &dto.WithdrawResponse{Success: true, Message: "Withdrawal successful", Currency: model.JPY, Balance: 70000}, withdrawn)
+
+	// Moving money between currencies is never implicit
+	_, err = balances.Transfer(ctx, &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100, Currency: model.EUR, ToCurrency: model.USD})
+	assert.ErrorIs(t, err, apperror.ErrInvalidRequest)
+	_, err = balances.Transfer(ctx, &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100, Currency: model.JPY})
+	assert.ErrorIs(t, err, apperror.ErrAccountNotFound)
+
+	history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1, Currency: model.EUR})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, model.EUR, history[0].Currency)
+	for _, userID := range []uint{1, 2} {
+		assert.NoError(t, balances.VerifyBalance(context.Background(), userID))
+	}
+	assert.Equal(t, model.Money(0), store.total())
+}
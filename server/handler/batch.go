@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/validation"
+)
+
+// BatchTransfer pays out from one wallet to every recipient of the request in the sender's
+// currency. Each item is a transfer of its own, with its own transfer ID and the fee of a transfer
+// of its amount, and every transaction of the batch carries the same batch ID. The items are
+// applied in order in one unit of work: the first item that cannot be applied rolls back the whole
+// batch, and the error names it, unless BestEffort is set. Then an item the ledger refuses, say for
+// insufficient funds or a frozen recipient, is reported as failed and the rest are still applied;
+// a storage failure still rolls back the whole batch. Every wallet of the batch is locked before
+// the first item is applied. Only the sender may pay out from a wallet.
+func (c *BalanceHandler) BatchTransfer(ctx context.Context, request *dto.BatchTransferRequest) (*dto.BatchTransferResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.Authorize(ctx, request.FromUserID); err != nil {
+		return nil, err
+	}
+	currency := model.CurrencyOrDefault(request.Currency)
+	payload := *request
+	payload.IdempotencyKey = ""
+	return runIdempotent(ctx, c, request.IdempotencyKey, "batch_transfer", payload, func(ctx context.Context) (*dto.BatchTransferResponse, error) {
+		// Lock every wallet the batch pays into, and the house account its fees go to, at once,
+		// so batches paying the same wallets in another order cannot deadlock on each other
+		keys := []model.BalanceKey{{UserID: payload.FromUserID, Currency: currency}, {UserID: model.SystemAccountHouse, Currency: currency}}
+		for _, item := range payload.Items {
+			keys = append(keys, model.BalanceKey{UserID: item.ToUserID, Currency: currency})
+		}
+		if err := c.ledger().Lock(ctx, keys...); err != nil {
+			return nil, err
+		}
+
+		batchID, err := newTransferID()
+		if err != nil {
+			return nil, err
+		}
+		response := &dto.BatchTransferResponse{
+			BatchID:  batchID,
+			Currency: currency,
+			Items:    make([]dto.BatchTransferResult, 0, len(payload.Items)),
+		}
+		for i, item := range payload.Items {
+			result := dto.BatchTransferResult{Index: i, ToUserID: item.ToUserID, Amount: item.Amount}
+			result.TransferID, result.Fee, err = c.transferBatchItem(ctx, payload.FromUserID, currency, batchID, item)
+			switch {
+			case err == nil:
+				response.Succeeded++
+				response.Fees = response.Fees.Add(result.Fee)
+				response.Total = response.Total.Add(item.Amount).Add(result.Fee)
+			case payload.BestEffort && isRefusal(err):
+				log.Printf("Skipping item %d of batch %s to user %d: %v\n", i, batchID, item.ToUserID, err)
+				response.Failed++
+				result.Fee = 0
+				result.Error = err.Error()
+			default:
+				return nil, fmt.Errorf("item %d to user %d: %w", i, item.ToUserID, err)
+			}
+			response.Items = append(response.Items, result)
+		}
+		response.Success = response.Failed == 0
+		response.Message = "Batch transfer successful"
+		if !response.Success {
+			response.Message = fmt.Sprintf("Batch transfer partly applied: %d of %d items failed", response.Failed, len(payload.Items))
+		}
+		return response, nil
+	})
+}
+
+// transferBatchItem posts the transfer of item from fromUserID as part of batch batchID, and
+// returns its transfer ID and the fee charged for it
+func (c *BalanceHandler) transferBatchItem(ctx context.Context, fromUserID uint, currency model.Currency, batchID string, item dto.BatchTransferItem) (string, model.Money, error) {
+	transferID, err := newTransferID()
+	if err != nil {
+		return "", 0, err
+	}
+	entry := &model.JournalEntry{
+		Description: fmt.Sprintf("batch transfer from user %d to user %d", fromUserID, item.ToUserID),
+		Reference: model.TransactionReference{
+			TransferID:        transferID,
+			BatchID:           batchID,
+			Memo:              item.Memo,
+			ExternalReference: item.ExternalReference,
+		},
+		Postings: []model.Posting{
+			{UserID: fromUserID, Type: model.TransactionTypeTransferSend, Amount: item.Amount.Neg(), Currency: currency, CounterpartyID: item.ToUserID},
+			{UserID: item.ToUserID, Type: model.TransactionTypeTransferReceive, Amount: item.Amount, Currency: currency, CounterpartyID: fromUserID},
+		},
+	}
+	fee := c.Fees.Fee(model.TransactionTypeTransferSend, currency, item.Amount)
+	entry.Postings = append(entry.Postings, feePostings(fromUserID, currency, fee)...)
+	if _, err := c.ledger().Post(ctx, entry); err != nil {
+		return "", 0, err
+	}
+	return transferID, fee, nil
+}
+
+// isRefusal reports whether err is the ledger turning an entry down before writing any of it,
+// which leaves the unit of work fit to carry on with the rest of a best-effort batch
+func isRefusal(err error) bool {
+	for _, refusal := range []error{
+		apperror.ErrAccountNotFound,
+		apperror.ErrInsufficientFunds,
+		apperror.ErrLimitExceeded,
+		apperror.ErrAccountFrozen,
+		apperror.ErrAccountClosed,
+	} {
+		if errors.Is(err, refusal) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/dto"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchTest has user 1 holding 100.00 and users 2, 3 and 4 nothing, with user 3 frozen, charged
+// testFees
+var batchTest = memoryTest{Funds: map[uint]model.Money{1: 10000, 2: 0, 3: 0, 4: 0}, Frozen: []uint{3}, Fees: testFees}
+
+// batchTransactions returns the transactions of batch batchID
+func batchTransactions(t *testing.T, store *memoryStore, batchID string) []model.Transaction {
+	var batch []model.Transaction
+	for _, userID := range []uint{1, 2, 3, 4} {
+		history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: userID})
+		require.NoError(t, err)
+		for _, transaction := range history {
+			if transaction.BatchID == batchID {
+				batch = append(batch, transaction)
+			}
+		}
+	}
+	return batch
+}
+
+func TestBatchTransfer(t *testing.T) {
+	store, balances := batchTest.setUp(t)
+
+	response, err := balances.BatchTransfer(customerContext(1), &dto.BatchTransferRequest{FromUserID: 1, Items: []dto.BatchTransferItem{
+		{ToUserID: 2, Amount: 2000, Memo: "March payout"},
+		{ToUserID: 4, Amount: 3000, ExternalReference: "INV-4"},
+	}})
+	require.NoError(t, err)
+	assert.True(t, response.Success)
+	assert.Equal(t, 2, response.Succeeded)
+	assert.Equal(t, model.Money(100), response.Fees)
+	assert.Equal(t, model.Money(5100), response.Total)
+	assert.Equal(t, model.Money(10000-5100), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(2000), store.balance(2, model.USD))
+	assert.Equal(t, model.Money(3000), store.balance(4, model.USD))
+
+	// Every transaction of the batch, fees included, carries its ID, and each item its own
+	// transfer ID
+	require.NotEmpty(t, response.BatchID)
+	transactions := batchTransactions(t, store, response.BatchID)
+	assert.Len(t, transactions, 6)
+	transferIDs := map[string]bool{}
+	for _, transaction := range transactions {
+		transferIDs[transaction.TransferID] = true
+	}
+	assert.Equal(t, map[string]bool{response.Items[0].TransferID: true, response.Items[1].TransferID: true}, transferIDs)
+	assert.NotEqual(t, response.Items[0].TransferID, response.Items[1].TransferID)
+	assert.Equal(t, model.Money(0), store.total())
+}
+
+func TestBatchTransferAllOrNothing(t *testing.T) {
+	tests := []struct {
+		name          string
+		items         []dto.BatchTransferItem
+		expectedError error
+	}{
+		{
+			name:          "Frozen recipient",
+			items:         []dto.BatchTransferItem{{ToUserID: 2, Amount: 2000}, {ToUserID: 3, Amount: 2000}},
+			expectedError: apperror.ErrAccountFrozen,
+		},
+		{
+			name:          "Unknown recipient",
+			items:         []dto.BatchTransferItem{{ToUserID: 2, Amount: 2000}, {ToUserID: 9, Amount: 2000}},
+			expectedError: apperror.ErrAccountNotFound,
+		},
+		{
+			name:          "Funds run out part way",
+			items:         []dto.BatchTransferItem{{ToUserID: 2, Amount: 5000}, {ToUserID: 4, Amount: 5000}},
+			expectedError: apperror.ErrInsufficientFunds,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, balances := batchTest.setUp(t)
+			_, err := balances.BatchTransfer(customerContext(1), &dto.BatchTransferRequest{FromUserID: 1, Items: tt.items})
+			assert.ErrorIs(t, err, tt.expectedError)
+			assert.ErrorContains(t, err, "item 1")
+
+			// The items before the failing one are rolled back too
+			assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+			assert.Equal(t, model.Money(0), store.balance(2, model.USD))
+			assert.Equal(t, model.Money(0), store.balance(model.SystemAccountHouse, model.USD))
+		})
+	}
+}
+
+func TestBatchTransferBestEffort(t *testing.T) {
+	store, balances := batchTest.setUp(t)
+
+	response, err := balances.BatchTransfer(customerContext(1), &dto.BatchTransferRequest{FromUserID: 1, BestEffort: true, Items: []dto.BatchTransferItem{
+		{ToUserID: 2, Amount: 5000},
+		{ToUserID: 3, Amount: 1000},
+		{ToUserID: 4, Amount: 5000},
+		{ToUserID: 4, Amount: 4000},
+	}})
+	require.NoError(t, err)
+	assert.False(t, response.Success)
+	assert.Equal(t, 2, response.Succeeded)
+	assert.Equal(t, 2, response.Failed)
+	assert.Equal(t, model.Money(9100), response.Total)
+
+	// Items refused by the ledger are reported without a fee, and the rest applied
+	assert.Empty(t, response.Items[1].TransferID)
+	assert.Contains(t, response.Items[1].Error, apperror.ErrAccountFrozen.Error())
+	assert.Zero(t, response.Items[2].Fee)
+	assert.Equal(t, "insufficient USD funds for user 1", response.Items[2].Error)
+	assert.NotEmpty(t, response.Items[3].TransferID)
+	assert.Equal(t, model.Money(10000-9100), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(4000), store.balance(4, model.USD))
+	assert.Len(t, batchTransactions(t, store, response.BatchID), 6)
+	assert.Equal(t, model.Money(0), store.total())
+	assert.NoError(t, balances.VerifyBalance(context.Background(), 1))
+}
+
+func TestConcurrentBatchTransfers(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 1000000, 2: 1000000, 3: 0, 4: 0})
+	balances := newMemoryBalanceHandler(store)
+	balances.Fees = testFees
+
+	// Users 1 and 2 pay users 3 and 4 in opposite orders, which deadlocks if each item only locks
+	// its own wallets
+	const batches = 50
+	var wg sync.WaitGroup
+	for _, batch := range []struct {
+		from uint
+		to   []uint
+	}{{1, []uint{3, 4}}, {2, []uint{4, 3}}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range batches {
+				_, err := balances.BatchTransfer(adminContext(), &dto.BatchTransferRequest{FromUserID: batch.from, Items: []dto.BatchTransferItem{
+					{ToUserID: batch.to[0], Amount: 1000},
+					{ToUserID: batch.to[1], Amount: 1000},
+				}})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("concurrent batches did not finish, row locks are probably taken in an inconsistent order")
+	}
+	assert.Equal(t, model.Money(2*batches*2000), store.balance(3, model.USD)+store.balance(4, model.USD))
+	assert.Equal(t, model.Money(0), store.total())
+}
+
+func TestBatchTransferIdempotency(t *testing.T) {
+	store, balances := batchTest.setUp(t)
+	request := &dto.BatchTransferRequest{FromUserID: 1, Items: []dto.BatchTransferItem{{ToUserID: 2, Amount: 2000}}, IdempotencyKey: "payout-1"}
+
+	first, err := balances.BatchTransfer(customerContext(1), request)
+	require.NoError(t, err)
+	second, err := balances.BatchTransfer(customerContext(1), request)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, model.Money(2000), store.balance(2, model.USD))
+
+	// Another user cannot pay out from the wallet
+	_, err = balances.BatchTransfer(customerContext(2), &dto.BatchTransferRequest{FromUserID: 1, Items: []dto.BatchTransferItem{{ToUserID: 2, Amount: 2000}}})
+	assert.ErrorIs(t, err, apperror.ErrForbidden)
+}
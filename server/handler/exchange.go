@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/config"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/validation"
+)
+
+// QuoteExchange prices selling an amount of one currency of the user's wallet for another at
+// the current rate less the spread. The price is stored as a quote that Exchange accepts until
+// it expires after config.QuoteLifetime, so the user knows what they get before committing.
+func (c *BalanceHandler) QuoteExchange(ctx context.Context, request *dto.ExchangeQuoteRequest) (*dto.ExchangeQuoteResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.Authorize(ctx, request.UserID); err != nil {
+		return nil, err
+	}
+	var quote *model.ExchangeQuote
+	err := c.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		// Refuse quotes for currencies the wallet does not hold now rather than at the exchange
+		for _, currency := range []model.Currency{request.FromCurrency, request.ToCurrency} {
+			if _, err := c.BalanceRepo.GetBalanceRecord(ctx, request.UserID, currency); err != nil {
+				log.Printf("Error fetching %s balance for user %d: %v\n", currency, request.UserID, err)
+				return fmt.Errorf("failed to fetch %s balance for user %d: %w", currency, request.UserID, err)
+			}
+		}
+		var err error
+		quote, err = c.createQuote(ctx, request.UserID, request.FromCurrency, request.ToCurrency, request.Amount)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quoteResponse(quote), nil
+}
+
+// Exchange sells money in one currency of the user's wallet for another, at the price of the
+// quote the request names or, without one, at the current price. The sold currency goes to the
+// exchange desk, which pays out the bought currency less the spread, and the spread goes to the
+// house account. The user's two transactions share the quote ID as their transfer ID.
+func (c *BalanceHandler) Exchange(ctx context.Context, request *dto.ExchangeRequest) (*dto.ExchangeResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.Authorize(ctx, request.UserID); err != nil {
+		return nil, err
+	}
+	payload := *request
+	payload.IdempotencyKey = ""
+	return runIdempotent(ctx, c, request.IdempotencyKey, "exchange", payload, func(ctx context.Context) (*dto.ExchangeResponse, error) {
+		quote, err := c.exchangeQuote(ctx, &payload)
+		if err != nil {
+			return nil, err
+		}
+		entry := &model.JournalEntry{
+			Description: fmt.Sprintf("exchange of %s to %s for user %d", quote.FromCurrency.Format(quote.Amount), quote.ToCurrency, quote.UserID),
+			Postings:    conversionPostings(quote.UserID, quote.UserID, quote, model.TransactionTypeExchange, model.TransactionTypeExchange),
+			Reference:   model.TransactionReference{TransferID: quote.ID},
+		}
+		balances, err := c.ledger().Post(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.useQuote(ctx, quote, entry.ID); err != nil {
+			return nil, err
+		}
+
+		return &dto.ExchangeResponse{
+			Success:               true,
+			Message:               "Exchange successful",
+			ExchangeQuoteResponse: *quoteResponse(quote),
+			JournalEntryID:        entry.ID,
+			FromBalance:           balances[model.BalanceKey{UserID: quote.UserID, Currency: quote.FromCurrency}],
+			ToBalance:             balances[model.BalanceKey{UserID: quote.UserID, Currency: quote.ToCurrency}],
+		}, nil
+	})
+}
+
+// exchangeQuote returns the quote named by request, which must be the user's and still open, or
+// a new quote for the request's currencies and amount when it names none
+func (c *BalanceHandler) exchangeQuote(ctx context.Context, request *dto.ExchangeRequest) (*model.ExchangeQuote, error) {
+	if request.QuoteID == "" {
+		return c.createQuote(ctx, request.UserID, request.FromCurrency, request.ToCurrency, request.Amount)
+	}
+	quote, err := c.ExchangeRepo.GetQuote(ctx, request.QuoteID)
+	if err != nil {
+		log.Printf("Error fetching exchange quote %s: %v\n", request.QuoteID, err)
+		return nil, fmt.Errorf("failed to fetch exchange quote %s: %w", request.QuoteID, err)
+	}
+	switch {
+	case quote.UserID != request.UserID:
+		// Reported like a missing quote, so that quote IDs of other users cannot be probed
+		return nil, fmt.Errorf("%w: exchange quote %s", apperror.ErrNotFound, quote.ID)
+	case quote.JournalEntryID != 0:
+		return nil, fmt.Errorf("%w: exchange quote %s was already used", apperror.ErrConflict, quote.ID)
+	case quote.Expired(c.now()):
+		return nil, fmt.Errorf("%w: exchange quote %s expired at %s", apperror.ErrConflict, quote.ID, quote.ExpiresAt.Format(time.RFC3339))
+	}
+	return quote, nil
+}
+
+// createQuote prices selling amount of from for to and stores the price as a quote for userID.
+// The rate provider gives the mid-market rate; the house keeps ExchangeSpread of the converted
+// amount, rounded up, along with whatever the precision of to leaves over.
+func (c *BalanceHandler) createQuote(ctx context.Context, userID uint, from, to model.Currency, amount model.Money) (*model.ExchangeQuote, error) {
+	rate, err := c.Rates.Rate(ctx, from, to)
+	if err != nil {
+		log.Printf("Error fetching %s/%s exchange rate: %v\n", from, to, err)
+		return nil, fmt.Errorf("failed to fetch %s/%s exchange rate: %w", from, to, err)
+	}
+	converted, err := rate.Convert(amount)
+	if err != nil {
+		return nil, validation.Errors{{Field: "amount", Message: fmt.Sprintf("is too large to convert to %s", to)}}
+	}
+	received := to.Truncate(converted - converted.Share(c.ExchangeSpread))
+	if !received.IsPositive() {
+		return nil, validation.Errors{{Field: "amount", Message: fmt.Sprintf("is too small to buy any %s", to)}}
+	}
+	id, err := newTransferID()
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &model.ExchangeQuote{
+		ID:           id,
+		UserID:       userID,
+		FromCurrency: from,
+		ToCurrency:   to,
+		Amount:       amount,
+		Rate:         rate,
+		ToAmount:     received,
+		Spread:       converted - received,
+		ExpiresAt:    c.now().Add(config.QuoteLifetime),
+	}
+	if err := c.ExchangeRepo.CreateQuote(ctx, quote); err != nil {
+		log.Printf("Error creating exchange quote for user %d: %v\n", userID, err)
+		return nil, fmt.Errorf("failed to create exchange quote for user %d: %w", userID, err)
+	}
+	return quote, nil
+}
+
+// useQuote marks quote as posted by the journal entry, which fails if it was used or expired
+// since it was read
+func (c *BalanceHandler) useQuote(ctx context.Context, quote *model.ExchangeQuote, journalEntryID uint) error {
+	if err := c.ExchangeRepo.UseQuote(ctx, quote.ID, journalEntryID, c.now()); err != nil {
+		log.Printf("Error using exchange quote %s: %v\n", quote.ID, err)
+		return fmt.Errorf("failed to use exchange quote %s: %w", quote.ID, err)
+	}
+	return nil
+}
+
+// conversionPostings move quote.Amount of quote.FromCurrency out of fromUserID's wallet and
+// quote.ToAmount of quote.ToCurrency into toUserID's through the exchange desk, which is paid
+// the sold currency and pays out the bought one, and credit the spread to the house account
+func conversionPostings(fromUserID, toUserID uint, quote *model.ExchangeQuote, sendType, receiveType model.TransactionType) []model.Posting {
+	// Exchanges within one wallet have no counterparty
+	var fromCounterparty, toCounterparty uint
+	if fromUserID != toUserID {
+		fromCounterparty, toCounterparty = toUserID, fromUserID
+	}
+	postings := []model.Posting{
+		{UserID: fromUserID, Type: sendType, Amount: quote.Amount.Neg(), Currency: quote.FromCurrency, CounterpartyID: fromCounterparty},
+		{UserID: model.SystemAccountExchange, Type: model.TransactionTypeExchange, Amount: quote.Amount, Currency: quote.FromCurrency},
+		{UserID: model.SystemAccountExchange, Type: model.TransactionTypeExchange, Amount: quote.ToAmount.Add(quote.Spread).Neg(), Currency: quote.ToCurrency},
+		{UserID: toUserID, Type: receiveType, Amount: quote.ToAmount, Currency: quote.ToCurrency, CounterpartyID: toCounterparty},
+	}
+	if quote.Spread.IsPositive() {
+		postings = append(postings, model.Posting{UserID: model.SystemAccountHouse, Type: model.TransactionTypeExchange, Amount: quote.Spread, Currency: quote.ToCurrency})
+	}
+	return postings
+}
+
+// quoteResponse converts a quote to its API representation
+func quoteResponse(quote *model.ExchangeQuote) *dto.ExchangeQuoteResponse {
+	return &dto.ExchangeQuoteResponse{
+		QuoteID:      quote.ID,
+		UserID:       quote.UserID,
+		FromCurrency: quote.FromCurrency,
+		Amount:       quote.Amount,
+		ConversionResponse: dto.ConversionResponse{
+			ToCurrency: quote.ToCurrency,
+			ToAmount:   quote.ToAmount,
+			Rate:       quote.Rate,
+			Spread:     quote.Spread,
+		},
+		ExpiresAt: quote.ExpiresAt,
+	}
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/config"
+	"walletApp/dto"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExchangeAtQuote(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000})
+	store.fund(1, model.EUR, 5000)
+	balances := newMemoryBalanceHandler(store)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	balances.Now = func() time.Time { return now }
+	ctx := customerContext(1)
+
+	// 50.00 EUR at 1.1 is 55.00 USD, of which the house keeps 0.5%, rounded up to 0.28
+	quote, err := balances.QuoteExchange(ctx, &dto.ExchangeQuoteRequest{UserID: 1, FromCurrency: model.EUR, ToCurrency: model.USD, Amount: 5000})
+	require.NoError(t, err)
+	assert.Equal(t, &dto.ExchangeQuoteResponse{
+		QuoteID:            quote.QuoteID,
+		UserID:             1,
+		FromCurrency:       model.EUR,
+		Amount:             5000,
+		ConversionResponse: dto.ConversionResponse{ToCurrency: model.USD, ToAmount: 5472, Rate: 110000000, Spread: 28},
+		ExpiresAt:          now.Add(config.QuoteLifetime),
+	}, quote)
+	assert.Equal(t, model.Money(5000), store.balance(1, model.EUR), "a quote does not move money")
+
+	now = now.Add(config.QuoteLifetime - time.Second)
+	exchanged, err := balances.Exchange(ctx, &dto.ExchangeRequest{UserID: 1, QuoteID: quote.QuoteID})
+	require.NoError(t, err)
+	assert.Equal(t, *quote, exchanged.ExchangeQuoteResponse)
+	assert.Equal(t, model.Money(0), exchanged.FromBalance)
+	assert.Equal(t, model.Money(15472), exchanged.ToBalance)
+	assert.NotZero(t, exchanged.JournalEntryID)
+
+	// The desk took the euros and paid out the dollars, and the house got the spread
+	assert.Equal(t, model.Money(5000), store.balance(model.SystemAccountExchange, model.EUR))
+	assert.Equal(t, model.Money(-5500), store.balance(model.SystemAccountExchange, model.USD))
+	assert.Equal(t, model.Money(28), store.balance(model.SystemAccountHouse, model.USD))
+	assert.NoError(t, balances.VerifyBalance(context.Background(), 1))
+	assert.Equal(t, model.Money(0), store.total())
+
+	// Both legs are linked by the quote ID
+	history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	for _, transaction := range history {
+		assert.Equal(t, model.TransactionTypeExchange, transaction.Type)
+		assert.Equal(t, quote.QuoteID, transaction.TransferID)
+		assert.Equal(t, exchanged.JournalEntryID, transaction.JournalEntryID)
+		assert.Zero(t, transaction.CounterpartyID)
+	}
+	amounts := map[model.Currency]model.Money{history[0].Currency: history[0].Amount, history[1].Currency: history[1].Amount}
+	assert.Equal(t, map[model.Currency]model.Money{model.EUR: -5000, model.USD: 5472}, amounts)
+
+	// A quote is used once, and exchanges cannot be reversed
+	_, err = balances.Exchange(ctx, &dto.ExchangeRequest{UserID: 1, QuoteID: quote.QuoteID})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	_, err = balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: history[0].ID, Reason: "changed my mind"})
+	assert.ErrorIs(t, err, apperror.ErrInvalidRequest)
+}
+
+func TestExchangeQuoteExpiry(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000})
+	store.fund(1, model.EUR, 0)
+	balances := newMemoryBalanceHandler(store)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	balances.Now = func() time.Time { return now }
+	ctx := customerContext(1)
+
+	quote, err := balances.QuoteExchange(ctx, &dto.ExchangeQuoteRequest{UserID: 1, FromCurrency: model.USD, ToCurrency: model.EUR, Amount: 5000})
+	require.NoError(t, err)
+
+	now = now.Add(config.QuoteLifetime)
+	_, err = balances.Exchange(ctx, &dto.ExchangeRequest{UserID: 1, QuoteID: quote.QuoteID})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(0), store.balance(1, model.EUR))
+
+	// Without a quote the exchange is priced now
+	exchanged, err := balances.Exchange(ctx, &dto.ExchangeRequest{UserID: 1, FromCurrency: model.USD, ToCurrency: model.EUR, Amount: 5000})
+	require.NoError(t, err)
+	assert.NotEqual(t, quote.QuoteID, exchanged.QuoteID)
+	assert.Equal(t, model.Money(5000), exchanged.FromBalance)
+	assert.Equal(t, model.Money(4522), exchanged.ToBalance)
+}
+
+func TestExchangeRounding(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000})
+	store.fund(1, model.JPY, 0)
+	balances := newMemoryBalanceHandler(store)
+
+	// 10.01 USD at 200 is 2002 JPY, less a spread of 10.01 JPY; yen have no minor unit, so the
+	// house keeps the fraction too
+	exchanged, err := balances.Exchange(customerContext(1), &dto.ExchangeRequest{UserID: 1, FromCurrency: model.USD, ToCurrency: model.JPY, Amount: 1001})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(199100), exchanged.ToAmount)
+	assert.Equal(t, model.Money(1100), exchanged.Spread)
+	assert.Equal(t, model.Money(199100), store.balance(1, model.JPY))
+	assert.Equal(t, model.Money(0), store.total())
+}
+
+func TestTransferWithConversion(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 20000, 2: 0})
+	store.fund(2, model.EUR, 0)
+	balances := newMemoryBalanceHandler(store)
+
+	// 110.00 USD at 0.9090909 is 99.99 EUR, less a spread of 0.50 EUR
+	response, err := balances.Transfer(adminContext(), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 11000, ToCurrency: model.EUR, Convert: true, Memo: "rent"})
+	require.NoError(t, err)
+	assert.Equal(t, model.USD, response.Currency)
+	assert.Equal(t, &dto.ConversionResponse{ToCurrency: model.EUR, ToAmount: 9949, Rate: 90909090, Spread: 50}, response.Conversion)
+	assert.Equal(t, map[string]model.Money{"sender_balance": 9000, "recipient_balance": 9949}, response.Data)
+
+	received, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 2})
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	assert.Equal(t, model.TransactionTypeTransferReceive, received[0].Type)
+	assert.Equal(t, model.EUR, received[0].Currency)
+	assert.Equal(t, uint(1), received[0].CounterpartyID)
+	assert.Equal(t, model.TransactionReference{TransferID: response.TransferID, Memo: "rent"}, received[0].TransactionReference)
+
+	// The price is kept as a used quote under the transfer ID
+	quote, err := store.GetQuote(context.Background(), response.TransferID)
+	require.NoError(t, err)
+	assert.NotZero(t, quote.JournalEntryID)
+	assert.Equal(t, model.Money(9949), quote.ToAmount)
+
+	// Converted transfers are not reversed at a stale price
+	_, err = balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: received[0].ID, Reason: "wrong recipient"})
+	assert.ErrorIs(t, err, apperror.ErrInvalidRequest)
+	assert.Equal(t, model.Money(0), store.total())
+}
+
+func TestExchangeErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		request     *dto.ExchangeRequest
+		expectError error
+	}{
+		{
+			name:        "More than the balance",
+			ctx:         customerContext(1),
+			request:     &dto.ExchangeRequest{UserID: 1, FromCurrency: model.USD, ToCurrency: model.EUR, Amount: 10001},
+			expectError: apperror.ErrInsufficientFunds,
+		},
+		{
+			name:        "Currency the wallet does not hold",
+			ctx:         customerContext(1),
+			request:     &dto.ExchangeRequest{UserID: 1, FromCurrency: model.USD, ToCurrency: model.GBP, Amount: 100},
+			expectError: apperror.ErrAccountNotFound,
+		},
+		{
+			name:        "Currency without a rate",
+			ctx:         customerContext(1),
+			request:     &dto.ExchangeRequest{UserID: 1, FromCurrency: model.USD, ToCurrency: model.CHF, Amount: 100},
+			expectError: apperror.ErrRateUnavailable,
+		},
+		{
+			name:        "Too little to buy anything",
+			ctx:         customerContext(1),
+			request:     &dto.ExchangeRequest{UserID: 1, FromCurrency: model.JPY, ToCurrency: model.USD, Amount: 100},
+			expectError: apperror.ErrInvalidRequest,
+		},
+		{
+			name:        "Another user's wallet",
+			ctx:         customerContext(2),
+			request:     &dto.ExchangeRequest{UserID: 1, FromCurrency: model.USD, ToCurrency: model.EUR, Amount: 100},
+			expectError: apperror.ErrForbidden,
+		},
+		{
+			name:        "Unknown quote",
+			ctx:         customerContext(1),
+			request:     &dto.ExchangeRequest{UserID: 1, QuoteID: "2f1c6a3e-8b7d-4c1e-9a0b-5d6e7f8a9b0c"},
+			expectError: apperror.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore(map[uint]model.Money{1: 10000})
+			store.fund(1, model.EUR, 0)
+			store.fund(1, model.JPY, 100000)
+			_, err := newMemoryBalanceHandler(store).Exchange(tt.ctx, tt.request)
+			assert.ErrorIs(t, err, tt.expectError)
+			assert.Empty(t, store.quotes, "a failed exchange leaves no quote behind")
+			assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+		})
+	}
+}
+
+func TestExchangeQuoteOfAnotherUser(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 10000})
+	store.fund(1, model.EUR, 0)
+	store.fund(2, model.EUR, 0)
+	balances := newMemoryBalanceHandler(store)
+
+	quote, err := balances.QuoteExchange(customerContext(1), &dto.ExchangeQuoteRequest{UserID: 1, FromCurrency: model.USD, ToCurrency: model.EUR, Amount: 1000})
+	require.NoError(t, err)
+	_, err = balances.Exchange(customerContext(2), &dto.ExchangeRequest{UserID: 2, QuoteID: quote.QuoteID})
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+	assert.Equal(t, model.Money(10000), store.balance(2, model.USD))
+}
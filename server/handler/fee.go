@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"walletApp/auth"
+	"walletApp/dto"
+	"walletApp/fees"
+	"walletApp/model"
+	"walletApp/validation"
+)
+
+// QuoteFee returns the fee a withdrawal or transfer of the amount would be charged right now,
+// without moving any money. Anyone signed in may ask.
+func (c *BalanceHandler) QuoteFee(ctx context.Context, request *dto.FeeQuoteRequest) (*dto.FeeQuoteResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if _, err := auth.FromContext(ctx); err != nil {
+		return nil, err
+	}
+	operation, _ := fees.ParseOperation(request.Operation)
+	currency := model.CurrencyOrDefault(request.Currency)
+	fee := c.Fees.Fee(operation, currency, request.Amount)
+	return &dto.FeeQuoteResponse{
+		Operation: operation.String(),
+		Amount:    request.Amount,
+		Currency:  currency,
+		Fee:       fee,
+		Total:     request.Amount.Add(fee),
+	}, nil
+}
+
+// feePostings returns the postings that charge the user fee in currency and pay it to the house
+// account, or none when there is no fee
+func feePostings(userID uint, currency model.Currency, fee model.Money) []model.Posting {
+	if !fee.IsPositive() {
+		return nil
+	}
+	return []model.Posting{
+		{UserID: userID, Type: model.TransactionTypeFee, Amount: fee.Neg(), Currency: currency},
+		{UserID: model.SystemAccountHouse, Type: model.TransactionTypeFee, Amount: fee, Currency: currency},
+	}
+}
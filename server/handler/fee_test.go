@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"walletApp/apperror"
+	"walletApp/dto"
+	"walletApp/fees"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFees charges USD withdrawals 1.00 plus 1%, and USD transfers 0.50
+var testFees = fees.Schedule{
+	model.TransactionTypeWithdraw:     {model.USD: {Flat: 100, BasisPoints: 100}},
+	model.TransactionTypeTransferSend: {model.USD: {Flat: 50}},
+}
+
+// feesTest has user 1 holding 100.00 and user 2 nothing, charged testFees
+var feesTest = memoryTest{Funds: map[uint]model.Money{1: 10000, 2: 0}, Fees: testFees}
+
+func TestWithdrawChargesFee(t *testing.T) {
+	store, balances := feesTest.setUp(t)
+
+	response, err := balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 5000})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(150), response.Fee)
+	assert.Equal(t, model.Money(10000-5000-150), response.Balance)
+	assert.Equal(t, model.Money(150), store.balance(model.SystemAccountHouse, model.USD))
+	assert.Equal(t, model.Money(5000), store.balance(model.SystemAccountCashOut, model.USD))
+
+	// The withdrawal and its fee are separate rows of one journal entry
+	history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	types := []model.TransactionType{history[0].Type, history[1].Type}
+	assert.ElementsMatch(t, []model.TransactionType{model.TransactionTypeWithdraw, model.TransactionTypeFee}, types)
+	assert.Equal(t, history[0].JournalEntryID, history[1].JournalEntryID)
+	assert.Equal(t, model.Money(0), store.total())
+	assert.NoError(t, balances.VerifyBalance(context.Background(), 1))
+
+	// The fee has to be covered too, and nothing moves when it is not
+	_, err = balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 4800})
+	assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	assert.Equal(t, model.Money(4850), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(150), store.balance(model.SystemAccountHouse, model.USD))
+}
+
+func TestTransferChargesSender(t *testing.T) {
+	store, balances := feesTest.setUp(t)
+
+	response, err := balances.Transfer(customerContext(1), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 2500})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(50), response.Fee)
+	assert.Equal(t, map[string]model.Money{"sender_balance": 7450, "recipient_balance": 2500}, response.Data)
+	assert.Equal(t, model.Money(50), store.balance(model.SystemAccountHouse, model.USD))
+
+	// Currencies without a fee are free
+	store.fund(1, model.EUR, 10000)
+	store.fund(2, model.EUR, 0)
+	response, err = balances.Transfer(customerContext(1), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 2500, Currency: model.EUR})
+	require.NoError(t, err)
+	assert.Zero(t, response.Fee)
+	assert.Equal(t, model.Money(0), store.total())
+}
+
+// chargedTransfer transfers 25.00 from user 1 to user 2 and returns the sender's transfer and
+// fee transactions
+func chargedTransfer(t *testing.T, balances *BalanceHandler, store *memoryStore) (sent, fee model.Transaction) {
+	_, err := balances.Transfer(adminContext(), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 2500})
+	require.NoError(t, err)
+	history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	for _, transaction := range history {
+		if transaction.Type == model.TransactionTypeFee {
+			fee = transaction
+		} else {
+			sent = transaction
+		}
+	}
+	return sent, fee
+}
+
+func TestReversalsOfChargedTransactions(t *testing.T) {
+	amount := func(m model.Money) *model.Money { return &m }
+
+	t.Run("Full reversal gives the fee back", func(t *testing.T) {
+		store, balances := feesTest.setUp(t)
+		sent, _ := chargedTransfer(t, balances, store)
+
+		response, err := balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: sent.ID, Reason: "sent to the wrong wallet"})
+		require.NoError(t, err)
+		assert.Equal(t, model.Money(2500), response.Amount)
+		assert.Equal(t, map[uint]model.Money{1: 10000, 2: 0}, response.Balances)
+		assert.Equal(t, model.Money(0), store.balance(model.SystemAccountHouse, model.USD))
+
+		// Each reversal row links to the row it undoes, the fee's to the fee
+		history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1})
+		require.NoError(t, err)
+		byID := map[uint]model.Transaction{}
+		for _, transaction := range history {
+			byID[transaction.ID] = transaction
+		}
+		reversals := 0
+		for _, transaction := range history {
+			if transaction.Type == model.TransactionTypeReversal {
+				assert.Equal(t, transaction.Amount.Neg(), byID[transaction.OriginalTransactionID].Amount)
+				reversals++
+			}
+		}
+		assert.Equal(t, 2, reversals)
+		assert.NoError(t, balances.VerifyBalance(context.Background(), 1))
+	})
+
+	t.Run("Full reversal by the recipient keeps the fee", func(t *testing.T) {
+		store, balances := feesTest.setUp(t)
+		sent, _ := chargedTransfer(t, balances, store)
+
+		response, err := balances.Reverse(customerContext(2), &dto.ReverseRequest{TransactionID: sent.ID, Reason: "not mine"})
+		require.NoError(t, err)
+		assert.Equal(t, model.Money(0), response.Refundable)
+		assert.Equal(t, map[uint]model.Money{1: 9950, 2: 0}, response.Balances)
+		assert.Equal(t, model.Money(50), store.balance(model.SystemAccountHouse, model.USD))
+		assert.Equal(t, model.Money(0), store.total())
+		assert.NoError(t, balances.VerifyBalance(context.Background(), 1))
+	})
+
+	t.Run("Refund keeps the fee", func(t *testing.T) {
+		store, balances := feesTest.setUp(t)
+		sent, _ := chargedTransfer(t, balances, store)
+
+		response, err := balances.Reverse(customerContext(2), &dto.ReverseRequest{TransactionID: sent.ID, Amount: amount(1000), Reason: "one item returned"})
+		require.NoError(t, err)
+		assert.Equal(t, model.Money(1500), response.Refundable)
+		assert.Equal(t, map[uint]model.Money{1: 10000 - 2500 - 50 + 1000, 2: 1500}, response.Balances)
+
+		response, err = balances.Reverse(customerContext(2), &dto.ReverseRequest{TransactionID: sent.ID, Amount: amount(1500), Reason: "rest returned"})
+		require.NoError(t, err)
+		assert.Equal(t, model.Money(0), response.Refundable)
+		assert.Equal(t, model.Money(9950), store.balance(1, model.USD))
+		assert.Equal(t, model.Money(50), store.balance(model.SystemAccountHouse, model.USD))
+		assert.Equal(t, model.Money(0), store.total())
+	})
+
+	t.Run("Fees do not count towards what can be given back", func(t *testing.T) {
+		store, balances := feesTest.setUp(t)
+		sent, _ := chargedTransfer(t, balances, store)
+
+		// Less than the fee has been refunded, so the credits of the entry would still cover
+		// a full reversal if the fee counted
+		_, err := balances.Reverse(customerContext(2), &dto.ReverseRequest{TransactionID: sent.ID, Amount: amount(40), Reason: "one item returned"})
+		require.NoError(t, err)
+		_, err = balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: sent.ID, Reason: "sent to the wrong wallet"})
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+
+		store, balances = feesTest.setUp(t)
+		sent, _ = chargedTransfer(t, balances, store)
+		_, err = balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: sent.ID, Reason: "sent to the wrong wallet"})
+		require.NoError(t, err)
+		_, err = balances.Reverse(customerContext(2), &dto.ReverseRequest{TransactionID: sent.ID, Amount: amount(50), Reason: "one item returned"})
+		assert.ErrorIs(t, err, apperror.ErrConflict)
+		assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+	})
+
+	t.Run("Fees are not reversed on their own", func(t *testing.T) {
+		store, balances := feesTest.setUp(t)
+		_, fee := chargedTransfer(t, balances, store)
+
+		_, err := balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: fee.ID, Reason: "fee waived"})
+		assert.ErrorIs(t, err, apperror.ErrInvalidRequest)
+	})
+}
+
+func TestQuoteFee(t *testing.T) {
+	tests := []struct {
+		name          string
+		request       *dto.FeeQuoteRequest
+		expected      *dto.FeeQuoteResponse
+		expectedError error
+	}{
+		{
+			name:     "Withdrawal",
+			request:  &dto.FeeQuoteRequest{Operation: "withdraw", Amount: 5000},
+			expected: &dto.FeeQuoteResponse{Operation: "Withdraw", Amount: 5000, Currency: model.USD, Fee: 150, Total: 5150},
+		},
+		{
+			name:     "Transfer",
+			request:  &dto.FeeQuoteRequest{Operation: "Transfer", Amount: 2500, Currency: model.USD},
+			expected: &dto.FeeQuoteResponse{Operation: "TransferSend", Amount: 2500, Currency: model.USD, Fee: 50, Total: 2550},
+		},
+		{
+			name:     "Free currency",
+			request:  &dto.FeeQuoteRequest{Operation: "Withdraw", Amount: 2500, Currency: model.EUR},
+			expected: &dto.FeeQuoteResponse{Operation: "Withdraw", Amount: 2500, Currency: model.EUR, Fee: 0, Total: 2500},
+		},
+		{
+			name:          "Deposit",
+			request:       &dto.FeeQuoteRequest{Operation: "Deposit", Amount: 2500},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, balances := feesTest.setUp(t)
+			response, err := balances.QuoteFee(customerContext(2), tt.request)
+			assert.ErrorIs(t, err, tt.expectedError)
+			assert.Equal(t, tt.expected, response)
+			// A quote moves no money
+			assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+		})
+	}
+
+	_, balances := feesTest.setUp(t)
+	_, err := balances.QuoteFee(context.Background(), &dto.FeeQuoteRequest{Operation: "Withdraw", Amount: 100})
+	assert.ErrorIs(t, err, apperror.ErrUnauthenticated)
+}
@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/config"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/validation"
+)
+
+// expiredHoldsBatchSize bounds how many expired holds ExpireHolds reads at once
+const expiredHoldsBatchSize = 100
+
+// AuthorizeHold reserves an amount of the user's wallet for a merchant's wallet until the final
+// amount is known. The money stays in the ledger balance but cannot be spent until the hold is
+// captured, voided or expires. Only merchant wallets may be paid by holds, and only the wallet's
+// owner and admins may place a hold on it.
+func (c *BalanceHandler) AuthorizeHold(ctx context.Context, request *dto.AuthorizeHoldRequest) (*dto.HoldResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.Authorize(ctx, request.UserID); err != nil {
+		return nil, err
+	}
+	key := model.BalanceKey{UserID: request.UserID, Currency: model.CurrencyOrDefault(request.Currency)}
+	payload := *request
+	payload.IdempotencyKey = ""
+	return runIdempotent(ctx, c, request.IdempotencyKey, "authorize_hold", payload, func(ctx context.Context) (*dto.HoldResponse, error) {
+		now := c.now()
+		expiresAt := payload.ExpiresAt
+		if expiresAt.IsZero() {
+			expiresAt = now.Add(config.DefaultHoldLifetime)
+		}
+		if !expiresAt.After(now) || expiresAt.After(now.Add(config.MaxHoldLifetime)) {
+			return nil, validation.Errors{{Field: "expires_at", Message: fmt.Sprintf("must be in the future and at most %d days away", config.MaxHoldLifetime/(24*time.Hour))}}
+		}
+		// Refuse holds for merchants that could not be paid now rather than at capture
+		merchant, err := c.BalanceRepo.GetBalanceRecord(ctx, payload.MerchantID, key.Currency)
+		if err != nil {
+			log.Printf("Error fetching %s balance for user %d: %v\n", key.Currency, payload.MerchantID, err)
+			return nil, fmt.Errorf("failed to fetch %s balance for user %d: %w", key.Currency, payload.MerchantID, err)
+		}
+		if merchant.Product != model.AccountProductMerchant {
+			return nil, validation.Errors{{Field: "merchant_id", Message: "must be a merchant wallet"}}
+		}
+
+		balance, err := c.ledger().readBalance(ctx, key)
+		if err != nil {
+			log.Printf("Error fetching %s balance for user %d: %v\n", key.Currency, key.UserID, err)
+			return nil, fmt.Errorf("failed to fetch %s balance for user %d: %w", key.Currency, key.UserID, err)
+		}
+		if err := checkAccountActive(key.UserID, balance.Status); err != nil {
+			return nil, err
+		}
+		if balance.Available() < payload.Amount {
+			log.Printf("Insufficient %s balance for user %d\n", key.Currency, key.UserID)
+			return nil, &apperror.InsufficientFundsError{UserID: key.UserID, Currency: string(key.Currency)}
+		}
+		if err := c.updateHeld(ctx, balance, balance.Held.Add(payload.Amount)); err != nil {
+			return nil, err
+		}
+
+		hold := &model.Hold{
+			UserID:            key.UserID,
+			MerchantID:        payload.MerchantID,
+			Amount:            payload.Amount,
+			Currency:          key.Currency,
+			Status:            model.HoldStatusAuthorized,
+			Memo:              payload.Memo,
+			ExternalReference: payload.ExternalReference,
+			ExpiresAt:         expiresAt,
+		}
+		if err := c.HoldRepo.CreateHold(ctx, hold); err != nil {
+			log.Printf("Error creating hold for user %d: %v\n", key.UserID, err)
+			return nil, fmt.Errorf("failed to create hold for user %d: %w", key.UserID, err)
+		}
+		return holdResponse(hold), nil
+	})
+}
+
+// CaptureHold pays the merchant out of an authorized hold: the whole held amount or, with an
+// amount, only that much, releasing the rest. Both wallets record the payment as a Capture
+// carrying the hold's memo and external reference. The payment is a transfer to the merchant, so
+// the wallet is charged the fee of a transfer of the captured amount, which has to fit in what is
+// available once the hold is released. Only the merchant and admins may capture a hold, only
+// before it expires, and only while the merchant's wallet is still a merchant wallet.
+func (c *BalanceHandler) CaptureHold(ctx context.Context, request *dto.CaptureHoldRequest) (*dto.HoldResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if _, err := auth.FromContext(ctx); err != nil {
+		return nil, err
+	}
+	payload := *request
+	payload.IdempotencyKey = ""
+	return runIdempotent(ctx, c, request.IdempotencyKey, "capture_hold", payload, func(ctx context.Context) (*dto.HoldResponse, error) {
+		hold, err := c.openHold(ctx, payload.HoldID)
+		if err != nil {
+			return nil, err
+		}
+		merchant, err := c.BalanceRepo.GetBalanceRecord(ctx, hold.MerchantID, hold.Currency)
+		if err != nil {
+			log.Printf("Error fetching %s balance for user %d: %v\n", hold.Currency, hold.MerchantID, err)
+			return nil, fmt.Errorf("failed to fetch %s balance for user %d: %w", hold.Currency, hold.MerchantID, err)
+		}
+		if merchant.Product != model.AccountProductMerchant {
+			return nil, fmt.Errorf("%w: user %d is no longer a merchant", apperror.ErrConflict, hold.MerchantID)
+		}
+		amount := hold.Amount
+		if payload.Amount != nil {
+			amount = *payload.Amount
+			if amount > hold.Amount {
+				return nil, validation.Errors{{Field: "amount", Message: fmt.Sprintf("must not exceed the held amount of %s", hold.Amount)}}
+			}
+			if !hold.Currency.Allows(amount) {
+				return nil, validation.Errors{{Field: "amount", Message: fmt.Sprintf("must have at most %d decimal places in %s", hold.Currency.Decimals(), hold.Currency)}}
+			}
+		}
+
+		// The whole hold is released as the captured part of it is paid
+		entry := &model.JournalEntry{
+			Description: fmt.Sprintf("capture of hold %d of user %d by user %d", hold.ID, hold.UserID, hold.MerchantID),
+			Postings: []model.Posting{
+				{UserID: hold.UserID, Type: model.TransactionTypeCapture, Amount: amount.Neg(), Currency: hold.Currency, CounterpartyID: hold.MerchantID},
+				{UserID: hold.MerchantID, Type: model.TransactionTypeCapture, Amount: amount, Currency: hold.Currency, CounterpartyID: hold.UserID},
+			},
+			Reference: model.TransactionReference{Memo: hold.Memo, ExternalReference: hold.ExternalReference},
+		}
+		fee := c.Fees.Fee(model.TransactionTypeTransferSend, hold.Currency, amount)
+		entry.Postings = append(entry.Postings, feePostings(hold.UserID, hold.Currency, fee)...)
+		released := map[model.BalanceKey]model.Money{{UserID: hold.UserID, Currency: hold.Currency}: hold.Amount}
+		if _, err := c.ledger().PostReleasing(ctx, entry, released); err != nil {
+			return nil, err
+		}
+
+		now := c.now()
+		hold.Status, hold.CapturedAmount, hold.JournalEntryID, hold.ResolvedAt = model.HoldStatusCaptured, amount, entry.ID, &now
+		if err := c.resolveHold(ctx, hold); err != nil {
+			return nil, err
+		}
+		return holdResponse(hold), nil
+	})
+}
+
+// VoidHold cancels an authorized hold without paying anything, making its money available again.
+// Only the merchant and admins may void a hold.
+func (c *BalanceHandler) VoidHold(ctx context.Context, request *dto.HoldRequest) (*dto.HoldResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if _, err := auth.FromContext(ctx); err != nil {
+		return nil, err
+	}
+	var hold *model.Hold
+	err := c.runWithRetry(ctx, func(ctx context.Context) error {
+		var err error
+		hold, err = c.openHold(ctx, request.HoldID)
+		if err != nil {
+			return err
+		}
+		return c.release(ctx, hold, model.HoldStatusVoided)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return holdResponse(hold), nil
+}
+
+// GetHold returns a hold to the owner of its wallet, its merchant or an admin
+func (c *BalanceHandler) GetHold(ctx context.Context, request *dto.HoldRequest) (*dto.HoldResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if _, err := auth.FromContext(ctx); err != nil {
+		return nil, err
+	}
+	hold, err := c.HoldRepo.GetHold(ctx, request.HoldID)
+	if err != nil {
+		log.Printf("Error fetching hold %d: %v\n", request.HoldID, err)
+		return nil, fmt.Errorf("failed to fetch hold %d: %w", request.HoldID, err)
+	}
+	if err := auth.Authorize(ctx, hold.UserID); err != nil {
+		if err := auth.Authorize(ctx, hold.MerchantID); err != nil {
+			return nil, err
+		}
+	}
+	return holdResponse(hold), nil
+}
+
+// ExpireHolds releases every authorized hold whose expiry has passed and returns how many it
+// released. It is run periodically on behalf of the wallet itself rather than a caller, so it
+// authorizes no one.
+func (c *BalanceHandler) ExpireHolds(ctx context.Context) (int, error) {
+	expired := 0
+	for {
+		holds, err := c.HoldRepo.GetExpiredHolds(ctx, c.now(), expiredHoldsBatchSize)
+		if err != nil {
+			log.Printf("Error fetching expired holds: %v\n", err)
+			return expired, fmt.Errorf("failed to fetch expired holds: %w", err)
+		}
+		for _, hold := range holds {
+			err := c.runWithRetry(ctx, func(ctx context.Context) error {
+				expiring := hold
+				return c.release(ctx, &expiring, model.HoldStatusExpired)
+			})
+			// A hold captured or voided since it was read is no longer ours to expire
+			if errors.Is(err, apperror.ErrConflict) {
+				continue
+			}
+			if err != nil {
+				return expired, err
+			}
+			expired++
+		}
+		if len(holds) < expiredHoldsBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// openHold returns the hold with id for its merchant or an admin to capture or void, which is
+// only possible while it is authorized and has not expired
+func (c *BalanceHandler) openHold(ctx context.Context, id uint) (*model.Hold, error) {
+	hold, err := c.HoldRepo.GetHold(ctx, id)
+	if err != nil {
+		log.Printf("Error fetching hold %d: %v\n", id, err)
+		return nil, fmt.Errorf("failed to fetch hold %d: %w", id, err)
+	}
+	if err := auth.Authorize(ctx, hold.MerchantID); err != nil {
+		return nil, err
+	}
+	switch {
+	case hold.Status != model.HoldStatusAuthorized:
+		return nil, fmt.Errorf("%w: hold %d is %s", apperror.ErrConflict, hold.ID, hold.Status)
+	case hold.Expired(c.now()):
+		return nil, fmt.Errorf("%w: hold %d expired at %s", apperror.ErrConflict, hold.ID, hold.ExpiresAt.Format(time.RFC3339))
+	}
+	return hold, nil
+}
+
+// release frees the money reserved by an authorized hold without paying any of it and records
+// the hold as resolved with status
+func (c *BalanceHandler) release(ctx context.Context, hold *model.Hold, status model.HoldStatus) error {
+	if err := c.releaseHeld(ctx, hold); err != nil {
+		return err
+	}
+	now := c.now()
+	hold.Status, hold.ResolvedAt = status, &now
+	return c.resolveHold(ctx, hold)
+}
+
+// releaseHeld takes the hold's amount off the money reserved on its wallet's balance
+func (c *BalanceHandler) releaseHeld(ctx context.Context, hold *model.Hold) error {
+	key := model.BalanceKey{UserID: hold.UserID, Currency: hold.Currency}
+	balance, err := c.ledger().readBalance(ctx, key)
+	if err != nil {
+		log.Printf("Error fetching %s balance for user %d: %v\n", key.Currency, key.UserID, err)
+		return fmt.Errorf("failed to fetch %s balance for user %d: %w", key.Currency, key.UserID, err)
+	}
+	return c.updateHeld(ctx, balance, balance.Held.Sub(hold.Amount))
+}
+
+// updateHeld sets the money reserved by holds on balance
+func (c *BalanceHandler) updateHeld(ctx context.Context, balance *model.Balance, held model.Money) error {
+	err := c.BalanceRepo.UpdateHeld(ctx, balance.UserID, balance.Currency, held, balance.Version)
+	if err != nil {
+		log.Printf("Error updating held %s for user %d: %v\n", balance.Currency, balance.UserID, err)
+		return fmt.Errorf("failed to update held %s for user %d: %w", balance.Currency, balance.UserID, err)
+	}
+	return nil
+}
+
+// resolveHold stores the outcome of an authorized hold, which fails if it was resolved since it
+// was read
+func (c *BalanceHandler) resolveHold(ctx context.Context, hold *model.Hold) error {
+	if err := c.HoldRepo.ResolveHold(ctx, hold); err != nil {
+		log.Printf("Error resolving hold %d: %v\n", hold.ID, err)
+		return fmt.Errorf("failed to resolve hold %d: %w", hold.ID, err)
+	}
+	return nil
+}
+
+// holdResponse converts a hold to its API representation
+func holdResponse(hold *model.Hold) *dto.HoldResponse {
+	return &dto.HoldResponse{
+		ID:                hold.ID,
+		UserID:            hold.UserID,
+		MerchantID:        hold.MerchantID,
+		Amount:            hold.Amount,
+		Currency:          hold.Currency,
+		CapturedAmount:    hold.CapturedAmount,
+		Status:            hold.Status.String(),
+		Memo:              hold.Memo,
+		ExternalReference: hold.ExternalReference,
+		ExpiresAt:         hold.ExpiresAt,
+		JournalEntryID:    hold.JournalEntryID,
+	}
+}
@@ -0,0 +1,428 @@
+package handler
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/config"
+	"walletApp/dto"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// holdTest has user 1 holding 100.00, and merchant 2 and user 3, who is no merchant, nothing
+var holdTest = memoryTest{Funds: map[uint]model.Money{1: 10000, 2: 0, 3: 0}, Merchants: []uint{2}}
+
+func TestAuthorizeAndCaptureHold(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	store, balances := holdTest.at(now).setUp(t)
+
+	hold, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 4000, Memo: "hotel", ExternalReference: "BOOKING-7"})
+	require.NoError(t, err)
+	assert.Equal(t, &dto.HoldResponse{
+		ID:                hold.ID,
+		UserID:            1,
+		MerchantID:        2,
+		Amount:            4000,
+		Currency:          model.USD,
+		Status:            "Authorized",
+		Memo:              "hotel",
+		ExternalReference: "BOOKING-7",
+		ExpiresAt:         now.Add(config.DefaultHoldLifetime),
+	}, hold)
+
+	// The held money stays in the balance but cannot be spent
+	balance, err := balances.CheckBalance(customerContext(1), 1, "")
+	require.NoError(t, err)
+	assert.Equal(t, &dto.CheckBalanceResponse{UserID: 1, Currency: model.USD, Balance: 10000, Available: 6000}, balance)
+	_, err = balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 6001})
+	assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	_, err = balances.Transfer(customerContext(1), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 6001})
+	assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	_, err = balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 6000})
+	require.NoError(t, err)
+
+	// The merchant captures less than was held, which releases the rest
+	amount := model.Money(3000)
+	captured, err := balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: hold.ID, Amount: &amount})
+	require.NoError(t, err)
+	assert.Equal(t, "Captured", captured.Status)
+	assert.Equal(t, model.Money(3000), captured.CapturedAmount)
+	assert.NotZero(t, captured.JournalEntryID)
+	assert.Equal(t, model.Money(1000), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(3000), store.balance(2, model.USD))
+	assert.Equal(t, model.Money(0), store.heldAmount(1, model.USD))
+
+	// Both wallets record the payment with the hold's reference
+	for _, tt := range []struct {
+		userID, counterpartyID uint
+		amount                 model.Money
+	}{{1, 2, -3000}, {2, 1, 3000}} {
+		history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: tt.userID, Types: []model.TransactionType{model.TransactionTypeCapture}})
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, tt.amount, history[0].Amount)
+		assert.Equal(t, tt.counterpartyID, history[0].CounterpartyID)
+		assert.Equal(t, "hotel", history[0].Memo)
+		assert.Equal(t, "BOOKING-7", history[0].ExternalReference)
+	}
+
+	// A hold is captured only once
+	_, err = balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: hold.ID})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	assert.Equal(t, model.Money(0), store.total())
+	assert.NoError(t, balances.VerifyBalance(context.Background(), 1))
+	assert.NoError(t, balances.VerifyBalance(context.Background(), 2))
+}
+
+func TestCaptureHoldInFull(t *testing.T) {
+	store, balances := holdTest.at(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)).setUp(t)
+	hold, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 4000})
+	require.NoError(t, err)
+
+	// More than was held cannot be captured
+	tooMuch := model.Money(4001)
+	_, err = balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: hold.ID, Amount: &tooMuch})
+	assert.ErrorIs(t, err, apperror.ErrInvalidRequest)
+
+	// Retrying with the same idempotency key does not pay twice
+	request := &dto.CaptureHoldRequest{HoldID: hold.ID, IdempotencyKey: "capture-1"}
+	first, err := balances.CaptureHold(adminContext(), request)
+	require.NoError(t, err)
+	second, err := balances.CaptureHold(adminContext(), request)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, model.Money(4000), first.CapturedAmount)
+	assert.Equal(t, model.Money(6000), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(4000), store.balance(2, model.USD))
+	assert.Equal(t, model.Money(0), store.heldAmount(1, model.USD))
+}
+
+func TestCaptureHoldFee(t *testing.T) {
+	store, balances := holdTest.at(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)).setUp(t)
+	balances.Fees = testFees
+	hold, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 10000, Memo: "hotel"})
+	require.NoError(t, err)
+
+	// The wallet is charged the transfer fee on top of the captured amount, which the whole hold
+	// leaves no room for
+	_, err = balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: hold.ID})
+	assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	assert.Equal(t, model.Money(10000), store.heldAmount(1, model.USD))
+
+	amount := model.Money(9950)
+	_, err = balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: hold.ID, Amount: &amount})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(0), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(9950), store.balance(2, model.USD))
+	assert.Equal(t, model.Money(50), store.balance(model.SystemAccountHouse, model.USD))
+	charged, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1, Types: []model.TransactionType{model.TransactionTypeFee}})
+	require.NoError(t, err)
+	require.Len(t, charged, 1)
+	assert.Equal(t, model.Money(-50), charged[0].Amount)
+	assert.Equal(t, "hotel", charged[0].Memo)
+	assert.Equal(t, model.Money(0), store.total())
+}
+
+func TestVoidHold(t *testing.T) {
+	store, balances := holdTest.at(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)).setUp(t)
+	hold, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 4000})
+	require.NoError(t, err)
+
+	// The payer can read the hold but only the merchant or an admin may release or capture it
+	_, err = balances.GetHold(customerContext(1), &dto.HoldRequest{HoldID: hold.ID})
+	assert.NoError(t, err)
+	_, err = balances.GetHold(customerContext(3), &dto.HoldRequest{HoldID: hold.ID})
+	assert.ErrorIs(t, err, apperror.ErrForbidden)
+	_, err = balances.VoidHold(customerContext(1), &dto.HoldRequest{HoldID: hold.ID})
+	assert.ErrorIs(t, err, apperror.ErrForbidden)
+	_, err = balances.CaptureHold(customerContext(1), &dto.CaptureHoldRequest{HoldID: hold.ID})
+	assert.ErrorIs(t, err, apperror.ErrForbidden)
+
+	voided, err := balances.VoidHold(customerContext(2), &dto.HoldRequest{HoldID: hold.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "Voided", voided.Status)
+	assert.Equal(t, model.Money(0), voided.CapturedAmount)
+	assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(0), store.heldAmount(1, model.USD))
+
+	_, err = balances.VoidHold(customerContext(2), &dto.HoldRequest{HoldID: hold.ID})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	_, err = balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: hold.ID})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	_, err = balances.VoidHold(customerContext(2), &dto.HoldRequest{HoldID: hold.ID + 1})
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+}
+
+func TestExpireHolds(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	store, balances := memoryTest{Funds: map[uint]model.Money{1: 10000, 2: 0}, Merchants: []uint{2}}.withClock(func() time.Time { return now }).setUp(t)
+
+	short, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 1000, ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	long, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 2000})
+	require.NoError(t, err)
+
+	expired, err := balances.ExpireHolds(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired)
+
+	// Once a hold expires it can no longer be captured, even before the sweeper releases it
+	now = now.Add(time.Hour)
+	_, err = balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: short.ID})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	assert.Equal(t, model.Money(3000), store.heldAmount(1, model.USD))
+
+	expired, err = balances.ExpireHolds(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	hold, err := balances.GetHold(customerContext(1), &dto.HoldRequest{HoldID: short.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "Expired", hold.Status)
+	assert.Equal(t, model.Money(2000), store.heldAmount(1, model.USD))
+
+	now = now.Add(config.DefaultHoldLifetime)
+	expired, err = balances.ExpireHolds(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	hold, err = balances.GetHold(customerContext(1), &dto.HoldRequest{HoldID: long.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "Expired", hold.Status)
+	assert.Equal(t, model.Money(0), store.heldAmount(1, model.USD))
+	assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+}
+
+func TestAuthorizeHoldErrors(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		request       *dto.AuthorizeHoldRequest
+		expectedError error
+	}{
+		{
+			name:          "More than is available",
+			ctx:           customerContext(1),
+			request:       &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 7001},
+			expectedError: apperror.ErrInsufficientFunds,
+		},
+		{
+			name:          "Expiry in the past",
+			ctx:           customerContext(1),
+			request:       &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 100, ExpiresAt: now},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+		{
+			name:          "Expiry too far away",
+			ctx:           customerContext(1),
+			request:       &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 100, ExpiresAt: now.Add(config.MaxHoldLifetime + time.Second)},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+		{
+			name:          "Merchant without a wallet",
+			ctx:           customerContext(1),
+			request:       &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 9, Amount: 100},
+			expectedError: apperror.ErrAccountNotFound,
+		},
+		{
+			name:          "Wallet that is not a merchant's",
+			ctx:           customerContext(1),
+			request:       &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 3, Amount: 100},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+		{
+			name:          "Merchant without a wallet in the currency",
+			ctx:           customerContext(1),
+			request:       &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 100, Currency: model.EUR},
+			expectedError: apperror.ErrAccountNotFound,
+		},
+		{
+			name:          "Someone else's wallet",
+			ctx:           customerContext(2),
+			request:       &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 100},
+			expectedError: apperror.ErrForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, balances := holdTest.at(now).setUp(t)
+			// Another hold already reserves 30.00
+			_, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 3000})
+			require.NoError(t, err)
+
+			_, err = balances.AuthorizeHold(tt.ctx, tt.request)
+			assert.ErrorIs(t, err, tt.expectedError)
+			assert.Equal(t, model.Money(3000), store.heldAmount(1, model.USD))
+		})
+	}
+}
+
+func TestHoldsOfFrozenWallets(t *testing.T) {
+	store, balances := holdTest.at(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)).setUp(t)
+	accounts := newMemoryAccountHandler(store)
+	hold, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 4000})
+	require.NoError(t, err)
+
+	// A frozen merchant cannot be paid, so the hold stays authorized
+	_, err = accounts.FreezeAccount(adminContext(), &dto.AccountRequest{UserID: 2})
+	require.NoError(t, err)
+	_, err = balances.CaptureHold(adminContext(), &dto.CaptureHoldRequest{HoldID: hold.ID})
+	assert.ErrorIs(t, err, apperror.ErrAccountFrozen)
+	assert.Equal(t, model.Money(4000), store.heldAmount(1, model.USD))
+	current, err := balances.GetHold(adminContext(), &dto.HoldRequest{HoldID: hold.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "Authorized", current.Status)
+
+	// Voiding only frees the money, which a frozen wallet allows
+	_, err = accounts.FreezeAccount(adminContext(), &dto.AccountRequest{UserID: 1})
+	require.NoError(t, err)
+	_, err = balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 100})
+	assert.ErrorIs(t, err, apperror.ErrAccountFrozen)
+	_, err = balances.VoidHold(adminContext(), &dto.HoldRequest{HoldID: hold.ID})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(0), store.heldAmount(1, model.USD))
+}
+
+func TestCloseAccountWithHold(t *testing.T) {
+	store, balances := holdTest.at(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)).setUp(t)
+	accounts := newMemoryAccountHandler(store)
+	_, err := accounts.SetOverdraftLimit(adminContext(), &dto.SetOverdraftLimitRequest{UserID: 3, Limit: 5000})
+	require.NoError(t, err)
+	hold, err := balances.AuthorizeHold(customerContext(3), &dto.AuthorizeHoldRequest{UserID: 3, MerchantID: 2, Amount: 3000})
+	require.NoError(t, err)
+
+	// The balance is zero, but closing would leave the hold to be captured from a closed wallet
+	_, err = accounts.CloseAccount(customerContext(3), &dto.AccountRequest{UserID: 3})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	assert.ErrorContains(t, err, "with 30.00 USD on hold")
+
+	_, err = balances.VoidHold(adminContext(), &dto.HoldRequest{HoldID: hold.ID})
+	require.NoError(t, err)
+	closed, err := accounts.CloseAccount(customerContext(3), &dto.AccountRequest{UserID: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "Closed", closed.Status)
+}
+
+func TestCaptureForFormerMerchant(t *testing.T) {
+	store, balances := holdTest.at(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)).setUp(t)
+	hold, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 4000})
+	require.NoError(t, err)
+
+	// A wallet that stopped being a merchant's cannot be paid by its holds any more
+	_, err = newMemoryAccountHandler(store).SetProduct(adminContext(), &dto.SetProductRequest{UserID: 2, Product: "checking"})
+	require.NoError(t, err)
+	_, err = balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: hold.ID})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	assert.Equal(t, model.Money(0), store.balance(2, model.USD))
+	_, err = balances.VoidHold(customerContext(2), &dto.HoldRequest{HoldID: hold.ID})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(0), store.heldAmount(1, model.USD))
+}
+
+func TestRefundCapturedHold(t *testing.T) {
+	store, balances := holdTest.at(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)).setUp(t)
+	hold, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 4000})
+	require.NoError(t, err)
+	_, err = balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: hold.ID})
+	require.NoError(t, err)
+	paid, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, paid, 1)
+
+	// The payer cannot take the money back, but the merchant can refund it
+	amount := model.Money(1500)
+	_, err = balances.Reverse(customerContext(1), &dto.ReverseRequest{TransactionID: paid[0].ID, Amount: &amount, Reason: "ask the merchant"})
+	assert.ErrorIs(t, err, apperror.ErrForbidden)
+	refund, err := balances.Reverse(customerContext(2), &dto.ReverseRequest{TransactionID: paid[0].ID, Amount: &amount, Reason: "minibar not used"})
+	require.NoError(t, err)
+	assert.Equal(t, map[uint]model.Money{1: 7500, 2: 2500}, refund.Balances)
+	assert.Equal(t, model.Money(2500), refund.Refundable)
+}
+
+func TestConcurrentHoldsConserveMoney(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency ConcurrencyControl
+	}{
+		{name: "Pessimistic locking", concurrency: PessimisticLocking},
+		{name: "Optimistic locking", concurrency: OptimisticLocking},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, balances := memoryTest{Funds: map[uint]model.Money{1: 100000, 2: 100000, 3: 100000}, Merchants: []uint{1, 2, 3}}.setUp(t)
+			balances.Concurrency = tt.concurrency
+
+			// Each worker places holds between random wallets and captures or voids them while
+			// the others spend and hold the same money
+			const workers = 16
+			const operationsPerWorker = 30
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			var unresolved []uint
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(seed int64) {
+					defer wg.Done()
+					rng := rand.New(rand.NewSource(seed))
+					for i := 0; i < operationsPerWorker; i++ {
+						payer, merchant := uint(rng.Intn(3)+1), uint(rng.Intn(2)+1)
+						if merchant >= payer {
+							merchant++
+						}
+						amount := model.Money(rng.Intn(20000) + 1)
+						if rng.Intn(3) == 0 {
+							balances.Transfer(adminContext(), &dto.TransferRequest{FromUserID: payer, ToUserID: merchant, Amount: amount})
+							continue
+						}
+						hold, err := balances.AuthorizeHold(adminContext(), &dto.AuthorizeHoldRequest{UserID: payer, MerchantID: merchant, Amount: amount})
+						if err != nil {
+							continue
+						}
+						if rng.Intn(2) == 0 {
+							captured := model.Money(rng.Intn(int(amount)) + 1)
+							_, err = balances.CaptureHold(adminContext(), &dto.CaptureHoldRequest{HoldID: hold.ID, Amount: &captured})
+						} else {
+							_, err = balances.VoidHold(adminContext(), &dto.HoldRequest{HoldID: hold.ID})
+						}
+						// Optimistic locking may run out of retries, leaving the hold to void later
+						if err != nil {
+							assert.ErrorIs(t, err, apperror.ErrConflict)
+							mu.Lock()
+							unresolved = append(unresolved, hold.ID)
+							mu.Unlock()
+						}
+					}
+				}(int64(w))
+			}
+
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(30 * time.Second):
+				t.Fatal("concurrent holds did not finish, row locks are probably taken in an inconsistent order")
+			}
+			for _, holdID := range unresolved {
+				_, err := balances.VoidHold(adminContext(), &dto.HoldRequest{HoldID: holdID})
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, model.Money(0), store.total(), "money was created or destroyed")
+			for _, userID := range []uint{1, 2, 3} {
+				assert.Equal(t, model.Money(0), store.heldAmount(userID, model.USD), "user %d still has money held", userID)
+				assert.GreaterOrEqual(t, store.balance(userID, model.USD), model.Money(0))
+				assert.NoError(t, balances.VerifyBalance(adminContext(), userID))
+			}
+		})
+	}
+}
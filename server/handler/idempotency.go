@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/model"
+)
+
+// ErrIdempotencyKeyReused is returned when an idempotency key is sent again with a different
+// request than the one it was first used for. It matches apperror.ErrConflict.
+var ErrIdempotencyKeyReused = fmt.Errorf("%w: idempotency key was already used for a different request", apperror.ErrConflict)
+
+// internalKeyContextKey is the context key under which withInternalIdempotencyKey stores a key
+type internalKeyContextKey struct{}
+
+// withInternalIdempotencyKey returns a copy of ctx making the request it is used for idempotent
+// under the internal key named name, in place of any key of the request. Request validation
+// rejects keys with the internal prefix, so no client can send the same key.
+func withInternalIdempotencyKey(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, internalKeyContextKey{}, model.InternalIdempotencyKeyPrefix+name)
+}
+
+// runIdempotent runs op in a unit of work. When key is set, the response of the first
+// successful run is stored under it in the same unit of work, and later calls with that key
+// return the stored response without running op again. Failed runs are not stored, so a
+// request that failed can be retried with the same key. Keys are scoped to the caller: another
+// caller sending the same key neither gets the stored response nor learns the key is taken.
+func runIdempotent[T any](ctx context.Context, c *BalanceHandler, key, operation string, payload any, op func(ctx context.Context) (*T, error)) (*T, error) {
+	if internal, ok := ctx.Value(internalKeyContextKey{}).(string); ok {
+		key = internal
+	}
+	var response *T
+	if key == "" {
+		err := c.runWithRetry(ctx, func(ctx context.Context) error {
+			var err error
+			response, err = op(ctx)
+			return err
+		})
+		return response, err
+	}
+
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := requestFingerprint(principal.UserID, operation, payload)
+	if err != nil {
+		return nil, err
+	}
+	err = c.runWithRetry(ctx, func(ctx context.Context) error {
+		record, err := c.IdempotencyRepo.GetIdempotencyRecord(ctx, principal.UserID, key)
+		if err != nil {
+			log.Printf("Error fetching idempotency record %q: %v\n", key, err)
+			return fmt.Errorf("failed to fetch idempotency record: %w", err)
+		}
+		if record != nil {
+			response, err = replay[T](record, operation, fingerprint)
+			return err
+		}
+
+		response, err = op(ctx)
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(response)
+		if err != nil {
+			return fmt.Errorf("failed to encode response for idempotency key %q: %w", key, err)
+		}
+		err = c.IdempotencyRepo.CreateIdempotencyRecord(ctx, &model.IdempotencyRecord{
+			UserID:      principal.UserID,
+			Key:         key,
+			Operation:   operation,
+			Fingerprint: fingerprint,
+			Response:    string(body),
+		})
+		if err != nil {
+			log.Printf("Error storing idempotency record %q: %v\n", key, err)
+			return fmt.Errorf("failed to store idempotency record: %w", err)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrIdempotencyKeyReused) {
+		// A concurrent request with the same key may have committed first, in which case our
+		// insert failed on the unique key and the stored response is the one to return
+		if record, lookupErr := c.IdempotencyRepo.GetIdempotencyRecord(ctx, principal.UserID, key); lookupErr == nil && record != nil {
+			return replay[T](record, operation, fingerprint)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// replay decodes the response stored in record after checking it belongs to the same request
+func replay[T any](record *model.IdempotencyRecord, operation, fingerprint string) (*T, error) {
+	if record.Operation != operation || record.Fingerprint != fingerprint {
+		return nil, fmt.Errorf("%w: %q", ErrIdempotencyKeyReused, record.Key)
+	}
+	log.Printf("Replaying stored response for idempotency key %q\n", record.Key)
+	var response T
+	if err := json.Unmarshal([]byte(record.Response), &response); err != nil {
+		return nil, fmt.Errorf("failed to decode stored response for idempotency key %q: %w", record.Key, err)
+	}
+	return &response, nil
+}
+
+// requestFingerprint hashes the caller, operation and request payload, which must not include
+// the idempotency key itself
+func requestFingerprint(callerID uint, operation string, payload any) (string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+	sum := sha256.Sum256(append([]byte(fmt.Sprintf("%d:%s:", callerID, operation)), body...))
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepositWithIdempotencyKey(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000})
+	handler := newMemoryBalanceHandler(store)
+	ctx := adminContext()
+
+	request := &dto.DepositRequest{UserID: 1, Amount: 2500, IdempotencyKey: "deposit-1"}
+	first, err := handler.Deposit(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(12500), first.Balance)
+
+	// A retry returns the stored response without depositing again
+	replayed, err := handler.Deposit(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, first, replayed)
+	assert.Equal(t, model.Money(12500), store.balance(1, model.USD))
+	assert.Len(t, store.transactions, 1)
+
+	// Reusing the key for a different payload is rejected
+	_, err = handler.Deposit(ctx, &dto.DepositRequest{UserID: 1, Amount: 9900, IdempotencyKey: "deposit-1"})
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// Reusing the key for a different operation is rejected
+	_, err = handler.Withdraw(ctx, &dto.WithdrawRequest{UserID: 1, Amount: 2500, IdempotencyKey: "deposit-1"})
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	assert.Equal(t, model.Money(12500), store.balance(1, model.USD))
+}
+
+func TestTransferWithIdempotencyKey(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 0})
+	handler := newMemoryBalanceHandler(store)
+	ctx := adminContext()
+
+	// A failed attempt is not stored, so the same key can be retried once funds arrive
+	request := &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 15000, IdempotencyKey: "transfer-1"}
+	_, err := handler.Transfer(ctx, request)
+	assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	assert.Empty(t, store.idempotency)
+
+	_, err = handler.Deposit(ctx, &dto.DepositRequest{UserID: 1, Amount: 5000})
+	require.NoError(t, err)
+
+	first, err := handler.Transfer(ctx, request)
+	require.NoError(t, err)
+	assert.True(t, first.Success)
+
+	replayed, err := handler.Transfer(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, first, replayed)
+	assert.Equal(t, model.Money(0), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(15000), store.balance(2, model.USD))
+}
+
+func TestIdempotencyKeysAreScopedToCaller(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 10000})
+	handler := newMemoryBalanceHandler(store)
+	otherAdmin := auth.WithPrincipal(context.Background(), auth.Principal{UserID: 101, Role: model.UserRoleAdmin})
+
+	// Another caller sending the same key and payload makes a request of its own rather than
+	// getting the first caller's response back
+	request := &dto.DepositRequest{UserID: 1, Amount: 2500, IdempotencyKey: "deposit-1"}
+	_, err := handler.Deposit(adminContext(), request)
+	require.NoError(t, err)
+	_, err = handler.Deposit(otherAdmin, request)
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(15000), store.balance(1, model.USD))
+
+	// Nor does a different payload under the same key conflict with the other caller's
+	_, err = handler.Transfer(customerContext(1), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 1000, IdempotencyKey: "rent"})
+	require.NoError(t, err)
+	_, err = handler.Transfer(customerContext(2), &dto.TransferRequest{FromUserID: 2, ToUserID: 1, Amount: 3000, IdempotencyKey: "rent"})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(17000), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(8000), store.balance(2, model.USD))
+}
+
+func TestRunIdempotentLostRace(t *testing.T) {
+	// The first lookup finds nothing, the insert then fails because a concurrent request with
+	// the same key committed first, and the follow-up lookup finds that request's response
+	fingerprint, err := requestFingerprint(100, "deposit", dto.DepositRequest{UserID: 1, Amount: 2500})
+	require.NoError(t, err)
+	stored := &model.IdempotencyRecord{
+		UserID:      100,
+		Key:         "deposit-1",
+		Operation:   "deposit",
+		Fingerprint: fingerprint,
+		Response:    `{"success":true,"message":"Success Deposit","balance":25.00}`,
+	}
+	mockIdempotencyRepo := storage.NewMockIdempotencyRepository(func(m *mock.Mock) {
+		m.On("GetIdempotencyRecord", mock.Anything, uint(100), "deposit-1").Return(nil, nil).Once()
+		m.On("CreateIdempotencyRecord", mock.Anything, mock.Anything).Return(errors.New("duplicate key value violates unique constraint"))
+		m.On("GetIdempotencyRecord", mock.Anything, uint(100), "deposit-1").Return(stored, nil)
+	})
+	handler := &BalanceHandler{
+		IdempotencyRepo: mockIdempotencyRepo,
+		UnitOfWork:      newPassthroughUnitOfWork(),
+	}
+
+	response, err := runIdempotent(adminContext(), handler, "deposit-1", "deposit", dto.DepositRequest{UserID: 1, Amount: 2500},
+		func(ctx context.Context) (*dto.DepositResponse, error) {
+			return &dto.DepositResponse{Success: true, Message: "Success Deposit", Balance: 2500}, nil
+		})
+
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(2500), response.Balance)
+}
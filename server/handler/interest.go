@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"walletApp/apperror"
+	"walletApp/config"
+	"walletApp/interest"
+	"walletApp/limits"
+	"walletApp/model"
+	"walletApp/storage"
+)
+
+// accrualBatchSize bounds how many balances AccrueInterest reads at once
+const accrualBatchSize = 100
+
+// InterestHandler accrues interest on savings wallets and on overdrawn wallets every day, and
+// settles it every month. A day's interest is worked out from the balance at the end of the day,
+// so a day is accrued once it is over, and a month is settled once all of its days are.
+type InterestHandler struct {
+	InterestRepo storage.InterestRepository
+	// Balances reads the balances and their transactions and posts the settlements. Its
+	// Overdraft policy holds the interest rate of overdrawn balances.
+	Balances *BalanceHandler
+	// Rates holds the annual rate of every product and currency, see the interest package
+	Rates interest.Rates
+	// Now returns the current time, which decides which days and months are over. time.Now is
+	// used when nil.
+	Now func() time.Time
+}
+
+// NewInterestHandler creates a new instance of InterestHandler paying interest through balances
+func NewInterestHandler(balances *BalanceHandler) *InterestHandler {
+	return &InterestHandler{
+		InterestRepo: storage.NewInterestRepository(config.DB),
+		Balances:     balances,
+		Rates:        config.InterestRates(),
+	}
+}
+
+// now returns the current time according to the handler's clock
+func (c *InterestHandler) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// AccrueInterest records the interest every savings wallet earned, and every wallet with a
+// credit line owed, on each of its balances over the days that are over and not yet accrued, and
+// returns how many days it accrued. A balance seen for the first time starts accruing on the
+// current day, so the day before is recorded without interest; days missed while the job did not
+// run are caught up on, up to config.InterestMaxCatchUpDays. It is run periodically on behalf of
+// the wallet itself rather than a caller, so it authorizes no one.
+func (c *InterestHandler) AccrueInterest(ctx context.Context) (int, error) {
+	today := interest.DayStart(c.now())
+	accrued := 0
+	savings := func(ctx context.Context, after model.BalanceKey, limit int) ([]model.Balance, error) {
+		return c.Balances.BalanceRepo.GetBalancesByProduct(ctx, model.AccountProductSavings, after, limit)
+	}
+	// A savings wallet with a credit line is listed twice, and has nothing left to accrue the
+	// second time
+	for _, list := range []func(ctx context.Context, after model.BalanceKey, limit int) ([]model.Balance, error){
+		savings,
+		c.Balances.BalanceRepo.GetBalancesWithOverdraft,
+	} {
+		after := model.BalanceKey{}
+		for {
+			balances, err := list(ctx, after, accrualBatchSize)
+			if err != nil {
+				log.Printf("Error fetching balances accruing interest: %v\n", err)
+				return accrued, fmt.Errorf("failed to fetch balances accruing interest: %w", err)
+			}
+			for _, balance := range balances {
+				days, err := c.accrue(ctx, balance.Key(), today)
+				// Another run accrued the balance meanwhile
+				if errors.Is(err, apperror.ErrConflict) {
+					continue
+				}
+				if err != nil {
+					log.Printf("Error accruing %s interest for user %d: %v\n", balance.Currency, balance.UserID, err)
+					return accrued, fmt.Errorf("failed to accrue %s interest for user %d: %w", balance.Currency, balance.UserID, err)
+				}
+				accrued += days
+			}
+			if len(balances) < accrualBatchSize {
+				break
+			}
+			after = balances[len(balances)-1].Key()
+		}
+	}
+	return accrued, nil
+}
+
+// accruesInterest reports whether interest accrues on balance: the wallet is not closed, and the
+// balance is a savings wallet's, has a credit line, or is still overdrawn after losing it
+func accruesInterest(balance *model.Balance) bool {
+	if balance.Status == model.AccountStatusClosed {
+		return false
+	}
+	return balance.Product == model.AccountProductSavings || balance.OverdraftLimit.IsPositive() || balance.IsOverdrawn()
+}
+
+// accrue records the interest of the balance of key for every day before today since its last
+// accrual, and returns how many days it recorded
+func (c *InterestHandler) accrue(ctx context.Context, key model.BalanceKey, today time.Time) (int, error) {
+	accrued := 0
+	err := c.Balances.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		accrued = 0
+		// Lock the balance so that no posting lands between reading it and adding up what
+		// changed it since the end of each day
+		balance, err := c.Balances.BalanceRepo.GetBalanceForUpdate(ctx, key.UserID, key.Currency)
+		if err != nil {
+			return err
+		}
+		// The wallet may have changed since it was listed
+		if !accruesInterest(balance) {
+			return nil
+		}
+		last, err := c.InterestRepo.GetLastAccrual(ctx, key.UserID, key.Currency)
+		if err != nil {
+			return err
+		}
+		if last == nil || last.Day.Before(today.AddDate(0, 0, -config.InterestMaxCatchUpDays)) {
+			// A balance seen for the first time, or again after a long break, earns from today
+			// on. Yesterday is recorded without interest so that later runs know where to start.
+			return c.record(ctx, balance, today.AddDate(0, 0, -1), false)
+		}
+		for day := interest.DayStart(last.Day).AddDate(0, 0, 1); day.Before(today); day = day.AddDate(0, 0, 1) {
+			if err := c.record(ctx, balance, day, true); err != nil {
+				return err
+			}
+			accrued++
+		}
+		return nil
+	})
+	return accrued, err
+}
+
+// record accrues the interest balance earned over day at the rate of its product, or owed at
+// the overdraft rate when it ended the day below zero, or records the day without interest
+// unless accrue is set. Its balance at the end of the day is the current one less what the
+// transactions made since then added to it.
+func (c *InterestHandler) record(ctx context.Context, balance *model.Balance, day time.Time, accrue bool) error {
+	endOfDay := day.AddDate(0, 0, 1)
+	changed, err := c.Balances.TransactionRepo.SumAmountsSince(ctx, balance.UserID, balance.Currency, endOfDay)
+	if err != nil {
+		return err
+	}
+	accrual := &model.InterestAccrual{
+		UserID:   balance.UserID,
+		Currency: balance.Currency,
+		Day:      day,
+		Balance:  balance.Balance.Sub(changed),
+	}
+	switch {
+	case !accrue:
+	case accrual.Balance.IsNegative():
+		accrual.RateBasisPoints = c.Balances.Overdraft.Terms(balance.Currency).RateBasisPoints
+		accrual.Amount = -interest.Daily(accrual.Balance.Neg(), accrual.RateBasisPoints)
+	default:
+		accrual.RateBasisPoints = c.Rates.Rate(balance.Product, balance.Currency)
+		accrual.Amount = interest.Daily(accrual.Balance, accrual.RateBasisPoints)
+	}
+	return c.InterestRepo.CreateAccrual(ctx, accrual)
+}
+
+// PayInterest settles the interest every balance accrued over the months that are over, and
+// returns how many payments it made. The month's accruals are added up and rounded towards zero
+// to the decimal places of the currency; the fraction left over is not carried into the next
+// month. What a balance earned is credited from the house account as TransactionTypeInterest,
+// and what it owes for being overdrawn is charged to it as TransactionTypeOverdraftInterest. A
+// frozen wallet is settled once it is active again; a wallet cannot be closed with interest left
+// to settle. It is run periodically on behalf of the wallet itself rather than a caller, so it
+// authorizes no one.
+func (c *InterestHandler) PayInterest(ctx context.Context) (int, error) {
+	monthStart := limits.MonthStart(c.now())
+	keys, err := c.InterestRepo.GetUnpaidBalances(ctx, monthStart)
+	if err != nil {
+		log.Printf("Error fetching unpaid interest: %v\n", err)
+		return 0, fmt.Errorf("failed to fetch unpaid interest: %w", err)
+	}
+	paid := 0
+	for _, key := range keys {
+		var amount model.Money
+		err := c.Balances.runWithRetry(ctx, func(ctx context.Context) error {
+			var err error
+			amount, err = c.pay(ctx, key, monthStart)
+			return err
+		})
+		switch {
+		// Another run paid the balance meanwhile
+		case errors.Is(err, apperror.ErrConflict):
+			continue
+		case errors.Is(err, apperror.ErrAccountFrozen) || errors.Is(err, apperror.ErrAccountClosed):
+			log.Printf("Not settling %s interest with user %d yet: %v\n", key.Currency, key.UserID, err)
+			continue
+		case err != nil:
+			log.Printf("Error settling %s interest with user %d: %v\n", key.Currency, key.UserID, err)
+			return paid, fmt.Errorf("failed to settle %s interest with user %d: %w", key.Currency, key.UserID, err)
+		}
+		if amount != 0 {
+			paid++
+		}
+	}
+	return paid, nil
+}
+
+// pay credits the balance of key with its unpaid accruals of days before before, or charges it
+// when they add up to less than zero, and marks them paid, returning the amount credited
+func (c *InterestHandler) pay(ctx context.Context, key model.BalanceKey, before time.Time) (model.Money, error) {
+	accruals, err := c.InterestRepo.GetUnpaidAccruals(ctx, key.UserID, key.Currency, before)
+	if err != nil || len(accruals) == 0 {
+		return 0, err
+	}
+	var total int64
+	ids := make([]uint, 0, len(accruals))
+	for _, accrual := range accruals {
+		total += accrual.Amount
+		ids = append(ids, accrual.ID)
+	}
+	amount := key.Currency.Truncate(model.AccruedMoney(total))
+	var journalEntryID uint
+	if amount != 0 {
+		month := accruals[len(accruals)-1].Day.Format("January 2006")
+		entry := &model.JournalEntry{
+			Description: fmt.Sprintf("interest to user %d", key.UserID),
+			Reference:   model.TransactionReference{Memo: fmt.Sprintf("Interest for %s", month)},
+		}
+		transactionType := model.TransactionTypeInterest
+		if amount.IsNegative() {
+			transactionType = model.TransactionTypeOverdraftInterest
+			entry.Description = fmt.Sprintf("overdraft interest from user %d", key.UserID)
+			entry.Reference.Memo = fmt.Sprintf("Overdraft interest for %s", month)
+		}
+		entry.Postings = []model.Posting{
+			{UserID: model.SystemAccountHouse, Type: transactionType, Amount: amount.Neg(), Currency: key.Currency},
+			{UserID: key.UserID, Type: transactionType, Amount: amount, Currency: key.Currency},
+		}
+		if _, err := c.Balances.ledger().Post(ctx, entry); err != nil {
+			return 0, err
+		}
+		journalEntryID = entry.ID
+	}
+	if err := c.InterestRepo.MarkAccrualsPaid(ctx, ids, journalEntryID, c.now()); err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
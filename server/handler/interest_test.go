@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/dto"
+	"walletApp/interest"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testInterestRates pays savings wallets 3.65% a year in USD, which is a tenth of a percent over
+// ten days: 0.10 a day on 1,000.00
+var testInterestRates = interest.Rates{model.AccountProductSavings: {model.USD: 365}}
+
+// interestTest has user 1 holding 1,000.00 in a savings wallet and user 2 500.00 in a checking
+// wallet
+var interestTest = memoryTest{Funds: map[uint]model.Money{1: 100000, 2: 50000}, Savings: []uint{1}}
+
+// newMemoryInterestHandler returns an InterestHandler backed by store paying testInterestRates,
+// which settles through balances and shares its clock
+func newMemoryInterestHandler(store *memoryStore, balances *BalanceHandler) *InterestHandler {
+	return &InterestHandler{InterestRepo: store, Balances: balances, Rates: testInterestRates, Now: balances.Now}
+}
+
+// runInterest runs the interest jobs the way the app does, accruing and then paying out, and
+// returns how many days were accrued and how many payouts were made
+func runInterest(t *testing.T, handler *InterestHandler) (accrued, paid int) {
+	accrued, err := handler.AccrueInterest(context.Background())
+	require.NoError(t, err)
+	paid, err = handler.PayInterest(context.Background())
+	require.NoError(t, err)
+	return accrued, paid
+}
+
+// accrualAmounts returns the balance and interest of every accrual of the user's USD balance,
+// oldest first
+func accrualAmounts(store *memoryStore, userID uint) (balances []model.Money, amounts []int64) {
+	for _, accrual := range store.interestAccruals() {
+		if accrual.UserID == userID && accrual.Currency == model.USD {
+			balances = append(balances, accrual.Balance)
+			amounts = append(amounts, accrual.Amount)
+		}
+	}
+	return balances, amounts
+}
+
+func TestInterestAccruesDailyAndPaysMonthly(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 2, 28, 12, 0, 0, 0, time.UTC)}
+	store, balances := interestTest.withClock(clock.Now).setUp(t)
+	handler := newMemoryInterestHandler(store, balances)
+
+	// The first run only notes where user 1's wallet starts earning
+	accrued, paid := runInterest(t, handler)
+	assert.Equal(t, 0, accrued)
+	assert.Equal(t, 0, paid)
+
+	clock.now = clock.now.AddDate(0, 0, 1)
+	accrued, _ = runInterest(t, handler)
+	assert.Equal(t, 1, accrued)
+	_, err := handler.Balances.Deposit(adminContext(), &dto.DepositRequest{UserID: 1, Amount: 100000})
+	require.NoError(t, err)
+
+	// February is paid out once its last day, the leap day, is accrued on the 1st of March
+	clock.now = clock.now.AddDate(0, 0, 1)
+	accrued, paid = runInterest(t, handler)
+	assert.Equal(t, 1, accrued)
+	assert.Equal(t, 1, paid)
+	principals, amounts := accrualAmounts(store, 1)
+	assert.Equal(t, []model.Money{100000, 100000, 200000}, principals)
+	assert.Equal(t, []int64{0, 10 * model.AccrualScale, 20 * model.AccrualScale}, amounts)
+	assert.Equal(t, model.Money(200030), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(-30), store.balance(model.SystemAccountHouse, model.USD))
+
+	history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1, Types: []model.TransactionType{model.TransactionTypeInterest}})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, model.Money(30), history[0].Amount)
+	assert.Equal(t, "Interest for February 2024", history[0].Memo)
+
+	// March earns 0.20003 a day on 2,000.30, of which the 6.20093 of the month pays 6.20
+	for clock.now.Month() == time.March {
+		clock.now = clock.now.AddDate(0, 0, 1)
+		runInterest(t, handler)
+	}
+	_, amounts = accrualAmounts(store, 1)
+	assert.Len(t, amounts, 3+31)
+	assert.Equal(t, int64(20003*model.AccrualScale/1000), amounts[len(amounts)-1])
+	assert.Equal(t, model.Money(200030+620), store.balance(1, model.USD))
+
+	// Checking wallets earn nothing, and the ledger still balances
+	principals, _ = accrualAmounts(store, 2)
+	assert.Empty(t, principals)
+	assert.Equal(t, model.Money(50000), store.balance(2, model.USD))
+	assert.Equal(t, model.Money(0), store.total())
+	assert.NoError(t, handler.Balances.VerifyBalance(context.Background(), 1))
+}
+
+func TestInterestCatchesUpOnEndOfDayBalances(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	store, balances := interestTest.withClock(clock.Now).setUp(t)
+	handler := newMemoryInterestHandler(store, balances)
+	runInterest(t, handler)
+
+	// The job does not run for three days, over which the balance changes twice
+	clock.now = time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)
+	_, err := handler.Balances.Withdraw(adminContext(), &dto.WithdrawRequest{UserID: 1, Amount: 50000})
+	require.NoError(t, err)
+	clock.now = time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	_, err = handler.Balances.Deposit(adminContext(), &dto.DepositRequest{UserID: 1, Amount: 150000})
+	require.NoError(t, err)
+
+	clock.now = time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	accrued, _ := runInterest(t, handler)
+	assert.Equal(t, 3, accrued)
+	principals, amounts := accrualAmounts(store, 1)
+	assert.Equal(t, []model.Money{100000, 100000, 50000, 50000}, principals)
+	assert.Equal(t, []int64{0, 10 * model.AccrualScale, 5 * model.AccrualScale, 5 * model.AccrualScale}, amounts)
+
+	// Accruing is idempotent
+	accrued, _ = runInterest(t, handler)
+	assert.Equal(t, 0, accrued)
+	assert.Len(t, store.interestAccruals(), 4)
+}
+
+func TestInterestPayout(t *testing.T) {
+	t.Run("A frozen wallet is paid once unfrozen", func(t *testing.T) {
+		clock := &scheduleClock{now: time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC)}
+		store, balances := interestTest.withClock(clock.Now).setUp(t)
+		handler := newMemoryInterestHandler(store, balances)
+		accounts := newMemoryAccountHandler(store)
+		runInterest(t, handler)
+		clock.now = clock.now.AddDate(0, 0, 1)
+		runInterest(t, handler)
+
+		_, err := accounts.FreezeAccount(adminContext(), &dto.AccountRequest{UserID: 1})
+		require.NoError(t, err)
+		clock.now = clock.now.AddDate(0, 0, 1)
+		accrued, paid := runInterest(t, handler)
+		assert.Equal(t, 1, accrued)
+		assert.Equal(t, 0, paid)
+		assert.Equal(t, model.Money(100000), store.balance(1, model.USD))
+
+		_, err = accounts.UnfreezeAccount(adminContext(), &dto.AccountRequest{UserID: 1})
+		require.NoError(t, err)
+		_, paid = runInterest(t, handler)
+		assert.Equal(t, 1, paid)
+		assert.Equal(t, model.Money(100020), store.balance(1, model.USD))
+	})
+
+	t.Run("A wallet moved to checking is paid what it accrued", func(t *testing.T) {
+		clock := &scheduleClock{now: time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC)}
+		store, balances := interestTest.withClock(clock.Now).setUp(t)
+		handler := newMemoryInterestHandler(store, balances)
+		runInterest(t, handler)
+		clock.now = clock.now.AddDate(0, 0, 1)
+		runInterest(t, handler)
+
+		_, err := newMemoryAccountHandler(store).SetProduct(adminContext(), &dto.SetProductRequest{UserID: 1, Product: "checking"})
+		require.NoError(t, err)
+		clock.now = clock.now.AddDate(0, 0, 1)
+		accrued, paid := runInterest(t, handler)
+		assert.Equal(t, 0, accrued)
+		assert.Equal(t, 1, paid)
+		assert.Equal(t, model.Money(100010), store.balance(1, model.USD))
+	})
+
+	t.Run("Less than a cent is not paid", func(t *testing.T) {
+		clock := &scheduleClock{now: time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC)}
+		store, balances := interestTest.withClock(clock.Now).setUp(t)
+		handler := newMemoryInterestHandler(store, balances)
+		_, err := handler.Balances.Withdraw(adminContext(), &dto.WithdrawRequest{UserID: 1, Amount: 99950})
+		require.NoError(t, err)
+		runInterest(t, handler)
+		clock.now = clock.now.AddDate(0, 0, 2)
+		accrued, paid := runInterest(t, handler)
+		assert.Equal(t, 2, accrued)
+		assert.Equal(t, 0, paid)
+		assert.Equal(t, model.Money(50), store.balance(1, model.USD))
+
+		// The accruals are settled all the same, so they are not looked at again
+		for _, accrual := range store.interestAccruals() {
+			assert.NotNil(t, accrual.PaidAt)
+			assert.Zero(t, accrual.JournalEntryID)
+		}
+	})
+}
+
+func TestSetProduct(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000})
+	accounts := newMemoryAccountHandler(store)
+
+	account, err := accounts.SetProduct(adminContext(), &dto.SetProductRequest{UserID: 1, Product: "savings"})
+	require.NoError(t, err)
+	assert.Equal(t, "Savings", account.Product)
+
+	// A currency opened later belongs to the same product
+	opened, err := accounts.OpenAccount(adminContext(), &dto.AccountRequest{UserID: 1, Currency: model.EUR})
+	require.NoError(t, err)
+	assert.Equal(t, "Savings", opened.Product)
+	assert.Equal(t, model.AccountProductSavings, store.products[model.BalanceKey{UserID: 1, Currency: model.EUR}])
+
+	_, err = accounts.SetProduct(customerContext(1), &dto.SetProductRequest{UserID: 1, Product: "Checking"})
+	assert.ErrorIs(t, err, apperror.ErrForbidden)
+}
@@ -0,0 +1,351 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+	"walletApp/apperror"
+	"walletApp/limits"
+	"walletApp/model"
+	"walletApp/overdraft"
+	"walletApp/storage"
+)
+
+// ErrUnbalancedEntry is returned when a journal entry's postings do not sum to zero
+var ErrUnbalancedEntry = errors.New("journal entry postings do not sum to zero")
+
+// Ledger posts double-entry journal entries. It is the only place where balances change:
+// every posting is applied to its account's balance and, for user wallets, recorded in the
+// transaction history in the same unit of work as the entry itself.
+type Ledger struct {
+	BalanceRepo     storage.BalanceRepository
+	TransactionRepo storage.TransactionRepository
+	JournalRepo     storage.JournalRepository
+	Concurrency     ConcurrencyControl
+	// Limits caps the money each posting may take out of a user wallet, unlimited when empty
+	Limits limits.Policy
+	// Overdraft prices taking a wallet below zero on its credit line, free when empty
+	Overdraft overdraft.Policy
+	// Now returns the current time, which stamps the transactions and decides which of them
+	// count towards a limit. time.Now is used when nil.
+	Now func() time.Time
+}
+
+// Post validates and applies entry, returning the new value of every balance it touched.
+// It must run inside a unit of work so that a failure part way through is rolled back. The
+// overdraft fee of every wallet the entry takes below zero is added to the entry, and has to fit
+// in the wallet's credit line along with the rest of it.
+func (l *Ledger) Post(ctx context.Context, entry *model.JournalEntry) (map[model.BalanceKey]model.Money, error) {
+	return l.PostReleasing(ctx, entry, nil)
+}
+
+// PostReleasing posts entry like Post after taking released off the money held on each balance,
+// so a capture can spend what its hold reserved. The held amount is written with the balance,
+// in the same lock order, rather than before posting.
+func (l *Ledger) PostReleasing(ctx context.Context, entry *model.JournalEntry, released map[model.BalanceKey]model.Money) (map[model.BalanceKey]model.Money, error) {
+	if len(entry.Postings) < 2 {
+		return nil, fmt.Errorf("%w: an entry needs at least two postings", ErrUnbalancedEntry)
+	}
+	for currency, total := range entry.Totals() {
+		if !currency.IsSupported() {
+			return nil, fmt.Errorf("%w: %q", model.ErrUnsupportedCurrency, currency)
+		}
+		if total != 0 {
+			return nil, fmt.Errorf("%w: %s postings sum to %s", ErrUnbalancedEntry, currency, total)
+		}
+	}
+
+	keys := make([]model.BalanceKey, 0, len(entry.Postings))
+	for _, posting := range entry.Postings {
+		keys = append(keys, posting.Key())
+	}
+	// Read and write balances in ascending user ID and currency order, so two entries touching
+	// the same balances in a different order cannot deadlock on each other's rows
+	order := lockOrder(keys...)
+
+	balances := make(map[model.BalanceKey]*model.Balance, len(order))
+	for _, key := range order {
+		balance, err := l.readBalance(ctx, key)
+		if err != nil {
+			log.Printf("Error fetching %s balance for user %d: %v\n", key.Currency, key.UserID, err)
+			return nil, fmt.Errorf("failed to fetch %s balance for user %d: %w", key.Currency, key.UserID, err)
+		}
+		balances[key] = balance
+	}
+	for _, key := range order {
+		if err := checkAccountActive(key.UserID, balances[key].Status); err != nil {
+			return nil, err
+		}
+	}
+	if err := l.checkLimits(ctx, entry, balances); err != nil {
+		return nil, err
+	}
+
+	newBalances := make(map[model.BalanceKey]model.Money, len(order))
+	newHeld := make(map[model.BalanceKey]model.Money, len(order))
+	for key, balance := range balances {
+		newBalances[key] = balance.Balance
+		newHeld[key] = balance.Held.Sub(released[key])
+	}
+	for _, posting := range entry.Postings {
+		newBalances[posting.Key()] = newBalances[posting.Key()].Add(posting.Amount)
+	}
+	// The overdraft fee is charged before funds are checked, so it counts towards the credit line
+	charged, err := l.chargeOverdraftFees(ctx, entry, order, balances, newBalances)
+	if err != nil {
+		return nil, err
+	}
+	order = lockOrder(append(order, charged...)...)
+
+	// Money reserved by holds stays in the balance but cannot be spent, while the credit line
+	// can be. Only wallets the entry spends from are held to this, so one left beyond a lowered
+	// overdraft limit can still be paid into, and the interest charged for the credit line may
+	// take a wallet past its limit.
+	charges := make(map[model.BalanceKey]model.Money)
+	for _, posting := range entry.Postings {
+		if posting.Type == model.TransactionTypeOverdraftInterest {
+			charges[posting.Key()] = charges[posting.Key()].Add(posting.Amount)
+		}
+	}
+	for _, key := range order {
+		if model.IsSystemAccount(key.UserID) {
+			continue
+		}
+		before := balances[key]
+		after := &model.Balance{Balance: newBalances[key].Sub(charges[key]), Held: newHeld[key], OverdraftLimit: before.OverdraftLimit}
+		if after.Available() < before.Available() && after.Available().IsNegative() {
+			log.Printf("Insufficient %s balance for user %d\n", key.Currency, key.UserID)
+			return nil, &apperror.InsufficientFundsError{UserID: key.UserID, Currency: string(key.Currency)}
+		}
+	}
+
+	for _, key := range order {
+		version := balances[key].Version
+		if released[key] != 0 {
+			err := l.BalanceRepo.UpdateHeld(ctx, key.UserID, key.Currency, newHeld[key], version)
+			if err != nil {
+				log.Printf("Error updating held %s for user %d: %v\n", key.Currency, key.UserID, err)
+				return nil, fmt.Errorf("failed to update held %s for user %d: %w", key.Currency, key.UserID, err)
+			}
+			version++
+		}
+		err := l.BalanceRepo.UpdateBalance(ctx, key.UserID, key.Currency, newBalances[key], version)
+		if err != nil {
+			log.Printf("Error updating %s balance for user %d: %v\n", key.Currency, key.UserID, err)
+			return nil, fmt.Errorf("failed to update %s balance for user %d: %w", key.Currency, key.UserID, err)
+		}
+	}
+
+	err = l.JournalRepo.CreateJournalEntry(ctx, entry)
+	if err != nil {
+		log.Printf("Error creating journal entry %q: %v\n", entry.Description, err)
+		return nil, fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	// Record each user wallet posting in that user's transaction history
+	now := l.now()
+	for _, posting := range entry.Postings {
+		if model.IsSystemAccount(posting.UserID) {
+			continue
+		}
+		transaction := &model.Transaction{
+			UserID:                posting.UserID,
+			Type:                  posting.Type,
+			Amount:                posting.Amount,
+			Currency:              posting.Currency,
+			JournalEntryID:        entry.ID,
+			CounterpartyID:        posting.CounterpartyID,
+			OriginalTransactionID: posting.OriginalTransactionID,
+			TransactionReference:  entry.Reference,
+			Timestamp:             now,
+		}
+		err = l.TransactionRepo.CreateTransaction(ctx, transaction)
+		if err != nil {
+			log.Printf("Error creating transaction for user %d: %v\n", posting.UserID, err)
+			return nil, fmt.Errorf("failed to create transaction for user %d: %w", posting.UserID, err)
+		}
+	}
+
+	return newBalances, nil
+}
+
+// chargeOverdraftFees adds to entry the overdraft fee of every user wallet in order that the entry
+// takes from zero or above to below zero, paid to the house account, and applies the fees to
+// newBalances. It returns the balances it read to pay the fees to, which the entry did not touch
+// before; the house account sorts after every user wallet, so reading it last keeps the lock
+// order.
+func (l *Ledger) chargeOverdraftFees(ctx context.Context, entry *model.JournalEntry, order []model.BalanceKey, balances map[model.BalanceKey]*model.Balance, newBalances map[model.BalanceKey]model.Money) ([]model.BalanceKey, error) {
+	var fees []model.Posting
+	for _, key := range order {
+		fee := l.Overdraft.Terms(key.Currency).Fee
+		if model.IsSystemAccount(key.UserID) || balances[key].IsOverdrawn() || !newBalances[key].IsNegative() || !fee.IsPositive() {
+			continue
+		}
+		fees = append(fees,
+			model.Posting{UserID: key.UserID, Type: model.TransactionTypeOverdraftFee, Amount: fee.Neg(), Currency: key.Currency},
+			model.Posting{UserID: model.SystemAccountHouse, Type: model.TransactionTypeOverdraftFee, Amount: fee, Currency: key.Currency})
+	}
+	var read []model.BalanceKey
+	for _, posting := range fees {
+		key := posting.Key()
+		if _, ok := balances[key]; !ok {
+			balance, err := l.readBalance(ctx, key)
+			if err != nil {
+				log.Printf("Error fetching %s balance for user %d: %v\n", key.Currency, key.UserID, err)
+				return nil, fmt.Errorf("failed to fetch %s balance for user %d: %w", key.Currency, key.UserID, err)
+			}
+			balances[key] = balance
+			newBalances[key] = balance.Balance
+			read = append(read, key)
+		}
+		newBalances[key] = newBalances[key].Add(posting.Amount)
+	}
+	entry.Postings = append(entry.Postings, fees...)
+	return read, nil
+}
+
+// checkLimits refuses an entry that takes more money out of a user wallet than the limits of
+// the wallet's tier allow for the posting's type. It runs once the balances are read, so their
+// rows are locked, or at a version their update checks, and concurrent postings cannot both
+// spend the same allowance. Reversals and refunds do not give any allowance back.
+func (l *Ledger) checkLimits(ctx context.Context, entry *model.JournalEntry, balances map[model.BalanceKey]*model.Balance) error {
+	if len(l.Limits) == 0 {
+		return nil
+	}
+	type outflow struct {
+		key       model.BalanceKey
+		operation model.TransactionType
+	}
+	amounts := make(map[outflow]model.Money, len(entry.Postings))
+	var outflows []outflow
+	for _, posting := range entry.Postings {
+		if model.IsSystemAccount(posting.UserID) || !posting.Amount.IsNegative() {
+			continue
+		}
+		out := outflow{key: posting.Key(), operation: limits.Operation(posting.Type)}
+		if _, ok := amounts[out]; !ok {
+			outflows = append(outflows, out)
+		}
+		amounts[out] = amounts[out].Sub(posting.Amount)
+	}
+
+	now := l.now()
+	for _, out := range outflows {
+		limit, ok := l.Limits.Limit(balances[out.key].Tier, out.operation, out.key.Currency)
+		if !ok {
+			continue
+		}
+		var usage limits.Usage
+		for _, period := range []struct {
+			cap   model.Money
+			since time.Time
+			into  *model.Money
+		}{
+			{limit.Daily, limits.WindowStart(now), &usage.Daily},
+			{limit.Monthly, limits.MonthStart(now), &usage.Monthly},
+		} {
+			if period.cap == 0 {
+				continue
+			}
+			total, err := l.TransactionRepo.SumOutflows(ctx, out.key.UserID, out.key.Currency, limits.Types(out.operation), period.since)
+			if err != nil {
+				log.Printf("Error summing %s %s transactions for user %d: %v\n", out.key.Currency, out.operation, out.key.UserID, err)
+				return fmt.Errorf("failed to sum %s %s transactions for user %d: %w", out.key.Currency, out.operation, out.key.UserID, err)
+			}
+			*period.into = total
+		}
+		if err := limit.Check(out.key.UserID, out.operation, out.key.Currency, amounts[out], usage); err != nil {
+			log.Printf("Limit exceeded for user %d: %v\n", out.key.UserID, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// now returns the current time according to the ledger's clock
+func (l *Ledger) now() time.Time {
+	if l.Now == nil {
+		return time.Now()
+	}
+	return l.Now()
+}
+
+// Verify checks that every stored balance of userID equals the sum of its postings
+func (l *Ledger) Verify(ctx context.Context, userID uint) error {
+	balances, err := l.BalanceRepo.GetBalances(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch balances for user %d: %w", userID, err)
+	}
+	for _, balance := range balances {
+		total, err := l.JournalRepo.SumPostingsByUserID(ctx, userID, balance.Currency)
+		if err != nil {
+			return fmt.Errorf("failed to sum %s postings for user %d: %w", balance.Currency, userID, err)
+		}
+		if balance.Balance != total {
+			return fmt.Errorf("%s balance for user %d is %s but its postings sum to %s", balance.Currency, userID, balance.Balance, total)
+		}
+	}
+	return nil
+}
+
+// checkAccountActive rejects postings to a frozen or closed user wallet. The status is read
+// from the same balance row as the amount, so a concurrent status change either happens
+// before the posting or makes its balance update fail.
+func checkAccountActive(userID uint, status model.AccountStatus) error {
+	if model.IsSystemAccount(userID) {
+		return nil
+	}
+	switch status {
+	case model.AccountStatusFrozen:
+		return fmt.Errorf("%w: user %d", apperror.ErrAccountFrozen, userID)
+	case model.AccountStatusClosed:
+		return fmt.Errorf("%w: user %d", apperror.ErrAccountClosed, userID)
+	}
+	return nil
+}
+
+// Lock locks the balances of keys in lock order ahead of posting several entries in one unit of
+// work. Each entry only keeps the lock order of its own balances, so without this two units of
+// work posting to the same balances in a different order of entries could deadlock. Balances that
+// do not exist are left for the posting to refuse. With optimistic concurrency control nothing is
+// locked.
+func (l *Ledger) Lock(ctx context.Context, keys ...model.BalanceKey) error {
+	if l.Concurrency == OptimisticLocking {
+		return nil
+	}
+	for _, key := range lockOrder(keys...) {
+		_, err := l.BalanceRepo.GetBalanceForUpdate(ctx, key.UserID, key.Currency)
+		if errors.Is(err, apperror.ErrAccountNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error locking %s balance for user %d: %v\n", key.Currency, key.UserID, err)
+			return fmt.Errorf("failed to lock %s balance for user %d: %w", key.Currency, key.UserID, err)
+		}
+	}
+	return nil
+}
+
+// readBalance reads a balance that is about to be changed, locking the row unless the
+// ledger uses optimistic concurrency control
+func (l *Ledger) readBalance(ctx context.Context, key model.BalanceKey) (*model.Balance, error) {
+	if l.Concurrency == OptimisticLocking {
+		return l.BalanceRepo.GetBalanceRecord(ctx, key.UserID, key.Currency)
+	}
+	return l.BalanceRepo.GetBalanceForUpdate(ctx, key.UserID, key.Currency)
+}
+
+// lockOrder returns the distinct balances in the order their rows must be locked
+func lockOrder(keys ...model.BalanceKey) []model.BalanceKey {
+	ordered := make([]model.BalanceKey, 0, len(keys))
+	for _, key := range keys {
+		if !slices.Contains(ordered, key) {
+			ordered = append(ordered, key)
+		}
+	}
+	slices.SortFunc(ordered, model.BalanceKey.Compare)
+	return ordered
+}
@@ -0,0 +1,267 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"walletApp/apperror"
+	"walletApp/model"
+	"walletApp/storage"
+	"walletApp/storage/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLedgerPost(t *testing.T) {
+	tests := []struct {
+		name             string
+		postings         []model.Posting
+		balances         map[uint]model.Money
+		statuses         map[uint]model.AccountStatus
+		createEntryError error
+		expectError      error
+		expectedBalances map[uint]model.Money
+		expectedHistory  int
+	}{
+		{
+			name: "Deposit from cash-in",
+			postings: []model.Posting{
+				{UserID: model.SystemAccountCashIn, Type: model.TransactionTypeDeposit, Amount: -500, Currency: model.USD},
+				{UserID: 1, Type: model.TransactionTypeDeposit, Amount: 500, Currency: model.USD},
+			},
+			balances:         map[uint]model.Money{1: 1000, model.SystemAccountCashIn: -1000},
+			expectedBalances: map[uint]model.Money{1: 1500, model.SystemAccountCashIn: -1500},
+			expectedHistory:  1,
+		},
+		{
+			name: "Transfer between users",
+			postings: []model.Posting{
+				{UserID: 2, Type: model.TransactionTypeTransferSend, Amount: -300, Currency: model.USD},
+				{UserID: 1, Type: model.TransactionTypeTransferReceive, Amount: 300, Currency: model.USD},
+			},
+			balances:         map[uint]model.Money{1: 1000, 2: 300},
+			expectedBalances: map[uint]model.Money{1: 1300, 2: 0},
+			expectedHistory:  2,
+		},
+		{
+			name: "Unbalanced entry",
+			postings: []model.Posting{
+				{UserID: 1, Type: model.TransactionTypeTransferSend, Amount: -300, Currency: model.USD},
+				{UserID: 2, Type: model.TransactionTypeTransferReceive, Amount: 200, Currency: model.USD},
+			},
+			balances:    map[uint]model.Money{1: 1000, 2: 1000},
+			expectError: ErrUnbalancedEntry,
+		},
+		{
+			name: "Entry balanced only across currencies",
+			postings: []model.Posting{
+				{UserID: 1, Type: model.TransactionTypeTransferSend, Amount: -300, Currency: model.EUR},
+				{UserID: 2, Type: model.TransactionTypeTransferReceive, Amount: 300, Currency: model.USD},
+			},
+			balances:    map[uint]model.Money{1: 1000, 2: 1000},
+			expectError: ErrUnbalancedEntry,
+		},
+		{
+			name: "Posting without currency",
+			postings: []model.Posting{
+				{UserID: 1, Type: model.TransactionTypeTransferSend, Amount: -300},
+				{UserID: 2, Type: model.TransactionTypeTransferReceive, Amount: 300},
+			},
+			balances:    map[uint]model.Money{1: 1000, 2: 1000},
+			expectError: model.ErrUnsupportedCurrency,
+		},
+		{
+			name: "Single posting",
+			postings: []model.Posting{
+				{UserID: 1, Type: model.TransactionTypeDeposit, Amount: 0, Currency: model.USD},
+			},
+			balances:    map[uint]model.Money{1: 1000},
+			expectError: ErrUnbalancedEntry,
+		},
+		{
+			name: "User wallet would go negative",
+			postings: []model.Posting{
+				{UserID: 1, Type: model.TransactionTypeWithdraw, Amount: -1500, Currency: model.USD},
+				{UserID: model.SystemAccountCashOut, Type: model.TransactionTypeWithdraw, Amount: 1500, Currency: model.USD},
+			},
+			balances:    map[uint]model.Money{1: 1000, model.SystemAccountCashOut: 0},
+			expectError: &apperror.InsufficientFundsError{UserID: 1, Currency: "USD"},
+		},
+		{
+			name: "Recipient wallet is frozen",
+			postings: []model.Posting{
+				{UserID: 1, Type: model.TransactionTypeTransferSend, Amount: -300, Currency: model.USD},
+				{UserID: 2, Type: model.TransactionTypeTransferReceive, Amount: 300, Currency: model.USD},
+			},
+			balances:    map[uint]model.Money{1: 1000, 2: 0},
+			statuses:    map[uint]model.AccountStatus{2: model.AccountStatusFrozen},
+			expectError: errors.New("account frozen: user 2"),
+		},
+		{
+			name: "Wallet is closed",
+			postings: []model.Posting{
+				{UserID: model.SystemAccountCashIn, Type: model.TransactionTypeDeposit, Amount: -500, Currency: model.USD},
+				{UserID: 1, Type: model.TransactionTypeDeposit, Amount: 500, Currency: model.USD},
+			},
+			balances:    map[uint]model.Money{1: 0, model.SystemAccountCashIn: 0},
+			statuses:    map[uint]model.AccountStatus{1: model.AccountStatusClosed},
+			expectError: errors.New("account closed: user 1"),
+		},
+		{
+			name: "Journal entry insert fails",
+			postings: []model.Posting{
+				{UserID: model.SystemAccountCashIn, Type: model.TransactionTypeDeposit, Amount: -500, Currency: model.USD},
+				{UserID: 1, Type: model.TransactionTypeDeposit, Amount: 500, Currency: model.USD},
+			},
+			balances:         map[uint]model.Money{1: 1000, model.SystemAccountCashIn: -1000},
+			expectedBalances: map[uint]model.Money{1: 1500, model.SystemAccountCashIn: -1500},
+			createEntryError: errors.New("database error"),
+			expectError:      errors.New("failed to create journal entry: database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockBalanceRepo := storage.NewMockBalanceRepository(func(m *mock.Mock) {
+				for userID, balance := range tt.balances {
+					m.On("GetBalanceForUpdate", mock.Anything, userID, model.USD).Return(&model.Balance{UserID: userID, Balance: balance, Version: 1, Status: tt.statuses[userID]}, nil)
+					m.On("UpdateBalance", mock.Anything, userID, model.USD, tt.expectedBalances[userID], uint(1)).Return(nil)
+				}
+				// Balances outside the expectations above are a test failure
+				m.On("UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("unexpected balance update"))
+			})
+			var history []*model.Transaction
+			mockTxRepo := storage.NewMockTransactionRepository(func(m *mock.Mock) {
+				m.On("CreateTransaction", mock.Anything, mock.Anything).
+					Run(func(args mock.Arguments) { history = append(history, args.Get(1).(*model.Transaction)) }).
+					Return(nil)
+			})
+			mockJournalRepo := storage.NewMockJournalRepository(func(m *mock.Mock) {
+				m.On("CreateJournalEntry", mock.Anything, mock.Anything).
+					Run(func(args mock.Arguments) { args.Get(1).(*model.JournalEntry).ID = 42 }).
+					Return(tt.createEntryError)
+			})
+
+			ledger := &Ledger{
+				BalanceRepo:     mockBalanceRepo,
+				TransactionRepo: mockTxRepo,
+				JournalRepo:     mockJournalRepo,
+			}
+			balances, err := ledger.Post(context.Background(), &model.JournalEntry{Postings: tt.postings})
+
+			if tt.expectError != nil {
+				if errors.Is(tt.expectError, ErrUnbalancedEntry) || errors.Is(tt.expectError, model.ErrUnsupportedCurrency) {
+					assert.ErrorIs(t, err, tt.expectError)
+				} else {
+					assert.EqualError(t, err, tt.expectError.Error())
+				}
+				assert.Nil(t, balances)
+				return
+			}
+
+			assert.NoError(t, err)
+			expectedBalances := map[model.BalanceKey]model.Money{}
+			for userID, balance := range tt.expectedBalances {
+				expectedBalances[model.BalanceKey{UserID: userID, Currency: model.USD}] = balance
+			}
+			assert.Equal(t, expectedBalances, balances)
+			assert.Len(t, history, tt.expectedHistory)
+			for _, transaction := range history {
+				assert.False(t, model.IsSystemAccount(transaction.UserID), "system accounts have no history")
+				assert.Equal(t, uint(42), transaction.JournalEntryID)
+				assert.Equal(t, model.USD, transaction.Currency)
+			}
+		})
+	}
+}
+
+func TestLedgerPostReleasing(t *testing.T) {
+	postings := []model.Posting{
+		{UserID: 1, Type: model.TransactionTypeCapture, Amount: -700, Currency: model.USD},
+		{UserID: 2, Type: model.TransactionTypeCapture, Amount: 700, Currency: model.USD},
+	}
+	tests := []struct {
+		name        string
+		released    model.Money
+		expectError error
+	}{
+		{name: "Held money cannot be spent", expectError: apperror.ErrInsufficientFunds},
+		{name: "Released with the posting", released: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockBalanceRepo := storage.NewMockBalanceRepository(func(m *mock.Mock) {
+				m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Currency: model.USD, Balance: 1000, Held: 400, Version: 1}, nil)
+				m.On("GetBalanceForUpdate", mock.Anything, uint(2), model.USD).Return(&model.Balance{UserID: 2, Currency: model.USD, Version: 1}, nil)
+				// The held amount is written first, so the balance update expects its version
+				m.On("UpdateHeld", mock.Anything, uint(1), model.USD, model.Money(0), uint(1)).Return(nil).Once()
+				m.On("UpdateBalance", mock.Anything, uint(1), model.USD, model.Money(300), uint(2)).Return(nil).Once()
+				m.On("UpdateBalance", mock.Anything, uint(2), model.USD, model.Money(700), uint(1)).Return(nil).Once()
+			})
+			ledger := &Ledger{
+				BalanceRepo:     mockBalanceRepo,
+				TransactionRepo: storage.NewMockTransactionRepository(func(m *mock.Mock) { m.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil) }),
+				JournalRepo:     newMockJournalRepository(),
+			}
+			released := map[model.BalanceKey]model.Money{{UserID: 1, Currency: model.USD}: tt.released}
+
+			balances, err := ledger.PostReleasing(context.Background(), &model.JournalEntry{Postings: postings}, released)
+			if tt.expectError != nil {
+				assert.ErrorIs(t, err, tt.expectError)
+				mockBalanceRepo.(*mocks.BalanceRepository).AssertNotCalled(t, "UpdateHeld", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, map[model.BalanceKey]model.Money{{UserID: 1, Currency: model.USD}: 300, {UserID: 2, Currency: model.USD}: 700}, balances)
+			mockBalanceRepo.(*mocks.BalanceRepository).AssertExpectations(t)
+		})
+	}
+}
+
+func TestLedgerVerify(t *testing.T) {
+	tests := []struct {
+		name        string
+		balance     model.Money
+		postings    model.Money
+		expectError bool
+	}{
+		{
+			name:        "Balance matches postings",
+			balance:     1500,
+			postings:    1500,
+			expectError: false,
+		},
+		{
+			name:        "Balance drifted from postings",
+			balance:     1500,
+			postings:    1000,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &Ledger{
+				BalanceRepo: storage.NewMockBalanceRepository(func(m *mock.Mock) {
+					m.On("GetBalances", mock.Anything, uint(1)).Return([]model.Balance{
+						{UserID: 1, Currency: model.EUR},
+						{UserID: 1, Currency: model.USD, Balance: tt.balance},
+					}, nil)
+				}),
+				JournalRepo: storage.NewMockJournalRepository(func(m *mock.Mock) {
+					m.On("SumPostingsByUserID", mock.Anything, uint(1), model.EUR).Return(model.Money(0), nil)
+					m.On("SumPostingsByUserID", mock.Anything, uint(1), model.USD).Return(tt.postings, nil)
+				}),
+			}
+
+			err := ledger.Verify(context.Background(), 1)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
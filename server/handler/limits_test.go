@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/dto"
+	"walletApp/limits"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLimits caps Standard USD withdrawals at 100.00 each, 250.00 a day and 400.00 a month, and
+// Standard USD transfers at 150.00 a day. Premium wallets are only held to 1000.00 a day.
+var testLimits = limits.Policy{
+	model.AccountTierStandard: {
+		model.TransactionTypeWithdraw:     {model.USD: {PerTransaction: 10000, Daily: 25000, Monthly: 40000}},
+		model.TransactionTypeTransferSend: {model.USD: {Daily: 15000}},
+	},
+	model.AccountTierPremium: {
+		model.TransactionTypeWithdraw: {model.USD: {Daily: 100000}},
+	},
+}
+
+// limitsTest has user 1 holding 5000.00 and user 2 nothing, held to testLimits
+var limitsTest = memoryTest{Funds: map[uint]model.Money{1: 500000, 2: 0}, Limits: testLimits}
+
+// limitError returns the LimitExceededError err wraps, failing the test when there is none
+func limitError(t *testing.T, err error) *apperror.LimitExceededError {
+	t.Helper()
+	var limitErr *apperror.LimitExceededError
+	require.True(t, errors.As(err, &limitErr), "expected a limit error, got %v", err)
+	return limitErr
+}
+
+func TestWithdrawLimits(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)}
+	store, balances := limitsTest.withClock(clock.Now).setUp(t)
+	withdraw := func(amount model.Money) error {
+		_, err := balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: amount})
+		return err
+	}
+
+	// A single withdrawal may not exceed the per-transaction cap
+	err := withdraw(10001)
+	assert.ErrorIs(t, err, apperror.ErrLimitExceeded)
+	assert.Equal(t, &apperror.LimitExceededError{
+		UserID: 1, Operation: "Withdraw", Period: limits.PeriodTransaction, Currency: "USD", Limit: 10000, Remaining: 10000,
+	}, limitError(t, err))
+	assert.Equal(t, model.Money(500000), store.balance(1, model.USD))
+
+	require.NoError(t, withdraw(10000))
+	require.NoError(t, withdraw(10000))
+	err = withdraw(6000)
+	assert.Equal(t, &apperror.LimitExceededError{
+		UserID: 1, Operation: "Withdraw", Period: limits.PeriodDaily, Currency: "USD", Limit: 25000, Remaining: 5000,
+	}, limitError(t, err))
+	require.NoError(t, withdraw(5000))
+
+	// The daily window rolls, so the allowance comes back a day after the withdrawals
+	clock.now = clock.now.Add(23 * time.Hour)
+	assert.ErrorIs(t, withdraw(100), apperror.ErrLimitExceeded)
+	clock.now = clock.now.Add(time.Hour + time.Second)
+	require.NoError(t, withdraw(10000))
+
+	// 350.00 withdrawn this month leaves 50.00 of the monthly cap, until the month turns
+	err = withdraw(6000)
+	assert.Equal(t, &apperror.LimitExceededError{
+		UserID: 1, Operation: "Withdraw", Period: limits.PeriodMonthly, Currency: "USD", Limit: 40000, Remaining: 5000,
+	}, limitError(t, err))
+	clock.now = time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, withdraw(6000))
+	assert.Equal(t, model.Money(500000-35000-6000), store.balance(1, model.USD))
+}
+
+func TestTransferLimits(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)}
+	store, balances := limitsTest.withClock(clock.Now).setUp(t)
+	ctx := customerContext(1)
+
+	_, err := balances.Transfer(ctx, &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 10000})
+	require.NoError(t, err)
+	_, err = balances.Transfer(ctx, &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 6000})
+	assert.Equal(t, &apperror.LimitExceededError{
+		UserID: 1, Operation: "TransferSend", Period: limits.PeriodDaily, Currency: "USD", Limit: 15000, Remaining: 5000,
+	}, limitError(t, err))
+
+	// Transfers are limited apart from withdrawals, and only for the paying wallet
+	_, err = balances.Withdraw(ctx, &dto.WithdrawRequest{UserID: 1, Amount: 10000})
+	require.NoError(t, err)
+	_, err = balances.Transfer(customerContext(2), &dto.TransferRequest{FromUserID: 2, ToUserID: 1, Amount: 10000})
+	require.NoError(t, err)
+
+	// Money without limits in its currency moves freely
+	store.fund(1, model.EUR, 100000)
+	store.fund(2, model.EUR, 0)
+	_, err = balances.Transfer(ctx, &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100000, Currency: model.EUR})
+	require.NoError(t, err)
+}
+
+func TestCaptureLimits(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)}
+	test := limitsTest
+	test.Merchants = []uint{2}
+	store, balances := test.withClock(clock.Now).setUp(t)
+	hold, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 20000})
+	require.NoError(t, err)
+
+	// Capturing pays the merchant, so it is held to the transfer limits
+	_, err = balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: hold.ID})
+	assert.Equal(t, &apperror.LimitExceededError{
+		UserID: 1, Operation: "TransferSend", Period: limits.PeriodDaily, Currency: "USD", Limit: 15000, Remaining: 15000,
+	}, limitError(t, err))
+	assert.Equal(t, model.Money(500000), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(20000), store.heldAmount(1, model.USD))
+
+	// Transfers and captures share the allowance
+	_, err = balances.Transfer(customerContext(1), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 10000})
+	require.NoError(t, err)
+	amount := model.Money(6000)
+	_, err = balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: hold.ID, Amount: &amount})
+	assert.ErrorIs(t, err, apperror.ErrLimitExceeded)
+	amount = 5000
+	_, err = balances.CaptureHold(customerContext(2), &dto.CaptureHoldRequest{HoldID: hold.ID, Amount: &amount})
+	require.NoError(t, err)
+	_, err = balances.Transfer(customerContext(1), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 1})
+	assert.ErrorIs(t, err, apperror.ErrLimitExceeded)
+	assert.Equal(t, model.Money(500000-15000), store.balance(1, model.USD))
+}
+
+func TestTierChangesLimits(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)}
+	store, balances := limitsTest.withClock(clock.Now).setUp(t)
+	accounts := newMemoryAccountHandler(store)
+	withdraw := func(amount model.Money) error {
+		_, err := balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: amount})
+		return err
+	}
+
+	require.NoError(t, withdraw(10000))
+	assert.ErrorIs(t, withdraw(50000), apperror.ErrLimitExceeded)
+
+	account, err := accounts.SetTier(adminContext(), &dto.SetTierRequest{UserID: 1, Tier: "premium"})
+	require.NoError(t, err)
+	assert.Equal(t, "Premium", account.Tier)
+
+	// Premium wallets have no per-transaction cap, and the day's earlier withdrawal still counts
+	require.NoError(t, withdraw(50000))
+	err = withdraw(50000)
+	assert.Equal(t, &apperror.LimitExceededError{
+		UserID: 1, Operation: "Withdraw", Period: limits.PeriodDaily, Currency: "USD", Limit: 100000, Remaining: 40000,
+	}, limitError(t, err))
+
+	// A tier without limits for an operation leaves it unlimited
+	_, err = balances.Transfer(customerContext(1), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100000})
+	require.NoError(t, err)
+}
@@ -0,0 +1,1012 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/dto"
+	"walletApp/exchange"
+	"walletApp/fees"
+	"walletApp/limits"
+	"walletApp/model"
+	"walletApp/overdraft"
+	"walletApp/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory stand-in for the balances, transactions, journal, idempotency,
+// adjustments, exchange quotes, holds, schedules and interest accruals tables. Row locks taken
+// through GetBalanceForUpdate or UpdateBalance are held until the surrounding unit of work ends,
+// which mirrors row locking inside a Postgres transaction: a handler that locks rows in an
+// inconsistent order deadlocks here just as it would in the database.
+type memoryStore struct {
+	mu           sync.Mutex
+	balances     map[model.BalanceKey]model.Money
+	held         map[model.BalanceKey]model.Money
+	versions     map[model.BalanceKey]uint
+	statuses     map[model.BalanceKey]model.AccountStatus
+	tiers        map[model.BalanceKey]model.AccountTier
+	products     map[model.BalanceKey]model.AccountProduct
+	overdrafts   map[model.BalanceKey]model.Money
+	rowLocks     map[model.BalanceKey]*sync.Mutex
+	transactions []model.Transaction
+	postings     []model.Posting
+	entries      uint
+	reversed     map[uint]model.Money
+	idempotency  map[idempotencyKey]model.IdempotencyRecord
+	adjustments  []model.Adjustment
+	quotes       map[string]model.ExchangeQuote
+	holds        []model.Hold
+	schedules    []model.Schedule
+	accruals     []model.InterestAccrual
+}
+
+// memoryTx tracks the row locks and undo log of one unit of work
+type memoryTx struct {
+	locked []model.BalanceKey
+	undo   []func()
+}
+
+type memoryTxKey struct{}
+
+// idempotencyKey is the unique key of an idempotency record
+type idempotencyKey struct {
+	userID uint
+	key    string
+}
+
+// newMemoryStore creates a store holding the given user balances in model.DefaultCurrency,
+// funded from the cash-in system account by an opening journal entry. The system accounts
+// hold every supported currency.
+func newMemoryStore(balances map[uint]model.Money) *memoryStore {
+	store := &memoryStore{
+		balances:    map[model.BalanceKey]model.Money{},
+		held:        map[model.BalanceKey]model.Money{},
+		versions:    map[model.BalanceKey]uint{},
+		statuses:    map[model.BalanceKey]model.AccountStatus{},
+		tiers:       map[model.BalanceKey]model.AccountTier{},
+		products:    map[model.BalanceKey]model.AccountProduct{},
+		overdrafts:  map[model.BalanceKey]model.Money{},
+		rowLocks:    map[model.BalanceKey]*sync.Mutex{},
+		idempotency: map[idempotencyKey]model.IdempotencyRecord{},
+		reversed:    map[uint]model.Money{},
+		quotes:      map[string]model.ExchangeQuote{},
+	}
+	systemAccounts := []uint{
+		model.SystemAccountCashIn,
+		model.SystemAccountCashOut,
+		model.SystemAccountAdjustments,
+		model.SystemAccountExchange,
+		model.SystemAccountHouse,
+	}
+	for _, userID := range systemAccounts {
+		for _, currency := range model.Currencies() {
+			key := model.BalanceKey{UserID: userID, Currency: currency}
+			store.balances[key] = 0
+			store.rowLocks[key] = &sync.Mutex{}
+		}
+	}
+	for userID, balance := range balances {
+		store.fund(userID, model.DefaultCurrency, balance)
+	}
+	return store
+}
+
+// fund opens the user's balance in currency holding amount, funded from the cash-in system account
+func (s *memoryStore) fund(userID uint, currency model.Currency, amount model.Money) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := model.BalanceKey{UserID: userID, Currency: currency}
+	cashIn := model.BalanceKey{UserID: model.SystemAccountCashIn, Currency: currency}
+	s.balances[key] = amount
+	s.rowLocks[key] = &sync.Mutex{}
+	s.balances[cashIn] -= amount
+	s.postings = append(s.postings,
+		model.Posting{UserID: userID, Amount: amount, Currency: currency},
+		model.Posting{UserID: model.SystemAccountCashIn, Amount: amount.Neg(), Currency: currency})
+}
+
+// balance returns the user's balance in currency
+func (s *memoryStore) balance(userID uint, currency model.Currency) model.Money {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balances[model.BalanceKey{UserID: userID, Currency: currency}]
+}
+
+func (s *memoryStore) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		return fn(ctx)
+	}
+	tx := &memoryTx{}
+	err := fn(context.WithValue(ctx, memoryTxKey{}, tx))
+	if err != nil {
+		s.mu.Lock()
+		for i := len(tx.undo) - 1; i >= 0; i-- {
+			tx.undo[i]()
+		}
+		s.mu.Unlock()
+	}
+	s.mu.Lock()
+	rowLocks := make([]*sync.Mutex, 0, len(tx.locked))
+	for _, key := range tx.locked {
+		rowLocks = append(rowLocks, s.rowLocks[key])
+	}
+	s.mu.Unlock()
+	for _, rowLock := range rowLocks {
+		rowLock.Unlock()
+	}
+	return err
+}
+
+// lockRow blocks until the unit of work in ctx holds the row lock for key
+func (s *memoryStore) lockRow(ctx context.Context, key model.BalanceKey) (*memoryTx, error) {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return nil, errors.New("row lock requested outside a unit of work")
+	}
+	s.mu.Lock()
+	rowLock, ok := s.rowLocks[key]
+	s.mu.Unlock()
+	if !ok {
+		return nil, &apperror.AccountNotFoundError{UserID: key.UserID, Currency: string(key.Currency)}
+	}
+	if !slices.Contains(tx.locked, key) {
+		rowLock.Lock()
+		tx.locked = append(tx.locked, key)
+		// Yield while holding the lock to widen the window for lock-order races
+		runtime.Gosched()
+	}
+	return tx, nil
+}
+
+func (s *memoryStore) GetBalance(ctx context.Context, userID uint, currency model.Currency) (model.Money, error) {
+	balance, err := s.GetBalanceRecord(ctx, userID, currency)
+	if err != nil {
+		return 0, err
+	}
+	return balance.Balance, nil
+}
+
+func (s *memoryStore) GetBalanceRecord(ctx context.Context, userID uint, currency model.Currency) (*model.Balance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.record(model.BalanceKey{UserID: userID, Currency: currency})
+}
+
+// record returns the balance row of key; the caller must hold s.mu
+func (s *memoryStore) record(key model.BalanceKey) (*model.Balance, error) {
+	balance, ok := s.balances[key]
+	if !ok {
+		return nil, &apperror.AccountNotFoundError{UserID: key.UserID, Currency: string(key.Currency)}
+	}
+	return &model.Balance{UserID: key.UserID, Currency: key.Currency, Balance: balance, Held: s.held[key], Version: s.versions[key], Status: s.statuses[key], Tier: s.tiers[key], Product: s.products[key], OverdraftLimit: s.overdrafts[key]}, nil
+}
+
+func (s *memoryStore) GetBalanceForUpdate(ctx context.Context, userID uint, currency model.Currency) (*model.Balance, error) {
+	if _, err := s.lockRow(ctx, model.BalanceKey{UserID: userID, Currency: currency}); err != nil {
+		return nil, err
+	}
+	return s.GetBalanceRecord(ctx, userID, currency)
+}
+
+func (s *memoryStore) GetBalances(ctx context.Context, userID uint) ([]model.Balance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var balances []model.Balance
+	for _, currency := range model.Currencies() {
+		if balance, err := s.record(model.BalanceKey{UserID: userID, Currency: currency}); err == nil {
+			balances = append(balances, *balance)
+		}
+	}
+	if len(balances) == 0 {
+		return nil, &apperror.AccountNotFoundError{UserID: userID}
+	}
+	return balances, nil
+}
+
+func (s *memoryStore) GetBalancesForUpdate(ctx context.Context, userID uint) ([]model.Balance, error) {
+	balances, err := s.GetBalances(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, balance := range balances {
+		if _, err := s.lockRow(ctx, balance.Key()); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetBalances(ctx, userID)
+}
+
+func (s *memoryStore) UpdateBalance(ctx context.Context, userID uint, currency model.Currency, newBalance model.Money, version uint) error {
+	key := model.BalanceKey{UserID: userID, Currency: currency}
+	tx, err := s.lockRow(ctx, key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.versions[key] != version {
+		return &storage.VersionConflictError{UserID: userID, Version: version}
+	}
+	previous := s.balances[key]
+	s.balances[key] = newBalance
+	s.versions[key]++
+	// Rolling back restores the amount but keeps versions increasing, so a reader that saw
+	// the uncommitted write can never match it again
+	tx.undo = append(tx.undo, func() {
+		s.balances[key] = previous
+		s.versions[key]++
+	})
+	return nil
+}
+
+func (s *memoryStore) UpdateHeld(ctx context.Context, userID uint, currency model.Currency, held model.Money, version uint) error {
+	key := model.BalanceKey{UserID: userID, Currency: currency}
+	tx, err := s.lockRow(ctx, key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.versions[key] != version {
+		return &storage.VersionConflictError{UserID: userID, Version: version}
+	}
+	previous := s.held[key]
+	s.held[key] = held
+	s.versions[key]++
+	tx.undo = append(tx.undo, func() {
+		s.held[key] = previous
+		s.versions[key]++
+	})
+	return nil
+}
+
+// heldAmount returns the amount held on the user's balance in currency
+func (s *memoryStore) heldAmount(userID uint, currency model.Currency) model.Money {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.held[model.BalanceKey{UserID: userID, Currency: currency}]
+}
+
+func (s *memoryStore) CreateBalance(ctx context.Context, balance *model.Balance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := balance.Key()
+	if _, ok := s.balances[key]; ok {
+		return fmt.Errorf("%w: %s balance for user %d already exists", apperror.ErrConflict, key.Currency, key.UserID)
+	}
+	s.balances[key] = balance.Balance
+	s.statuses[key] = balance.Status
+	s.tiers[key] = balance.Tier
+	s.products[key] = balance.Product
+	s.overdrafts[key] = balance.OverdraftLimit
+	s.rowLocks[key] = &sync.Mutex{}
+	return nil
+}
+
+func (s *memoryStore) UpdateBalanceStatus(ctx context.Context, userID uint, currency model.Currency, status model.AccountStatus, version uint) error {
+	key := model.BalanceKey{UserID: userID, Currency: currency}
+	tx, err := s.lockRow(ctx, key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.versions[key] != version {
+		return &storage.VersionConflictError{UserID: userID, Version: version}
+	}
+	previous := s.statuses[key]
+	s.statuses[key] = status
+	s.versions[key]++
+	tx.undo = append(tx.undo, func() {
+		s.statuses[key] = previous
+		s.versions[key]++
+	})
+	return nil
+}
+
+func (s *memoryStore) UpdateBalanceTier(ctx context.Context, userID uint, currency model.Currency, tier model.AccountTier, version uint) error {
+	key := model.BalanceKey{UserID: userID, Currency: currency}
+	tx, err := s.lockRow(ctx, key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.versions[key] != version {
+		return &storage.VersionConflictError{UserID: userID, Version: version}
+	}
+	previous := s.tiers[key]
+	s.tiers[key] = tier
+	s.versions[key]++
+	tx.undo = append(tx.undo, func() {
+		s.tiers[key] = previous
+		s.versions[key]++
+	})
+	return nil
+}
+
+func (s *memoryStore) UpdateBalanceProduct(ctx context.Context, userID uint, currency model.Currency, product model.AccountProduct, version uint) error {
+	key := model.BalanceKey{UserID: userID, Currency: currency}
+	tx, err := s.lockRow(ctx, key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.versions[key] != version {
+		return &storage.VersionConflictError{UserID: userID, Version: version}
+	}
+	previous := s.products[key]
+	s.products[key] = product
+	s.versions[key]++
+	tx.undo = append(tx.undo, func() {
+		s.products[key] = previous
+		s.versions[key]++
+	})
+	return nil
+}
+
+func (s *memoryStore) GetBalancesByProduct(ctx context.Context, product model.AccountProduct, after model.BalanceKey, limit int) ([]model.Balance, error) {
+	return s.balancesWhere(after, limit, func(balance *model.Balance) bool { return balance.Product == product }), nil
+}
+
+func (s *memoryStore) UpdateOverdraftLimit(ctx context.Context, userID uint, currency model.Currency, overdraftLimit model.Money, version uint) error {
+	key := model.BalanceKey{UserID: userID, Currency: currency}
+	tx, err := s.lockRow(ctx, key)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.versions[key] != version {
+		return &storage.VersionConflictError{UserID: userID, Version: version}
+	}
+	previous := s.overdrafts[key]
+	s.overdrafts[key] = overdraftLimit
+	s.versions[key]++
+	tx.undo = append(tx.undo, func() {
+		s.overdrafts[key] = previous
+		s.versions[key]++
+	})
+	return nil
+}
+
+func (s *memoryStore) GetBalancesWithOverdraft(ctx context.Context, after model.BalanceKey, limit int) ([]model.Balance, error) {
+	return s.balancesWhere(after, limit, func(balance *model.Balance) bool {
+		return !model.IsSystemAccount(balance.UserID) && (balance.OverdraftLimit.IsPositive() || balance.IsOverdrawn())
+	}), nil
+}
+
+func (s *memoryStore) GetOverdrawnBalances(ctx context.Context, after model.BalanceKey, limit int) ([]model.Balance, error) {
+	return s.balancesWhere(after, limit, func(balance *model.Balance) bool {
+		return !model.IsSystemAccount(balance.UserID) && balance.IsOverdrawn()
+	}), nil
+}
+
+// balancesWhere returns up to limit balances matching match after the balance identified by
+// after, ordered by user and currency
+func (s *memoryStore) balancesWhere(after model.BalanceKey, limit int, match func(balance *model.Balance) bool) []model.Balance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var balances []model.Balance
+	for key := range s.balances {
+		if balance, _ := s.record(key); key.Compare(after) > 0 && match(balance) {
+			balances = append(balances, *balance)
+		}
+	}
+	slices.SortFunc(balances, func(a, b model.Balance) int { return a.Key().Compare(b.Key()) })
+	if len(balances) > limit {
+		balances = balances[:limit]
+	}
+	return balances
+}
+
+func (s *memoryStore) CreateTransaction(ctx context.Context, transaction *model.Transaction) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return errors.New("CreateTransaction called outside a unit of work")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	transaction.ID = uint(len(s.transactions) + 1)
+	s.transactions = append(s.transactions, *transaction)
+	n := len(s.transactions) - 1
+	tx.undo = append(tx.undo, func() { s.transactions = s.transactions[:n] })
+	return nil
+}
+
+func (s *memoryStore) ListTransactions(ctx context.Context, query model.TransactionQuery) ([]model.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var transactions []model.Transaction
+	for _, transaction := range s.transactions {
+		if transaction.UserID == query.UserID && matchesQuery(transaction, query) {
+			transactions = append(transactions, transaction)
+		}
+	}
+	// Newest first, reading backward from a cursor starts next to the cursor
+	slices.SortFunc(transactions, func(a, b model.Transaction) int {
+		return compareTransactions(b, a)
+	})
+	if query.Cursor != nil && query.Cursor.Backward {
+		slices.Reverse(transactions)
+	}
+	if query.Limit > 0 && len(transactions) > query.Limit {
+		transactions = transactions[:query.Limit]
+	}
+	if query.Cursor != nil && query.Cursor.Backward {
+		slices.Reverse(transactions)
+	}
+	return transactions, nil
+}
+
+func (s *memoryStore) SumOutflows(ctx context.Context, userID uint, currency model.Currency, types []model.TransactionType, since time.Time) (model.Money, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sum model.Money
+	for _, transaction := range s.transactions {
+		if transaction.UserID == userID && transaction.Currency == currency && slices.Contains(types, transaction.Type) && transaction.Amount.IsNegative() && !transaction.Timestamp.Before(since) {
+			sum = sum.Sub(transaction.Amount)
+		}
+	}
+	return sum, nil
+}
+
+func (s *memoryStore) SumAmountsSince(ctx context.Context, userID uint, currency model.Currency, since time.Time) (model.Money, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sum model.Money
+	for _, transaction := range s.transactions {
+		if transaction.UserID == userID && transaction.Currency == currency && !transaction.Timestamp.Before(since) {
+			sum = sum.Add(transaction.Amount)
+		}
+	}
+	return sum, nil
+}
+
+func (s *memoryStore) GetTransaction(ctx context.Context, id uint) (*model.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == 0 || int(id) > len(s.transactions) {
+		return nil, fmt.Errorf("%w: transaction %d", apperror.ErrNotFound, id)
+	}
+	transaction := s.transactions[id-1]
+	return &transaction, nil
+}
+
+func (s *memoryStore) GetTransactionsByJournalEntryID(ctx context.Context, journalEntryID uint) ([]model.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var transactions []model.Transaction
+	for _, transaction := range s.transactions {
+		if transaction.JournalEntryID == journalEntryID {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions, nil
+}
+
+// matchesQuery reports whether transaction passes the filters and cursor of query
+func matchesQuery(transaction model.Transaction, query model.TransactionQuery) bool {
+	if query.Currency != "" && transaction.Currency != query.Currency {
+		return false
+	}
+	if len(query.Types) > 0 && !slices.Contains(query.Types, transaction.Type) {
+		return false
+	}
+	if !query.From.IsZero() && transaction.Timestamp.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && !transaction.Timestamp.Before(query.To) {
+		return false
+	}
+	if query.MinAmount != nil && transaction.Amount < *query.MinAmount {
+		return false
+	}
+	if query.MaxAmount != nil && transaction.Amount > *query.MaxAmount {
+		return false
+	}
+	if cursor := query.Cursor; cursor != nil {
+		order := compareTransactions(transaction, model.Transaction{ID: cursor.ID, Timestamp: cursor.Timestamp})
+		if (cursor.Backward && order <= 0) || (!cursor.Backward && order >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareTransactions orders transactions by timestamp and then ID, like the (timestamp, id) keyset
+func compareTransactions(a, b model.Transaction) int {
+	if c := a.Timestamp.Compare(b.Timestamp); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+func (s *memoryStore) CreateJournalEntry(ctx context.Context, entry *model.JournalEntry) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return errors.New("CreateJournalEntry called outside a unit of work")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries++
+	entry.ID = s.entries
+	n := len(s.postings)
+	for _, posting := range entry.Postings {
+		posting.JournalEntryID = entry.ID
+		s.postings = append(s.postings, posting)
+	}
+	tx.undo = append(tx.undo, func() { s.postings = s.postings[:n] })
+	return nil
+}
+
+func (s *memoryStore) SumPostingsByUserID(ctx context.Context, userID uint, currency model.Currency) (model.Money, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total model.Money
+	for _, posting := range s.postings {
+		if posting.UserID == userID && posting.Currency == currency {
+			total = total.Add(posting.Amount)
+		}
+	}
+	return total, nil
+}
+
+func (s *memoryStore) GetJournalEntry(ctx context.Context, id uint) (*model.JournalEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := &model.JournalEntry{ID: id, ReversedAmount: s.reversed[id]}
+	for _, posting := range s.postings {
+		if posting.JournalEntryID == id {
+			entry.Postings = append(entry.Postings, posting)
+		}
+	}
+	if id == 0 || len(entry.Postings) == 0 {
+		return nil, fmt.Errorf("%w: journal entry %d", apperror.ErrNotFound, id)
+	}
+	return entry, nil
+}
+
+func (s *memoryStore) AddReversedAmount(ctx context.Context, id uint, amount model.Money) (model.Money, error) {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return 0, errors.New("AddReversedAmount called outside a unit of work")
+	}
+	entry, err := s.GetJournalEntry(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	// Cap the total at the credits of the entry other than fees, as the SQL repository does
+	var credits model.Money
+	for _, posting := range entry.Postings {
+		if posting.Amount.IsPositive() && !slices.Contains(model.FeeTypes(), posting.Type) {
+			credits = credits.Add(posting.Amount)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.reversed[id]
+	if previous+amount > credits {
+		return 0, fmt.Errorf("%w: journal entry %d cannot be reversed by another %s", apperror.ErrConflict, id, amount)
+	}
+	s.reversed[id] = previous + amount
+	tx.undo = append(tx.undo, func() { s.reversed[id] = previous })
+	return previous + amount, nil
+}
+
+func (s *memoryStore) total() model.Money {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total model.Money
+	for _, balance := range s.balances {
+		total += balance
+	}
+	return total
+}
+
+func (s *memoryStore) GetIdempotencyRecord(ctx context.Context, userID uint, key string) (*model.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.idempotency[idempotencyKey{userID: userID, key: key}]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (s *memoryStore) CreateIdempotencyRecord(ctx context.Context, record *model.IdempotencyRecord) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return errors.New("CreateIdempotencyRecord called outside a unit of work")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := idempotencyKey{userID: record.UserID, key: record.Key}
+	if _, exists := s.idempotency[key]; exists {
+		return fmt.Errorf("duplicate idempotency key %q of user %d", record.Key, record.UserID)
+	}
+	s.idempotency[key] = *record
+	tx.undo = append(tx.undo, func() { delete(s.idempotency, key) })
+	return nil
+}
+
+func (s *memoryStore) CreateAdjustment(ctx context.Context, adjustment *model.Adjustment) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return errors.New("CreateAdjustment called outside a unit of work")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	adjustment.ID = uint(len(s.adjustments) + 1)
+	s.adjustments = append(s.adjustments, *adjustment)
+	n := len(s.adjustments) - 1
+	tx.undo = append(tx.undo, func() { s.adjustments = s.adjustments[:n] })
+	return nil
+}
+
+func (s *memoryStore) GetAdjustment(ctx context.Context, id uint) (*model.Adjustment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == 0 || int(id) > len(s.adjustments) {
+		return nil, fmt.Errorf("%w: adjustment %d", apperror.ErrNotFound, id)
+	}
+	adjustment := s.adjustments[id-1]
+	return &adjustment, nil
+}
+
+func (s *memoryStore) GetAdjustmentsByStatus(ctx context.Context, status model.AdjustmentStatus) ([]model.Adjustment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var adjustments []model.Adjustment
+	for _, adjustment := range s.adjustments {
+		if adjustment.Status == status {
+			adjustments = append(adjustments, adjustment)
+		}
+	}
+	return adjustments, nil
+}
+
+func (s *memoryStore) ReviewAdjustment(ctx context.Context, adjustment *model.Adjustment) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return errors.New("ReviewAdjustment called outside a unit of work")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.adjustments[adjustment.ID-1]
+	if previous.Status != model.AdjustmentStatusPending {
+		return fmt.Errorf("%w: adjustment %d is no longer pending", apperror.ErrConflict, adjustment.ID)
+	}
+	s.adjustments[adjustment.ID-1] = *adjustment
+	tx.undo = append(tx.undo, func() { s.adjustments[adjustment.ID-1] = previous })
+	return nil
+}
+
+func (s *memoryStore) CreateQuote(ctx context.Context, quote *model.ExchangeQuote) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return errors.New("CreateQuote called outside a unit of work")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotes[quote.ID] = *quote
+	tx.undo = append(tx.undo, func() { delete(s.quotes, quote.ID) })
+	return nil
+}
+
+func (s *memoryStore) GetQuote(ctx context.Context, id string) (*model.ExchangeQuote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	quote, ok := s.quotes[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: exchange quote %s", apperror.ErrNotFound, id)
+	}
+	return &quote, nil
+}
+
+func (s *memoryStore) UseQuote(ctx context.Context, id string, journalEntryID uint, now time.Time) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return errors.New("UseQuote called outside a unit of work")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, ok := s.quotes[id]
+	if !ok || previous.JournalEntryID != 0 || previous.Expired(now) {
+		return fmt.Errorf("%w: exchange quote %s was already used or has expired", apperror.ErrConflict, id)
+	}
+	used := previous
+	used.JournalEntryID = journalEntryID
+	s.quotes[id] = used
+	tx.undo = append(tx.undo, func() { s.quotes[id] = previous })
+	return nil
+}
+
+func (s *memoryStore) CreateHold(ctx context.Context, hold *model.Hold) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return errors.New("CreateHold called outside a unit of work")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hold.ID = uint(len(s.holds) + 1)
+	s.holds = append(s.holds, *hold)
+	n := len(s.holds) - 1
+	tx.undo = append(tx.undo, func() { s.holds = s.holds[:n] })
+	return nil
+}
+
+func (s *memoryStore) GetHold(ctx context.Context, id uint) (*model.Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == 0 || int(id) > len(s.holds) {
+		return nil, fmt.Errorf("%w: hold %d", apperror.ErrNotFound, id)
+	}
+	hold := s.holds[id-1]
+	return &hold, nil
+}
+
+func (s *memoryStore) GetExpiredHolds(ctx context.Context, now time.Time, limit int) ([]model.Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var holds []model.Hold
+	for _, hold := range s.holds {
+		if hold.Status == model.HoldStatusAuthorized && hold.Expired(now) {
+			holds = append(holds, hold)
+		}
+	}
+	slices.SortStableFunc(holds, func(a, b model.Hold) int { return a.ExpiresAt.Compare(b.ExpiresAt) })
+	if len(holds) > limit {
+		holds = holds[:limit]
+	}
+	return holds, nil
+}
+
+func (s *memoryStore) ResolveHold(ctx context.Context, hold *model.Hold) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return errors.New("ResolveHold called outside a unit of work")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.holds[hold.ID-1]
+	if previous.Status != model.HoldStatusAuthorized {
+		return fmt.Errorf("%w: hold %d is no longer authorized", apperror.ErrConflict, hold.ID)
+	}
+	s.holds[hold.ID-1] = *hold
+	tx.undo = append(tx.undo, func() { s.holds[hold.ID-1] = previous })
+	return nil
+}
+
+func (s *memoryStore) CreateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedule.ID = uint(len(s.schedules) + 1)
+	s.schedules = append(s.schedules, *schedule)
+	return nil
+}
+
+func (s *memoryStore) GetSchedule(ctx context.Context, id uint) (*model.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == 0 || int(id) > len(s.schedules) {
+		return nil, fmt.Errorf("%w: schedule %d", apperror.ErrNotFound, id)
+	}
+	schedule := s.schedules[id-1]
+	return &schedule, nil
+}
+
+func (s *memoryStore) GetSchedulesByUserID(ctx context.Context, userID uint) ([]model.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var schedules []model.Schedule
+	for _, schedule := range s.schedules {
+		if schedule.FromUserID == userID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+func (s *memoryStore) GetDueSchedules(ctx context.Context, now time.Time, limit int) ([]model.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var schedules []model.Schedule
+	for _, schedule := range s.schedules {
+		if schedule.Status == model.ScheduleStatusActive && !schedule.NextRunAt.After(now) {
+			schedules = append(schedules, schedule)
+		}
+	}
+	slices.SortStableFunc(schedules, func(a, b model.Schedule) int { return a.NextRunAt.Compare(b.NextRunAt) })
+	if len(schedules) > limit {
+		schedules = schedules[:limit]
+	}
+	return schedules, nil
+}
+
+func (s *memoryStore) UpdateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.schedules[schedule.ID-1].Version != schedule.Version {
+		return fmt.Errorf("%w: schedule %d was modified concurrently", apperror.ErrConflict, schedule.ID)
+	}
+	schedule.Version++
+	s.schedules[schedule.ID-1] = *schedule
+	return nil
+}
+
+func (s *memoryStore) CreateAccrual(ctx context.Context, accrual *model.InterestAccrual) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return errors.New("CreateAccrual called outside a unit of work")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.accruals {
+		if existing.UserID == accrual.UserID && existing.Currency == accrual.Currency && existing.Day.Equal(accrual.Day) {
+			return fmt.Errorf("%w: %s interest of user %d already accrued for %s", apperror.ErrConflict, accrual.Currency, accrual.UserID, accrual.Day.Format(time.DateOnly))
+		}
+	}
+	accrual.ID = uint(len(s.accruals) + 1)
+	s.accruals = append(s.accruals, *accrual)
+	n := len(s.accruals) - 1
+	tx.undo = append(tx.undo, func() { s.accruals = s.accruals[:n] })
+	return nil
+}
+
+func (s *memoryStore) GetLastAccrual(ctx context.Context, userID uint, currency model.Currency) (*model.InterestAccrual, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var last *model.InterestAccrual
+	for _, accrual := range s.accruals {
+		if accrual.UserID == userID && accrual.Currency == currency && (last == nil || accrual.Day.After(last.Day)) {
+			last = &accrual
+		}
+	}
+	return last, nil
+}
+
+func (s *memoryStore) GetUnpaidBalances(ctx context.Context, before time.Time) ([]model.BalanceKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []model.BalanceKey
+	for _, accrual := range s.accruals {
+		key := model.BalanceKey{UserID: accrual.UserID, Currency: accrual.Currency}
+		if accrual.PaidAt == nil && accrual.Day.Before(before) && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, model.BalanceKey.Compare)
+	return keys, nil
+}
+
+func (s *memoryStore) GetUnpaidAccruals(ctx context.Context, userID uint, currency model.Currency, before time.Time) ([]model.InterestAccrual, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var accruals []model.InterestAccrual
+	for _, accrual := range s.accruals {
+		if accrual.UserID == userID && accrual.Currency == currency && accrual.PaidAt == nil && accrual.Day.Before(before) {
+			accruals = append(accruals, accrual)
+		}
+	}
+	slices.SortStableFunc(accruals, func(a, b model.InterestAccrual) int { return a.Day.Compare(b.Day) })
+	return accruals, nil
+}
+
+func (s *memoryStore) MarkAccrualsPaid(ctx context.Context, ids []uint, journalEntryID uint, paidAt time.Time) error {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return errors.New("MarkAccrualsPaid called outside a unit of work")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if s.accruals[id-1].PaidAt != nil {
+			return fmt.Errorf("%w: interest accrual %d was already paid", apperror.ErrConflict, id)
+		}
+	}
+	for _, id := range ids {
+		previous := s.accruals[id-1]
+		s.accruals[id-1].JournalEntryID, s.accruals[id-1].PaidAt = journalEntryID, &paidAt
+		tx.undo = append(tx.undo, func() { s.accruals[id-1] = previous })
+	}
+	return nil
+}
+
+// interestAccruals returns every interest accrual recorded, oldest first
+func (s *memoryStore) interestAccruals() []model.InterestAccrual {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.accruals)
+}
+
+// testRates prices every supported currency but CHF in USD
+var testRates = &exchange.StaticRates{Base: model.USD, Rates: map[model.Currency]model.Rate{
+	model.EUR: 110000000, // 1.1
+	model.GBP: 125000000, // 1.25
+	model.JPY: 500000,    // 0.005
+}}
+
+// testExchangeSpread is the spread of handlers made by newMemoryBalanceHandler, 0.5%
+const testExchangeSpread = 50
+
+// newMemoryBalanceHandler returns a BalanceHandler backed entirely by store, pricing exchanges
+// at testRates
+func newMemoryBalanceHandler(store *memoryStore) *BalanceHandler {
+	return &BalanceHandler{
+		BalanceRepo:     store,
+		TransactionRepo: store,
+		JournalRepo:     store,
+		IdempotencyRepo: store,
+		ExchangeRepo:    store,
+		HoldRepo:        store,
+		UnitOfWork:      store,
+		Rates:           testRates,
+		ExchangeSpread:  testExchangeSpread,
+	}
+}
+
+// memoryTest describes the wallets a handler test starts from, and the policies and clock of the
+// balance handler under test
+type memoryTest struct {
+	Funds       map[uint]model.Money // What each user holds in USD
+	Merchants   []uint
+	Savings     []uint
+	Frozen      []uint
+	CreditLines map[uint]model.Money // The overdraft limit of each user given one
+	Fees        fees.Schedule
+	Limits      limits.Policy
+	Overdraft   overdraft.Policy
+	Now         func() time.Time // The handler's clock, time.Now when nil
+}
+
+// at returns a copy of m whose clock reads now
+func (m memoryTest) at(now time.Time) memoryTest {
+	return m.withClock(func() time.Time { return now })
+}
+
+// withClock returns a copy of m whose clock is now
+func (m memoryTest) withClock(now func() time.Time) memoryTest {
+	m.Now = now
+	return m
+}
+
+// setUp returns a store holding the wallets of m, set up by an admin through the AccountHandler,
+// and a balance handler for it with the policies and clock of m
+func (m memoryTest) setUp(t *testing.T) (*memoryStore, *BalanceHandler) {
+	t.Helper()
+	store := newMemoryStore(m.Funds)
+	accounts := newMemoryAccountHandler(store)
+	for _, userID := range m.Merchants {
+		_, err := accounts.SetProduct(adminContext(), &dto.SetProductRequest{UserID: userID, Product: "Merchant"})
+		require.NoError(t, err)
+	}
+	for _, userID := range m.Savings {
+		_, err := accounts.SetProduct(adminContext(), &dto.SetProductRequest{UserID: userID, Product: "Savings"})
+		require.NoError(t, err)
+	}
+	for _, userID := range m.Frozen {
+		_, err := accounts.FreezeAccount(adminContext(), &dto.AccountRequest{UserID: userID})
+		require.NoError(t, err)
+	}
+	for userID, limit := range m.CreditLines {
+		_, err := accounts.SetOverdraftLimit(adminContext(), &dto.SetOverdraftLimitRequest{UserID: userID, Limit: limit})
+		require.NoError(t, err)
+	}
+	balances := newMemoryBalanceHandler(store)
+	balances.Fees = m.Fees
+	balances.Limits = m.Limits
+	balances.Overdraft = m.Overdraft
+	balances.Now = m.Now
+	return store, balances
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/validation"
+)
+
+// overdrawnBatchSize bounds how many overdrawn balances OverdraftReport reads at once
+const overdrawnBatchSize = 100
+
+// SetOverdraftLimit gives the user's balance in one currency a credit line, letting it be spent
+// down to minus the limit, or takes it away with a zero limit. A lower limit only stops further
+// spending: a balance already beyond it stays overdrawn until it is paid into. Only admins may
+// change credit lines.
+func (c *AccountHandler) SetOverdraftLimit(ctx context.Context, request *dto.SetOverdraftLimitRequest) (*dto.AccountResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	currency := model.CurrencyOrDefault(request.Currency)
+	found := false
+	balances, err := c.updateWallet(ctx, request.UserID, func(ctx context.Context, balance *model.Balance) error {
+		if balance.Currency != currency {
+			return nil
+		}
+		found = true
+		if balance.Status == model.AccountStatusClosed {
+			return fmt.Errorf("%w: user %d", apperror.ErrAccountClosed, request.UserID)
+		}
+		if err := c.BalanceRepo.UpdateOverdraftLimit(ctx, balance.UserID, currency, request.Limit, balance.Version); err != nil {
+			return err
+		}
+		balance.OverdraftLimit = request.Limit
+		balance.Version++
+		return nil
+	})
+	if err == nil && !found {
+		err = &apperror.AccountNotFoundError{UserID: request.UserID, Currency: string(currency)}
+	}
+	if err != nil {
+		log.Printf("Error changing %s overdraft limit for user %d to %s: %v\n", currency, request.UserID, currency.Format(request.Limit), err)
+		return nil, fmt.Errorf("failed to change %s overdraft limit for user %d: %w", currency, request.UserID, err)
+	}
+	return accountResponse(balances), nil
+}
+
+// OverdraftReport lists every user balance currently below zero, with what they owe in total in
+// each currency. Only admins may see it.
+func (c *AccountHandler) OverdraftReport(ctx context.Context) (*dto.OverdraftReportResponse, error) {
+	if err := auth.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	report := &dto.OverdraftReportResponse{Balances: []dto.OverdrawnBalance{}, Totals: map[model.Currency]model.Money{}}
+	after := model.BalanceKey{}
+	for {
+		balances, err := c.BalanceRepo.GetOverdrawnBalances(ctx, after, overdrawnBatchSize)
+		if err != nil {
+			log.Printf("Error fetching overdrawn balances: %v\n", err)
+			return nil, fmt.Errorf("failed to fetch overdrawn balances: %w", err)
+		}
+		for _, balance := range balances {
+			report.Balances = append(report.Balances, dto.OverdrawnBalance{
+				UserID:         balance.UserID,
+				Currency:       balance.Currency,
+				Balance:        balance.Balance,
+				OverdraftLimit: balance.OverdraftLimit,
+				Available:      balance.Available(),
+			})
+			report.Totals[balance.Currency] = report.Totals[balance.Currency].Sub(balance.Balance)
+		}
+		if len(balances) < overdrawnBatchSize {
+			return report, nil
+		}
+		after = balances[len(balances)-1].Key()
+	}
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/overdraft"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOverdraft charges 15.00 for going below zero in USD and 36.50% a year on the overdrawn
+// balance, which is 1.00 a day on 1,000.00
+var testOverdraft = overdraft.Policy{model.USD: {Fee: 1500, RateBasisPoints: 3650}}
+
+// overdraftTest has user 1 holding 100.00 with a credit line of 500.00 and user 2 holding 100.00
+// without one, charged testOverdraft
+var overdraftTest = memoryTest{
+	Funds:       map[uint]model.Money{1: 10000, 2: 10000},
+	CreditLines: map[uint]model.Money{1: 50000},
+	Overdraft:   testOverdraft,
+}
+
+func TestWithdrawIntoOverdraft(t *testing.T) {
+	store, balances := overdraftTest.setUp(t)
+
+	// Going below zero is charged once, on top of the withdrawal
+	response, err := balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 30000})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(10000-30000-1500), response.Balance)
+	_, err = balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 10000})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(-31500), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(1500), store.balance(model.SystemAccountHouse, model.USD))
+
+	fees, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1, Types: []model.TransactionType{model.TransactionTypeOverdraftFee}})
+	require.NoError(t, err)
+	require.Len(t, fees, 1)
+	assert.Equal(t, model.Money(-1500), fees[0].Amount)
+
+	checked, err := balances.CheckBalance(customerContext(1), 1, model.USD)
+	require.NoError(t, err)
+	assert.Equal(t, &dto.CheckBalanceResponse{UserID: 1, Currency: model.USD, Balance: -31500, Available: 18500, OverdraftLimit: 50000}, checked)
+
+	// The credit line is all that can be spent, and a wallet without one still cannot go below zero
+	_, err = balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 18501})
+	assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	_, err = balances.Transfer(customerContext(2), &dto.TransferRequest{FromUserID: 2, ToUserID: 1, Amount: 10001})
+	assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+
+	// Back above zero, going below it again is charged again
+	_, err = balances.Deposit(adminContext(), &dto.DepositRequest{UserID: 1, Amount: 41500})
+	require.NoError(t, err)
+	_, err = balances.Transfer(customerContext(1), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 10001})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(-1501), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(3000), store.balance(model.SystemAccountHouse, model.USD))
+
+	assert.Equal(t, model.Money(0), store.total())
+	assert.NoError(t, balances.VerifyBalance(context.Background(), 1))
+}
+
+func TestOverdraftFeeReversal(t *testing.T) {
+	// withdraw takes user 1 to -115.00, overdraft fee included, and returns the withdrawal
+	withdraw := func(t *testing.T) (*memoryStore, *BalanceHandler, uint) {
+		store, balances := overdraftTest.setUp(t)
+		_, err := balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 20000})
+		require.NoError(t, err)
+		history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1, Types: []model.TransactionType{model.TransactionTypeWithdraw}})
+		require.NoError(t, err)
+		return store, balances, history[0].ID
+	}
+
+	t.Run("Refunds give back the amount withdrawn only", func(t *testing.T) {
+		store, balances, id := withdraw(t)
+		refund := model.Money(5000)
+		_, err := balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: id, Amount: &refund, Reason: "partly returned"})
+		require.NoError(t, err)
+		assert.Equal(t, model.Money(-6500), store.balance(1, model.USD))
+		refund = 15000
+		_, err = balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: id, Amount: &refund, Reason: "returned"})
+		require.NoError(t, err)
+		assert.Equal(t, model.Money(8500), store.balance(1, model.USD))
+		assert.Equal(t, model.Money(1500), store.balance(model.SystemAccountHouse, model.USD))
+	})
+
+	t.Run("A full reversal gives back the fee as well", func(t *testing.T) {
+		store, balances, id := withdraw(t)
+		_, err := balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: id, Reason: "withdrawn in error"})
+		require.NoError(t, err)
+		assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+		assert.Equal(t, model.Money(0), store.balance(model.SystemAccountHouse, model.USD))
+	})
+}
+
+func TestOverdraftFeeWithinLimit(t *testing.T) {
+	store, balances := overdraftTest.setUp(t)
+
+	// Spending the whole credit line leaves nothing for the fee of going below zero
+	_, err := balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 60000})
+	assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(0), store.balance(model.SystemAccountHouse, model.USD))
+
+	// The fee and the withdrawal together may take the wallet right to its limit
+	_, err = balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 58500})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(-50000), store.balance(1, model.USD))
+}
+
+func TestHoldOnCreditLine(t *testing.T) {
+	test := overdraftTest
+	test.Merchants = []uint{2}
+	store, balances := test.setUp(t)
+
+	_, err := balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 60000})
+	require.NoError(t, err)
+	_, err = balances.AuthorizeHold(customerContext(1), &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 1})
+	assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+}
+
+func TestLoweredOverdraftLimit(t *testing.T) {
+	store, balances := overdraftTest.setUp(t)
+	accounts := newMemoryAccountHandler(store)
+	_, err := balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 40000})
+	require.NoError(t, err)
+
+	account, err := accounts.SetOverdraftLimit(adminContext(), &dto.SetOverdraftLimitRequest{UserID: 1, Limit: 10000})
+	require.NoError(t, err)
+	assert.Equal(t, []dto.CurrencyBalance{{Currency: model.USD, Balance: -31500, OverdraftLimit: 10000}}, account.Balances)
+
+	// A wallet beyond its limit can be paid into, but not spend
+	_, err = balances.Transfer(customerContext(2), &dto.TransferRequest{FromUserID: 2, ToUserID: 1, Amount: 5000})
+	require.NoError(t, err)
+	_, err = balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 1})
+	assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	assert.Equal(t, model.Money(-26500), store.balance(1, model.USD))
+
+	// Nor can it close while it owes money
+	_, err = accounts.CloseAccount(adminContext(), &dto.AccountRequest{UserID: 1})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+}
+
+func TestSetOverdraftLimit(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000})
+	accounts := newMemoryAccountHandler(store)
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		request       *dto.SetOverdraftLimitRequest
+		expectedError error
+	}{
+		{
+			name:    "Credit line given",
+			ctx:     adminContext(),
+			request: &dto.SetOverdraftLimitRequest{UserID: 1, Currency: model.USD, Limit: 25000},
+		},
+		{
+			name:          "Customers cannot give themselves credit",
+			ctx:           customerContext(1),
+			request:       &dto.SetOverdraftLimitRequest{UserID: 1, Limit: 25000},
+			expectedError: apperror.ErrForbidden,
+		},
+		{
+			name:          "Currency not held",
+			ctx:           adminContext(),
+			request:       &dto.SetOverdraftLimitRequest{UserID: 1, Currency: model.EUR, Limit: 25000},
+			expectedError: apperror.ErrAccountNotFound,
+		},
+		{
+			name:          "Negative limit",
+			ctx:           adminContext(),
+			request:       &dto.SetOverdraftLimitRequest{UserID: 1, Limit: -1},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account, err := accounts.SetOverdraftLimit(tt.ctx, tt.request)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.request.Limit, account.Balances[0].OverdraftLimit)
+		})
+	}
+}
+
+func TestOverdraftReport(t *testing.T) {
+	store, balances := overdraftTest.setUp(t)
+	accounts := newMemoryAccountHandler(store)
+	store.fund(3, model.EUR, 0)
+	_, err := accounts.SetOverdraftLimit(adminContext(), &dto.SetOverdraftLimitRequest{UserID: 3, Currency: model.EUR, Limit: 10000})
+	require.NoError(t, err)
+	_, err = balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 20000})
+	require.NoError(t, err)
+	_, err = balances.Withdraw(customerContext(3), &dto.WithdrawRequest{UserID: 3, Amount: 2500, Currency: model.EUR})
+	require.NoError(t, err)
+
+	// System accounts below zero are not overdrawn wallets
+	report, err := accounts.OverdraftReport(adminContext())
+	require.NoError(t, err)
+	assert.Equal(t, &dto.OverdraftReportResponse{
+		Balances: []dto.OverdrawnBalance{
+			{UserID: 1, Currency: model.USD, Balance: -11500, OverdraftLimit: 50000, Available: 38500},
+			{UserID: 3, Currency: model.EUR, Balance: -2500, OverdraftLimit: 10000, Available: 7500},
+		},
+		Totals: map[model.Currency]model.Money{model.USD: 11500, model.EUR: 2500},
+	}, report)
+
+	_, err = accounts.OverdraftReport(customerContext(1))
+	assert.ErrorIs(t, err, apperror.ErrForbidden)
+}
+
+func TestOverdraftInterest(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 3, 30, 12, 0, 0, 0, time.UTC)}
+	test := overdraftTest
+	test.Overdraft = overdraft.Policy{model.USD: {RateBasisPoints: 3650}}
+	store, balances := test.withClock(clock.Now).setUp(t)
+	handler := newMemoryInterestHandler(store, balances)
+	_, err := balances.Withdraw(customerContext(1), &dto.WithdrawRequest{UserID: 1, Amount: 60000})
+	require.NoError(t, err)
+
+	runInterest(t, handler)
+	clock.now = clock.now.AddDate(0, 0, 1)
+	runInterest(t, handler)
+
+	// March's two days at 0.50 are charged even though they take the wallet past its limit
+	clock.now = clock.now.AddDate(0, 0, 1)
+	accrued, paid := runInterest(t, handler)
+	assert.Equal(t, 1, accrued)
+	assert.Equal(t, 1, paid)
+	assert.Equal(t, model.Money(-50000-100), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(100), store.balance(model.SystemAccountHouse, model.USD))
+	_, amounts := accrualAmounts(store, 1)
+	assert.Equal(t, []int64{0, -50 * model.AccrualScale, -50 * model.AccrualScale}, amounts)
+
+	history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1, Types: []model.TransactionType{model.TransactionTypeOverdraftInterest}})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, model.Money(-100), history[0].Amount)
+	assert.Equal(t, "Overdraft interest for March 2024", history[0].Memo)
+
+	// Wallets without a credit line accrue nothing, and the ledger still balances
+	_, owed := accrualAmounts(store, 2)
+	assert.Empty(t, owed)
+	assert.Equal(t, model.Money(0), store.total())
+	assert.NoError(t, balances.VerifyBalance(context.Background(), 1))
+}
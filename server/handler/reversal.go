@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/validation"
+)
+
+// reversibleTypes are the transaction types that Reverse accepts. Corrections go through the
+// maker-checker review of adjustments instead, and reversals and refunds are final.
+var reversibleTypes = []model.TransactionType{
+	model.TransactionTypeDeposit,
+	model.TransactionTypeWithdraw,
+	model.TransactionTypeTransferSend,
+	model.TransactionTypeTransferReceive,
+	model.TransactionTypeCapture,
+}
+
+// Reverse undoes a deposit, withdrawal, transfer or captured hold by posting a compensating
+// journal entry that moves the money back. Without an amount the whole transaction is reversed,
+// which is only possible while none of it has been refunded; with an amount, that much is
+// refunded, and refunds may be repeated until the whole transaction has been given back. Each
+// history row of the compensating entry links to the row of the same wallet it undoes.
+//
+// Admins may reverse any of these transactions. The recipient of a transfer and the merchant
+// of a captured hold may also reverse or refund it, since the money comes out of their own
+// wallet, but the fees charged for it are only given back when an admin reverses it.
+func (c *BalanceHandler) Reverse(ctx context.Context, request *dto.ReverseRequest) (*dto.ReverseResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	principal, err := auth.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payload := *request
+	payload.IdempotencyKey = ""
+	return runIdempotent(ctx, c, request.IdempotencyKey, "reverse", payload, func(ctx context.Context) (*dto.ReverseResponse, error) {
+		original, err := c.TransactionRepo.GetTransaction(ctx, payload.TransactionID)
+		if err != nil {
+			log.Printf("Error fetching transaction %d: %v\n", payload.TransactionID, err)
+			return nil, fmt.Errorf("failed to fetch transaction %d: %w", payload.TransactionID, err)
+		}
+		if !slices.Contains(reversibleTypes, original.Type) {
+			return nil, fmt.Errorf("%w: %s transactions cannot be reversed", apperror.ErrInvalidRequest, original.Type)
+		}
+		entry, err := c.JournalRepo.GetJournalEntry(ctx, original.JournalEntryID)
+		if err != nil {
+			log.Printf("Error fetching journal entry %d: %v\n", original.JournalEntryID, err)
+			return nil, fmt.Errorf("failed to fetch journal entry %d: %w", original.JournalEntryID, err)
+		}
+		if err := authorizeReversal(ctx, entry); err != nil {
+			return nil, err
+		}
+		// Giving back a conversion would need a fresh price, so it is left to a new transfer
+		if len(entry.Totals()) > 1 {
+			return nil, fmt.Errorf("%w: transaction %d converted between currencies and cannot be reversed", apperror.ErrInvalidRequest, original.ID)
+		}
+
+		transactionType, amount := model.TransactionTypeReversal, entry.Amount()
+		if payload.Amount != nil {
+			transactionType, amount = model.TransactionTypeRefund, *payload.Amount
+			if amount > entry.Amount() {
+				return nil, validation.Errors{{Field: "amount", Message: fmt.Sprintf("must not exceed the transaction amount of %s", entry.Amount())}}
+			}
+			if amount != entry.Amount() && len(principalPostings(entry)) != 2 {
+				return nil, fmt.Errorf("%w: transaction %d can only be reversed in full", apperror.ErrInvalidRequest, original.ID)
+			}
+		}
+		// Claim the amount before posting, so that a concurrent reversal of the same entry
+		// either waits for this one or fails instead of giving the money back twice
+		reversedAmount, err := c.JournalRepo.AddReversedAmount(ctx, entry.ID, amount)
+		if err != nil {
+			log.Printf("Error reversing %s of journal entry %d: %v\n", amount, entry.ID, err)
+			return nil, fmt.Errorf("failed to reverse transaction %d: %w", original.ID, err)
+		}
+
+		originals, err := c.TransactionRepo.GetTransactionsByJournalEntryID(ctx, entry.ID)
+		if err != nil {
+			log.Printf("Error fetching transactions of journal entry %d: %v\n", entry.ID, err)
+			return nil, fmt.Errorf("failed to fetch transactions of journal entry %d: %w", entry.ID, err)
+		}
+		reversal := &model.JournalEntry{
+			Description:     fmt.Sprintf("%s of journal entry %d: %s", transactionType, entry.ID, payload.Reason),
+			Postings:        reversalPostings(entry, originals, transactionType, amount, principal.IsAdmin()),
+			ReversesEntryID: entry.ID,
+			Reference: model.TransactionReference{
+				TransferID:        original.TransferID,
+				Memo:              payload.Reason,
+				ExternalReference: original.ExternalReference,
+			},
+		}
+		balances, err := c.ledger().Post(ctx, reversal)
+		if err != nil {
+			return nil, err
+		}
+
+		response := &dto.ReverseResponse{
+			Success:        true,
+			Message:        fmt.Sprintf("%s successful", transactionType),
+			Type:           transactionType.String(),
+			Amount:         amount,
+			Currency:       original.Currency,
+			JournalEntryID: reversal.ID,
+			Refundable:     entry.Amount() - reversedAmount,
+			Balances:       map[uint]model.Money{},
+		}
+		for key, balance := range balances {
+			if !model.IsSystemAccount(key.UserID) {
+				response.Balances[key.UserID] = balance
+			}
+		}
+		return response, nil
+	})
+}
+
+// authorizeReversal lets admins reverse any entry, and the recipient of a transfer or the
+// merchant paid by a capture refund it
+func authorizeReversal(ctx context.Context, entry *model.JournalEntry) error {
+	for _, posting := range entry.Postings {
+		captured := posting.Type == model.TransactionTypeCapture && posting.Amount.IsPositive()
+		if posting.Type == model.TransactionTypeTransferReceive || captured {
+			return auth.Authorize(ctx, posting.UserID)
+		}
+	}
+	return auth.AuthorizeAdmin(ctx)
+}
+
+// reversalPostings returns the postings that give amount of entry back. A full reversal negates
+// every posting, and the fees too when withFees is set; a refund of an entry with two postings
+// besides its fees moves amount from the credited account back to the debited one, and the fees
+// are kept.
+func reversalPostings(entry *model.JournalEntry, originals []model.Transaction, transactionType model.TransactionType, amount model.Money, withFees bool) []model.Posting {
+	type row struct {
+		userID          uint
+		transactionType model.TransactionType
+	}
+	byRow := make(map[row]model.Transaction, len(originals))
+	for _, transaction := range originals {
+		byRow[row{transaction.UserID, transaction.Type}] = transaction
+	}
+	postings := entry.Postings
+	if transactionType == model.TransactionTypeRefund || !withFees {
+		postings = principalPostings(entry)
+	}
+	reversal := make([]model.Posting, 0, len(postings))
+	for _, posting := range postings {
+		reversed := posting.Amount.Neg()
+		if amount != entry.Amount() {
+			reversed = amount
+			if posting.Amount.IsPositive() {
+				reversed = amount.Neg()
+			}
+		}
+		original := byRow[row{posting.UserID, posting.Type}]
+		reversal = append(reversal, model.Posting{
+			UserID:                posting.UserID,
+			Type:                  transactionType,
+			Amount:                reversed,
+			Currency:              posting.Currency,
+			CounterpartyID:        original.CounterpartyID,
+			OriginalTransactionID: original.ID,
+		})
+	}
+	return reversal
+}
+
+// principalPostings returns the postings of entry that move its amount, leaving out the fees
+// charged for it
+func principalPostings(entry *model.JournalEntry) []model.Posting {
+	return slices.DeleteFunc(slices.Clone(entry.Postings), func(posting model.Posting) bool {
+		return posting.Type.IsFee()
+	})
+}
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"walletApp/apperror"
+	"walletApp/dto"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transferForReversal transfers 2500 from user 1 to user 2 and returns the sender's transaction
+func transferForReversal(t *testing.T, balances *BalanceHandler, store *memoryStore) model.Transaction {
+	_, err := balances.Transfer(adminContext(), &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 2500, ExternalReference: "INV-7"})
+	require.NoError(t, err)
+	sent, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, sent, 1)
+	return sent[0]
+}
+
+func TestReverseTransfer(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 0})
+	balances := newMemoryBalanceHandler(store)
+	sent := transferForReversal(t, balances, store)
+
+	response, err := balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: sent.ID, Reason: "sent to the wrong wallet"})
+	require.NoError(t, err)
+	assert.Equal(t, "Reversal", response.Type)
+	assert.Equal(t, model.Money(2500), response.Amount)
+	assert.Equal(t, model.Money(0), response.Refundable)
+	assert.Equal(t, map[uint]model.Money{1: 10000, 2: 0}, response.Balances)
+
+	// Each wallet's reversal links to that wallet's side of the transfer
+	for _, userID := range []uint{1, 2} {
+		history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: userID})
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		reversal, original := history[0], history[1]
+		assert.Equal(t, model.TransactionTypeReversal, reversal.Type)
+		assert.Equal(t, original.Amount.Neg(), reversal.Amount)
+		assert.Equal(t, original.ID, reversal.OriginalTransactionID)
+		assert.Equal(t, original.CounterpartyID, reversal.CounterpartyID)
+		assert.Equal(t, original.TransferID, reversal.TransferID)
+		assert.Equal(t, "sent to the wrong wallet", reversal.Memo)
+		assert.Equal(t, "INV-7", reversal.ExternalReference)
+	}
+
+	// Neither side of the transfer can be reversed again
+	_, err = balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: sent.ID + 1, Reason: "again"})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(0), store.total())
+	assert.NoError(t, balances.VerifyBalance(context.Background(), 1))
+	assert.NoError(t, balances.VerifyBalance(context.Background(), 2))
+}
+
+func TestRefundTransfer(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 0})
+	balances := newMemoryBalanceHandler(store)
+	sent := transferForReversal(t, balances, store)
+	amount := func(m model.Money) *model.Money { return &m }
+
+	// The recipient refunds the transfer in two parts
+	first, err := balances.Reverse(customerContext(2), &dto.ReverseRequest{TransactionID: sent.ID, Amount: amount(1000), Reason: "one item returned"})
+	require.NoError(t, err)
+	assert.Equal(t, "Refund", first.Type)
+	assert.Equal(t, model.Money(1500), first.Refundable)
+	assert.Equal(t, map[uint]model.Money{1: 8500, 2: 1500}, first.Balances)
+
+	// A full reversal is no longer possible once part has been refunded
+	_, err = balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: sent.ID, Reason: "wrong wallet"})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+
+	second, err := balances.Reverse(customerContext(2), &dto.ReverseRequest{TransactionID: sent.ID, Amount: amount(1500), Reason: "rest returned"})
+	require.NoError(t, err)
+	assert.Equal(t, model.Money(0), second.Refundable)
+	assert.Equal(t, map[uint]model.Money{1: 10000, 2: 0}, second.Balances)
+
+	_, err = balances.Reverse(customerContext(2), &dto.ReverseRequest{TransactionID: sent.ID, Amount: amount(1), Reason: "one more"})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+
+	history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1, Types: []model.TransactionType{model.TransactionTypeRefund}})
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, []model.Money{1500, 1000}, []model.Money{history[0].Amount, history[1].Amount})
+	assert.Equal(t, sent.ID, history[0].OriginalTransactionID)
+}
+
+func TestReverseErrors(t *testing.T) {
+	amount := func(m model.Money) *model.Money { return &m }
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		request     func(sent model.Transaction) *dto.ReverseRequest
+		spend       model.Money // Withdrawn by the recipient before the reversal
+		expectError error
+	}{
+		{
+			name: "Refund larger than the transfer",
+			ctx:  adminContext(),
+			request: func(sent model.Transaction) *dto.ReverseRequest {
+				return &dto.ReverseRequest{TransactionID: sent.ID, Amount: amount(2501), Reason: "too much"}
+			},
+			expectError: apperror.ErrInvalidRequest,
+		},
+		{
+			name: "Reversal without reason",
+			ctx:  adminContext(),
+			request: func(sent model.Transaction) *dto.ReverseRequest {
+				return &dto.ReverseRequest{TransactionID: sent.ID}
+			},
+			expectError: apperror.ErrInvalidRequest,
+		},
+		{
+			name: "Sender refunds their own transfer",
+			ctx:  customerContext(1),
+			request: func(sent model.Transaction) *dto.ReverseRequest {
+				return &dto.ReverseRequest{TransactionID: sent.ID, Reason: "changed my mind"}
+			},
+			expectError: apperror.ErrForbidden,
+		},
+		{
+			name: "Customer reverses a deposit",
+			ctx:  customerContext(1),
+			request: func(sent model.Transaction) *dto.ReverseRequest {
+				return &dto.ReverseRequest{TransactionID: sent.ID + 2, Reason: "mistake"}
+			},
+			expectError: apperror.ErrForbidden,
+		},
+		{
+			name: "Unknown transaction",
+			ctx:  adminContext(),
+			request: func(sent model.Transaction) *dto.ReverseRequest {
+				return &dto.ReverseRequest{TransactionID: 99, Reason: "mistake"}
+			},
+			expectError: apperror.ErrNotFound,
+		},
+		{
+			name: "Recipient already spent the money",
+			ctx:  adminContext(),
+			request: func(sent model.Transaction) *dto.ReverseRequest {
+				return &dto.ReverseRequest{TransactionID: sent.ID, Reason: "wrong wallet"}
+			},
+			spend:       2000,
+			expectError: apperror.ErrInsufficientFunds,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 0})
+			balances := newMemoryBalanceHandler(store)
+			sent := transferForReversal(t, balances, store)
+			_, err := balances.Deposit(adminContext(), &dto.DepositRequest{UserID: 1, Amount: 100})
+			require.NoError(t, err)
+			if tt.spend > 0 {
+				_, err := balances.Withdraw(adminContext(), &dto.WithdrawRequest{UserID: 2, Amount: tt.spend})
+				require.NoError(t, err)
+			}
+
+			_, err = balances.Reverse(tt.ctx, tt.request(sent))
+			assert.ErrorIs(t, err, tt.expectError)
+			assert.Empty(t, store.reversed[sent.JournalEntryID])
+			assert.Equal(t, model.Money(7600), store.balance(1, model.USD))
+		})
+	}
+}
+
+func TestReversingAReversalIsRejected(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 0})
+	balances := newMemoryBalanceHandler(store)
+	sent := transferForReversal(t, balances, store)
+	_, err := balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: sent.ID, Reason: "wrong wallet"})
+	require.NoError(t, err)
+
+	history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1})
+	require.NoError(t, err)
+	_, err = balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: history[0].ID, Reason: "undo the undo"})
+	assert.ErrorIs(t, err, apperror.ErrInvalidRequest)
+}
+
+func TestConcurrentReversalsOfOneTransfer(t *testing.T) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 0})
+	balances := newMemoryBalanceHandler(store)
+	sent := transferForReversal(t, balances, store)
+
+	const attempts = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := balances.Reverse(adminContext(), &dto.ReverseRequest{TransactionID: sent.ID, Reason: "wrong wallet"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			assert.ErrorIs(t, err, apperror.ErrConflict)
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+	assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(0), store.balance(2, model.USD))
+}
@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/config"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/storage"
+	"walletApp/validation"
+)
+
+// dueSchedulesBatchSize bounds how many due schedules RunDueSchedules reads at once
+const dueSchedulesBatchSize = 100
+
+// maxLastErrorLength matches the size of the schedules.last_error column
+const maxLastErrorLength = 255
+
+// ScheduleHandler keeps standing orders to transfer money and runs them when they are due.
+// Every run is an ordinary transfer made on behalf of the paying wallet's owner, so it is
+// checked, limited and recorded exactly like one the owner made by hand.
+type ScheduleHandler struct {
+	ScheduleRepo storage.ScheduleRepository
+	BalanceRepo  storage.BalanceRepository
+	Transfers    *BalanceHandler
+	// Now returns the current time, which decides when schedules are due. time.Now is used when
+	// nil.
+	Now func() time.Time
+}
+
+// NewScheduleHandler creates a new instance of ScheduleHandler running its transfers through
+// transfers
+func NewScheduleHandler(transfers *BalanceHandler) *ScheduleHandler {
+	return &ScheduleHandler{
+		ScheduleRepo: storage.NewScheduleRepository(config.DB),
+		BalanceRepo:  storage.NewBalanceRepository(config.DB),
+		Transfers:    transfers,
+	}
+}
+
+// now returns the current time according to the handler's clock
+func (c *ScheduleHandler) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// CreateSchedule records a standing order to transfer money from the user's wallet, starting at
+// StartAt or right away when it is not set. Only the wallet's owner and admins may schedule
+// transfers out of it.
+func (c *ScheduleHandler) CreateSchedule(ctx context.Context, request *dto.CreateScheduleRequest) (*dto.ScheduleResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.Authorize(ctx, request.FromUserID); err != nil {
+		return nil, err
+	}
+	creator, err := auth.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recurrence, _ := model.ParseRecurrence(request.Recurrence)
+	failurePolicy := model.ScheduleFailSkip
+	if request.FailurePolicy != "" {
+		failurePolicy, _ = model.ParseScheduleFailPolicy(request.FailurePolicy)
+	}
+	now := c.now()
+	startAt := request.StartAt
+	if startAt.IsZero() {
+		startAt = now
+	}
+	// A start that was now when the request was sent is a little in the past on arrival
+	if startAt.Before(now.Add(-time.Minute)) {
+		return nil, validation.Errors{{Field: "start_at", Message: "must not be in the past"}}
+	}
+
+	schedule := &model.Schedule{
+		FromUserID:        request.FromUserID,
+		ToUserID:          request.ToUserID,
+		Amount:            request.Amount,
+		Currency:          model.CurrencyOrDefault(request.Currency),
+		Memo:              request.Memo,
+		ExternalReference: request.ExternalReference,
+		Recurrence:        recurrence,
+		StartAt:           startAt,
+		FailurePolicy:     failurePolicy,
+		Status:            model.ScheduleStatusActive,
+		NextRunAt:         startAt,
+		CreatedBy:         creator.UserID,
+	}
+	if !request.EndAt.IsZero() {
+		endAt := request.EndAt
+		schedule.EndAt = &endAt
+	}
+	// Refuse schedules between wallets that do not exist now rather than at the first run
+	for _, userID := range []uint{schedule.FromUserID, schedule.ToUserID} {
+		if _, err := c.BalanceRepo.GetBalanceRecord(ctx, userID, schedule.Currency); err != nil {
+			log.Printf("Error fetching %s balance for user %d: %v\n", schedule.Currency, userID, err)
+			return nil, fmt.Errorf("failed to fetch %s balance for user %d: %w", schedule.Currency, userID, err)
+		}
+	}
+	if err := c.ScheduleRepo.CreateSchedule(ctx, schedule); err != nil {
+		log.Printf("Error creating schedule for user %d: %v\n", schedule.FromUserID, err)
+		return nil, fmt.Errorf("failed to create schedule for user %d: %w", schedule.FromUserID, err)
+	}
+	return scheduleResponse(schedule), nil
+}
+
+// ListSchedules returns the schedules paying out of the user's wallet, oldest first, to the
+// wallet's owner or an admin
+func (c *ScheduleHandler) ListSchedules(ctx context.Context, request *dto.ListSchedulesRequest) (*dto.ScheduleListResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.Authorize(ctx, request.UserID); err != nil {
+		return nil, err
+	}
+	schedules, err := c.ScheduleRepo.GetSchedulesByUserID(ctx, request.UserID)
+	if err != nil {
+		log.Printf("Error fetching schedules for user %d: %v\n", request.UserID, err)
+		return nil, fmt.Errorf("failed to fetch schedules for user %d: %w", request.UserID, err)
+	}
+	response := &dto.ScheduleListResponse{Schedules: make([]dto.ScheduleResponse, 0, len(schedules))}
+	for _, schedule := range schedules {
+		response.Schedules = append(response.Schedules, *scheduleResponse(&schedule))
+	}
+	return response, nil
+}
+
+// CancelSchedule stops an active schedule before its next occurrence. Only the paying wallet's
+// owner and admins may cancel it.
+func (c *ScheduleHandler) CancelSchedule(ctx context.Context, request *dto.ScheduleRequest) (*dto.ScheduleResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if _, err := auth.FromContext(ctx); err != nil {
+		return nil, err
+	}
+	schedule, err := c.ScheduleRepo.GetSchedule(ctx, request.ScheduleID)
+	if err != nil {
+		log.Printf("Error fetching schedule %d: %v\n", request.ScheduleID, err)
+		return nil, fmt.Errorf("failed to fetch schedule %d: %w", request.ScheduleID, err)
+	}
+	if err := auth.Authorize(ctx, schedule.FromUserID); err != nil {
+		return nil, err
+	}
+	if schedule.Status != model.ScheduleStatusActive {
+		return nil, fmt.Errorf("%w: schedule %d is %s", apperror.ErrConflict, schedule.ID, schedule.Status)
+	}
+	schedule.Status = model.ScheduleStatusCancelled
+	// A run updating the schedule since it was read makes this fail with a conflict
+	if err := c.ScheduleRepo.UpdateSchedule(ctx, schedule); err != nil {
+		log.Printf("Error cancelling schedule %d: %v\n", schedule.ID, err)
+		return nil, fmt.Errorf("failed to cancel schedule %d: %w", schedule.ID, err)
+	}
+	return scheduleResponse(schedule), nil
+}
+
+// RunDueSchedules runs the next occurrence of every active schedule that is due and returns how
+// many transfers it made. Occurrences missed while the scheduler was down are run late, and a
+// schedule that missed several catches up on them over the following calls. It is run
+// periodically on behalf of the wallet itself rather than a caller, so it authorizes no one.
+func (c *ScheduleHandler) RunDueSchedules(ctx context.Context) (int, error) {
+	transferred := 0
+	for {
+		schedules, err := c.ScheduleRepo.GetDueSchedules(ctx, c.now(), dueSchedulesBatchSize)
+		if err != nil {
+			log.Printf("Error fetching due schedules: %v\n", err)
+			return transferred, fmt.Errorf("failed to fetch due schedules: %w", err)
+		}
+		for _, schedule := range schedules {
+			ok := c.run(ctx, &schedule)
+			err := c.ScheduleRepo.UpdateSchedule(ctx, &schedule)
+			// A schedule cancelled since it was read is no longer ours to run; its transfer, if
+			// any, was still made
+			if errors.Is(err, apperror.ErrConflict) {
+				continue
+			}
+			if err != nil {
+				log.Printf("Error updating schedule %d: %v\n", schedule.ID, err)
+				return transferred, fmt.Errorf("failed to update schedule %d: %w", schedule.ID, err)
+			}
+			if ok {
+				transferred++
+			}
+		}
+		if len(schedules) < dueSchedulesBatchSize {
+			return transferred, nil
+		}
+	}
+}
+
+// run makes the transfer of the schedule's current occurrence and moves the schedule on
+// according to the outcome, reporting whether money was transferred. The transfer's internal
+// idempotency key names the occurrence, so running it again after its schedule failed to update
+// does not pay twice.
+func (c *ScheduleHandler) run(ctx context.Context, schedule *model.Schedule) bool {
+	ctx = auth.WithPrincipal(ctx, auth.Principal{UserID: schedule.FromUserID, Role: model.UserRoleCustomer})
+	ctx = withInternalIdempotencyKey(ctx, fmt.Sprintf("schedule-%d-%d", schedule.ID, schedule.Occurrence))
+	response, err := c.Transfers.Transfer(ctx, &dto.TransferRequest{
+		FromUserID:        schedule.FromUserID,
+		ToUserID:          schedule.ToUserID,
+		Amount:            schedule.Amount,
+		Currency:          schedule.Currency,
+		Memo:              schedule.Memo,
+		ExternalReference: schedule.ExternalReference,
+	})
+	if err == nil {
+		schedule.LastTransferID, schedule.LastError = response.TransferID, ""
+		schedule.Advance()
+		return true
+	}
+
+	log.Printf("Error running schedule %d occurrence %d: %v\n", schedule.ID, schedule.Occurrence, err)
+	schedule.LastError = err.Error()
+	if len(schedule.LastError) > maxLastErrorLength {
+		schedule.LastError = schedule.LastError[:maxLastErrorLength]
+	}
+	switch {
+	case !retryableScheduleError(err):
+		schedule.Status = model.ScheduleStatusFailed
+	case schedule.Attempts+1 < config.ScheduleMaxAttempts:
+		schedule.Attempts++
+		schedule.NextRunAt = c.now().Add(config.ScheduleRetryInterval)
+	case schedule.FailurePolicy == model.ScheduleFailSkip:
+		schedule.Advance()
+	default:
+		schedule.Status = model.ScheduleStatusFailed
+	}
+	return false
+}
+
+// retryableScheduleError reports whether a failed scheduled transfer may succeed later without
+// anyone changing the schedule, such as when the wallet is topped up or unfrozen, or a day's
+// transfers drop out of its daily limit. An idempotency key already used for another transfer
+// stays so, however often it is retried.
+func retryableScheduleError(err error) bool {
+	if errors.Is(err, ErrIdempotencyKeyReused) {
+		return false
+	}
+	for _, target := range []error{apperror.ErrInsufficientFunds, apperror.ErrAccountFrozen, apperror.ErrLimitExceeded, apperror.ErrConflict, apperror.ErrStorage} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleResponse describes schedule to its owner
+func scheduleResponse(schedule *model.Schedule) *dto.ScheduleResponse {
+	response := &dto.ScheduleResponse{
+		ID:                schedule.ID,
+		FromUserID:        schedule.FromUserID,
+		ToUserID:          schedule.ToUserID,
+		Amount:            schedule.Amount,
+		Currency:          schedule.Currency,
+		Memo:              schedule.Memo,
+		ExternalReference: schedule.ExternalReference,
+		Recurrence:        schedule.Recurrence.String(),
+		StartAt:           schedule.StartAt,
+		FailurePolicy:     schedule.FailurePolicy.String(),
+		Status:            schedule.Status.String(),
+		NextRunAt:         schedule.NextRunAt,
+		Attempts:          schedule.Attempts,
+		LastTransferID:    schedule.LastTransferID,
+		LastError:         schedule.LastError,
+	}
+	if schedule.EndAt != nil {
+		response.EndAt = *schedule.EndAt
+	}
+	return response
+}
@@ -0,0 +1,342 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/config"
+	"walletApp/dto"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewScheduleHandler(t *testing.T) {
+	balances := NewBalanceHandler()
+	handler := NewScheduleHandler(balances)
+
+	assert.NotNil(t, handler, "Expected non-nil handler, got nil")
+	assert.NotNil(t, handler.ScheduleRepo, "Expected non-nil ScheduleRepo, got nil")
+	assert.NotNil(t, handler.BalanceRepo, "Expected non-nil BalanceRepo, got nil")
+	assert.Same(t, balances, handler.Transfers)
+}
+
+// scheduleClock is a clock the test moves by hand
+type scheduleClock struct{ now time.Time }
+
+func (c *scheduleClock) Now() time.Time { return c.now }
+
+// newScheduleTest returns a store where user 1 holds 100.00 and user 2 nothing, and a schedule
+// handler for it whose balance and schedule clocks both read clock
+func newScheduleTest(clock *scheduleClock) (*memoryStore, *ScheduleHandler) {
+	store := newMemoryStore(map[uint]model.Money{1: 10000, 2: 0})
+	balances := newMemoryBalanceHandler(store)
+	balances.Now = clock.Now
+	return store, &ScheduleHandler{ScheduleRepo: store, BalanceRepo: store, Transfers: balances, Now: clock.Now}
+}
+
+func TestMonthlySchedule(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)}
+	store, schedules := newScheduleTest(clock)
+
+	start := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 4, 30, 23, 59, 59, 0, time.UTC)
+	created, err := schedules.CreateSchedule(customerContext(1), &dto.CreateScheduleRequest{
+		FromUserID: 1, ToUserID: 2, Amount: 1500, Memo: "rent", Recurrence: "monthly", StartAt: start, EndAt: end,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &dto.ScheduleResponse{
+		ID:            created.ID,
+		FromUserID:    1,
+		ToUserID:      2,
+		Amount:        1500,
+		Currency:      model.USD,
+		Memo:          "rent",
+		Recurrence:    "Monthly",
+		StartAt:       start,
+		EndAt:         end,
+		FailurePolicy: "Skip",
+		Status:        "Active",
+		NextRunAt:     start,
+	}, created)
+
+	// Nothing is due before the start
+	transferred, err := schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, transferred)
+
+	clock.now = start
+	transferred, err = schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, transferred)
+	schedule, err := store.GetSchedule(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC), schedule.NextRunAt, "February has no 31st")
+	assert.NotEmpty(t, schedule.LastTransferID)
+
+	// Running again at the same time pays nothing more
+	transferred, err = schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, transferred)
+
+	// The scheduler was down through March 31 and catches up one occurrence per run
+	clock.now = time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	for range 2 {
+		transferred, err = schedules.RunDueSchedules(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, transferred)
+	}
+	schedule, err = store.GetSchedule(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 4, 30, 9, 0, 0, 0, time.UTC), schedule.NextRunAt)
+
+	// The April occurrence is the last one before the end
+	clock.now = time.Date(2024, 4, 30, 9, 0, 0, 0, time.UTC)
+	transferred, err = schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, transferred)
+	schedule, err = store.GetSchedule(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScheduleStatusCompleted, schedule.Status)
+	assert.Equal(t, uint(4), schedule.Occurrence)
+
+	assert.Equal(t, model.Money(4000), store.balance(1, model.USD))
+	assert.Equal(t, model.Money(6000), store.balance(2, model.USD))
+	history, err := store.ListTransactions(context.Background(), model.TransactionQuery{UserID: 2})
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+	for _, transaction := range history {
+		assert.Equal(t, "rent", transaction.Memo)
+	}
+	assert.Zero(t, store.total())
+}
+
+func TestScheduleRetriesFailedRuns(t *testing.T) {
+	tests := []struct {
+		name           string
+		failurePolicy  string
+		expectedStatus model.ScheduleStatus
+		expectedNext   time.Duration // After the start
+	}{
+		{name: "Skip", failurePolicy: "Skip", expectedStatus: model.ScheduleStatusActive, expectedNext: 7 * 24 * time.Hour},
+		{name: "Stop", failurePolicy: "Stop", expectedStatus: model.ScheduleStatusFailed},
+		{name: "Default", expectedStatus: model.ScheduleStatusActive, expectedNext: 7 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+			clock := &scheduleClock{now: start}
+			store, schedules := newScheduleTest(clock)
+			created, err := schedules.CreateSchedule(customerContext(1), &dto.CreateScheduleRequest{
+				FromUserID: 1, ToUserID: 2, Amount: 20000, Recurrence: "Weekly", FailurePolicy: tt.failurePolicy,
+			})
+			require.NoError(t, err)
+
+			for attempt := uint(1); attempt <= config.ScheduleMaxAttempts; attempt++ {
+				transferred, err := schedules.RunDueSchedules(context.Background())
+				require.NoError(t, err)
+				assert.Zero(t, transferred)
+				schedule, err := store.GetSchedule(context.Background(), created.ID)
+				require.NoError(t, err)
+				assert.Contains(t, schedule.LastError, "insufficient USD funds")
+				if attempt < config.ScheduleMaxAttempts {
+					assert.Equal(t, attempt, schedule.Attempts)
+					assert.Equal(t, clock.now.Add(config.ScheduleRetryInterval), schedule.NextRunAt)
+					clock.now = schedule.NextRunAt
+				}
+			}
+
+			schedule, err := store.GetSchedule(context.Background(), created.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, schedule.Status)
+			if tt.expectedStatus == model.ScheduleStatusActive {
+				assert.Equal(t, uint(1), schedule.Occurrence)
+				assert.Zero(t, schedule.Attempts)
+				assert.Equal(t, start.Add(tt.expectedNext), schedule.NextRunAt)
+			}
+			assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+		})
+	}
+}
+
+func TestScheduleRetrySucceeds(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	store, schedules := newScheduleTest(clock)
+	created, err := schedules.CreateSchedule(customerContext(1), &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 2, Amount: 20000, Recurrence: "Once"})
+	require.NoError(t, err)
+
+	transferred, err := schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, transferred)
+
+	// Topping up the wallet before the retry lets it through
+	_, err = schedules.Transfers.Deposit(customerContext(1), &dto.DepositRequest{UserID: 1, Amount: 10000})
+	require.NoError(t, err)
+	clock.now = clock.now.Add(config.ScheduleRetryInterval)
+	transferred, err = schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, transferred)
+
+	schedule, err := store.GetSchedule(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScheduleStatusCompleted, schedule.Status)
+	assert.Empty(t, schedule.LastError)
+	assert.Equal(t, model.Money(20000), store.balance(2, model.USD))
+}
+
+func TestScheduleFailsOnClosedAccount(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	store, schedules := newScheduleTest(clock)
+	created, err := schedules.CreateSchedule(customerContext(1), &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 2, Amount: 100, Recurrence: "Daily"})
+	require.NoError(t, err)
+	_, err = newMemoryAccountHandler(store).CloseAccount(adminContext(), &dto.AccountRequest{UserID: 2})
+	require.NoError(t, err)
+
+	// Retrying cannot help, so the schedule stops at once whatever its policy
+	_, err = schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	schedule, err := store.GetSchedule(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScheduleStatusFailed, schedule.Status)
+	assert.Contains(t, schedule.LastError, apperror.ErrAccountClosed.Error())
+}
+
+func TestScheduleRunIsIdempotent(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	store, schedules := newScheduleTest(clock)
+	created, err := schedules.CreateSchedule(customerContext(1), &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 2, Amount: 1000, Recurrence: "Daily"})
+	require.NoError(t, err)
+	before, err := store.GetSchedule(context.Background(), created.ID)
+	require.NoError(t, err)
+
+	transferred, err := schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, transferred)
+
+	// The schedule loses its update as if the scheduler crashed after the transfer
+	after, err := store.GetSchedule(context.Background(), created.ID)
+	require.NoError(t, err)
+	before.Version = after.Version
+	store.schedules[created.ID-1] = *before
+
+	transferred, err = schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, transferred, "The replayed transfer counts as made")
+	assert.Equal(t, model.Money(1000), store.balance(2, model.USD))
+	replayed, err := store.GetSchedule(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, after.LastTransferID, replayed.LastTransferID)
+}
+
+func TestScheduleKeysCannotBeClaimed(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	store, schedules := newScheduleTest(clock)
+	created, err := schedules.CreateSchedule(customerContext(1), &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 2, Amount: 1000, Recurrence: "Daily"})
+	require.NoError(t, err)
+
+	// A client cannot send the key the schedule's first run is going to use
+	_, err = schedules.Transfers.Transfer(customerContext(1), &dto.TransferRequest{
+		FromUserID:     1,
+		ToUserID:       2,
+		Amount:         1,
+		IdempotencyKey: fmt.Sprintf("%sschedule-%d-0", model.InternalIdempotencyKeyPrefix, created.ID),
+	})
+	assert.ErrorIs(t, err, apperror.ErrInvalidRequest)
+	transferred, err := schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, transferred)
+
+	// Were the next run's key taken by another transfer anyway, retrying would not help
+	store.idempotency[idempotencyKey{userID: 1, key: fmt.Sprintf("%sschedule-%d-1", model.InternalIdempotencyKeyPrefix, created.ID)}] = model.IdempotencyRecord{Operation: "transfer"}
+	clock.now = clock.now.AddDate(0, 0, 1)
+	_, err = schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	schedule, err := store.GetSchedule(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScheduleStatusFailed, schedule.Status)
+	assert.Equal(t, model.Money(1000), store.balance(2, model.USD))
+}
+
+func TestCancelSchedule(t *testing.T) {
+	clock := &scheduleClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	store, schedules := newScheduleTest(clock)
+	created, err := schedules.CreateSchedule(customerContext(1), &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 2, Amount: 1000, Recurrence: "Daily"})
+	require.NoError(t, err)
+
+	_, err = schedules.CancelSchedule(customerContext(2), &dto.ScheduleRequest{ScheduleID: created.ID})
+	assert.ErrorIs(t, err, apperror.ErrForbidden)
+
+	cancelled, err := schedules.CancelSchedule(customerContext(1), &dto.ScheduleRequest{ScheduleID: created.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "Cancelled", cancelled.Status)
+	_, err = schedules.CancelSchedule(adminContext(), &dto.ScheduleRequest{ScheduleID: created.ID})
+	assert.ErrorIs(t, err, apperror.ErrConflict)
+	_, err = schedules.CancelSchedule(customerContext(1), &dto.ScheduleRequest{ScheduleID: 9})
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+
+	transferred, err := schedules.RunDueSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, transferred)
+	assert.Equal(t, model.Money(10000), store.balance(1, model.USD))
+
+	list, err := schedules.ListSchedules(customerContext(1), &dto.ListSchedulesRequest{UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, list.Schedules, 1)
+	assert.Equal(t, "Cancelled", list.Schedules[0].Status)
+	_, err = schedules.ListSchedules(customerContext(2), &dto.ListSchedulesRequest{UserID: 1})
+	assert.ErrorIs(t, err, apperror.ErrForbidden)
+}
+
+func TestCreateScheduleErrors(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		request       *dto.CreateScheduleRequest
+		expectedError error
+	}{
+		{
+			name:          "Someone else's wallet",
+			ctx:           customerContext(2),
+			request:       &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 2, Amount: 100, Recurrence: "Once"},
+			expectedError: apperror.ErrForbidden,
+		},
+		{
+			name:          "Start in the past",
+			ctx:           customerContext(1),
+			request:       &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 2, Amount: 100, Recurrence: "Once", StartAt: now.Add(-time.Hour)},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+		{
+			name:          "Recipient without a wallet",
+			ctx:           customerContext(1),
+			request:       &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 9, Amount: 100, Recurrence: "Once"},
+			expectedError: apperror.ErrAccountNotFound,
+		},
+		{
+			name:          "No wallet in the currency",
+			ctx:           customerContext(1),
+			request:       &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 2, Amount: 100, Currency: model.EUR, Recurrence: "Once"},
+			expectedError: apperror.ErrAccountNotFound,
+		},
+		{
+			name:          "Unknown recurrence",
+			ctx:           customerContext(1),
+			request:       &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 2, Amount: 100, Recurrence: "Hourly"},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, schedules := newScheduleTest(&scheduleClock{now: now})
+			_, err := schedules.CreateSchedule(tt.ctx, tt.request)
+			assert.ErrorIs(t, err, tt.expectedError)
+			assert.Empty(t, store.schedules)
+		})
+	}
+}
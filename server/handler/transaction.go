@@ -2,11 +2,18 @@ package handler
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
+	"walletApp/auth"
 	"walletApp/config"
 	"walletApp/dto"
+	"walletApp/model"
 	"walletApp/storage"
+	"walletApp/validation"
 )
 
 type TransactionHandler struct {
@@ -18,14 +25,113 @@ func NewTransactionHandler() *TransactionHandler {
 	return &TransactionHandler{TransactionRepo: storage.NewTransactionRepository(config.DB)}
 }
 
-func (c *TransactionHandler) ViewTransactionHistory(ctx context.Context, userID uint) (*dto.TransactionHistoryResponse, error) {
-	transactions, err := c.TransactionRepo.GetTransactionsByUserID(ctx, userID)
+// ViewTransactionHistory returns one page of the user's transactions matching the request's
+// filters, newest first. The response's NextCursor and PrevCursor, passed back as the request's
+// Cursor with the same filters, select the pages of older and newer transactions.
+func (c *TransactionHandler) ViewTransactionHistory(ctx context.Context, request *dto.TransactionHistoryRequest) (*dto.TransactionHistoryResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	if err := auth.Authorize(ctx, request.UserID); err != nil {
+		return nil, err
+	}
+	query, err := transactionQuery(request)
 	if err != nil {
-		log.Printf("Error fetching transaction history for user %d: %v\n", userID, err)
-		return nil, fmt.Errorf("failed to fetch transaction history for user %d: %w", userID, err)
+		return nil, err
+	}
+	pageSize := request.Limit
+	if pageSize == 0 {
+		pageSize = validation.DefaultPageSize
+	}
+	// Ask for one transaction more than fits on the page to learn whether another page follows
+	query.Limit = pageSize + 1
+
+	transactions, err := c.TransactionRepo.ListTransactions(ctx, query)
+	if err != nil {
+		log.Printf("Error fetching transaction history for user %d: %v\n", request.UserID, err)
+		return nil, fmt.Errorf("failed to fetch transaction history for user %d: %w", request.UserID, err)
+	}
+
+	backward := query.Cursor != nil && query.Cursor.Backward
+	more := len(transactions) > pageSize
+	if more {
+		if backward {
+			// The extra transaction is the newest one, furthest from the cursor
+			transactions = transactions[1:]
+		} else {
+			transactions = transactions[:pageSize]
+		}
+	}
+
+	response := &dto.TransactionHistoryResponse{Transactions: transactions}
+	if len(transactions) > 0 {
+		// Going back from a cursor, there are always older transactions: the ones already seen
+		if more || backward {
+			response.NextCursor = encodeCursor(transactions[len(transactions)-1], false)
+		}
+		if (more && backward) || (!backward && query.Cursor != nil) {
+			response.PrevCursor = encodeCursor(transactions[0], true)
+		}
+	}
+	return response, nil
+}
+
+// transactionQuery translates a validated history request into a repository query
+func transactionQuery(request *dto.TransactionHistoryRequest) (model.TransactionQuery, error) {
+	query := model.TransactionQuery{
+		UserID:    request.UserID,
+		Currency:  request.Currency,
+		From:      request.From,
+		To:        request.To,
+		MinAmount: request.MinAmount,
+		MaxAmount: request.MaxAmount,
 	}
+	for _, name := range request.Types {
+		transactionType, _ := model.ParseTransactionType(name)
+		query.Types = append(query.Types, transactionType)
+	}
+	if request.Cursor != "" {
+		cursor, err := decodeCursor(request.Cursor)
+		if err != nil {
+			return query, validation.Errors{{Field: "cursor", Message: "is not a cursor returned by an earlier page"}}
+		}
+		query.Cursor = cursor
+	}
+	return query, nil
+}
 
-	return &dto.TransactionHistoryResponse{
-		Transactions: transactions,
+// encodeCursor returns an opaque cursor selecting the transactions older than transaction, or
+// newer than it when backward is set
+func encodeCursor(transaction model.Transaction, backward bool) string {
+	direction := "older"
+	if backward {
+		direction = "newer"
+	}
+	position := fmt.Sprintf("%s:%d:%d", direction, transaction.Timestamp.UnixNano(), transaction.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(position))
+}
+
+// decodeCursor parses a cursor made by encodeCursor
+func decodeCursor(text string) (*model.TransactionCursor, error) {
+	position, err := base64.RawURLEncoding.DecodeString(text)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(string(position), ":")
+	if len(parts) != 3 || (parts[0] != "older" && parts[0] != "newer") {
+		return nil, fmt.Errorf("malformed cursor %q", position)
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &model.TransactionCursor{
+		Timestamp: time.Unix(0, nanos).UTC(),
+		ID:        uint(id),
+		Backward:  parts[0] == "newer",
 	}, nil
 }
@@ -1,14 +1,18 @@
 package handler
 
 import (
-	"context"
 	"errors"
 	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/dto"
 	"walletApp/model"
 	"walletApp/storage"
+	"walletApp/validation"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewTransactionHandler(t *testing.T) {
@@ -33,13 +37,13 @@ func TestViewTransactionHistory(t *testing.T) {
 				{
 					ID:     1,
 					UserID: 1,
-					Amount: 100.0,
+					Amount: 100,
 					Type:   model.TransactionTypeDeposit,
 				},
 				{
 					ID:     2,
 					UserID: 1,
-					Amount: -50.0,
+					Amount: -50,
 					Type:   model.TransactionTypeWithdraw,
 				},
 			},
@@ -63,24 +67,34 @@ func TestViewTransactionHistory(t *testing.T) {
 			expectSuccess:  false,
 			expectedLength: 0,
 		},
+		{
+			name:           "Missing User ID",
+			userID:         0,
+			transactions:   nil,
+			repoError:      nil,
+			expectSuccess:  false,
+			expectedLength: 0,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockTransactionRepo := storage.NewMockTransactionRepository(
 				func(m *mock.Mock) {
-					m.On("GetTransactionsByUserID", mock.Anything, tt.userID).Return(tt.transactions, tt.repoError)
+					m.On("ListTransactions", mock.Anything, model.TransactionQuery{UserID: tt.userID, Limit: validation.DefaultPageSize + 1}).Return(tt.transactions, tt.repoError)
 				},
 			)
 
 			handler := &TransactionHandler{
 				TransactionRepo: mockTransactionRepo,
 			}
-			response, err := handler.ViewTransactionHistory(context.Background(), tt.userID)
+			response, err := handler.ViewTransactionHistory(adminContext(), &dto.TransactionHistoryRequest{UserID: tt.userID})
 			if tt.expectSuccess {
 				assert.NoError(t, err)
 				assert.NotNil(t, response)
 				assert.Equal(t, tt.expectedLength, len(response.Transactions))
+				assert.Empty(t, response.NextCursor, "everything fits on the first page")
+				assert.Empty(t, response.PrevCursor, "nothing comes before the first page")
 
 				// Verify the transactions match
 				if tt.expectedLength > 0 {
@@ -98,3 +112,60 @@ func TestViewTransactionHistory(t *testing.T) {
 		})
 	}
 }
+
+func TestTransactionHistoryPagination(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newMemoryStore(nil)
+	store.transactions = []model.Transaction{
+		{ID: 1, UserID: 1, Type: model.TransactionTypeDeposit, Amount: 1000, Timestamp: start},
+		{ID: 2, UserID: 1, Type: model.TransactionTypeWithdraw, Amount: -100, Timestamp: start.Add(time.Hour)},
+		{ID: 3, UserID: 2, Type: model.TransactionTypeDeposit, Amount: 500, Timestamp: start.Add(time.Hour)},
+		// Transactions 4 and 5 share a timestamp, so pages must break ties by ID
+		{ID: 4, UserID: 1, Type: model.TransactionTypeTransferSend, Amount: -200, Timestamp: start.Add(2 * time.Hour)},
+		{ID: 5, UserID: 1, Type: model.TransactionTypeWithdraw, Amount: -300, Timestamp: start.Add(2 * time.Hour)},
+		{ID: 6, UserID: 1, Type: model.TransactionTypeDeposit, Amount: 50, Timestamp: start.Add(3 * time.Hour)},
+	}
+	handler := &TransactionHandler{TransactionRepo: store}
+	ctx := adminContext()
+
+	page := func(request *dto.TransactionHistoryRequest) (*dto.TransactionHistoryResponse, []uint) {
+		t.Helper()
+		response, err := handler.ViewTransactionHistory(ctx, request)
+		require.NoError(t, err)
+		var ids []uint
+		for _, transaction := range response.Transactions {
+			ids = append(ids, transaction.ID)
+		}
+		return response, ids
+	}
+
+	first, ids := page(&dto.TransactionHistoryRequest{UserID: 1, Limit: 2})
+	assert.Equal(t, []uint{6, 5}, ids)
+	assert.Empty(t, first.PrevCursor)
+
+	second, ids := page(&dto.TransactionHistoryRequest{UserID: 1, Limit: 2, Cursor: first.NextCursor})
+	assert.Equal(t, []uint{4, 2}, ids)
+
+	last, ids := page(&dto.TransactionHistoryRequest{UserID: 1, Limit: 2, Cursor: second.NextCursor})
+	assert.Equal(t, []uint{1}, ids)
+	assert.Empty(t, last.NextCursor)
+
+	// Walking back returns the same pages
+	back, ids := page(&dto.TransactionHistoryRequest{UserID: 1, Limit: 2, Cursor: last.PrevCursor})
+	assert.Equal(t, []uint{4, 2}, ids)
+	assert.Equal(t, second.NextCursor, back.NextCursor)
+	back, ids = page(&dto.TransactionHistoryRequest{UserID: 1, Limit: 2, Cursor: back.PrevCursor})
+	assert.Equal(t, []uint{6, 5}, ids)
+	assert.Empty(t, back.PrevCursor)
+	assert.NotEmpty(t, back.NextCursor)
+
+	// Filters apply to every page
+	minAmount := model.Money(-250)
+	_, ids = page(&dto.TransactionHistoryRequest{UserID: 1, Types: []string{"Withdraw", "TransferSend"}, MinAmount: &minAmount})
+	assert.Equal(t, []uint{4, 2}, ids)
+	_, ids = page(&dto.TransactionHistoryRequest{UserID: 1, From: start.Add(time.Hour), To: start.Add(3 * time.Hour)})
+	assert.Equal(t, []uint{5, 4, 2}, ids)
+
+	_, err := handler.ViewTransactionHistory(ctx, &dto.TransactionHistoryRequest{UserID: 1, Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, apperror.ErrInvalidRequest)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/config"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/storage"
+	"walletApp/validation"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// errInvalidCredentials is returned for an unknown username and a wrong password alike, so
+// that a failed login does not reveal which usernames exist
+var errInvalidCredentials = fmt.Errorf("%w: invalid username or password", apperror.ErrUnauthenticated)
+
+// dummyPasswordHash is compared against when the username is unknown, so that a failed login
+// takes as long whether or not the user exists
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+type UserHandler struct {
+	UserRepo    storage.UserRepository
+	BalanceRepo storage.BalanceRepository
+	UnitOfWork  storage.UnitOfWork
+	Tokens      *auth.TokenManager
+}
+
+// NewUserHandler creates a new instance of UserHandler
+func NewUserHandler() *UserHandler {
+	return &UserHandler{
+		UserRepo:    storage.NewUserRepository(config.DB),
+		BalanceRepo: storage.NewBalanceRepository(config.DB),
+		UnitOfWork:  storage.NewUnitOfWork(config.DB),
+		Tokens:      auth.NewTokenManager(config.TokenSecret()),
+	}
+}
+
+// Register creates a customer with a hashed password and opens the customer's wallet in
+// model.DefaultCurrency
+func (c *UserHandler) Register(ctx context.Context, request *dto.RegisterRequest) (*dto.RegisterResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &model.User{Username: request.Username, PasswordHash: string(hash), Role: model.UserRoleCustomer}
+	err = c.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := c.UserRepo.CreateUser(ctx, user); err != nil {
+			return err
+		}
+		return c.BalanceRepo.CreateBalance(ctx, &model.Balance{UserID: user.ID, Currency: model.DefaultCurrency, Status: model.AccountStatusActive})
+	})
+	if err != nil {
+		log.Printf("Error registering user %q: %v\n", request.Username, err)
+		return nil, fmt.Errorf("failed to register user %q: %w", request.Username, err)
+	}
+
+	return &dto.RegisterResponse{UserID: user.ID, Username: user.Username}, nil
+}
+
+// Login checks the user's password and issues a token identifying the user
+func (c *UserHandler) Login(ctx context.Context, request *dto.LoginRequest) (*dto.LoginResponse, error) {
+	if err := validation.Validate(request); err != nil {
+		return nil, err
+	}
+	user, err := c.UserRepo.GetUserByUsername(ctx, request.Username)
+	if err != nil {
+		log.Printf("Error fetching user %q: %v\n", request.Username, err)
+		return nil, fmt.Errorf("failed to fetch user %q: %w", request.Username, err)
+	}
+
+	hash := dummyPasswordHash
+	if user != nil {
+		hash = []byte(user.PasswordHash)
+	}
+	err = bcrypt.CompareHashAndPassword(hash, []byte(request.Password))
+	if user == nil || errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check password for user %q: %w", request.Username, err)
+	}
+
+	token, expiresAt, err := c.Tokens.Issue(auth.Principal{UserID: user.ID, Role: user.Role})
+	if err != nil {
+		return nil, err
+	}
+	return &dto.LoginResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		UserID:    user.ID,
+		Role:      user.Role.String(),
+	}, nil
+}
+
+// Authenticate returns the caller identified by a token issued by Login
+func (c *UserHandler) Authenticate(token string) (auth.Principal, error) {
+	return c.Tokens.Verify(token)
+}
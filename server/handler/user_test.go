@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNewUserHandler(t *testing.T) {
+	handler := NewUserHandler()
+
+	assert.NotNil(t, handler, "Expected non-nil handler, got nil")
+	assert.NotNil(t, handler.UserRepo, "Expected non-nil UserRepo, got nil")
+	assert.NotNil(t, handler.BalanceRepo, "Expected non-nil BalanceRepo, got nil")
+	assert.NotNil(t, handler.UnitOfWork, "Expected non-nil UnitOfWork, got nil")
+	assert.NotNil(t, handler.Tokens, "Expected non-nil Tokens, got nil")
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name            string
+		request         *dto.RegisterRequest
+		createUserError error
+		expectedError   error
+	}{
+		{
+			name:    "New user",
+			request: &dto.RegisterRequest{Username: "dana", Password: "correct horse"},
+		},
+		{
+			name:            "Username taken",
+			request:         &dto.RegisterRequest{Username: "dana", Password: "correct horse"},
+			createUserError: apperror.ErrConflict,
+			expectedError:   apperror.ErrConflict,
+		},
+		{
+			name:          "Password too short",
+			request:       &dto.RegisterRequest{Username: "dana", Password: "short"},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created *model.User
+			mockUserRepo := storage.NewMockUserRepository(func(m *mock.Mock) {
+				m.On("CreateUser", mock.Anything, mock.Anything).
+					Run(func(args mock.Arguments) {
+						created = args.Get(1).(*model.User)
+						created.ID = 7
+					}).
+					Return(tt.createUserError)
+			})
+			mockBalanceRepo := storage.NewMockBalanceRepository(func(m *mock.Mock) {
+				m.On("CreateBalance", mock.Anything, &model.Balance{UserID: 7, Currency: model.USD, Status: model.AccountStatusActive}).Return(nil)
+			})
+			handler := &UserHandler{
+				UserRepo:    mockUserRepo,
+				BalanceRepo: mockBalanceRepo,
+				UnitOfWork:  newPassthroughUnitOfWork(),
+			}
+
+			response, err := handler.Register(context.Background(), tt.request)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.Nil(t, response)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &dto.RegisterResponse{UserID: 7, Username: "dana"}, response)
+			assert.Equal(t, model.UserRoleCustomer, created.Role)
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(created.PasswordHash), []byte(tt.request.Password)))
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+	stored := &model.User{ID: 3, Username: "carol", PasswordHash: string(hash), Role: model.UserRoleAdmin}
+
+	tests := []struct {
+		name          string
+		request       *dto.LoginRequest
+		expectedError error
+	}{
+		{
+			name:    "Correct password",
+			request: &dto.LoginRequest{Username: "carol", Password: "correct horse"},
+		},
+		{
+			name:          "Wrong password",
+			request:       &dto.LoginRequest{Username: "carol", Password: "battery staple"},
+			expectedError: apperror.ErrUnauthenticated,
+		},
+		{
+			name:          "Unknown user",
+			request:       &dto.LoginRequest{Username: "mallory", Password: "correct horse"},
+			expectedError: apperror.ErrUnauthenticated,
+		},
+		{
+			name:          "Missing password",
+			request:       &dto.LoginRequest{Username: "carol"},
+			expectedError: apperror.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := storage.NewMockUserRepository(func(m *mock.Mock) {
+				m.On("GetUserByUsername", mock.Anything, "carol").Return(stored, nil)
+				m.On("GetUserByUsername", mock.Anything, "mallory").Return(nil, nil)
+			})
+			now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			tokens := auth.NewTokenManager([]byte("test secret"))
+			tokens.Now = func() time.Time { return now }
+			handler := &UserHandler{UserRepo: mockUserRepo, Tokens: tokens}
+
+			response, err := handler.Login(context.Background(), tt.request)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.Nil(t, response)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, uint(3), response.UserID)
+			assert.Equal(t, "Admin", response.Role)
+			assert.Equal(t, now.Add(auth.DefaultTokenTTL), response.ExpiresAt)
+
+			principal, err := handler.Authenticate(response.Token)
+			require.NoError(t, err)
+			assert.Equal(t, auth.Principal{UserID: 3, Role: model.UserRoleAdmin}, principal)
+		})
+	}
+}
@@ -0,0 +1,509 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/dto"
+	"walletApp/model"
+	"walletApp/validation"
+)
+
+// maxRequestBodyBytes bounds the size of a JSON request body
+const maxRequestBodyBytes = 1 << 20
+
+// Routes returns the REST/JSON API. It exposes the operations of the CLI that move money or read
+// a wallet, holds, schedules, reversals and the review of adjustments; opening, freezing,
+// unfreezing and closing accounts, setting tiers, products and overdraft limits and the overdraft
+// report are only available from the CLI:
+//
+//	POST /api/register                              dto.RegisterRequest         -> dto.RegisterResponse
+//	POST /api/login                                 dto.LoginRequest            -> dto.LoginResponse
+//	POST /api/deposit                               dto.DepositRequest          -> dto.DepositResponse
+//	POST /api/withdraw                              dto.WithdrawRequest         -> dto.WithdrawResponse
+//	POST /api/transfer                              dto.TransferRequest         -> dto.TransferResponse
+//	POST /api/transfer/batch                        dto.BatchTransferRequest    -> dto.BatchTransferResponse
+//	POST /api/fees/quote                            dto.FeeQuoteRequest         -> dto.FeeQuoteResponse
+//	POST /api/exchange/quotes                       dto.ExchangeQuoteRequest    -> dto.ExchangeQuoteResponse
+//	POST /api/exchange                              dto.ExchangeRequest         -> dto.ExchangeResponse
+//	POST /api/holds                                 dto.AuthorizeHoldRequest    -> dto.HoldResponse
+//	GET  /api/holds/{holdID}                        -> dto.HoldResponse
+//	POST /api/holds/{holdID}/capture                dto.CaptureHoldRequest      -> dto.HoldResponse
+//	POST /api/holds/{holdID}/void                   -> dto.HoldResponse
+//	POST /api/schedules                             dto.CreateScheduleRequest   -> dto.ScheduleResponse
+//	POST /api/schedules/{scheduleID}/cancel         -> dto.ScheduleResponse
+//	GET  /api/users/{userID}/schedules              -> dto.ScheduleListResponse
+//	GET  /api/users/{userID}/balance                -> dto.CheckBalanceResponse
+//	GET  /api/users/{userID}/transactions           -> dto.TransactionHistoryResponse, see historyRequest
+//	POST /api/transactions/{transactionID}/reverse  dto.ReverseRequest          -> dto.ReverseResponse
+//	POST /api/adjustments                           dto.CreateAdjustmentRequest -> dto.AdjustmentResponse
+//	GET  /api/adjustments/pending                   -> dto.AdjustmentListResponse
+//	POST /api/adjustments/{adjustmentID}/approve    -> dto.AdjustmentResponse
+//	POST /api/adjustments/{adjustmentID}/reject     -> dto.AdjustmentResponse
+//
+// Every endpoint except register and login needs an "Authorization: Bearer <token>" header
+// holding a token from login. Failures are answered with a dto.ErrorResponse and a status code
+// derived from the apperror.
+func (a *App) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/register", a.handleRegister)
+	mux.HandleFunc("POST /api/login", a.handleLogin)
+	mux.HandleFunc("POST /api/deposit", a.authenticated(a.handleDeposit))
+	mux.HandleFunc("POST /api/withdraw", a.authenticated(a.handleWithdraw))
+	mux.HandleFunc("POST /api/transfer", a.authenticated(a.handleTransfer))
+	mux.HandleFunc("POST /api/transfer/batch", a.authenticated(a.handleBatchTransfer))
+	mux.HandleFunc("POST /api/fees/quote", a.authenticated(a.handleQuoteFee))
+	mux.HandleFunc("POST /api/exchange/quotes", a.authenticated(a.handleQuoteExchange))
+	mux.HandleFunc("POST /api/exchange", a.authenticated(a.handleExchange))
+	mux.HandleFunc("POST /api/holds", a.authenticated(a.handleAuthorizeHold))
+	mux.HandleFunc("GET /api/holds/{holdID}", a.authenticated(a.handleGetHold))
+	mux.HandleFunc("POST /api/holds/{holdID}/capture", a.authenticated(a.handleCaptureHold))
+	mux.HandleFunc("POST /api/holds/{holdID}/void", a.authenticated(a.handleVoidHold))
+	mux.HandleFunc("POST /api/schedules", a.authenticated(a.handleCreateSchedule))
+	mux.HandleFunc("POST /api/schedules/{scheduleID}/cancel", a.authenticated(a.handleCancelSchedule))
+	mux.HandleFunc("GET /api/users/{userID}/schedules", a.authenticated(a.handleListSchedules))
+	mux.HandleFunc("GET /api/users/{userID}/balance", a.authenticated(a.handleBalance))
+	mux.HandleFunc("GET /api/users/{userID}/transactions", a.authenticated(a.handleTransactions))
+	mux.HandleFunc("POST /api/transactions/{transactionID}/reverse", a.authenticated(a.handleReverse))
+	mux.HandleFunc("POST /api/adjustments", a.authenticated(a.handleCreateAdjustment))
+	mux.HandleFunc("GET /api/adjustments/pending", a.authenticated(a.handlePendingAdjustments))
+	mux.HandleFunc("POST /api/adjustments/{adjustmentID}/approve", a.authenticated(a.handleReviewAdjustment(a.AdjustmentHandler.ApproveAdjustment)))
+	mux.HandleFunc("POST /api/adjustments/{adjustmentID}/reject", a.authenticated(a.handleReviewAdjustment(a.AdjustmentHandler.RejectAdjustment)))
+	return mux
+}
+
+// authenticated runs next with the caller identified by the request's bearer token attached to
+// the request context, or answers 401 if the token is missing or invalid
+func (a *App) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			respond(w, http.StatusOK, nil, fmt.Errorf("%w: missing bearer token", apperror.ErrUnauthenticated))
+			return
+		}
+		principal, err := a.UserHandler.Authenticate(token)
+		if err != nil {
+			respond(w, http.StatusOK, nil, err)
+			return
+		}
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+func (a *App) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var request dto.RegisterRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.UserHandler.Register(r.Context(), &request)
+	respond(w, http.StatusCreated, response, err)
+}
+
+func (a *App) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var request dto.LoginRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.UserHandler.Login(r.Context(), &request)
+	respond(w, http.StatusOK, response, err)
+}
+
+// ListenAndServe serves the API on addr until ctx is cancelled, then shuts down gracefully
+func (a *App) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           a.Routes(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("HTTP API listening on %s\n", addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+func (a *App) handleDeposit(w http.ResponseWriter, r *http.Request) {
+	var request dto.DepositRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.BalanceHandler.Deposit(r.Context(), &request)
+	respond(w, http.StatusOK, response, err)
+}
+
+func (a *App) handleWithdraw(w http.ResponseWriter, r *http.Request) {
+	var request dto.WithdrawRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.BalanceHandler.Withdraw(r.Context(), &request)
+	respond(w, http.StatusOK, response, err)
+}
+
+func (a *App) handleTransfer(w http.ResponseWriter, r *http.Request) {
+	var request dto.TransferRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.BalanceHandler.Transfer(r.Context(), &request)
+	respond(w, http.StatusOK, response, err)
+}
+
+func (a *App) handleBatchTransfer(w http.ResponseWriter, r *http.Request) {
+	var request dto.BatchTransferRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.BalanceHandler.BatchTransfer(r.Context(), &request)
+	respond(w, http.StatusOK, response, err)
+}
+
+func (a *App) handleQuoteFee(w http.ResponseWriter, r *http.Request) {
+	var request dto.FeeQuoteRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.BalanceHandler.QuoteFee(r.Context(), &request)
+	respond(w, http.StatusOK, response, err)
+}
+
+func (a *App) handleQuoteExchange(w http.ResponseWriter, r *http.Request) {
+	var request dto.ExchangeQuoteRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.BalanceHandler.QuoteExchange(r.Context(), &request)
+	respond(w, http.StatusOK, response, err)
+}
+
+func (a *App) handleExchange(w http.ResponseWriter, r *http.Request) {
+	var request dto.ExchangeRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.BalanceHandler.Exchange(r.Context(), &request)
+	respond(w, http.StatusOK, response, err)
+}
+
+// handleReverse reverses or refunds the transaction named in the path
+func (a *App) handleReverse(w http.ResponseWriter, r *http.Request) {
+	transactionID, ok := pathID(w, r, "transactionID", "transaction_id")
+	if !ok {
+		return
+	}
+	var request dto.ReverseRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	request.TransactionID = transactionID
+	response, err := a.BalanceHandler.Reverse(r.Context(), &request)
+	respond(w, http.StatusOK, response, err)
+}
+
+func (a *App) handleAuthorizeHold(w http.ResponseWriter, r *http.Request) {
+	var request dto.AuthorizeHoldRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.BalanceHandler.AuthorizeHold(r.Context(), &request)
+	respond(w, http.StatusCreated, response, err)
+}
+
+func (a *App) handleGetHold(w http.ResponseWriter, r *http.Request) {
+	holdID, ok := pathID(w, r, "holdID", "hold_id")
+	if !ok {
+		return
+	}
+	response, err := a.BalanceHandler.GetHold(r.Context(), &dto.HoldRequest{HoldID: holdID})
+	respond(w, http.StatusOK, response, err)
+}
+
+// handleCaptureHold captures the hold named in the path, in full unless the body has an amount
+func (a *App) handleCaptureHold(w http.ResponseWriter, r *http.Request) {
+	holdID, ok := pathID(w, r, "holdID", "hold_id")
+	if !ok {
+		return
+	}
+	var request dto.CaptureHoldRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	request.HoldID = holdID
+	response, err := a.BalanceHandler.CaptureHold(r.Context(), &request)
+	respond(w, http.StatusOK, response, err)
+}
+
+func (a *App) handleVoidHold(w http.ResponseWriter, r *http.Request) {
+	holdID, ok := pathID(w, r, "holdID", "hold_id")
+	if !ok {
+		return
+	}
+	response, err := a.BalanceHandler.VoidHold(r.Context(), &dto.HoldRequest{HoldID: holdID})
+	respond(w, http.StatusOK, response, err)
+}
+
+func (a *App) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var request dto.CreateScheduleRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.ScheduleHandler.CreateSchedule(r.Context(), &request)
+	respond(w, http.StatusCreated, response, err)
+}
+
+func (a *App) handleCancelSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID, ok := pathID(w, r, "scheduleID", "schedule_id")
+	if !ok {
+		return
+	}
+	response, err := a.ScheduleHandler.CancelSchedule(r.Context(), &dto.ScheduleRequest{ScheduleID: scheduleID})
+	respond(w, http.StatusOK, response, err)
+}
+
+func (a *App) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	response, err := a.ScheduleHandler.ListSchedules(r.Context(), &dto.ListSchedulesRequest{UserID: userID})
+	respond(w, http.StatusOK, response, err)
+}
+
+// handleBalance returns the user's balance in the currency named by the currency query
+// parameter, or in model.DefaultCurrency without one
+func (a *App) handleBalance(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	response, err := a.BalanceHandler.CheckBalance(r.Context(), userID, queryCurrency(r))
+	respond(w, http.StatusOK, response, err)
+}
+
+func (a *App) handleTransactions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUserID(w, r)
+	if !ok {
+		return
+	}
+	request, err := historyRequest(r, userID)
+	if err != nil {
+		respond(w, http.StatusOK, nil, err)
+		return
+	}
+	response, err := a.TransactionHandler.ViewTransactionHistory(r.Context(), request)
+	respond(w, http.StatusOK, response, err)
+}
+
+// queryCurrency returns the currency query parameter, empty when it is missing
+func queryCurrency(r *http.Request) model.Currency {
+	return currencyCode(r.URL.Query().Get("currency"))
+}
+
+// currencyCode returns the currency with the given code in any case. Unsupported codes are left
+// for validation to report.
+func currencyCode(code string) model.Currency {
+	return model.Currency(strings.ToUpper(code))
+}
+
+// historyRequest reads the filters and page of a history request from the query string:
+// currency, types (comma separated), from and to (RFC 3339), min_amount and max_amount, cursor
+// and limit
+func historyRequest(r *http.Request, userID uint) (*dto.TransactionHistoryRequest, error) {
+	values := r.URL.Query()
+	request := &dto.TransactionHistoryRequest{UserID: userID, Currency: queryCurrency(r), Cursor: values.Get("cursor")}
+	var errs validation.Errors
+	for _, types := range values["types"] {
+		request.Types = append(request.Types, strings.Split(types, ",")...)
+	}
+	parseTime := func(field string, into *time.Time) {
+		if text := values.Get(field); text != "" {
+			parsed, err := time.Parse(time.RFC3339, text)
+			if err != nil {
+				errs = append(errs, validation.FieldError{Field: field, Message: "must be an RFC 3339 time such as 2024-01-02T15:04:05Z"})
+			}
+			*into = parsed
+		}
+	}
+	parseTime("from", &request.From)
+	parseTime("to", &request.To)
+	parseAmount := func(field string) *model.Money {
+		text := values.Get(field)
+		if text == "" {
+			return nil
+		}
+		amount, err := validation.ParseSignedAmount(field, text)
+		var fieldErrors validation.Errors
+		if errors.As(err, &fieldErrors) {
+			errs = append(errs, fieldErrors...)
+			return nil
+		}
+		return &amount
+	}
+	request.MinAmount = parseAmount("min_amount")
+	request.MaxAmount = parseAmount("max_amount")
+	if text := values.Get("limit"); text != "" {
+		limit, err := strconv.Atoi(text)
+		if err != nil {
+			errs = append(errs, validation.FieldError{Field: "limit", Message: "must be an integer"})
+		}
+		request.Limit = limit
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return request, nil
+}
+
+func (a *App) handleCreateAdjustment(w http.ResponseWriter, r *http.Request) {
+	var request dto.CreateAdjustmentRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	response, err := a.AdjustmentHandler.CreateAdjustment(r.Context(), &request)
+	respond(w, http.StatusCreated, response, err)
+}
+
+func (a *App) handlePendingAdjustments(w http.ResponseWriter, r *http.Request) {
+	response, err := a.AdjustmentHandler.ListPendingAdjustments(r.Context())
+	respond(w, http.StatusOK, response, err)
+}
+
+// handleReviewAdjustment returns a handler applying review, which approves or rejects, to the
+// adjustment named in the path
+func (a *App) handleReviewAdjustment(review func(context.Context, *dto.ReviewAdjustmentRequest) (*dto.AdjustmentResponse, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adjustmentID, ok := pathID(w, r, "adjustmentID", "adjustment_id")
+		if !ok {
+			return
+		}
+		response, err := review(r.Context(), &dto.ReviewAdjustmentRequest{AdjustmentID: adjustmentID})
+		respond(w, http.StatusOK, response, err)
+	}
+}
+
+// decodeJSON reads the request body into v, answering 400 and returning false if it is not
+// a single JSON object made of known fields
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, &dto.ErrorResponse{Code: "invalid_json", Message: err.Error()})
+		return false
+	}
+	if decoder.More() {
+		writeJSON(w, http.StatusBadRequest, &dto.ErrorResponse{Code: "invalid_json", Message: "request body must hold a single JSON object"})
+		return false
+	}
+	return true
+}
+
+// pathUserID parses the {userID} path segment, answering 400 and returning false if it is invalid
+func pathUserID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	return pathID(w, r, "userID", "user_id")
+}
+
+// pathID parses the path segment called name as the ID reported as field, answering 400 and
+// returning false if it is invalid
+func pathID(w http.ResponseWriter, r *http.Request, name, field string) (uint, bool) {
+	id, err := strconv.ParseUint(r.PathValue(name), 10, 0)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &dto.ErrorResponse{
+			Code:    "invalid_request",
+			Message: fmt.Sprintf("%s in path must be a positive integer", field),
+			Fields:  []dto.FieldError{{Field: field, Message: "must be a positive integer"}},
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respond writes response with status, or the error response for err if it is set
+func respond(w http.ResponseWriter, status int, response interface{}, err error) {
+	if err != nil {
+		status, body := errorResponse(err)
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		writeJSON(w, status, body)
+		return
+	}
+	writeJSON(w, status, response)
+}
+
+// errorResponse maps an error returned by a handler to a status code and response body
+func errorResponse(err error) (int, *dto.ErrorResponse) {
+	var fieldErrors validation.Errors
+	if errors.As(err, &fieldErrors) {
+		body := &dto.ErrorResponse{Code: "invalid_request", Message: err.Error()}
+		for _, fieldError := range fieldErrors {
+			body.Fields = append(body.Fields, dto.FieldError{Field: fieldError.Field, Message: fieldError.Message})
+		}
+		return http.StatusBadRequest, body
+	}
+	var limitErr *apperror.LimitExceededError
+	if errors.As(err, &limitErr) {
+		return http.StatusUnprocessableEntity, &dto.ErrorResponse{Code: "limit_exceeded", Message: err.Error(), Limit: &dto.LimitError{
+			Operation: limitErr.Operation,
+			Period:    limitErr.Period,
+			Currency:  model.Currency(limitErr.Currency),
+			Limit:     model.Money(limitErr.Limit),
+			Remaining: model.Money(limitErr.Remaining),
+		}}
+	}
+
+	switch {
+	case errors.Is(err, apperror.ErrInvalidRequest):
+		return http.StatusBadRequest, &dto.ErrorResponse{Code: "invalid_request", Message: err.Error()}
+	case errors.Is(err, apperror.ErrUnauthenticated):
+		return http.StatusUnauthorized, &dto.ErrorResponse{Code: "unauthenticated", Message: err.Error()}
+	case errors.Is(err, apperror.ErrForbidden):
+		return http.StatusForbidden, &dto.ErrorResponse{Code: "forbidden", Message: err.Error()}
+	case errors.Is(err, apperror.ErrAccountNotFound):
+		return http.StatusNotFound, &dto.ErrorResponse{Code: "account_not_found", Message: err.Error()}
+	case errors.Is(err, apperror.ErrNotFound):
+		return http.StatusNotFound, &dto.ErrorResponse{Code: "not_found", Message: err.Error()}
+	case errors.Is(err, apperror.ErrInsufficientFunds):
+		return http.StatusUnprocessableEntity, &dto.ErrorResponse{Code: "insufficient_funds", Message: err.Error()}
+	case errors.Is(err, apperror.ErrAccountFrozen):
+		return http.StatusForbidden, &dto.ErrorResponse{Code: "account_frozen", Message: err.Error()}
+	case errors.Is(err, apperror.ErrAccountClosed):
+		return http.StatusForbidden, &dto.ErrorResponse{Code: "account_closed", Message: err.Error()}
+	case errors.Is(err, apperror.ErrConflict):
+		return http.StatusConflict, &dto.ErrorResponse{Code: "conflict", Message: err.Error()}
+	case errors.Is(err, apperror.ErrRateUnavailable):
+		return http.StatusServiceUnavailable, &dto.ErrorResponse{Code: "rate_unavailable", Message: err.Error()}
+	case errors.Is(err, apperror.ErrStorage):
+		// Database details stay in the log rather than the response
+		log.Printf("Storage error serving request: %v\n", err)
+		return http.StatusServiceUnavailable, &dto.ErrorResponse{Code: "unavailable", Message: "the wallet service is temporarily unavailable"}
+	default:
+		log.Printf("Unexpected error serving request: %v\n", err)
+		return http.StatusInternalServerError, &dto.ErrorResponse{Code: "internal", Message: "internal server error"}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
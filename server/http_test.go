@@ -0,0 +1,553 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/auth"
+	"walletApp/dto"
+	"walletApp/exchange"
+	"walletApp/limits"
+	"walletApp/model"
+	"walletApp/server/handler"
+	"walletApp/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestApp returns an App whose handlers run against mocks set up by the given functions
+func newTestApp(balanceMocks, transactionMocks func(m *mock.Mock)) *App {
+	transactionRepo := storage.NewMockTransactionRepository(transactionMocks)
+	balances := &handler.BalanceHandler{
+		BalanceRepo:     storage.NewMockBalanceRepository(balanceMocks),
+		TransactionRepo: transactionRepo,
+		JournalRepo: storage.NewMockJournalRepository(func(m *mock.Mock) {
+			m.On("CreateJournalEntry", mock.Anything, mock.Anything).Return(nil)
+		}),
+		ExchangeRepo: storage.NewMockExchangeRepository(func(m *mock.Mock) {
+			m.On("CreateQuote", mock.Anything, mock.Anything).Return(nil)
+			m.On("UseQuote", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		}),
+		UnitOfWork: storage.NewMockUnitOfWork(func(m *mock.Mock) {
+			m.On("Do", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(ctx context.Context) error) error {
+				return fn(ctx)
+			})
+		}),
+		Rates: &exchange.StaticRates{Base: model.USD, Rates: map[model.Currency]model.Rate{model.EUR: 110000000}},
+	}
+	return &App{
+		BalanceHandler:     balances,
+		TransactionHandler: &handler.TransactionHandler{TransactionRepo: transactionRepo},
+		UserHandler:        &handler.UserHandler{Tokens: auth.NewTokenManager([]byte("test-secret"))},
+		AdjustmentHandler:  &handler.AdjustmentHandler{AdjustmentRepo: storage.NewMockAdjustmentRepository()},
+		ScheduleHandler:    &handler.ScheduleHandler{ScheduleRepo: storage.NewMockScheduleRepository(), BalanceRepo: balances.BalanceRepo, Transfers: balances},
+	}
+}
+
+// bearerToken returns a token signed by app for principal, as returned by login
+func bearerToken(t *testing.T, app *App, principal auth.Principal) string {
+	token, _, err := app.UserHandler.Tokens.Issue(principal)
+	require.NoError(t, err)
+	return token
+}
+
+var testAdmin = auth.Principal{UserID: 100, Role: model.UserRoleAdmin}
+
+func TestRoutes(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		body             string
+		balanceMocks     func(m *mock.Mock)
+		transactionMocks func(m *mock.Mock)
+		holdMocks        func(m *mock.Mock)
+		scheduleMocks    func(m *mock.Mock)
+		caller           *auth.Principal // Defaults to testAdmin
+		token            string          // Sent instead of a token for caller when set
+		expectedStatus   int
+		expectedCode     string
+		expectedBody     string
+	}{
+		{
+			name:   "Deposit",
+			method: http.MethodPost,
+			path:   "/api/deposit",
+			body:   `{"user_id": 1, "amount": "12.50"}`,
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Balance: 1000, Version: 1}, nil)
+				m.On("GetBalanceForUpdate", mock.Anything, model.SystemAccountCashIn, model.USD).Return(&model.Balance{UserID: model.SystemAccountCashIn}, nil)
+				m.On("UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			transactionMocks: func(m *mock.Mock) {
+				m.On("CreateTransaction", mock.Anything, mock.Anything).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"success":true,"message":"Success Deposit","currency":"USD","balance":22.50}`,
+		},
+		{
+			name:           "Deposit of negative amount",
+			method:         http.MethodPost,
+			path:           "/api/deposit",
+			body:           `{"user_id": 1, "amount": -5}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:           "Deposit with unknown field",
+			method:         http.MethodPost,
+			path:           "/api/deposit",
+			body:           `{"user_id": 1, "amount": 5, "colour": "red"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_json",
+		},
+		{
+			name:   "Withdrawal from unknown account",
+			method: http.MethodPost,
+			path:   "/api/withdraw",
+			body:   `{"user_id": 9, "amount": 5}`,
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceForUpdate", mock.Anything, uint(9), model.USD).Return(nil, &apperror.AccountNotFoundError{UserID: 9})
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "account_not_found",
+		},
+		{
+			name:   "Transfer with insufficient funds",
+			method: http.MethodPost,
+			path:   "/api/transfer",
+			body:   `{"from_user_id": 1, "to_user_id": 2, "amount": 50}`,
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Balance: 1000, Version: 1}, nil)
+				m.On("GetBalanceForUpdate", mock.Anything, uint(2), model.USD).Return(&model.Balance{UserID: 2, Version: 1}, nil)
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "insufficient_funds",
+		},
+		{
+			name:   "Batch transfer with insufficient funds",
+			method: http.MethodPost,
+			path:   "/api/transfer/batch",
+			body:   `{"from_user_id": 1, "items": [{"to_user_id": 2, "amount": 50}]}`,
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Balance: 1000, Version: 1}, nil)
+				m.On("GetBalanceForUpdate", mock.Anything, uint(2), model.USD).Return(&model.Balance{UserID: 2, Version: 1}, nil)
+				m.On("GetBalanceForUpdate", mock.Anything, model.SystemAccountHouse, model.USD).Return(&model.Balance{UserID: model.SystemAccountHouse}, nil)
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "insufficient_funds",
+		},
+		{
+			name:           "Fee quote",
+			method:         http.MethodPost,
+			path:           "/api/fees/quote",
+			body:           `{"operation": "transfer", "amount": "50.00", "currency": "EUR"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"operation":"TransferSend","amount":50.00,"currency":"EUR","fee":0.00,"total":50.00}`,
+		},
+		{
+			name:           "Fee quote of a deposit",
+			method:         http.MethodPost,
+			path:           "/api/fees/quote",
+			body:           `{"operation": "deposit", "amount": "50.00"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:           "Exchange quote into the same currency",
+			method:         http.MethodPost,
+			path:           "/api/exchange/quotes",
+			body:           `{"user_id": 1, "from_currency": "EUR", "to_currency": "EUR", "amount": 10}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:           "Exchange into a currency without a rate",
+			method:         http.MethodPost,
+			path:           "/api/exchange",
+			body:           `{"user_id": 1, "from_currency": "USD", "to_currency": "GBP", "amount": 10}`,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   "rate_unavailable",
+		},
+		{
+			name:   "Reversal of unknown transaction",
+			method: http.MethodPost,
+			path:   "/api/transactions/9/reverse",
+			body:   `{"reason": "sent to the wrong wallet"}`,
+			transactionMocks: func(m *mock.Mock) {
+				m.On("GetTransaction", mock.Anything, uint(9)).Return(nil, fmt.Errorf("%w: transaction 9", apperror.ErrNotFound))
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "not_found",
+		},
+		{
+			name:           "Refund of negative amount",
+			method:         http.MethodPost,
+			path:           "/api/transactions/9/reverse",
+			body:           `{"amount": "-1.00", "reason": "returned"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:   "Balance",
+			method: http.MethodGet,
+			path:   "/api/users/1/balance",
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceRecord", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Currency: model.USD, Balance: 1234, Held: 234}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"user_id":1,"currency":"USD","balance":12.34,"available":10.00}`,
+		},
+		{
+			name:   "Balance in another currency",
+			method: http.MethodGet,
+			path:   "/api/users/1/balance?currency=jpy",
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceRecord", mock.Anything, uint(1), model.JPY).Return(&model.Balance{UserID: 1, Currency: model.JPY, Balance: 500000}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"user_id":1,"currency":"JPY","balance":5000.00,"available":5000.00}`,
+		},
+		{
+			name:           "Balance in unsupported currency",
+			method:         http.MethodGet,
+			path:           "/api/users/1/balance?currency=XYZ",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:   "Balance while database is down",
+			method: http.MethodGet,
+			path:   "/api/users/1/balance",
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceRecord", mock.Anything, uint(1), model.USD).Return(nil, errors.Join(apperror.ErrStorage, errors.New("connection refused")))
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   "unavailable",
+		},
+		{
+			name:           "Balance with malformed user ID",
+			method:         http.MethodGet,
+			path:           "/api/users/abc/balance",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:   "Transaction history",
+			method: http.MethodGet,
+			path:   "/api/users/1/transactions",
+			transactionMocks: func(m *mock.Mock) {
+				m.On("ListTransactions", mock.Anything, model.TransactionQuery{UserID: 1, Limit: 21}).Return([]model.Transaction{{ID: 1, UserID: 1, Type: model.TransactionTypeDeposit, Amount: 100}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Filtered transaction history page",
+			method: http.MethodGet,
+			path:   "/api/users/1/transactions?types=Deposit,Withdraw&from=2024-01-01T00:00:00Z&max_amount=5.50&limit=2",
+			transactionMocks: func(m *mock.Mock) {
+				maxAmount := model.Money(550)
+				m.On("ListTransactions", mock.Anything, model.TransactionQuery{
+					UserID:    1,
+					Types:     []model.TransactionType{model.TransactionTypeDeposit, model.TransactionTypeWithdraw},
+					From:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					MaxAmount: &maxAmount,
+					Limit:     3,
+				}).Return([]model.Transaction{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"transactions":[]}`,
+		},
+		{
+			name:           "Transaction history with malformed filters",
+			method:         http.MethodGet,
+			path:           "/api/users/1/transactions?from=yesterday&min_amount=abc&limit=ten",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:           "Request with an invalid token",
+			method:         http.MethodGet,
+			path:           "/api/users/1/balance",
+			token:          "not-a-token",
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "unauthenticated",
+		},
+		{
+			name:           "Customer reading another wallet",
+			method:         http.MethodGet,
+			path:           "/api/users/1/balance",
+			caller:         &auth.Principal{UserID: 2},
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   "forbidden",
+		},
+		{
+			name:   "Authorize hold",
+			method: http.MethodPost,
+			path:   "/api/holds",
+			body:   `{"user_id": 1, "merchant_id": 2, "amount": "10.00", "memo": "hotel"}`,
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceRecord", mock.Anything, uint(2), model.USD).Return(&model.Balance{UserID: 2, Product: model.AccountProductMerchant}, nil)
+				m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Currency: model.USD, Balance: 5000, Version: 1}, nil)
+				m.On("UpdateHeld", mock.Anything, uint(1), model.USD, model.Money(1000), uint(1)).Return(nil)
+			},
+			holdMocks: func(m *mock.Mock) {
+				m.On("CreateHold", mock.Anything, mock.Anything).Run(func(args mock.Arguments) { args.Get(1).(*model.Hold).ID = 9 }).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Authorize hold expiring too late",
+			method:         http.MethodPost,
+			path:           "/api/holds",
+			body:           `{"user_id": 1, "merchant_id": 2, "amount": "10.00", "expires_at": "2999-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:   "Get hold",
+			method: http.MethodGet,
+			path:   "/api/holds/9",
+			holdMocks: func(m *mock.Mock) {
+				m.On("GetHold", mock.Anything, uint(9)).Return(&model.Hold{ID: 9, UserID: 1, MerchantID: 2, Amount: 1000, Currency: model.USD, Status: model.HoldStatusAuthorized, ExpiresAt: time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":9,"user_id":1,"merchant_id":2,"amount":10.00,"currency":"USD","captured_amount":0.00,"status":"Authorized","expires_at":"2024-03-08T12:00:00Z"}`,
+		},
+		{
+			name:   "Void hold",
+			method: http.MethodPost,
+			path:   "/api/holds/9/void",
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Currency: model.USD, Balance: 5000, Held: 1000, Version: 1}, nil)
+				m.On("UpdateHeld", mock.Anything, uint(1), model.USD, model.Money(0), uint(1)).Return(nil)
+			},
+			holdMocks: func(m *mock.Mock) {
+				m.On("GetHold", mock.Anything, uint(9)).Return(&model.Hold{ID: 9, UserID: 1, MerchantID: 2, Amount: 1000, Currency: model.USD, Status: model.HoldStatusAuthorized, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+				m.On("ResolveHold", mock.Anything, mock.Anything).Return(nil)
+			},
+			caller:         &auth.Principal{UserID: 2},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Capture another merchant's hold",
+			method: http.MethodPost,
+			path:   "/api/holds/9/capture",
+			body:   `{}`,
+			holdMocks: func(m *mock.Mock) {
+				m.On("GetHold", mock.Anything, uint(9)).Return(&model.Hold{ID: 9, UserID: 1, MerchantID: 2, Amount: 1000, Currency: model.USD, Status: model.HoldStatusAuthorized, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+			},
+			caller:         &auth.Principal{UserID: 3},
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   "forbidden",
+		},
+		{
+			name:   "Capture voided hold",
+			method: http.MethodPost,
+			path:   "/api/holds/9/capture",
+			body:   `{"amount": "5.00"}`,
+			holdMocks: func(m *mock.Mock) {
+				m.On("GetHold", mock.Anything, uint(9)).Return(&model.Hold{ID: 9, UserID: 1, MerchantID: 2, Amount: 1000, Currency: model.USD, Status: model.HoldStatusVoided, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "conflict",
+		},
+		{
+			name:           "Capture with malformed hold ID",
+			method:         http.MethodPost,
+			path:           "/api/holds/abc/capture",
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:   "Create schedule",
+			method: http.MethodPost,
+			path:   "/api/schedules",
+			body:   `{"from_user_id": 1, "to_user_id": 2, "amount": "15.00", "recurrence": "Monthly", "start_at": "2999-01-31T09:00:00Z"}`,
+			balanceMocks: func(m *mock.Mock) {
+				m.On("GetBalanceRecord", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1}, nil)
+				m.On("GetBalanceRecord", mock.Anything, uint(2), model.USD).Return(&model.Balance{UserID: 2}, nil)
+			},
+			scheduleMocks: func(m *mock.Mock) {
+				m.On("CreateSchedule", mock.Anything, mock.Anything).Run(func(args mock.Arguments) { args.Get(1).(*model.Schedule).ID = 4 }).Return(nil)
+			},
+			caller:         &auth.Principal{UserID: 1},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"id":4,"from_user_id":1,"to_user_id":2,"amount":15.00,"currency":"USD","recurrence":"Monthly","start_at":"2999-01-31T09:00:00Z","failure_policy":"Skip","status":"Active","next_run_at":"2999-01-31T09:00:00Z"}`,
+		},
+		{
+			name:           "Create schedule with unknown recurrence",
+			method:         http.MethodPost,
+			path:           "/api/schedules",
+			body:           `{"from_user_id": 1, "to_user_id": 2, "amount": "15.00", "recurrence": "Hourly"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:   "List schedules",
+			method: http.MethodGet,
+			path:   "/api/users/1/schedules",
+			scheduleMocks: func(m *mock.Mock) {
+				m.On("GetSchedulesByUserID", mock.Anything, uint(1)).Return([]model.Schedule{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"schedules":[]}`,
+		},
+		{
+			name:   "Cancel someone else's schedule",
+			method: http.MethodPost,
+			path:   "/api/schedules/4/cancel",
+			scheduleMocks: func(m *mock.Mock) {
+				m.On("GetSchedule", mock.Anything, uint(4)).Return(&model.Schedule{ID: 4, FromUserID: 1, ToUserID: 2, Status: model.ScheduleStatusActive}, nil)
+			},
+			caller:         &auth.Principal{UserID: 2},
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   "forbidden",
+		},
+		{
+			name:   "Cancel unknown schedule",
+			method: http.MethodPost,
+			path:   "/api/schedules/4/cancel",
+			scheduleMocks: func(m *mock.Mock) {
+				m.On("GetSchedule", mock.Anything, uint(4)).Return(nil, apperror.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "not_found",
+		},
+		{
+			name:           "Approval with malformed adjustment ID",
+			method:         http.MethodPost,
+			path:           "/api/adjustments/abc/approve",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:           "Customer creating an adjustment",
+			method:         http.MethodPost,
+			path:           "/api/adjustments",
+			body:           `{"user_id": 2, "amount": 50, "reason": "gift"}`,
+			caller:         &auth.Principal{UserID: 2},
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   "forbidden",
+		},
+		{
+			name:           "Wrong method",
+			method:         http.MethodGet,
+			path:           "/api/deposit",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			noMocks := func(m *mock.Mock) {}
+			if tt.balanceMocks == nil {
+				tt.balanceMocks = noMocks
+			}
+			if tt.transactionMocks == nil {
+				tt.transactionMocks = noMocks
+			}
+			app := newTestApp(tt.balanceMocks, tt.transactionMocks)
+			if tt.holdMocks != nil {
+				app.BalanceHandler.HoldRepo = storage.NewMockHoldRepository(tt.holdMocks)
+			}
+			if tt.scheduleMocks != nil {
+				app.ScheduleHandler.ScheduleRepo = storage.NewMockScheduleRepository(tt.scheduleMocks)
+			}
+
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.caller == nil {
+				tt.caller = &testAdmin
+			}
+			if tt.token == "" {
+				tt.token = bearerToken(t, app, *tt.caller)
+			}
+			request.Header.Set("Authorization", "Bearer "+tt.token)
+			recorder := httptest.NewRecorder()
+			app.Routes().ServeHTTP(recorder, request)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, recorder.Body.String())
+			}
+			if tt.expectedCode != "" {
+				var body dto.ErrorResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+				assert.Equal(t, tt.expectedCode, body.Code)
+			}
+		})
+	}
+}
+
+func TestErrorResponseListsInvalidFields(t *testing.T) {
+	app := newTestApp(func(m *mock.Mock) {}, func(m *mock.Mock) {})
+	request := httptest.NewRequest(http.MethodPost, "/api/transfer", strings.NewReader(`{"from_user_id": 1, "to_user_id": 1, "amount": 0}`))
+	request.Header.Set("Authorization", "Bearer "+bearerToken(t, app, testAdmin))
+	recorder := httptest.NewRecorder()
+	app.Routes().ServeHTTP(recorder, request)
+
+	var body dto.ErrorResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, []dto.FieldError{
+		{Field: "to_user_id", Message: "must be different from from_user_id"},
+		{Field: "amount", Message: "must be greater than zero"},
+	}, body.Fields)
+}
+
+func TestErrorResponseDescribesLimit(t *testing.T) {
+	app := newTestApp(func(m *mock.Mock) {
+		m.On("GetBalanceForUpdate", mock.Anything, uint(1), model.USD).Return(&model.Balance{UserID: 1, Balance: 10000, Version: 1}, nil)
+		m.On("GetBalanceForUpdate", mock.Anything, model.SystemAccountCashOut, model.USD).Return(&model.Balance{UserID: model.SystemAccountCashOut}, nil)
+	}, func(m *mock.Mock) {
+		m.On("SumOutflows", mock.Anything, uint(1), model.USD, []model.TransactionType{model.TransactionTypeWithdraw}, mock.Anything).Return(model.Money(2000), nil)
+	})
+	app.BalanceHandler.Limits = limits.Policy{model.AccountTierStandard: {model.TransactionTypeWithdraw: {model.USD: {Daily: 2500}}}}
+	request := httptest.NewRequest(http.MethodPost, "/api/withdraw", strings.NewReader(`{"user_id": 1, "amount": "10.00"}`))
+	request.Header.Set("Authorization", "Bearer "+bearerToken(t, app, testAdmin))
+	recorder := httptest.NewRecorder()
+	app.Routes().ServeHTTP(recorder, request)
+
+	var body dto.ErrorResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	assert.Equal(t, "limit_exceeded", body.Code)
+	assert.Equal(t, &dto.LimitError{Operation: "Withdraw", Period: "daily", Currency: model.USD, Limit: 2500, Remaining: 500}, body.Limit)
+}
+
+func TestAuthenticationRoutes(t *testing.T) {
+	t.Run("Request without a token", func(t *testing.T) {
+		app := newTestApp(func(m *mock.Mock) {}, func(m *mock.Mock) {})
+		request := httptest.NewRequest(http.MethodGet, "/api/users/1/balance", nil)
+		recorder := httptest.NewRecorder()
+		app.Routes().ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Equal(t, "Bearer", recorder.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Login with unknown username", func(t *testing.T) {
+		app := newTestApp(func(m *mock.Mock) {}, func(m *mock.Mock) {})
+		app.UserHandler.UserRepo = storage.NewMockUserRepository(func(m *mock.Mock) {
+			m.On("GetUserByUsername", mock.Anything, "mallory").Return(nil, nil)
+		})
+		request := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"username": "mallory", "password": "password123"}`))
+		recorder := httptest.NewRecorder()
+		app.Routes().ServeHTTP(recorder, request)
+
+		var body dto.ErrorResponse
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Equal(t, "unauthenticated", body.Code)
+	})
+}
@@ -2,30 +2,112 @@ package server
 
 import (
 	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+	"walletApp/apperror"
+	"walletApp/auth"
 	"walletApp/config"
 	"walletApp/dto"
+	"walletApp/model"
 	"walletApp/server/handler"
+	"walletApp/validation"
 )
 
 type App struct {
 	BalanceHandler     *handler.BalanceHandler
 	TransactionHandler *handler.TransactionHandler
+	AccountHandler     *handler.AccountHandler
+	UserHandler        *handler.UserHandler
+	AdjustmentHandler  *handler.AdjustmentHandler
+	ScheduleHandler    *handler.ScheduleHandler
+	InterestHandler    *handler.InterestHandler
 }
 
 func NewApp() *App {
 	config.InitDB()
+	balances := handler.NewBalanceHandler()
 	app := &App{
-		BalanceHandler:     handler.NewBalanceHandler(),
+		BalanceHandler:     balances,
 		TransactionHandler: handler.NewTransactionHandler(),
+		AccountHandler:     handler.NewAccountHandler(),
+		UserHandler:        handler.NewUserHandler(),
+		AdjustmentHandler:  handler.NewAdjustmentHandler(),
+		ScheduleHandler:    handler.NewScheduleHandler(balances),
+		InterestHandler:    handler.NewInterestHandler(balances),
 	}
 
 	return app
 }
 
+// ExpireHolds releases the holds that have expired every interval until ctx is cancelled
+func (a *App) ExpireHolds(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if expired, err := a.BalanceHandler.ExpireHolds(ctx); err != nil {
+				log.Printf("Error expiring holds: %v\n", err)
+			} else if expired > 0 {
+				log.Printf("Released %d expired holds\n", expired)
+			}
+		}
+	}
+}
+
+// RunSchedules runs the scheduled transfers that are due every interval until ctx is cancelled
+func (a *App) RunSchedules(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if transferred, err := a.ScheduleHandler.RunDueSchedules(ctx); err != nil {
+				log.Printf("Error running scheduled transfers: %v\n", err)
+			} else if transferred > 0 {
+				log.Printf("Made %d scheduled transfers\n", transferred)
+			}
+		}
+	}
+}
+
+// RunInterest accrues the interest of savings wallets for the days that are over, and pays out
+// the months that are over, every interval until ctx is cancelled
+func (a *App) RunInterest(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if accrued, err := a.InterestHandler.AccrueInterest(ctx); err != nil {
+				log.Printf("Error accruing interest: %v\n", err)
+			} else if accrued > 0 {
+				log.Printf("Accrued %d days of interest\n", accrued)
+			}
+			if paid, err := a.InterestHandler.PayInterest(ctx); err != nil {
+				log.Printf("Error paying interest: %v\n", err)
+			} else if paid > 0 {
+				log.Printf("Paid interest to %d balances\n", paid)
+			}
+		}
+	}
+}
+
 func (a *App) Start() {
+	principal := a.signIn()
 	for {
 		fmt.Println("\nWallet App CLI")
 		fmt.Println("----------")
@@ -34,74 +116,101 @@ func (a *App) Start() {
 		fmt.Println("3. Check Balance")
 		fmt.Println("4. View Transaction History")
 		fmt.Println("5. Transfer Money")
-		fmt.Println("6. Exit")
+		fmt.Println("6. Open Account")
+		fmt.Println("7. Freeze Account")
+		fmt.Println("8. Unfreeze Account")
+		fmt.Println("9. Close Account")
+		fmt.Println("10. Create Adjustment")
+		fmt.Println("11. List Pending Adjustments")
+		fmt.Println("12. Approve Adjustment")
+		fmt.Println("13. Reject Adjustment")
+		fmt.Println("14. Reverse or Refund Transaction")
+		fmt.Println("15. Exchange Currency")
+		fmt.Println("16. Authorize Hold")
+		fmt.Println("17. Capture Hold")
+		fmt.Println("18. Void Hold")
+		fmt.Println("19. Schedule Transfer")
+		fmt.Println("20. List Scheduled Transfers")
+		fmt.Println("21. Cancel Scheduled Transfer")
+		fmt.Println("22. Set Account Tier")
+		fmt.Println("23. Quote Fee")
+		fmt.Println("24. Set Account Product")
+		fmt.Println("25. Set Overdraft Limit")
+		fmt.Println("26. Overdraft Report")
+		fmt.Println("27. Batch Transfer")
+		fmt.Println("28. Exit")
 		fmt.Print("Enter your choice: ")
 
 		var choice int
 		fmt.Scan(&choice)
 
-		ctx := context.Background() // Create a context for each request
+		// Create a context for each request, carrying the signed in user
+		ctx := auth.WithPrincipal(context.Background(), principal)
 
 		switch choice {
 		case 1:
 			fmt.Print("Enter user ID: ")
 			var userID uint
 			fmt.Scan(&userID)
+			currency := scanCurrency()
 			fmt.Print("Enter amount to deposit: ")
-			var amount float64
-			fmt.Scan(&amount)
+			amount, err := scanAmount()
+			if err != nil {
+				printError(err)
+				break
+			}
 			resp, err := a.BalanceHandler.Deposit(ctx, &dto.DepositRequest{
-				UserID: userID,
-				Amount: amount,
+				UserID:   userID,
+				Amount:   amount,
+				Currency: currency,
 			})
 			if err != nil {
-				fmt.Println("Error:", err)
+				printError(err)
 			} else {
 				fmt.Println("Deposit successful!")
-				fmt.Printf("New Balance: %.2f\n", resp.Balance)
+				fmt.Printf("New Balance: %s\n", resp.Currency.Format(resp.Balance))
 			}
 		case 2:
 			fmt.Print("Enter user ID: ")
 			var userID uint
 			fmt.Scan(&userID)
+			currency := scanCurrency()
 			fmt.Print("Enter amount to withdraw: ")
-			var amount float64
-			fmt.Scan(&amount)
+			amount, err := scanAmount()
+			if err != nil {
+				printError(err)
+				break
+			}
 			newBalance, err := a.BalanceHandler.Withdraw(ctx, &dto.WithdrawRequest{
-				UserID: userID,
-				Amount: amount,
+				UserID:   userID,
+				Amount:   amount,
+				Currency: currency,
 			})
 			if err != nil {
-				fmt.Println("Error:", err)
+				printError(err)
 			} else {
 				fmt.Println("Withdrawal successful!")
-				fmt.Printf("New Balance: %.2f\n", newBalance.Balance)
+				fmt.Printf("Fee: %s\n", newBalance.Currency.Format(newBalance.Fee))
+				fmt.Printf("New Balance: %s\n", newBalance.Currency.Format(newBalance.Balance))
 			}
 		case 3:
 			fmt.Print("Enter user ID: ")
 			var userID uint
 			fmt.Scan(&userID)
-			balance, err := a.BalanceHandler.CheckBalance(ctx, userID)
+			currency := scanCurrency()
+			balance, err := a.BalanceHandler.CheckBalance(ctx, userID, currency)
 			if err != nil {
-				fmt.Println("Error:", err)
+				printError(err)
 			} else {
 				fmt.Println("Balance fetched successfully!")
-				fmt.Printf("Balance: %.2f\n", balance)
-			}
-		case 4:
-			fmt.Print("Enter user ID: ")
-			var userID uint
-			fmt.Scan(&userID)
-			resp, err := a.TransactionHandler.ViewTransactionHistory(ctx, userID)
-			if err != nil {
-				fmt.Println("Error:", err)
-			} else {
-				fmt.Println("Transaction History:")
-				fmt.Println("--------------------")
-				for _, transaction := range resp.Transactions {
-					fmt.Printf("%s: %.2f at %s\n", transaction.Type, transaction.Amount, transaction.Timestamp.Format("2006-01-02 15:04:05"))
+				fmt.Printf("Balance: %s\n", balance.Currency.Format(balance.Balance))
+				fmt.Printf("Available: %s\n", balance.Currency.Format(balance.Available))
+				if balance.OverdraftLimit != 0 {
+					fmt.Printf("Overdraft limit: %s\n", balance.Currency.Format(balance.OverdraftLimit))
 				}
 			}
+		case 4:
+			a.browseHistory(ctx)
 		case 5:
 			fmt.Print("Enter sender user ID: ")
 			var fromUserID uint
@@ -109,25 +218,157 @@ func (a *App) Start() {
 			fmt.Print("Enter recipient user ID: ")
 			var toUserID uint
 			fmt.Scan(&toUserID)
+			currency := scanCurrency()
+			fmt.Print("Enter recipient's currency, converting the money into it (- for the same): ")
+			var toCurrency string
+			fmt.Scan(&toCurrency)
+			if toCurrency == "-" {
+				toCurrency = ""
+			}
 			fmt.Print("Enter amount to transfer: ")
-			var amount float64
-			fmt.Scan(&amount)
+			amount, err := scanAmount()
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Print("Enter external reference, such as an invoice number (- for none): ")
+			var externalReference string
+			fmt.Scan(&externalReference)
+			if externalReference == "-" {
+				externalReference = ""
+			}
+			fmt.Print("Enter memo (optional): ")
+			memo := scanLine()
 			response, err := a.BalanceHandler.Transfer(ctx, &dto.TransferRequest{
-				FromUserID: fromUserID,
-				ToUserID:   toUserID,
-				Amount:     amount,
+				FromUserID:        fromUserID,
+				ToUserID:          toUserID,
+				Amount:            amount,
+				Currency:          currency,
+				ToCurrency:        currencyCode(toCurrency),
+				Convert:           toCurrency != "",
+				Memo:              memo,
+				ExternalReference: externalReference,
 			})
 			if err != nil {
-				fmt.Println("Error:", err)
+				printError(err)
 			} else {
 				fmt.Println(response.Message)
-				if response.Success {
-					data := response.Data
-					fmt.Printf("Sender's New Balance: %.2f\n", data["sender_balance"])
-					fmt.Printf("Recipient's New Balance: %.2f\n", data["recipient_balance"])
+				fmt.Printf("Transfer ID: %s\n", response.TransferID)
+				fmt.Printf("Fee: %s\n", response.Currency.Format(response.Fee))
+				fmt.Printf("Sender's New Balance: %s\n", response.Currency.Format(response.Data["sender_balance"]))
+				recipientCurrency := response.Currency
+				if conversion := response.Conversion; conversion != nil {
+					recipientCurrency = conversion.ToCurrency
+					fmt.Printf("Recipient received %s at %s\n", conversion.ToCurrency.Format(conversion.ToAmount), conversion.Rate)
 				}
+				fmt.Printf("Recipient's New Balance: %s\n", recipientCurrency.Format(response.Data["recipient_balance"]))
 			}
 		case 6:
+			a.runOpenAccountCommand(ctx)
+		case 7:
+			a.runAccountCommand(ctx, "frozen", a.AccountHandler.FreezeAccount)
+		case 8:
+			a.runAccountCommand(ctx, "unfrozen", a.AccountHandler.UnfreezeAccount)
+		case 9:
+			a.runAccountCommand(ctx, "closed", a.AccountHandler.CloseAccount)
+		case 10:
+			fmt.Print("Enter user ID: ")
+			var userID uint
+			fmt.Scan(&userID)
+			currency := scanCurrency()
+			fmt.Print("Enter amount, negative to debit the wallet: ")
+			var input string
+			fmt.Scan(&input)
+			amount, err := validation.ParseSignedAmount("amount", input)
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Print("Enter reason: ")
+			resp, err := a.AdjustmentHandler.CreateAdjustment(ctx, &dto.CreateAdjustmentRequest{
+				UserID:   userID,
+				Amount:   amount,
+				Currency: currency,
+				Reason:   scanLine(),
+			})
+			if err != nil {
+				printError(err)
+			} else {
+				fmt.Printf("Adjustment %d created, waiting for another admin to approve it\n", resp.ID)
+			}
+		case 11:
+			resp, err := a.AdjustmentHandler.ListPendingAdjustments(ctx)
+			if err != nil {
+				printError(err)
+			} else {
+				fmt.Println("Pending Adjustments:")
+				fmt.Println("--------------------")
+				for _, adjustment := range resp.Adjustments {
+					fmt.Printf("#%d: %s to user %d by admin %d (%s)\n", adjustment.ID, adjustment.Currency.Format(adjustment.Amount), adjustment.UserID, adjustment.CreatedBy, adjustment.Reason)
+				}
+			}
+		case 12:
+			a.runReviewCommand(ctx, a.AdjustmentHandler.ApproveAdjustment)
+		case 13:
+			a.runReviewCommand(ctx, a.AdjustmentHandler.RejectAdjustment)
+		case 14:
+			a.runReverseCommand(ctx)
+		case 15:
+			a.runExchangeCommand(ctx)
+		case 16:
+			a.runAuthorizeHoldCommand(ctx)
+		case 17:
+			a.runCaptureHoldCommand(ctx)
+		case 18:
+			fmt.Print("Enter hold ID: ")
+			var holdID uint
+			fmt.Scan(&holdID)
+			hold, err := a.BalanceHandler.VoidHold(ctx, &dto.HoldRequest{HoldID: holdID})
+			if err != nil {
+				printError(err)
+			} else {
+				fmt.Printf("Hold %d voided, %s is available again\n", hold.ID, hold.Currency.Format(hold.Amount))
+			}
+		case 19:
+			a.runCreateScheduleCommand(ctx)
+		case 20:
+			fmt.Print("Enter user ID: ")
+			var userID uint
+			fmt.Scan(&userID)
+			resp, err := a.ScheduleHandler.ListSchedules(ctx, &dto.ListSchedulesRequest{UserID: userID})
+			if err != nil {
+				printError(err)
+				break
+			}
+			if len(resp.Schedules) == 0 {
+				fmt.Println("No scheduled transfers")
+			}
+			for _, schedule := range resp.Schedules {
+				printSchedule(&schedule)
+			}
+		case 21:
+			fmt.Print("Enter schedule ID: ")
+			var scheduleID uint
+			fmt.Scan(&scheduleID)
+			schedule, err := a.ScheduleHandler.CancelSchedule(ctx, &dto.ScheduleRequest{ScheduleID: scheduleID})
+			if err != nil {
+				printError(err)
+			} else {
+				fmt.Printf("Schedule %d cancelled\n", schedule.ID)
+			}
+		case 22:
+			a.runSetTierCommand(ctx)
+		case 23:
+			a.runQuoteFeeCommand(ctx)
+		case 24:
+			a.runSetProductCommand(ctx)
+		case 25:
+			a.runSetOverdraftLimitCommand(ctx)
+		case 26:
+			a.runOverdraftReportCommand(ctx)
+		case 27:
+			a.runBatchTransferCommand(ctx)
+		case 28:
 			fmt.Println("Exiting...")
 			os.Exit(0)
 		default:
@@ -137,6 +378,723 @@ func (a *App) Start() {
 	}
 }
 
+// signIn keeps asking the user to log in or register until a login succeeds
+func (a *App) signIn() auth.Principal {
+	for {
+		fmt.Println("\nWallet App CLI")
+		fmt.Println("----------")
+		fmt.Println("1. Login")
+		fmt.Println("2. Register")
+		fmt.Println("3. Exit")
+		fmt.Print("Enter your choice: ")
+
+		var choice int
+		fmt.Scan(&choice)
+
+		ctx := context.Background()
+
+		switch choice {
+		case 1:
+			username, password := scanCredentials()
+			resp, err := a.UserHandler.Login(ctx, &dto.LoginRequest{Username: username, Password: password})
+			if err != nil {
+				printError(err)
+				break
+			}
+			principal, err := a.UserHandler.Authenticate(resp.Token)
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Printf("Welcome, %s! You are signed in as user %d (%s).\n", username, resp.UserID, resp.Role)
+			return principal
+		case 2:
+			username, password := scanCredentials()
+			resp, err := a.UserHandler.Register(ctx, &dto.RegisterRequest{Username: username, Password: password})
+			if err != nil {
+				printError(err)
+				break
+			}
+			fmt.Printf("Registered %s with user ID %d. You can now log in.\n", resp.Username, resp.UserID)
+		case 3:
+			fmt.Println("Exiting...")
+			os.Exit(0)
+		default:
+			fmt.Println("Invalid choice. Please try again.")
+		}
+	}
+}
+
+// scanCredentials reads a username and password from the user
+func scanCredentials() (string, string) {
+	var username, password string
+	fmt.Print("Enter username: ")
+	fmt.Scan(&username)
+	fmt.Print("Enter password: ")
+	fmt.Scan(&password)
+	return username, password
+}
+
+// browseHistory shows a user's transaction history a page at a time, moving to older or newer
+// pages or changing the filters when asked
+func (a *App) browseHistory(ctx context.Context) {
+	fmt.Print("Enter user ID: ")
+	request := &dto.TransactionHistoryRequest{}
+	fmt.Scan(&request.UserID)
+	for {
+		resp, err := a.TransactionHandler.ViewTransactionHistory(ctx, request)
+		if err != nil {
+			printError(err)
+			return
+		}
+		fmt.Println("Transaction History:")
+		fmt.Println("--------------------")
+		if len(resp.Transactions) == 0 {
+			fmt.Println("No transactions found")
+		}
+		for _, transaction := range resp.Transactions {
+			printTransaction(transaction)
+		}
+
+		options := []string{}
+		if resp.NextCursor != "" {
+			options = append(options, "n. Next page")
+		}
+		if resp.PrevCursor != "" {
+			options = append(options, "p. Previous page")
+		}
+		options = append(options, "f. Filter", "q. Back to menu")
+		fmt.Printf("\n%s\nEnter your choice: ", strings.Join(options, "\n"))
+		var choice string
+		fmt.Scan(&choice)
+		switch strings.ToLower(choice) {
+		case "n":
+			request.Cursor = resp.NextCursor
+		case "p":
+			request.Cursor = resp.PrevCursor
+		case "f":
+			if err := scanHistoryFilters(request); err != nil {
+				printError(err)
+			}
+			request.Cursor = ""
+		default:
+			return
+		}
+	}
+}
+
+// printTransaction prints one line of the history, followed by the transfer's counterparty, the
+// transaction a reversal undoes, and the batch and reference when there are any
+func printTransaction(transaction model.Transaction) {
+	fmt.Printf("#%d %s: %s at %s\n", transaction.ID, transaction.Type, model.CurrencyOrDefault(transaction.Currency).Format(transaction.Amount), transaction.Timestamp.Format("2006-01-02 15:04:05"))
+	switch transaction.Type {
+	case model.TransactionTypeReversal, model.TransactionTypeRefund:
+		fmt.Printf("    undoes transaction #%d\n", transaction.OriginalTransactionID)
+	case model.TransactionTypeTransferSend:
+		fmt.Printf("    to user %d, transfer %s\n", transaction.CounterpartyID, transaction.TransferID)
+	case model.TransactionTypeTransferReceive:
+		fmt.Printf("    from user %d, transfer %s\n", transaction.CounterpartyID, transaction.TransferID)
+	case model.TransactionTypeExchange:
+		fmt.Printf("    exchange %s\n", transaction.TransferID)
+	case model.TransactionTypeCapture:
+		fmt.Printf("    hold captured with user %d\n", transaction.CounterpartyID)
+	case model.TransactionTypeFee, model.TransactionTypeOverdraftFee:
+		if transaction.TransferID != "" {
+			fmt.Printf("    for transfer %s\n", transaction.TransferID)
+		}
+	}
+	if transaction.BatchID != "" {
+		fmt.Printf("    batch %s\n", transaction.BatchID)
+	}
+	if transaction.ExternalReference != "" {
+		fmt.Printf("    reference: %s\n", transaction.ExternalReference)
+	}
+	if transaction.Memo != "" {
+		fmt.Printf("    memo: %s\n", transaction.Memo)
+	}
+}
+
+// scanHistoryFilters replaces the filters of request with ones read from the user, who answers
+// "-" to leave a filter out
+func scanHistoryFilters(request *dto.TransactionHistoryRequest) error {
+	filters := dto.TransactionHistoryRequest{UserID: request.UserID}
+	var errs validation.Errors
+	var input string
+
+	fmt.Print("Currency (e.g. EUR) or -: ")
+	fmt.Scan(&input)
+	if input != "-" {
+		filters.Currency = currencyCode(input)
+	}
+	fmt.Print("Transaction types, comma separated (e.g. Deposit,Withdraw) or -: ")
+	fmt.Scan(&input)
+	if input != "-" {
+		filters.Types = strings.Split(input, ",")
+	}
+	for _, date := range []struct {
+		prompt string
+		field  string
+		into   *time.Time
+		offset time.Duration
+	}{
+		{"From date (YYYY-MM-DD) or -: ", "from", &filters.From, 0},
+		// The last day is included, so the range ends at the start of the following day
+		{"Until date, inclusive (YYYY-MM-DD) or -: ", "to", &filters.To, 24 * time.Hour},
+	} {
+		fmt.Print(date.prompt)
+		fmt.Scan(&input)
+		if input == "-" {
+			continue
+		}
+		day, err := time.ParseInLocation(time.DateOnly, input, time.Local)
+		if err != nil {
+			errs = append(errs, validation.FieldError{Field: date.field, Message: "must be a date such as 2024-01-31"})
+			continue
+		}
+		*date.into = day.Add(date.offset)
+	}
+	for _, bound := range []struct {
+		prompt string
+		field  string
+		into   **model.Money
+	}{
+		{"Minimum amount (negative for money out) or -: ", "min_amount", &filters.MinAmount},
+		{"Maximum amount (negative for money out) or -: ", "max_amount", &filters.MaxAmount},
+	} {
+		fmt.Print(bound.prompt)
+		fmt.Scan(&input)
+		if input == "-" {
+			continue
+		}
+		amount, err := validation.ParseSignedAmount(bound.field, input)
+		var fieldErrors validation.Errors
+		if errors.As(err, &fieldErrors) {
+			errs = append(errs, fieldErrors...)
+			continue
+		}
+		*bound.into = &amount
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	*request = filters
+	return nil
+}
+
+// runAccountCommand asks for a user ID and applies an account lifecycle operation to it
+func (a *App) runAccountCommand(ctx context.Context, done string, operation func(context.Context, *dto.AccountRequest) (*dto.AccountResponse, error)) {
+	fmt.Print("Enter user ID: ")
+	var userID uint
+	fmt.Scan(&userID)
+	resp, err := operation(ctx, &dto.AccountRequest{UserID: userID})
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("Account %s!\n", done)
+	printAccount(resp)
+}
+
+// runOpenAccountCommand asks for a user ID and a currency and opens the user's wallet in it
+func (a *App) runOpenAccountCommand(ctx context.Context) {
+	fmt.Print("Enter user ID: ")
+	var userID uint
+	fmt.Scan(&userID)
+	resp, err := a.AccountHandler.OpenAccount(ctx, &dto.AccountRequest{UserID: userID, Currency: scanCurrency()})
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Println("Account opened!")
+	printAccount(resp)
+}
+
+// runQuoteFeeCommand asks for an operation, a currency and an amount and prints what the
+// operation would be charged
+func (a *App) runQuoteFeeCommand(ctx context.Context) {
+	fmt.Print("Enter operation (Withdraw or Transfer): ")
+	var operation string
+	fmt.Scan(&operation)
+	currency := scanCurrency()
+	fmt.Print("Enter amount: ")
+	amount, err := scanAmount()
+	if err != nil {
+		printError(err)
+		return
+	}
+	quote, err := a.BalanceHandler.QuoteFee(ctx, &dto.FeeQuoteRequest{Operation: operation, Amount: amount, Currency: currency})
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("Fee: %s, %s leaves the wallet in total\n", quote.Currency.Format(quote.Fee), quote.Currency.Format(quote.Total))
+}
+
+// runSetTierCommand asks for a user ID and a tier and moves the user's wallet to it
+func (a *App) runSetTierCommand(ctx context.Context) {
+	fmt.Print("Enter user ID: ")
+	var userID uint
+	fmt.Scan(&userID)
+	fmt.Print("Enter tier (Standard, Verified or Premium): ")
+	var tier string
+	fmt.Scan(&tier)
+	resp, err := a.AccountHandler.SetTier(ctx, &dto.SetTierRequest{UserID: userID, Tier: tier})
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Println("Account tier changed!")
+	printAccount(resp)
+}
+
+// runSetProductCommand asks for a user ID and a product and moves the user's wallet to it
+func (a *App) runSetProductCommand(ctx context.Context) {
+	fmt.Print("Enter user ID: ")
+	var userID uint
+	fmt.Scan(&userID)
+	fmt.Print("Enter product (Checking, Savings or Merchant): ")
+	var product string
+	fmt.Scan(&product)
+	resp, err := a.AccountHandler.SetProduct(ctx, &dto.SetProductRequest{UserID: userID, Product: product})
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Println("Account product changed!")
+	printAccount(resp)
+}
+
+// runSetOverdraftLimitCommand asks for a user ID, a currency and a limit and gives the user's
+// balance in that currency a credit line of the limit
+func (a *App) runSetOverdraftLimitCommand(ctx context.Context) {
+	fmt.Print("Enter user ID: ")
+	var userID uint
+	fmt.Scan(&userID)
+	currency := scanCurrency()
+	fmt.Print("Enter overdraft limit (0 to take the credit line away): ")
+	limit, err := scanAmount()
+	if err != nil {
+		printError(err)
+		return
+	}
+	resp, err := a.AccountHandler.SetOverdraftLimit(ctx, &dto.SetOverdraftLimitRequest{UserID: userID, Currency: currency, Limit: limit})
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Println("Overdraft limit changed!")
+	printAccount(resp)
+}
+
+// runOverdraftReportCommand lists every wallet balance below zero and what they owe in total
+func (a *App) runOverdraftReportCommand(ctx context.Context) {
+	report, err := a.AccountHandler.OverdraftReport(ctx)
+	if err != nil {
+		printError(err)
+		return
+	}
+	if len(report.Balances) == 0 {
+		fmt.Println("No wallet is overdrawn")
+		return
+	}
+	for _, balance := range report.Balances {
+		fmt.Printf("User %d: %s of a %s limit, %s available\n", balance.UserID, balance.Currency.Format(balance.Balance), balance.Currency.Format(balance.OverdraftLimit), balance.Currency.Format(balance.Available))
+	}
+	for _, currency := range model.Currencies() {
+		if total, ok := report.Totals[currency]; ok {
+			fmt.Printf("Total owed: %s\n", currency.Format(total))
+		}
+	}
+}
+
+// runBatchTransferCommand pays out from one wallet to the recipients listed in a CSV file, all or
+// nothing unless the user asks for best effort, and prints the outcome of every item
+func (a *App) runBatchTransferCommand(ctx context.Context) {
+	request := &dto.BatchTransferRequest{}
+	fmt.Print("Enter sender's user ID: ")
+	fmt.Scan(&request.FromUserID)
+	request.Currency = scanCurrency()
+	fmt.Print("Enter path of the CSV file (to_user_id,amount[,memo[,external_reference]] per line): ")
+	path := scanLine()
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	defer file.Close()
+	request.Items, err = readBatchCSV(file)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Print("Apply the items that can be applied if others fail? (y/n): ")
+	var bestEffort string
+	fmt.Scan(&bestEffort)
+	request.BestEffort = strings.EqualFold(bestEffort, "y")
+
+	resp, err := a.BalanceHandler.BatchTransfer(ctx, request)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Println(resp.Message)
+	fmt.Printf("Batch ID: %s\n", resp.BatchID)
+	for _, item := range resp.Items {
+		if item.Error != "" {
+			fmt.Printf("#%d to user %d: %s failed: %s\n", item.Index+1, item.ToUserID, resp.Currency.Format(item.Amount), item.Error)
+			continue
+		}
+		fmt.Printf("#%d to user %d: %s sent, fee %s, transfer %s\n", item.Index+1, item.ToUserID, resp.Currency.Format(item.Amount), resp.Currency.Format(item.Fee), item.TransferID)
+	}
+	fmt.Printf("Sent %d of %d items, %s in total including %s of fees\n", resp.Succeeded, len(resp.Items), resp.Currency.Format(resp.Total), resp.Currency.Format(resp.Fees))
+}
+
+// readBatchCSV reads the items of a batch transfer, one per record of to_user_id, amount and an
+// optional memo and external reference. A first record starting with "to_user_id" is taken as a
+// header and skipped. Every malformed user ID and amount is reported, named after the item's field.
+func readBatchCSV(r io.Reader) ([]dto.BatchTransferItem, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", apperror.ErrInvalidRequest, err)
+	}
+	if len(records) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "to_user_id") {
+		records = records[1:]
+	}
+	items := make([]dto.BatchTransferItem, 0, len(records))
+	var errs validation.Errors
+	for i, record := range records {
+		field := fmt.Sprintf("items[%d].", i)
+		if len(record) < 2 || len(record) > 4 {
+			errs = append(errs, validation.FieldError{Field: fmt.Sprintf("items[%d]", i), Message: "must have a user ID, an amount and optionally a memo and an external reference"})
+			continue
+		}
+		var item dto.BatchTransferItem
+		userID, err := strconv.ParseUint(strings.TrimSpace(record[0]), 10, 0)
+		if err != nil {
+			errs = append(errs, validation.FieldError{Field: field + "to_user_id", Message: "must be a user ID"})
+		}
+		item.ToUserID = uint(userID)
+		item.Amount, err = validation.ParseAmount(field+"amount", strings.TrimSpace(record[1]))
+		var amountErrs validation.Errors
+		if errors.As(err, &amountErrs) {
+			errs = append(errs, amountErrs...)
+		}
+		if len(record) > 2 {
+			item.Memo = strings.TrimSpace(record[2])
+		}
+		if len(record) > 3 {
+			item.ExternalReference = strings.TrimSpace(record[3])
+		}
+		items = append(items, item)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return items, nil
+}
+
+// printAccount prints the status, tier and product of a wallet and its balance in each currency,
+// with the credit line of those that have one
+func printAccount(account *dto.AccountResponse) {
+	balances := make([]string, 0, len(account.Balances))
+	for _, balance := range account.Balances {
+		formatted := balance.Currency.Format(balance.Balance)
+		if balance.OverdraftLimit != 0 {
+			formatted += fmt.Sprintf(" (overdraft limit %s)", balance.Currency.Format(balance.OverdraftLimit))
+		}
+		balances = append(balances, formatted)
+	}
+	fmt.Printf("Status: %s, Tier: %s, Product: %s, Balances: %s\n", account.Status, account.Tier, account.Product, strings.Join(balances, ", "))
+}
+
+// runReviewCommand asks for an adjustment ID and approves or rejects that adjustment
+func (a *App) runReviewCommand(ctx context.Context, review func(context.Context, *dto.ReviewAdjustmentRequest) (*dto.AdjustmentResponse, error)) {
+	fmt.Print("Enter adjustment ID: ")
+	var adjustmentID uint
+	fmt.Scan(&adjustmentID)
+	resp, err := review(ctx, &dto.ReviewAdjustmentRequest{AdjustmentID: adjustmentID})
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("Adjustment %d %s: %s to user %d\n", resp.ID, strings.ToLower(resp.Status), resp.Currency.Format(resp.Amount), resp.UserID)
+}
+
+// runReverseCommand asks for a transaction ID and reverses it, or refunds part of it when the
+// user enters an amount
+func (a *App) runReverseCommand(ctx context.Context) {
+	fmt.Print("Enter transaction ID: ")
+	var transactionID uint
+	fmt.Scan(&transactionID)
+	fmt.Print("Enter amount to refund (- to reverse the whole transaction): ")
+	var input string
+	fmt.Scan(&input)
+	request := &dto.ReverseRequest{TransactionID: transactionID}
+	if input != "-" {
+		amount, err := validation.ParseAmount("amount", input)
+		if err != nil {
+			printError(err)
+			return
+		}
+		request.Amount = &amount
+	}
+	fmt.Print("Enter reason: ")
+	request.Reason = scanLine()
+	resp, err := a.BalanceHandler.Reverse(ctx, request)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Println(resp.Message)
+	fmt.Printf("%s of %s, %s left to refund\n", resp.Type, resp.Currency.Format(resp.Amount), resp.Currency.Format(resp.Refundable))
+	for userID, balance := range resp.Balances {
+		fmt.Printf("New balance of user %d: %s\n", userID, resp.Currency.Format(balance))
+	}
+}
+
+// runAuthorizeHoldCommand asks for a user, a merchant and an amount and places a hold for it,
+// which expires after config.DefaultHoldLifetime
+func (a *App) runAuthorizeHoldCommand(ctx context.Context) {
+	request := &dto.AuthorizeHoldRequest{}
+	fmt.Print("Enter user ID: ")
+	fmt.Scan(&request.UserID)
+	fmt.Print("Enter merchant user ID: ")
+	fmt.Scan(&request.MerchantID)
+	request.Currency = scanCurrency()
+	fmt.Print("Enter amount to hold: ")
+	amount, err := scanAmount()
+	if err != nil {
+		printError(err)
+		return
+	}
+	request.Amount = amount
+	fmt.Print("Enter memo (optional): ")
+	request.Memo = scanLine()
+	hold, err := a.BalanceHandler.AuthorizeHold(ctx, request)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("Hold %d authorized for %s until %s\n", hold.ID, hold.Currency.Format(hold.Amount), hold.ExpiresAt.Format("2006-01-02 15:04:05"))
+}
+
+// runCaptureHoldCommand asks for a hold and pays its merchant all of it or a smaller amount
+func (a *App) runCaptureHoldCommand(ctx context.Context) {
+	request := &dto.CaptureHoldRequest{}
+	fmt.Print("Enter hold ID: ")
+	fmt.Scan(&request.HoldID)
+	fmt.Print("Enter amount to capture (- for the whole hold): ")
+	var input string
+	fmt.Scan(&input)
+	if input != "-" {
+		amount, err := validation.ParseAmount("amount", input)
+		if err != nil {
+			printError(err)
+			return
+		}
+		request.Amount = &amount
+	}
+	hold, err := a.BalanceHandler.CaptureHold(ctx, request)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Printf("Hold %d captured: %s paid to user %d\n", hold.ID, hold.Currency.Format(hold.CapturedAmount), hold.MerchantID)
+	if released := hold.Amount.Sub(hold.CapturedAmount); released.IsPositive() {
+		fmt.Printf("%s released back to user %d\n", hold.Currency.Format(released), hold.UserID)
+	}
+}
+
+// runCreateScheduleCommand asks for the payer, the recipient, an amount, how often to pay it and
+// from when, and schedules the transfer
+func (a *App) runCreateScheduleCommand(ctx context.Context) {
+	request := &dto.CreateScheduleRequest{}
+	fmt.Print("Enter sender user ID: ")
+	fmt.Scan(&request.FromUserID)
+	fmt.Print("Enter recipient user ID: ")
+	fmt.Scan(&request.ToUserID)
+	request.Currency = scanCurrency()
+	fmt.Print("Enter amount to transfer: ")
+	amount, err := scanAmount()
+	if err != nil {
+		printError(err)
+		return
+	}
+	request.Amount = amount
+	fmt.Print("Repeat (Once, Daily, Weekly or Monthly): ")
+	fmt.Scan(&request.Recurrence)
+
+	var errs validation.Errors
+	var input string
+	for _, date := range []struct {
+		prompt string
+		field  string
+		into   *time.Time
+	}{
+		{"Start (YYYY-MM-DD HH:MM) or - for now: ", "start_at", &request.StartAt},
+		{"End (YYYY-MM-DD HH:MM) or - for never: ", "end_at", &request.EndAt},
+	} {
+		fmt.Print(date.prompt)
+		input = scanLine()
+		if input == "-" {
+			continue
+		}
+		at, err := time.ParseInLocation("2006-01-02 15:04", input, time.Local)
+		if err != nil {
+			errs = append(errs, validation.FieldError{Field: date.field, Message: "must be a time such as 2024-01-31 09:00"})
+			continue
+		}
+		*date.into = at
+	}
+	if len(errs) > 0 {
+		printError(errs)
+		return
+	}
+	fmt.Print("When a transfer keeps failing (Skip or Stop): ")
+	fmt.Scan(&request.FailurePolicy)
+	fmt.Print("Enter memo (optional): ")
+	request.Memo = scanLine()
+	schedule, err := a.ScheduleHandler.CreateSchedule(ctx, request)
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Println("Transfer scheduled!")
+	printSchedule(schedule)
+}
+
+// printSchedule prints a scheduled transfer and when it runs next
+func printSchedule(schedule *dto.ScheduleResponse) {
+	fmt.Printf("%d. %s %s from user %d to user %d, %s", schedule.ID, schedule.Recurrence, schedule.Currency.Format(schedule.Amount), schedule.FromUserID, schedule.ToUserID, schedule.Status)
+	if schedule.Status == model.ScheduleStatusActive.String() {
+		fmt.Printf(", next at %s", schedule.NextRunAt.Local().Format("2006-01-02 15:04"))
+	}
+	fmt.Println()
+	if schedule.LastError != "" {
+		fmt.Printf("    last error: %s\n", schedule.LastError)
+	}
+}
+
+// runExchangeCommand asks for a user ID, two currencies and an amount, shows the quoted price
+// and exchanges at it if the user accepts before the quote expires
+func (a *App) runExchangeCommand(ctx context.Context) {
+	request := &dto.ExchangeQuoteRequest{}
+	fmt.Print("Enter user ID: ")
+	fmt.Scan(&request.UserID)
+	fmt.Print("Enter currency to sell: ")
+	var from, to string
+	fmt.Scan(&from)
+	fmt.Print("Enter currency to buy: ")
+	fmt.Scan(&to)
+	request.FromCurrency, request.ToCurrency = currencyCode(from), currencyCode(to)
+	fmt.Print("Enter amount to sell: ")
+	amount, err := scanAmount()
+	if err != nil {
+		printError(err)
+		return
+	}
+	request.Amount = amount
+	quote, err := a.BalanceHandler.QuoteExchange(ctx, request)
+	if err != nil {
+		printError(err)
+		return
+	}
+
+	fmt.Printf("%s buys %s at %s, after a spread of %s\n", quote.FromCurrency.Format(quote.Amount), quote.ToCurrency.Format(quote.ToAmount), quote.Rate, quote.ToCurrency.Format(quote.Spread))
+	fmt.Printf("Accept before %s? (y/n): ", quote.ExpiresAt.Local().Format("15:04:05"))
+	var answer string
+	fmt.Scan(&answer)
+	if !strings.EqualFold(answer, "y") {
+		fmt.Println("Exchange cancelled")
+		return
+	}
+	resp, err := a.BalanceHandler.Exchange(ctx, &dto.ExchangeRequest{UserID: quote.UserID, QuoteID: quote.QuoteID})
+	if err != nil {
+		printError(err)
+		return
+	}
+	fmt.Println(resp.Message)
+	fmt.Printf("New Balances: %s, %s\n", resp.FromCurrency.Format(resp.FromBalance), resp.ToCurrency.Format(resp.ToBalance))
+}
+
+// scanLine reads the next line of input, which may contain spaces or be empty, after skipping
+// the line break left behind by the previous prompt
+func scanLine() string {
+	var line []byte
+	skippedLineBreak := false
+	buf := make([]byte, 1)
+	for {
+		if n, err := os.Stdin.Read(buf); n == 0 || err != nil {
+			break
+		}
+		if buf[0] == '\n' {
+			if !skippedLineBreak && len(strings.TrimSpace(string(line))) == 0 {
+				skippedLineBreak = true
+				line = line[:0]
+				continue
+			}
+			break
+		}
+		line = append(line, buf[0])
+	}
+	return strings.TrimSpace(string(line))
+}
+
+// scanCurrency asks for a currency code such as EUR, returning an empty currency, which stands
+// for model.DefaultCurrency, when the user answers "-"
+func scanCurrency() model.Currency {
+	fmt.Printf("Enter currency (- for %s): ", model.DefaultCurrency)
+	var input string
+	fmt.Scan(&input)
+	if input == "-" {
+		return ""
+	}
+	return currencyCode(input)
+}
+
+// scanAmount reads an amount of money such as 12.34 from the user
+func scanAmount() (model.Money, error) {
+	var input string
+	fmt.Scan(&input)
+	return validation.ParseAmount("amount", input)
+}
+
+// printError prints err, listing each invalid field on its own line for validation errors and
+// explaining the other domain errors in words the user can act on
+func printError(err error) {
+	var fieldErrors validation.Errors
+	switch {
+	case errors.As(err, &fieldErrors):
+		fmt.Println("Error: invalid input")
+		for _, fieldError := range fieldErrors {
+			fmt.Printf("  - %s %s\n", fieldError.Field, fieldError.Message)
+		}
+	case errors.Is(err, apperror.ErrUnauthenticated):
+		fmt.Println("Error: invalid username or password")
+	case errors.Is(err, apperror.ErrForbidden):
+		fmt.Println("Error: you are not allowed to do that:", err)
+	case errors.Is(err, apperror.ErrAccountNotFound):
+		fmt.Println("Error: no wallet exists for that user ID and currency")
+	case errors.Is(err, apperror.ErrInsufficientFunds):
+		fmt.Println("Error: insufficient balance")
+	case errors.Is(err, apperror.ErrAccountFrozen):
+		fmt.Println("Error: the wallet is frozen")
+	case errors.Is(err, apperror.ErrAccountClosed):
+		fmt.Println("Error: the wallet is closed")
+	case errors.Is(err, apperror.ErrConflict):
+		fmt.Println("Error: the request conflicts with another one, please try again:", err)
+	case errors.Is(err, apperror.ErrRateUnavailable):
+		fmt.Println("Error: no exchange rate is available for those currencies, please try again later")
+	case errors.Is(err, apperror.ErrStorage):
+		fmt.Println("Error: the wallet service is unavailable, please try again later")
+	default:
+		fmt.Println("Error:", err)
+	}
+}
+
 // Countdown function to return to the main menu
 func countdownToMainMenu() {
 	for i := 3; i > 0; i-- {
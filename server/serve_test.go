@@ -0,0 +1,57 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"walletApp/apperror"
+	"walletApp/dto"
+	"walletApp/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBatchCSV(t *testing.T) {
+	tests := []struct {
+		name           string
+		csv            string
+		expectedItems  []dto.BatchTransferItem
+		expectedErrors validation.Errors
+	}{
+		{
+			name: "Header, memo and reference",
+			csv:  "to_user_id,amount,memo,external_reference\n2,12.50\n3, 7,\"March, week 1\",INV-3\n",
+			expectedItems: []dto.BatchTransferItem{
+				{ToUserID: 2, Amount: 1250},
+				{ToUserID: 3, Amount: 700, Memo: "March, week 1", ExternalReference: "INV-3"},
+			},
+		},
+		{
+			name:          "Empty file",
+			csv:           "",
+			expectedItems: []dto.BatchTransferItem{},
+		},
+		{
+			name: "Malformed records",
+			csv:  "bob,12.50\n2,-1\n3\n",
+			expectedErrors: validation.Errors{
+				{Field: "items[0].to_user_id", Message: "must be a user ID"},
+				{Field: "items[1].amount", Message: "must be greater than zero"},
+				{Field: "items[2]", Message: "must have a user ID, an amount and optionally a memo and an external reference"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := readBatchCSV(strings.NewReader(tt.csv))
+			if tt.expectedErrors != nil {
+				assert.ErrorIs(t, err, apperror.ErrInvalidRequest)
+				assert.Equal(t, tt.expectedErrors, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedItems, items)
+		})
+	}
+}
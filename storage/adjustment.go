@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"context"
+	"walletApp/model"
+)
+
+// AdjustmentRepository defines the interface for storing manual balance adjustments
+//
+//go:generate mockery  --case underscore --name AdjustmentRepository
+type AdjustmentRepository interface {
+	CreateAdjustment(ctx context.Context, adjustment *model.Adjustment) error
+	GetAdjustment(ctx context.Context, id uint) (*model.Adjustment, error)
+	GetAdjustmentsByStatus(ctx context.Context, status model.AdjustmentStatus) ([]model.Adjustment, error)
+	ReviewAdjustment(ctx context.Context, adjustment *model.Adjustment) error
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletApp/apperror"
+	"walletApp/model"
+	"walletApp/storage/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type adjustmentRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+// NewAdjustmentRepository creates a new instance of adjustmentRepositoryImpl
+func NewAdjustmentRepository(db *gorm.DB) AdjustmentRepository {
+	return &adjustmentRepositoryImpl{DB: db}
+}
+
+// NewMockAdjustmentRepository creates a new instance of AdjustmentRepository with mocked methods
+func NewMockAdjustmentRepository(doMocks ...func(mock *mock.Mock)) AdjustmentRepository {
+	mockRepo := &mocks.AdjustmentRepository{}
+	for _, mockFunc := range doMocks {
+		mockFunc(&mockRepo.Mock)
+	}
+	return mockRepo
+}
+
+// CreateAdjustment inserts a new adjustment
+func (r *adjustmentRepositoryImpl) CreateAdjustment(ctx context.Context, adjustment *model.Adjustment) error {
+	return storageError(conn(ctx, r.DB).Create(adjustment).Error)
+}
+
+// GetAdjustment retrieves an adjustment by ID. An apperror.ErrNotFound is returned when there is none.
+func (r *adjustmentRepositoryImpl) GetAdjustment(ctx context.Context, id uint) (*model.Adjustment, error) {
+	var adjustment model.Adjustment
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&adjustment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: adjustment %d", apperror.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &adjustment, nil
+}
+
+// GetAdjustmentsByStatus retrieves the adjustments with the given status, oldest first
+func (r *adjustmentRepositoryImpl) GetAdjustmentsByStatus(ctx context.Context, status model.AdjustmentStatus) ([]model.Adjustment, error) {
+	var adjustments []model.Adjustment
+	err := conn(ctx, r.DB).Where("status = ?", status).Order("id").Find(&adjustments).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return adjustments, nil
+}
+
+// ReviewAdjustment stores the outcome of reviewing a pending adjustment: its status, reviewer,
+// review time and journal entry. Only a pending adjustment can be reviewed, so when two admins
+// review the same adjustment concurrently the second gets an apperror.ErrConflict.
+func (r *adjustmentRepositoryImpl) ReviewAdjustment(ctx context.Context, adjustment *model.Adjustment) error {
+	updates := map[string]interface{}{
+		"status":      adjustment.Status,
+		"reviewed_by": adjustment.ReviewedBy,
+		"reviewed_at": adjustment.ReviewedAt,
+	}
+	// Rejected adjustments post nothing, so their journal entry stays null
+	if adjustment.JournalEntryID != 0 {
+		updates["journal_entry_id"] = adjustment.JournalEntryID
+	}
+	result := conn(ctx, r.DB).Model(&model.Adjustment{}).
+		Where("id = ? AND status = ?", adjustment.ID, model.AdjustmentStatusPending).
+		Updates(updates)
+	if result.Error != nil {
+		return storageError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: adjustment %d is no longer pending", apperror.ErrConflict, adjustment.ID)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"walletApp/apperror"
+	"walletApp/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestGetAdjustment(t *testing.T) {
+	tests := []struct {
+		name               string
+		setupMock          func(sqlmock.Sqlmock)
+		expectedAdjustment *model.Adjustment
+		expectedError      error
+	}{
+		{
+			name: "Pending adjustment",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "adjustments" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(7, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "amount", "reason", "status", "created_by"}).
+						AddRow(7, 1, 2500, "refund for support case 12", model.AdjustmentStatusPending, 4))
+			},
+			expectedAdjustment: &model.Adjustment{ID: 7, UserID: 1, Amount: 2500, Reason: "refund for support case 12", Status: model.AdjustmentStatusPending, CreatedBy: 4},
+		},
+		{
+			name: "Unknown adjustment",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "adjustments" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(7, 1).
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedError: apperror.ErrNotFound,
+		},
+		{
+			name: "Database Error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "adjustments" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(7, 1).
+					WillReturnError(errors.New("database connection error"))
+			},
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			tt.setupMock(mock)
+
+			repo := NewAdjustmentRepository(gormDB)
+			adjustment, err := repo.GetAdjustment(context.Background(), 7)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedAdjustment, adjustment)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetAdjustmentsByStatus(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	mock.ExpectQuery(`SELECT \* FROM "adjustments" WHERE status = \$1 ORDER BY id`).
+		WithArgs(model.AdjustmentStatusPending).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "amount", "status"}).
+			AddRow(7, 1, 2500, model.AdjustmentStatusPending).
+			AddRow(8, 2, -100, model.AdjustmentStatusPending))
+
+	repo := NewAdjustmentRepository(gormDB)
+	adjustments, err := repo.GetAdjustmentsByStatus(context.Background(), model.AdjustmentStatusPending)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Adjustment{
+		{ID: 7, UserID: 1, Amount: 2500, Status: model.AdjustmentStatusPending},
+		{ID: 8, UserID: 2, Amount: -100, Status: model.AdjustmentStatusPending},
+	}, adjustments)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateAdjustment(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "adjustments" \("user_id","amount","currency","reason","status","created_by","created_at","reviewed_at"\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+
+	repo := NewAdjustmentRepository(gormDB)
+	adjustment := &model.Adjustment{UserID: 1, Amount: 2500, Currency: model.EUR, Reason: "refund for support case 12", CreatedBy: 4}
+	err := repo.CreateAdjustment(context.Background(), adjustment)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), adjustment.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReviewAdjustment(t *testing.T) {
+	tests := []struct {
+		name          string
+		rowsAffected  int64
+		mockError     error
+		expectedError error
+	}{
+		{
+			name:         "Pending adjustment reviewed",
+			rowsAffected: 1,
+		},
+		{
+			name:          "Adjustment already reviewed",
+			rowsAffected:  0,
+			expectedError: apperror.ErrConflict,
+		},
+		{
+			name:          "Database error",
+			mockError:     errors.New("database connection error"),
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewAdjustmentRepository(db)
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`UPDATE "adjustments" SET "journal_entry_id"=\$1,"reviewed_at"=\$2,"reviewed_by"=\$3,"status"=\$4 WHERE id = \$5 AND status = \$6`).
+				WithArgs(42, sqlmock.AnyArg(), 5, model.AdjustmentStatusApproved, 7, model.AdjustmentStatusPending)
+			if tt.mockError == nil {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+				mock.ExpectCommit()
+			} else {
+				exec.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			err := repo.ReviewAdjustment(context.Background(), &model.Adjustment{ID: 7, Status: model.AdjustmentStatusApproved, ReviewedBy: 5, JournalEntryID: 42})
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReviewAdjustmentRejected(t *testing.T) {
+	db, mock := setupMockDB()
+	repo := NewAdjustmentRepository(db)
+
+	// A rejection has no journal entry, so journal_entry_id is left null
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "adjustments" SET "reviewed_at"=\$1,"reviewed_by"=\$2,"status"=\$3 WHERE id = \$4 AND status = \$5`).
+		WithArgs(sqlmock.AnyArg(), 5, model.AdjustmentStatusRejected, 7, model.AdjustmentStatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.ReviewAdjustment(context.Background(), &model.Adjustment{ID: 7, Status: model.AdjustmentStatusRejected, ReviewedBy: 5})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewAdjustmentRepository(t *testing.T) {
+	gormDB, _ := setupMockDB()
+	repo := NewAdjustmentRepository(gormDB)
+
+	assert.NotNil(t, repo)
+	assert.IsType(t, &adjustmentRepositoryImpl{}, repo)
+}
+
+func TestNewMockAdjustmentRepository(t *testing.T) {
+	mockCalled := false
+	repo := NewMockAdjustmentRepository(func(m *mock.Mock) {
+		mockCalled = true
+		m.On("GetAdjustment", mock.Anything, uint(7)).Return(&model.Adjustment{ID: 7}, nil)
+	})
+
+	assert.True(t, mockCalled)
+	adjustment, err := repo.GetAdjustment(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), adjustment.ID)
+}
@@ -2,12 +2,27 @@ package storage
 
 import (
 	"context"
+	"walletApp/model"
 )
 
-// BalanceRepository defines the interface for balance-related operations
+// BalanceRepository defines the interface for balance-related operations. Each balance row
+// holds one user's money in one currency.
 //
 //go:generate mockery  --case underscore --name BalanceRepository
 type BalanceRepository interface {
-	GetBalance(ctx context.Context, userID uint) (float64, error)
-	UpdateBalance(ctx context.Context, userID uint, newBalance float64) error
+	GetBalance(ctx context.Context, userID uint, currency model.Currency) (model.Money, error)
+	GetBalanceRecord(ctx context.Context, userID uint, currency model.Currency) (*model.Balance, error)
+	GetBalanceForUpdate(ctx context.Context, userID uint, currency model.Currency) (*model.Balance, error)
+	GetBalances(ctx context.Context, userID uint) ([]model.Balance, error)
+	GetBalancesForUpdate(ctx context.Context, userID uint) ([]model.Balance, error)
+	UpdateBalance(ctx context.Context, userID uint, currency model.Currency, newBalance model.Money, version uint) error
+	CreateBalance(ctx context.Context, balance *model.Balance) error
+	UpdateBalanceStatus(ctx context.Context, userID uint, currency model.Currency, status model.AccountStatus, version uint) error
+	UpdateBalanceTier(ctx context.Context, userID uint, currency model.Currency, tier model.AccountTier, version uint) error
+	UpdateBalanceProduct(ctx context.Context, userID uint, currency model.Currency, product model.AccountProduct, version uint) error
+	GetBalancesByProduct(ctx context.Context, product model.AccountProduct, after model.BalanceKey, limit int) ([]model.Balance, error)
+	UpdateOverdraftLimit(ctx context.Context, userID uint, currency model.Currency, overdraftLimit model.Money, version uint) error
+	GetBalancesWithOverdraft(ctx context.Context, after model.BalanceKey, limit int) ([]model.Balance, error)
+	GetOverdrawnBalances(ctx context.Context, after model.BalanceKey, limit int) ([]model.Balance, error)
+	UpdateHeld(ctx context.Context, userID uint, currency model.Currency, held model.Money, version uint) error
 }
@@ -2,11 +2,13 @@ package storage
 
 import (
 	"context"
+	"walletApp/apperror"
 	"walletApp/model"
 	"walletApp/storage/mocks"
 
 	"github.com/stretchr/testify/mock"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type balanceRepositoryImpl struct {
@@ -27,21 +29,162 @@ func NewMockBalanceRepository(doMocks ...func(mock *mock.Mock)) BalanceRepositor
 	return mockRepo
 }
 
-// GetBalance retrieves the user's balance from Redis or the database
-func (r *balanceRepositoryImpl) GetBalance(ctx context.Context, userID uint) (float64, error) {
+// GetBalance retrieves the user's balance in currency from Redis or the database
+func (r *balanceRepositoryImpl) GetBalance(ctx context.Context, userID uint, currency model.Currency) (model.Money, error) {
 	var balance model.Balance
-	err := r.DB.WithContext(ctx).Where("user_id = ?", userID).Select("balance").First(&balance).Error
+	err := conn(ctx, r.DB).Where("user_id = ? AND currency = ?", userID, currency).Select("balance").First(&balance).Error
 	if err != nil {
-		return 0, err
+		return 0, balanceError(err, userID, currency)
 	}
 	return balance.Balance, nil
 }
 
-// UpdateBalance updates the user's balance in the database and Redis
-func (r *balanceRepositoryImpl) UpdateBalance(ctx context.Context, userID uint, newBalance float64) error {
-	err := r.DB.WithContext(ctx).Model(&model.Balance{}).Where("user_id = ?", userID).Update("balance", newBalance).Error
+// GetBalanceRecord retrieves the user's balance row in currency, including the version needed
+// by UpdateBalance
+func (r *balanceRepositoryImpl) GetBalanceRecord(ctx context.Context, userID uint, currency model.Currency) (*model.Balance, error) {
+	var balance model.Balance
+	err := conn(ctx, r.DB).Where("user_id = ? AND currency = ?", userID, currency).First(&balance).Error
+	if err != nil {
+		return nil, balanceError(err, userID, currency)
+	}
+	return &balance, nil
+}
+
+// GetBalanceForUpdate retrieves the user's balance row in currency and locks it with
+// SELECT ... FOR UPDATE. The lock is held until the surrounding unit of work commits or rolls
+// back, so it should only be called inside UnitOfWork.Do.
+func (r *balanceRepositoryImpl) GetBalanceForUpdate(ctx context.Context, userID uint, currency model.Currency) (*model.Balance, error) {
+	var balance model.Balance
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND currency = ?", userID, currency).First(&balance).Error
 	if err != nil {
-		return err
+		return nil, balanceError(err, userID, currency)
+	}
+	return &balance, nil
+}
+
+// GetBalances retrieves every balance row of the user's wallet, ordered by currency. An
+// *apperror.AccountNotFoundError is returned when the user has no wallet.
+func (r *balanceRepositoryImpl) GetBalances(ctx context.Context, userID uint) ([]model.Balance, error) {
+	return r.findBalances(conn(ctx, r.DB), userID)
+}
+
+// GetBalancesForUpdate retrieves and locks every balance row of the user's wallet, in the
+// currency order the ledger locks them in. It should only be called inside UnitOfWork.Do.
+func (r *balanceRepositoryImpl) GetBalancesForUpdate(ctx context.Context, userID uint) ([]model.Balance, error) {
+	return r.findBalances(conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}), userID)
+}
+
+func (r *balanceRepositoryImpl) findBalances(db *gorm.DB, userID uint) ([]model.Balance, error) {
+	var balances []model.Balance
+	err := db.Where("user_id = ?", userID).Order("currency").Find(&balances).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if len(balances) == 0 {
+		return nil, &apperror.AccountNotFoundError{UserID: userID}
+	}
+	return balances, nil
+}
+
+// UpdateBalance sets the user's balance in currency if the row is still at the given version,
+// and bumps the version. A *VersionConflictError is returned when another writer updated it first.
+func (r *balanceRepositoryImpl) UpdateBalance(ctx context.Context, userID uint, currency model.Currency, newBalance model.Money, version uint) error {
+	return r.updateVersioned(ctx, userID, currency, version, map[string]interface{}{"balance": newBalance})
+}
+
+// CreateBalance inserts a new balance row, opening the user's wallet in the balance's currency.
+// An apperror.ErrConflict is returned when the user already holds that currency.
+func (r *balanceRepositoryImpl) CreateBalance(ctx context.Context, balance *model.Balance) error {
+	return storageError(conn(ctx, r.DB).Create(balance).Error)
+}
+
+// UpdateBalanceStatus sets the status of the user's balance in currency if the row is still at
+// the given version, and bumps the version so that concurrent optimistic updates of the balance fail
+func (r *balanceRepositoryImpl) UpdateBalanceStatus(ctx context.Context, userID uint, currency model.Currency, status model.AccountStatus, version uint) error {
+	return r.updateVersioned(ctx, userID, currency, version, map[string]interface{}{"status": status})
+}
+
+// UpdateBalanceTier sets the tier of the user's balance in currency if the row is still at the
+// given version, and bumps the version so that a posting checked against the old tier's limits
+// fails when using optimistic locking
+func (r *balanceRepositoryImpl) UpdateBalanceTier(ctx context.Context, userID uint, currency model.Currency, tier model.AccountTier, version uint) error {
+	return r.updateVersioned(ctx, userID, currency, version, map[string]interface{}{"tier": tier})
+}
+
+// UpdateBalanceProduct sets the product of the user's balance in currency if the row is still at
+// the given version, and bumps the version
+func (r *balanceRepositoryImpl) UpdateBalanceProduct(ctx context.Context, userID uint, currency model.Currency, product model.AccountProduct, version uint) error {
+	return r.updateVersioned(ctx, userID, currency, version, map[string]interface{}{"product": product})
+}
+
+// GetBalancesByProduct retrieves up to limit balances of wallets of product, ordered by user and
+// currency and starting after the balance identified by after, so that a caller can page through
+// all of them by passing the key of the last balance it got
+func (r *balanceRepositoryImpl) GetBalancesByProduct(ctx context.Context, product model.AccountProduct, after model.BalanceKey, limit int) ([]model.Balance, error) {
+	var balances []model.Balance
+	err := conn(ctx, r.DB).
+		Where("product = ? AND (user_id, currency) > (?, ?)", product, after.UserID, after.Currency).
+		Order("user_id").Order("currency").Limit(limit).
+		Find(&balances).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return balances, nil
+}
+
+// UpdateOverdraftLimit sets how far below zero the user's balance in currency may be spent if the
+// row is still at the given version
+func (r *balanceRepositoryImpl) UpdateOverdraftLimit(ctx context.Context, userID uint, currency model.Currency, overdraftLimit model.Money, version uint) error {
+	return r.updateVersioned(ctx, userID, currency, version, map[string]interface{}{"overdraft_limit": overdraftLimit})
+}
+
+// GetBalancesWithOverdraft retrieves up to limit user balances that have a credit line or are
+// below zero, paged like GetBalancesByProduct
+func (r *balanceRepositoryImpl) GetBalancesWithOverdraft(ctx context.Context, after model.BalanceKey, limit int) ([]model.Balance, error) {
+	return r.getUserBalancesWhere(ctx, "(overdraft_limit > 0 OR balance < 0)", after, limit)
+}
+
+// GetOverdrawnBalances retrieves up to limit user balances that are below zero, paged like
+// GetBalancesByProduct. System accounts, which may go below zero without a credit line, are left
+// out.
+func (r *balanceRepositoryImpl) GetOverdrawnBalances(ctx context.Context, after model.BalanceKey, limit int) ([]model.Balance, error) {
+	return r.getUserBalancesWhere(ctx, "balance < 0", after, limit)
+}
+
+// getUserBalancesWhere retrieves up to limit user balances matching condition after the balance
+// identified by after, ordered by user and currency
+func (r *balanceRepositoryImpl) getUserBalancesWhere(ctx context.Context, condition string, after model.BalanceKey, limit int) ([]model.Balance, error) {
+	var balances []model.Balance
+	err := conn(ctx, r.DB).
+		Where(condition+" AND user_id <= ? AND (user_id, currency) > (?, ?)", model.MaxUserID, after.UserID, after.Currency).
+		Order("user_id").Order("currency").Limit(limit).
+		Find(&balances).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return balances, nil
+}
+
+// UpdateHeld sets the money reserved by holds on the user's balance in currency if the row is
+// still at the given version, and bumps the version so that a concurrent optimistic update of
+// the balance cannot spend the money being reserved
+func (r *balanceRepositoryImpl) UpdateHeld(ctx context.Context, userID uint, currency model.Currency, held model.Money, version uint) error {
+	return r.updateVersioned(ctx, userID, currency, version, map[string]interface{}{"held": held})
+}
+
+// updateVersioned sets columns of the user's balance in currency if the row is still at the given
+// version, and bumps the version so that concurrent optimistic updates of the balance fail. A
+// VersionConflictError is returned when the row moved on.
+func (r *balanceRepositoryImpl) updateVersioned(ctx context.Context, userID uint, currency model.Currency, version uint, columns map[string]interface{}) error {
+	columns["version"] = gorm.Expr("version + 1")
+	result := conn(ctx, r.DB).Model(&model.Balance{}).
+		Where("user_id = ? AND currency = ? AND version = ?", userID, currency, version).
+		Updates(columns)
+	if result.Error != nil {
+		return storageError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return &VersionConflictError{UserID: userID, Version: version}
 	}
 
 	return nil
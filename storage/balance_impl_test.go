@@ -2,7 +2,10 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"walletApp/apperror"
+	"walletApp/model"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -39,53 +42,64 @@ func TestGetBalance(t *testing.T) {
 	tests := []struct {
 		name            string
 		userID          uint
-		expectedBalance float64
+		expectedBalance model.Money
 		mockError       error
 		expectError     bool
+		expectedError   error
 	}{
 		{
 			name:            "Valid user balance",
 			userID:          1,
-			expectedBalance: 1000.0,
+			expectedBalance: 1000,
 			mockError:       nil,
 			expectError:     false,
 		},
 		{
 			name:            "User not found",
 			userID:          2,
-			expectedBalance: 0.0,
+			expectedBalance: 0,
 			mockError:       gorm.ErrRecordNotFound,
 			expectError:     true,
+			expectedError:   apperror.ErrAccountNotFound,
+		},
+		{
+			name:            "Database unreachable",
+			userID:          3,
+			expectedBalance: 0,
+			mockError:       errors.New("connection refused"),
+			expectError:     true,
+			expectedError:   apperror.ErrStorage,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Mock the SELECT query
-			query := `SELECT .*balance.* FROM "balances" WHERE user_id = \$1 .* LIMIT \$2`
+			query := `SELECT .*balance.* FROM "balances" WHERE user_id = \$1 AND currency = \$2 .* LIMIT \$3`
 			if tt.mockError == nil {
 				mock.ExpectQuery(query).
-					WithArgs(tt.userID, 1).
+					WithArgs(tt.userID, model.USD, 1).
 					WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(tt.expectedBalance))
 			} else {
 				mock.ExpectQuery(query).
-					WithArgs(tt.userID, 1).
+					WithArgs(tt.userID, model.USD, 1).
 					WillReturnError(tt.mockError)
 			}
 
 			ctx := context.Background()
-			balance, err := repo.GetBalance(ctx, tt.userID)
+			balance, err := repo.GetBalance(ctx, tt.userID, model.USD)
 
 			if tt.expectError {
 				if err == nil {
 					t.Errorf("Expected error, got nil")
 				}
+				assert.ErrorIs(t, err, tt.expectedError)
 			} else {
 				if err != nil {
 					t.Errorf("Unexpected error: %v", err)
 				}
 				if balance != tt.expectedBalance {
-					t.Errorf("Expected balance %.2f, got %.2f", tt.expectedBalance, balance)
+					t.Errorf("Expected balance %s, got %s", tt.expectedBalance, balance)
 				}
 			}
 
@@ -95,25 +109,183 @@ func TestGetBalance(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBalanceRecord(t *testing.T) {
+	tests := []struct {
+		name            string
+		userID          uint
+		lockRow         bool
+		expectedBalance model.Balance
+		mockError       error
+		expectError     bool
+	}{
+		{
+			name:            "Valid user balance",
+			userID:          1,
+			expectedBalance: model.Balance{ID: 1, UserID: 1, Currency: model.EUR, Balance: 1000, Version: 3},
+			mockError:       nil,
+			expectError:     false,
+		},
+		{
+			name:            "Valid user balance with row lock",
+			userID:          1,
+			lockRow:         true,
+			expectedBalance: model.Balance{ID: 1, UserID: 1, Currency: model.EUR, Balance: 1000, Version: 3},
+			mockError:       nil,
+			expectError:     false,
+		},
+		{
+			name:        "User not found",
+			userID:      2,
+			mockError:   gorm.ErrRecordNotFound,
+			expectError: true,
+		},
+		{
+			name:        "User not found with row lock",
+			userID:      2,
+			lockRow:     true,
+			mockError:   gorm.ErrRecordNotFound,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewBalanceRepository(db)
+
+			query := `SELECT \* FROM "balances" WHERE user_id = \$1 AND currency = \$2 .* LIMIT \$3$`
+			if tt.lockRow {
+				query = `SELECT \* FROM "balances" WHERE user_id = \$1 AND currency = \$2 .* LIMIT \$3 FOR UPDATE`
+			}
+			if tt.mockError == nil {
+				mock.ExpectQuery(query).
+					WithArgs(tt.userID, model.EUR, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "currency", "balance", "version"}).
+						AddRow(tt.expectedBalance.ID, tt.expectedBalance.UserID, tt.expectedBalance.Currency, tt.expectedBalance.Balance, tt.expectedBalance.Version))
+			} else {
+				mock.ExpectQuery(query).
+					WithArgs(tt.userID, model.EUR, 1).
+					WillReturnError(tt.mockError)
+			}
+
+			var balance *model.Balance
+			var err error
+			if tt.lockRow {
+				balance, err = repo.GetBalanceForUpdate(context.Background(), tt.userID, model.EUR)
+			} else {
+				balance, err = repo.GetBalanceRecord(context.Background(), tt.userID, model.EUR)
+			}
+
+			if tt.expectError {
+				assert.ErrorIs(t, err, apperror.ErrAccountNotFound)
+				assert.Nil(t, balance)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedBalance, *balance)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetBalances(t *testing.T) {
+	tests := []struct {
+		name             string
+		lockRows         bool
+		rows             *sqlmock.Rows
+		expectedBalances []model.Balance
+		expectedError    error
+	}{
+		{
+			name: "Wallet holding two currencies",
+			rows: sqlmock.NewRows([]string{"id", "user_id", "currency", "balance"}).
+				AddRow(2, 1, model.EUR, 500).
+				AddRow(1, 1, model.USD, 1000),
+			expectedBalances: []model.Balance{
+				{ID: 2, UserID: 1, Currency: model.EUR, Balance: 500},
+				{ID: 1, UserID: 1, Currency: model.USD, Balance: 1000},
+			},
+		},
+		{
+			name:     "Wallet locked",
+			lockRows: true,
+			rows:     sqlmock.NewRows([]string{"id", "user_id", "currency", "balance"}).AddRow(1, 1, model.USD, 1000),
+			expectedBalances: []model.Balance{
+				{ID: 1, UserID: 1, Currency: model.USD, Balance: 1000},
+			},
+		},
+		{
+			name:          "User without a wallet",
+			rows:          sqlmock.NewRows([]string{"id", "user_id", "currency", "balance"}),
+			expectedError: apperror.ErrAccountNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewBalanceRepository(db)
+
+			query := `SELECT \* FROM "balances" WHERE user_id = \$1 ORDER BY currency$`
+			if tt.lockRows {
+				query = `SELECT \* FROM "balances" WHERE user_id = \$1 ORDER BY currency FOR UPDATE`
+			}
+			mock.ExpectQuery(query).WithArgs(1).WillReturnRows(tt.rows)
+
+			var balances []model.Balance
+			var err error
+			if tt.lockRows {
+				balances, err = repo.GetBalancesForUpdate(context.Background(), 1)
+			} else {
+				balances, err = repo.GetBalances(context.Background(), 1)
+			}
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedBalances, balances)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestUpdateBalance(t *testing.T) {
 	tests := []struct {
-		name        string
-		userID      uint
-		newBalance  float64
-		mockError   error
-		expectError bool
+		name           string
+		userID         uint
+		newBalance     model.Money
+		version        uint
+		rowsAffected   int64
+		mockError      error
+		expectError    bool
+		expectConflict bool
 	}{
 		{
-			name:        "Successful balance update",
-			userID:      1,
-			newBalance:  150.0,
-			mockError:   nil,
-			expectError: false,
+			name:         "Successful balance update",
+			userID:       1,
+			newBalance:   150,
+			version:      3,
+			rowsAffected: 1,
+			mockError:    nil,
+			expectError:  false,
+		},
+		{
+			name:           "Version conflict",
+			userID:         1,
+			newBalance:     150,
+			version:        3,
+			rowsAffected:   0,
+			mockError:      nil,
+			expectError:    true,
+			expectConflict: true,
 		},
 		{
 			name:        "Update fails (user not found)",
 			userID:      2,
-			newBalance:  200.0,
+			newBalance:  200,
+			version:     0,
 			mockError:   gorm.ErrRecordNotFound,
 			expectError: true,
 		},
@@ -128,25 +300,25 @@ func TestUpdateBalance(t *testing.T) {
 			// Mock transaction Begin
 			mock.ExpectBegin()
 
-			query := `UPDATE "balances" SET "balance"=\$1 WHERE user_id = \$2`
+			query := `UPDATE "balances" SET "balance"=\$1,"version"=version \+ 1 WHERE user_id = \$2 AND currency = \$3 AND version = \$4`
 			if tt.mockError == nil {
 				mock.ExpectExec(query).
-					WithArgs(tt.newBalance, tt.userID).
-					WillReturnResult(sqlmock.NewResult(0, 1)) // Simulate successful update
+					WithArgs(tt.newBalance, tt.userID, model.USD, tt.version).
+					WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
 			} else {
 				mock.ExpectExec(query).
-					WithArgs(tt.newBalance, tt.userID).
+					WithArgs(tt.newBalance, tt.userID, model.USD, tt.version).
 					WillReturnError(tt.mockError) // Simulate error
 			}
 
-			// Mock transaction Commit or Rollback
-			if tt.expectError {
+			// Mock transaction Commit or Rollback; a version conflict is not a SQL error
+			if tt.mockError != nil {
 				mock.ExpectRollback()
 			} else {
 				mock.ExpectCommit()
 			}
 
-			err := repo.UpdateBalance(context.Background(), tt.userID, tt.newBalance)
+			err := repo.UpdateBalance(context.Background(), tt.userID, model.USD, tt.newBalance, tt.version)
 
 			if tt.expectError {
 				if err == nil {
@@ -157,6 +329,8 @@ func TestUpdateBalance(t *testing.T) {
 					t.Errorf("Unexpected error: %v", err)
 				}
 			}
+			assert.Equal(t, tt.expectConflict, errors.Is(err, ErrVersionConflict))
+			assert.Equal(t, tt.expectConflict, errors.Is(err, apperror.ErrConflict))
 
 			if err := mock.ExpectationsWereMet(); err != nil {
 				t.Errorf("Unmet expectations: %v", err)
@@ -165,6 +339,376 @@ func TestUpdateBalance(t *testing.T) {
 	}
 }
 
+func TestCreateBalance(t *testing.T) {
+	tests := []struct {
+		name          string
+		mockError     error
+		expectedError error
+	}{
+		{
+			name: "Account opened",
+		},
+		{
+			name:          "Account already exists",
+			mockError:     gorm.ErrDuplicatedKey,
+			expectedError: apperror.ErrConflict,
+		},
+		{
+			name:          "Database error",
+			mockError:     errors.New("database connection error"),
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewBalanceRepository(db)
+
+			mock.ExpectBegin()
+			query := mock.ExpectQuery(`INSERT INTO "balances"`)
+			if tt.mockError == nil {
+				query.WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectCommit()
+			} else {
+				query.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			err := repo.CreateBalance(context.Background(), &model.Balance{UserID: 4, Status: model.AccountStatusActive})
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdateBalanceStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		rowsAffected  int64
+		mockError     error
+		expectedError error
+	}{
+		{
+			name:         "Status updated",
+			rowsAffected: 1,
+		},
+		{
+			name:          "Version conflict",
+			rowsAffected:  0,
+			expectedError: ErrVersionConflict,
+		},
+		{
+			name:          "Database error",
+			mockError:     errors.New("database connection error"),
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewBalanceRepository(db)
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`UPDATE "balances" SET "status"=\$1,"version"=version \+ 1 WHERE user_id = \$2 AND currency = \$3 AND version = \$4`).
+				WithArgs(model.AccountStatusFrozen, 1, model.EUR, 3)
+			if tt.mockError == nil {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+				mock.ExpectCommit()
+			} else {
+				exec.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			err := repo.UpdateBalanceStatus(context.Background(), 1, model.EUR, model.AccountStatusFrozen, 3)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdateBalanceTier(t *testing.T) {
+	tests := []struct {
+		name          string
+		rowsAffected  int64
+		mockError     error
+		expectedError error
+	}{
+		{
+			name:         "Tier updated",
+			rowsAffected: 1,
+		},
+		{
+			name:          "Version conflict",
+			rowsAffected:  0,
+			expectedError: ErrVersionConflict,
+		},
+		{
+			name:          "Database error",
+			mockError:     errors.New("database connection error"),
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewBalanceRepository(db)
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`UPDATE "balances" SET "tier"=\$1,"version"=version \+ 1 WHERE user_id = \$2 AND currency = \$3 AND version = \$4`).
+				WithArgs(model.AccountTierPremium, 1, model.EUR, 3)
+			if tt.mockError == nil {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+				mock.ExpectCommit()
+			} else {
+				exec.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			err := repo.UpdateBalanceTier(context.Background(), 1, model.EUR, model.AccountTierPremium, 3)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdateBalanceProduct(t *testing.T) {
+	tests := []struct {
+		name          string
+		rowsAffected  int64
+		mockError     error
+		expectedError error
+	}{
+		{
+			name:         "Product updated",
+			rowsAffected: 1,
+		},
+		{
+			name:          "Version conflict",
+			rowsAffected:  0,
+			expectedError: ErrVersionConflict,
+		},
+		{
+			name:          "Database error",
+			mockError:     errors.New("database connection error"),
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewBalanceRepository(db)
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`UPDATE "balances" SET "product"=\$1,"version"=version \+ 1 WHERE user_id = \$2 AND currency = \$3 AND version = \$4`).
+				WithArgs(model.AccountProductSavings, 1, model.EUR, 3)
+			if tt.mockError == nil {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+				mock.ExpectCommit()
+			} else {
+				exec.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			err := repo.UpdateBalanceProduct(context.Background(), 1, model.EUR, model.AccountProductSavings, 3)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetBalancesByProduct(t *testing.T) {
+	db, mock := setupMockDB()
+	mock.ExpectQuery(`SELECT \* FROM "balances" WHERE product = \$1 AND \(user_id, currency\) > \(\$2, \$3\) ORDER BY user_id,currency LIMIT \$4`).
+		WithArgs(model.AccountProductSavings, 1, model.USD, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "currency", "balance", "product"}).
+			AddRow(2, "EUR", 5000, model.AccountProductSavings))
+
+	repo := NewBalanceRepository(db)
+	balances, err := repo.GetBalancesByProduct(context.Background(), model.AccountProductSavings, model.BalanceKey{UserID: 1, Currency: model.USD}, 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Balance{{UserID: 2, Currency: model.EUR, Balance: 5000, Product: model.AccountProductSavings}}, balances)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateOverdraftLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		rowsAffected  int64
+		mockError     error
+		expectedError error
+	}{
+		{
+			name:         "Overdraft limit updated",
+			rowsAffected: 1,
+		},
+		{
+			name:          "Version conflict",
+			rowsAffected:  0,
+			expectedError: ErrVersionConflict,
+		},
+		{
+			name:          "Database error",
+			mockError:     errors.New("database connection error"),
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewBalanceRepository(db)
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`UPDATE "balances" SET "overdraft_limit"=\$1,"version"=version \+ 1 WHERE user_id = \$2 AND currency = \$3 AND version = \$4`).
+				WithArgs(50000, 1, model.EUR, 3)
+			if tt.mockError == nil {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+				mock.ExpectCommit()
+			} else {
+				exec.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			err := repo.UpdateOverdraftLimit(context.Background(), 1, model.EUR, 50000, 3)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetBalancesWithOverdraft(t *testing.T) {
+	db, mock := setupMockDB()
+	mock.ExpectQuery(`SELECT \* FROM "balances" WHERE \(overdraft_limit > 0 OR balance < 0\) AND user_id <= \$1 AND \(user_id, currency\) > \(\$2, \$3\) ORDER BY user_id,currency LIMIT \$4`).
+		WithArgs(model.MaxUserID, 1, model.USD, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "currency", "balance", "overdraft_limit"}).
+			AddRow(2, "EUR", 5000, 10000))
+
+	repo := NewBalanceRepository(db)
+	balances, err := repo.GetBalancesWithOverdraft(context.Background(), model.BalanceKey{UserID: 1, Currency: model.USD}, 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Balance{{UserID: 2, Currency: model.EUR, Balance: 5000, OverdraftLimit: 10000}}, balances)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetOverdrawnBalances(t *testing.T) {
+	tests := []struct {
+		name             string
+		mockError        error
+		expectedBalances []model.Balance
+		expectedError    error
+	}{
+		{
+			name:             "Overdrawn balances",
+			expectedBalances: []model.Balance{{UserID: 2, Currency: model.EUR, Balance: -2500, OverdraftLimit: 10000}},
+		},
+		{
+			name:          "Database error",
+			mockError:     errors.New("database connection error"),
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			query := mock.ExpectQuery(`SELECT \* FROM "balances" WHERE balance < 0 AND user_id <= \$1 AND \(user_id, currency\) > \(\$2, \$3\) ORDER BY user_id,currency LIMIT \$4`).
+				WithArgs(model.MaxUserID, 0, "", 100)
+			if tt.mockError == nil {
+				query.WillReturnRows(sqlmock.NewRows([]string{"user_id", "currency", "balance", "overdraft_limit"}).
+					AddRow(2, "EUR", -2500, 10000))
+			} else {
+				query.WillReturnError(tt.mockError)
+			}
+
+			repo := NewBalanceRepository(db)
+			balances, err := repo.GetOverdrawnBalances(context.Background(), model.BalanceKey{}, 100)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.Equal(t, tt.expectedBalances, balances)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdateHeld(t *testing.T) {
+	tests := []struct {
+		name          string
+		rowsAffected  int64
+		mockError     error
+		expectedError error
+	}{
+		{
+			name:         "Held amount updated",
+			rowsAffected: 1,
+		},
+		{
+			name:          "Version conflict",
+			rowsAffected:  0,
+			expectedError: ErrVersionConflict,
+		},
+		{
+			name:          "Database error",
+			mockError:     errors.New("database connection error"),
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewBalanceRepository(db)
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`UPDATE "balances" SET "held"=\$1,"version"=version \+ 1 WHERE user_id = \$2 AND currency = \$3 AND version = \$4`).
+				WithArgs(2500, 1, model.EUR, 3)
+			if tt.mockError == nil {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+				mock.ExpectCommit()
+			} else {
+				exec.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			err := repo.UpdateHeld(context.Background(), 1, model.EUR, 2500, 3)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestNewBalanceRepository(t *testing.T) {
 	// Setup mock DB
 	gormDB, _ := setupMockDB()
@@ -186,7 +730,7 @@ func TestNewMockBalanceRepository(t *testing.T) {
 	mockCalled := false
 	repo = NewMockBalanceRepository(func(m *mock.Mock) {
 		mockCalled = true
-		m.On("GetBalance", mock.Anything, uint(1)).Return(100.0, nil)
+		m.On("GetBalance", mock.Anything, uint(1), model.USD).Return(model.Money(100), nil)
 	})
 
 	// Assertions
@@ -194,7 +738,7 @@ func TestNewMockBalanceRepository(t *testing.T) {
 	assert.True(t, mockCalled)
 
 	// Test that the mock works as expected
-	balance, err := repo.GetBalance(context.Background(), 1)
+	balance, err := repo.GetBalance(context.Background(), 1, model.USD)
 	assert.NoError(t, err)
-	assert.Equal(t, 100.0, balance)
+	assert.Equal(t, model.Money(100), balance)
 }
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"walletApp/apperror"
+	"walletApp/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict is matched by errors.Is when a compare-and-swap update loses a race
+var ErrVersionConflict = errors.New("version conflict")
+
+// VersionConflictError is returned when a balance row no longer has the version it was read at.
+// It matches both ErrVersionConflict and apperror.ErrConflict.
+type VersionConflictError struct {
+	UserID  uint
+	Version uint
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("balance for user %d was modified concurrently (expected version %d)", e.UserID, e.Version)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict || target == apperror.ErrConflict
+}
+
+// balanceError translates an error from reading userID's balance row in currency into an apperror
+func balanceError(err error, userID uint, currency model.Currency) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &apperror.AccountNotFoundError{UserID: userID, Currency: string(currency)}
+	}
+	return storageError(err)
+}
+
+// storageError marks a database error as apperror.ErrStorage, keeping the original in the chain.
+// Unique constraint violations are reported as apperror.ErrConflict instead, since retrying the
+// same insert cannot succeed.
+func storageError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("%w: %w", apperror.ErrConflict, err)
+	}
+	return fmt.Errorf("%w: %w", apperror.ErrStorage, err)
+}
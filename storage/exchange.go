@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"context"
+	"time"
+	"walletApp/model"
+)
+
+// ExchangeRepository defines the interface for storing the exchange quotes given to users
+//
+//go:generate mockery --case underscore --name ExchangeRepository
+type ExchangeRepository interface {
+	CreateQuote(ctx context.Context, quote *model.ExchangeQuote) error
+	GetQuote(ctx context.Context, id string) (*model.ExchangeQuote, error)
+	UseQuote(ctx context.Context, id string, journalEntryID uint, now time.Time) error
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+	"walletApp/storage/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type exchangeRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+// NewExchangeRepository creates a new instance of exchangeRepositoryImpl
+func NewExchangeRepository(db *gorm.DB) ExchangeRepository {
+	return &exchangeRepositoryImpl{DB: db}
+}
+
+// NewMockExchangeRepository creates a new instance of ExchangeRepository with mocked methods
+func NewMockExchangeRepository(doMocks ...func(mock *mock.Mock)) ExchangeRepository {
+	mockRepo := &mocks.ExchangeRepository{}
+	for _, mockFunc := range doMocks {
+		mockFunc(&mockRepo.Mock)
+	}
+	return mockRepo
+}
+
+// CreateQuote inserts a new exchange quote
+func (r *exchangeRepositoryImpl) CreateQuote(ctx context.Context, quote *model.ExchangeQuote) error {
+	return storageError(conn(ctx, r.DB).Create(quote).Error)
+}
+
+// GetQuote retrieves an exchange quote by ID. An apperror.ErrNotFound is returned when there is none.
+func (r *exchangeRepositoryImpl) GetQuote(ctx context.Context, id string) (*model.ExchangeQuote, error) {
+	var quote model.ExchangeQuote
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&quote).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: exchange quote %s", apperror.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &quote, nil
+}
+
+// UseQuote records that a quote was posted as the given journal entry. A quote can be used once
+// and only before it expires, so when two exchanges race for the same quote, or the quote
+// expired since it was read, an apperror.ErrConflict is returned.
+func (r *exchangeRepositoryImpl) UseQuote(ctx context.Context, id string, journalEntryID uint, now time.Time) error {
+	result := conn(ctx, r.DB).Model(&model.ExchangeQuote{}).
+		Where("id = ? AND journal_entry_id IS NULL AND expires_at > ?", id, now).
+		Update("journal_entry_id", journalEntryID)
+	if result.Error != nil {
+		return storageError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: exchange quote %s was already used or has expired", apperror.ErrConflict, id)
+	}
+	return nil
+}
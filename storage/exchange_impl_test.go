@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+const testQuoteID = "2f1c6a3e-8b7d-4c1e-9a0b-5d6e7f8a9b0c"
+
+func TestCreateQuote(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	expiresAt := time.Date(2024, 3, 1, 12, 0, 30, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "exchange_quotes" \("id","user_id","from_currency","to_currency","amount","rate","to_amount","spread","expires_at","created_at"\)`).
+		WithArgs(testQuoteID, 1, model.EUR, model.USD, 10000, 108500000, 10796, 54, expiresAt, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"journal_entry_id"}).AddRow(nil))
+	mock.ExpectCommit()
+
+	repo := NewExchangeRepository(gormDB)
+	quote := &model.ExchangeQuote{ID: testQuoteID, UserID: 1, FromCurrency: model.EUR, ToCurrency: model.USD, Amount: 10000, Rate: 108500000, ToAmount: 10796, Spread: 54, ExpiresAt: expiresAt}
+	err := repo.CreateQuote(context.Background(), quote)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetQuote(t *testing.T) {
+	tests := []struct {
+		name          string
+		setupMock     func(sqlmock.Sqlmock)
+		expectedQuote *model.ExchangeQuote
+		expectedError error
+	}{
+		{
+			name: "Open quote",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "exchange_quotes" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(testQuoteID, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "from_currency", "to_currency", "amount", "rate", "to_amount", "spread", "journal_entry_id"}).
+						AddRow(testQuoteID, 1, "EUR", "USD", 10000, 108500000, 10796, 54, nil))
+			},
+			expectedQuote: &model.ExchangeQuote{ID: testQuoteID, UserID: 1, FromCurrency: model.EUR, ToCurrency: model.USD, Amount: 10000, Rate: 108500000, ToAmount: 10796, Spread: 54},
+		},
+		{
+			name: "Unknown quote",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "exchange_quotes" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(testQuoteID, 1).
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedError: apperror.ErrNotFound,
+		},
+		{
+			name: "Database Error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "exchange_quotes" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(testQuoteID, 1).
+					WillReturnError(errors.New("database connection error"))
+			},
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			tt.setupMock(mock)
+
+			repo := NewExchangeRepository(gormDB)
+			quote, err := repo.GetQuote(context.Background(), testQuoteID)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedQuote, quote)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUseQuote(t *testing.T) {
+	tests := []struct {
+		name          string
+		rowsAffected  int64
+		mockError     error
+		expectedError error
+	}{
+		{
+			name:         "Open quote used",
+			rowsAffected: 1,
+		},
+		{
+			name:          "Quote already used or expired",
+			rowsAffected:  0,
+			expectedError: apperror.ErrConflict,
+		},
+		{
+			name:          "Database error",
+			mockError:     errors.New("database connection error"),
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	now := time.Date(2024, 3, 1, 12, 0, 10, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewExchangeRepository(db)
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`UPDATE "exchange_quotes" SET "journal_entry_id"=\$1 WHERE id = \$2 AND journal_entry_id IS NULL AND expires_at > \$3`).
+				WithArgs(42, testQuoteID, now)
+			if tt.mockError == nil {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+				mock.ExpectCommit()
+			} else {
+				exec.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			err := repo.UseQuote(context.Background(), testQuoteID, 42, now)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestNewExchangeRepository(t *testing.T) {
+	gormDB, _ := setupMockDB()
+	repo := NewExchangeRepository(gormDB)
+
+	assert.NotNil(t, repo)
+	assert.IsType(t, &exchangeRepositoryImpl{}, repo)
+}
+
+func TestNewMockExchangeRepository(t *testing.T) {
+	mockCalled := false
+	repo := NewMockExchangeRepository(func(m *mock.Mock) {
+		mockCalled = true
+		m.On("GetQuote", mock.Anything, testQuoteID).Return(&model.ExchangeQuote{ID: testQuoteID}, nil)
+	})
+
+	assert.True(t, mockCalled)
+	quote, err := repo.GetQuote(context.Background(), testQuoteID)
+	assert.NoError(t, err)
+	assert.Equal(t, testQuoteID, quote.ID)
+}
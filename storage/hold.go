@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"context"
+	"time"
+	"walletApp/model"
+)
+
+// HoldRepository defines the interface for storing the holds placed on wallets
+//
+//go:generate mockery --case underscore --name HoldRepository
+type HoldRepository interface {
+	CreateHold(ctx context.Context, hold *model.Hold) error
+	GetHold(ctx context.Context, id uint) (*model.Hold, error)
+	GetExpiredHolds(ctx context.Context, now time.Time, limit int) ([]model.Hold, error)
+	ResolveHold(ctx context.Context, hold *model.Hold) error
+}
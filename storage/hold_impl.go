@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+	"walletApp/storage/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type holdRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+// NewHoldRepository creates a new instance of holdRepositoryImpl
+func NewHoldRepository(db *gorm.DB) HoldRepository {
+	return &holdRepositoryImpl{DB: db}
+}
+
+// NewMockHoldRepository creates a new instance of HoldRepository with mocked methods
+func NewMockHoldRepository(doMocks ...func(mock *mock.Mock)) HoldRepository {
+	mockRepo := &mocks.HoldRepository{}
+	for _, mockFunc := range doMocks {
+		mockFunc(&mockRepo.Mock)
+	}
+	return mockRepo
+}
+
+// CreateHold inserts a new hold
+func (r *holdRepositoryImpl) CreateHold(ctx context.Context, hold *model.Hold) error {
+	return storageError(conn(ctx, r.DB).Create(hold).Error)
+}
+
+// GetHold retrieves a hold by ID. An apperror.ErrNotFound is returned when there is none.
+func (r *holdRepositoryImpl) GetHold(ctx context.Context, id uint) (*model.Hold, error) {
+	var hold model.Hold
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&hold).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: hold %d", apperror.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &hold, nil
+}
+
+// GetExpiredHolds retrieves up to limit authorized holds that expired by now, the longest
+// expired first
+func (r *holdRepositoryImpl) GetExpiredHolds(ctx context.Context, now time.Time, limit int) ([]model.Hold, error) {
+	var holds []model.Hold
+	err := conn(ctx, r.DB).
+		Where("status = ? AND expires_at <= ?", model.HoldStatusAuthorized, now).
+		Order("expires_at").Order("id").Limit(limit).
+		Find(&holds).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return holds, nil
+}
+
+// ResolveHold stores the outcome of an authorized hold: its status, captured amount, journal
+// entry and resolution time. Only an authorized hold can be resolved, so when a hold is captured,
+// voided or expired concurrently the second attempt gets an apperror.ErrConflict.
+func (r *holdRepositoryImpl) ResolveHold(ctx context.Context, hold *model.Hold) error {
+	updates := map[string]interface{}{
+		"status":          hold.Status,
+		"captured_amount": hold.CapturedAmount,
+		"resolved_at":     hold.ResolvedAt,
+	}
+	// Holds released without paying have no journal entry, which stays null
+	if hold.JournalEntryID != 0 {
+		updates["journal_entry_id"] = hold.JournalEntryID
+	}
+	result := conn(ctx, r.DB).Model(&model.Hold{}).
+		Where("id = ? AND status = ?", hold.ID, model.HoldStatusAuthorized).
+		Updates(updates)
+	if result.Error != nil {
+		return storageError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: hold %d is no longer authorized", apperror.ErrConflict, hold.ID)
+	}
+	return nil
+}
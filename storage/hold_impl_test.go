@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestCreateHold(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	expiresAt := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "holds" \("user_id","merchant_id","amount","currency","captured_amount","status","memo","external_reference","expires_at","created_at","resolved_at"\)`).
+		WithArgs(1, 2, 5000, model.EUR, 0, model.HoldStatusAuthorized, "hotel", "BOOKING-7", expiresAt, sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"journal_entry_id", "id"}).AddRow(nil, 9))
+	mock.ExpectCommit()
+
+	repo := NewHoldRepository(gormDB)
+	hold := &model.Hold{UserID: 1, MerchantID: 2, Amount: 5000, Currency: model.EUR, Memo: "hotel", ExternalReference: "BOOKING-7", ExpiresAt: expiresAt}
+	err := repo.CreateHold(context.Background(), hold)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(9), hold.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetHold(t *testing.T) {
+	tests := []struct {
+		name          string
+		setupMock     func(sqlmock.Sqlmock)
+		expectedHold  *model.Hold
+		expectedError error
+	}{
+		{
+			name: "Authorized hold",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "holds" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(9, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "merchant_id", "amount", "currency", "status", "journal_entry_id"}).
+						AddRow(9, 1, 2, 5000, "EUR", model.HoldStatusAuthorized, nil))
+			},
+			expectedHold: &model.Hold{ID: 9, UserID: 1, MerchantID: 2, Amount: 5000, Currency: model.EUR, Status: model.HoldStatusAuthorized},
+		},
+		{
+			name: "Unknown hold",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "holds" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(9, 1).
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedError: apperror.ErrNotFound,
+		},
+		{
+			name: "Database Error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "holds" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(9, 1).
+					WillReturnError(errors.New("database connection error"))
+			},
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			tt.setupMock(mock)
+
+			repo := NewHoldRepository(gormDB)
+			hold, err := repo.GetHold(context.Background(), 9)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedHold, hold)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetExpiredHolds(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	now := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT \* FROM "holds" WHERE status = \$1 AND expires_at <= \$2 ORDER BY expires_at,id LIMIT \$3`).
+		WithArgs(model.HoldStatusAuthorized, now, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "amount", "currency"}).
+			AddRow(3, 1, 5000, "EUR").
+			AddRow(4, 2, 700, "USD"))
+
+	repo := NewHoldRepository(gormDB)
+	holds, err := repo.GetExpiredHolds(context.Background(), now, 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Hold{
+		{ID: 3, UserID: 1, Amount: 5000, Currency: model.EUR},
+		{ID: 4, UserID: 2, Amount: 700, Currency: model.USD},
+	}, holds)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveHold(t *testing.T) {
+	resolvedAt := time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		hold          *model.Hold
+		expectedSQL   string
+		expectedArgs  []driver.Value
+		rowsAffected  int64
+		mockError     error
+		expectedError error
+	}{
+		{
+			name:         "Captured",
+			hold:         &model.Hold{ID: 9, Status: model.HoldStatusCaptured, CapturedAmount: 4200, JournalEntryID: 42, ResolvedAt: &resolvedAt},
+			expectedSQL:  `UPDATE "holds" SET "captured_amount"=\$1,"journal_entry_id"=\$2,"resolved_at"=\$3,"status"=\$4 WHERE id = \$5 AND status = \$6`,
+			expectedArgs: []driver.Value{4200, 42, resolvedAt, model.HoldStatusCaptured, 9, model.HoldStatusAuthorized},
+			rowsAffected: 1,
+		},
+		{
+			name:         "Voided without a journal entry",
+			hold:         &model.Hold{ID: 9, Status: model.HoldStatusVoided, ResolvedAt: &resolvedAt},
+			expectedSQL:  `UPDATE "holds" SET "captured_amount"=\$1,"resolved_at"=\$2,"status"=\$3 WHERE id = \$4 AND status = \$5`,
+			expectedArgs: []driver.Value{0, resolvedAt, model.HoldStatusVoided, 9, model.HoldStatusAuthorized},
+			rowsAffected: 1,
+		},
+		{
+			name:          "No longer authorized",
+			hold:          &model.Hold{ID: 9, Status: model.HoldStatusVoided, ResolvedAt: &resolvedAt},
+			expectedSQL:   `UPDATE "holds" SET "captured_amount"=\$1,"resolved_at"=\$2,"status"=\$3 WHERE id = \$4 AND status = \$5`,
+			expectedArgs:  []driver.Value{0, resolvedAt, model.HoldStatusVoided, 9, model.HoldStatusAuthorized},
+			rowsAffected:  0,
+			expectedError: apperror.ErrConflict,
+		},
+		{
+			name:          "Database error",
+			hold:          &model.Hold{ID: 9, Status: model.HoldStatusVoided, ResolvedAt: &resolvedAt},
+			expectedSQL:   `UPDATE "holds" SET "captured_amount"=\$1,"resolved_at"=\$2,"status"=\$3 WHERE id = \$4 AND status = \$5`,
+			expectedArgs:  []driver.Value{0, resolvedAt, model.HoldStatusVoided, 9, model.HoldStatusAuthorized},
+			mockError:     errors.New("database connection error"),
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewHoldRepository(db)
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(tt.expectedSQL).WithArgs(tt.expectedArgs...)
+			if tt.mockError == nil {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+				mock.ExpectCommit()
+			} else {
+				exec.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			err := repo.ResolveHold(context.Background(), tt.hold)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestNewHoldRepository(t *testing.T) {
+	gormDB, _ := setupMockDB()
+	repo := NewHoldRepository(gormDB)
+
+	assert.NotNil(t, repo)
+	assert.IsType(t, &holdRepositoryImpl{}, repo)
+}
+
+func TestNewMockHoldRepository(t *testing.T) {
+	mockCalled := false
+	repo := NewMockHoldRepository(func(m *mock.Mock) {
+		mockCalled = true
+		m.On("GetHold", mock.Anything, uint(9)).Return(&model.Hold{ID: 9}, nil)
+	})
+
+	assert.True(t, mockCalled)
+	hold, err := repo.GetHold(context.Background(), 9)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(9), hold.ID)
+}
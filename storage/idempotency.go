@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"context"
+	"walletApp/model"
+)
+
+// IdempotencyRepository defines the interface for idempotency record operations
+//
+//go:generate mockery --case underscore --name IdempotencyRepository
+type IdempotencyRepository interface {
+	GetIdempotencyRecord(ctx context.Context, userID uint, key string) (*model.IdempotencyRecord, error)
+	CreateIdempotencyRecord(ctx context.Context, record *model.IdempotencyRecord) error
+}
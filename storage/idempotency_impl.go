@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"walletApp/model"
+	"walletApp/storage/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type idempotencyRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+// NewIdempotencyRepository creates a new instance of idempotencyRepositoryImpl
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepositoryImpl{DB: db}
+}
+
+// NewMockIdempotencyRepository creates a new instance of IdempotencyRepository with mocked methods
+func NewMockIdempotencyRepository(doMocks ...func(mock *mock.Mock)) IdempotencyRepository {
+	mockRepo := &mocks.IdempotencyRepository{}
+	for _, mockFunc := range doMocks {
+		mockFunc(&mockRepo.Mock)
+	}
+	return mockRepo
+}
+
+// GetIdempotencyRecord retrieves the record the user stored under key, or nil if the user has not
+// used the key
+func (r *idempotencyRepositoryImpl) GetIdempotencyRecord(ctx context.Context, userID uint, key string) (*model.IdempotencyRecord, error) {
+	var record model.IdempotencyRecord
+	err := conn(ctx, r.DB).Where("user_id = ? AND key = ?", userID, key).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &record, nil
+}
+
+// CreateIdempotencyRecord stores a new record. The key is unique per user, so a concurrent
+// request of the same user that already stored the same key makes this fail.
+func (r *idempotencyRepositoryImpl) CreateIdempotencyRecord(ctx context.Context, record *model.IdempotencyRecord) error {
+	return storageError(conn(ctx, r.DB).Create(record).Error)
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"walletApp/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestGetIdempotencyRecord(t *testing.T) {
+	tests := []struct {
+		name           string
+		key            string
+		setupMock      func(sqlmock.Sqlmock)
+		expectedRecord *model.IdempotencyRecord
+		expectError    bool
+	}{
+		{
+			name: "Stored record",
+			key:  "deposit-1",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "idempotency_records" WHERE user_id = \$1 AND key = \$2 .* LIMIT \$3`).
+					WithArgs(7, "deposit-1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "key", "operation", "fingerprint", "response"}).
+						AddRow(1, 7, "deposit-1", "deposit", "abc", `{"success":true}`))
+			},
+			expectedRecord: &model.IdempotencyRecord{ID: 1, UserID: 7, Key: "deposit-1", Operation: "deposit", Fingerprint: "abc", Response: `{"success":true}`},
+			expectError:    false,
+		},
+		{
+			name: "Unused key",
+			key:  "deposit-2",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "idempotency_records" WHERE user_id = \$1 AND key = \$2 .* LIMIT \$3`).
+					WithArgs(7, "deposit-2", 1).
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedRecord: nil,
+			expectError:    false,
+		},
+		{
+			name: "Database Error",
+			key:  "deposit-3",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "idempotency_records" WHERE user_id = \$1 AND key = \$2 .* LIMIT \$3`).
+					WithArgs(7, "deposit-3", 1).
+					WillReturnError(errors.New("database connection error"))
+			},
+			expectedRecord: nil,
+			expectError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			tt.setupMock(mock)
+
+			repo := NewIdempotencyRepository(gormDB)
+			record, err := repo.GetIdempotencyRecord(context.Background(), 7, tt.key)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedRecord, record)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateIdempotencyRecord(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "idempotency_records"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	repo := NewIdempotencyRepository(gormDB)
+	err := repo.CreateIdempotencyRecord(context.Background(), &model.IdempotencyRecord{
+		UserID:      7,
+		Key:         "deposit-1",
+		Operation:   "deposit",
+		Fingerprint: "abc",
+		Response:    `{"success":true}`,
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewIdempotencyRepository(t *testing.T) {
+	gormDB, _ := setupMockDB()
+	repo := NewIdempotencyRepository(gormDB)
+
+	assert.NotNil(t, repo)
+	assert.IsType(t, &idempotencyRepositoryImpl{}, repo)
+}
+
+func TestNewMockIdempotencyRepository(t *testing.T) {
+	repo := NewMockIdempotencyRepository()
+	assert.NotNil(t, repo)
+
+	mockCalled := false
+	repo = NewMockIdempotencyRepository(func(m *mock.Mock) {
+		mockCalled = true
+		m.On("GetIdempotencyRecord", mock.Anything, uint(7), "deposit-1").Return(nil, nil)
+	})
+
+	assert.NotNil(t, repo)
+	assert.True(t, mockCalled)
+
+	record, err := repo.GetIdempotencyRecord(context.Background(), 7, "deposit-1")
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"context"
+	"time"
+	"walletApp/model"
+)
+
+// InterestRepository defines the interface for storing the interest savings wallets accrue
+//
+//go:generate mockery --case underscore --name InterestRepository
+type InterestRepository interface {
+	CreateAccrual(ctx context.Context, accrual *model.InterestAccrual) error
+	GetLastAccrual(ctx context.Context, userID uint, currency model.Currency) (*model.InterestAccrual, error)
+	GetUnpaidBalances(ctx context.Context, before time.Time) ([]model.BalanceKey, error)
+	GetUnpaidAccruals(ctx context.Context, userID uint, currency model.Currency, before time.Time) ([]model.InterestAccrual, error)
+	MarkAccrualsPaid(ctx context.Context, ids []uint, journalEntryID uint, paidAt time.Time) error
+}
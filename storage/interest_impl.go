@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+	"walletApp/storage/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type interestRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+// NewInterestRepository creates a new instance of interestRepositoryImpl
+func NewInterestRepository(db *gorm.DB) InterestRepository {
+	return &interestRepositoryImpl{DB: db}
+}
+
+// NewMockInterestRepository creates a new instance of InterestRepository with mocked methods
+func NewMockInterestRepository(doMocks ...func(mock *mock.Mock)) InterestRepository {
+	mockRepo := &mocks.InterestRepository{}
+	for _, mockFunc := range doMocks {
+		mockFunc(&mockRepo.Mock)
+	}
+	return mockRepo
+}
+
+// CreateAccrual inserts a new accrual. Each balance accrues a day once, so accruing a day again
+// gets an apperror.ErrConflict.
+func (r *interestRepositoryImpl) CreateAccrual(ctx context.Context, accrual *model.InterestAccrual) error {
+	return storageError(conn(ctx, r.DB).Create(accrual).Error)
+}
+
+// GetLastAccrual retrieves the accrual of the latest day the user's balance in currency
+// accrued, or nil if it never did
+func (r *interestRepositoryImpl) GetLastAccrual(ctx context.Context, userID uint, currency model.Currency) (*model.InterestAccrual, error) {
+	var accrual model.InterestAccrual
+	err := conn(ctx, r.DB).Where("user_id = ? AND currency = ?", userID, currency).Order("day DESC").First(&accrual).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &accrual, nil
+}
+
+// GetUnpaidBalances retrieves the balances with accruals of days before before that were not
+// paid out yet, ordered by user and currency
+func (r *interestRepositoryImpl) GetUnpaidBalances(ctx context.Context, before time.Time) ([]model.BalanceKey, error) {
+	var keys []model.BalanceKey
+	err := conn(ctx, r.DB).Model(&model.InterestAccrual{}).
+		Distinct("user_id", "currency").
+		Where("paid_at IS NULL AND day < ?", before).
+		Order("user_id").Order("currency").
+		Scan(&keys).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return keys, nil
+}
+
+// GetUnpaidAccruals retrieves the accruals of the user's balance in currency for days before
+// before that were not paid out yet, oldest first
+func (r *interestRepositoryImpl) GetUnpaidAccruals(ctx context.Context, userID uint, currency model.Currency, before time.Time) ([]model.InterestAccrual, error) {
+	var accruals []model.InterestAccrual
+	err := conn(ctx, r.DB).
+		Where("user_id = ? AND currency = ? AND paid_at IS NULL AND day < ?", userID, currency, before).
+		Order("day").
+		Find(&accruals).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return accruals, nil
+}
+
+// MarkAccrualsPaid records the accruals with ids as paid out at paidAt by the journal entry
+// journalEntryID, which is zero when they added up to less than a minor unit. An
+// apperror.ErrConflict is returned when any of them was already paid, so that the payout
+// posted in the same unit of work is rolled back.
+func (r *interestRepositoryImpl) MarkAccrualsPaid(ctx context.Context, ids []uint, journalEntryID uint, paidAt time.Time) error {
+	var entryID interface{}
+	if journalEntryID != 0 {
+		entryID = journalEntryID
+	}
+	result := conn(ctx, r.DB).Model(&model.InterestAccrual{}).
+		Where("id IN ? AND paid_at IS NULL", ids).
+		Updates(map[string]interface{}{"journal_entry_id": entryID, "paid_at": paidAt})
+	if result.Error != nil {
+		return storageError(result.Error)
+	}
+	if result.RowsAffected != int64(len(ids)) {
+		return fmt.Errorf("%w: interest accruals were paid out concurrently", apperror.ErrConflict)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestCreateAccrual(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		mockError     error
+		expectedError error
+	}{
+		{name: "Accrued"},
+		{name: "Day already accrued", mockError: gorm.ErrDuplicatedKey, expectedError: apperror.ErrConflict},
+		{name: "Database error", mockError: errors.New("database connection error"), expectedError: apperror.ErrStorage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			mock.ExpectBegin()
+			query := mock.ExpectQuery(`INSERT INTO "interest_accruals" \("user_id","currency","day","balance","rate_basis_points","amount","paid_at","created_at"\)`).
+				WithArgs(1, model.USD, day, 100000, 200, 5479452, nil, sqlmock.AnyArg())
+			if tt.mockError == nil {
+				query.WillReturnRows(sqlmock.NewRows([]string{"journal_entry_id", "id"}).AddRow(nil, 7))
+				mock.ExpectCommit()
+			} else {
+				query.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			repo := NewInterestRepository(gormDB)
+			accrual := &model.InterestAccrual{UserID: 1, Currency: model.USD, Day: day, Balance: 100000, RateBasisPoints: 200, Amount: 5479452}
+			err := repo.CreateAccrual(context.Background(), accrual)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, uint(7), accrual.ID)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetLastAccrual(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		setupMock       func(sqlmock.Sqlmock)
+		expectedAccrual *model.InterestAccrual
+		expectedError   error
+	}{
+		{
+			name: "Accrued before",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "interest_accruals" WHERE user_id = \$1 AND currency = \$2 ORDER BY day DESC,"interest_accruals"."id" LIMIT \$3`).
+					WithArgs(1, model.USD, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "currency", "day"}).AddRow(7, 1, "USD", day))
+			},
+			expectedAccrual: &model.InterestAccrual{ID: 7, UserID: 1, Currency: model.USD, Day: day},
+		},
+		{
+			name: "Never accrued",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "interest_accruals" WHERE user_id = \$1 AND currency = \$2 ORDER BY day DESC,"interest_accruals"."id" LIMIT \$3`).
+					WithArgs(1, model.USD, 1).
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+		},
+		{
+			name: "Database Error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "interest_accruals" WHERE user_id = \$1 AND currency = \$2 ORDER BY day DESC,"interest_accruals"."id" LIMIT \$3`).
+					WithArgs(1, model.USD, 1).
+					WillReturnError(errors.New("database connection error"))
+			},
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			tt.setupMock(mock)
+
+			repo := NewInterestRepository(gormDB)
+			accrual, err := repo.GetLastAccrual(context.Background(), 1, model.USD)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedAccrual, accrual)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetUnpaidBalances(t *testing.T) {
+	before := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	gormDB, mock := setupMockDB()
+	mock.ExpectQuery(`SELECT DISTINCT "user_id","currency" FROM "interest_accruals" WHERE paid_at IS NULL AND day < \$1 ORDER BY user_id,currency`).
+		WithArgs(before).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "currency"}).AddRow(1, "EUR").AddRow(1, "USD"))
+
+	repo := NewInterestRepository(gormDB)
+	keys, err := repo.GetUnpaidBalances(context.Background(), before)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.BalanceKey{{UserID: 1, Currency: model.EUR}, {UserID: 1, Currency: model.USD}}, keys)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUnpaidAccruals(t *testing.T) {
+	before := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	gormDB, mock := setupMockDB()
+	mock.ExpectQuery(`SELECT \* FROM "interest_accruals" WHERE user_id = \$1 AND currency = \$2 AND paid_at IS NULL AND day < \$3 ORDER BY day`).
+		WithArgs(1, model.USD, before).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "amount"}).AddRow(7, 1, 5479452))
+
+	repo := NewInterestRepository(gormDB)
+	accruals, err := repo.GetUnpaidAccruals(context.Background(), 1, model.USD, before)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.InterestAccrual{{ID: 7, UserID: 1, Amount: 5479452}}, accruals)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkAccrualsPaid(t *testing.T) {
+	paidAt := time.Date(2024, 4, 1, 0, 5, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		journalEntryID uint
+		expectedArgs   []driver.Value
+		rowsAffected   int64
+		mockError      error
+		expectedError  error
+	}{
+		{
+			name:           "Paid",
+			journalEntryID: 42,
+			expectedArgs:   []driver.Value{42, paidAt, 7, 8},
+			rowsAffected:   2,
+		},
+		{
+			name:         "Less than a minor unit",
+			expectedArgs: []driver.Value{nil, paidAt, 7, 8},
+			rowsAffected: 2,
+		},
+		{
+			name:           "Paid concurrently",
+			journalEntryID: 42,
+			expectedArgs:   []driver.Value{42, paidAt, 7, 8},
+			rowsAffected:   1,
+			expectedError:  apperror.ErrConflict,
+		},
+		{
+			name:           "Database error",
+			journalEntryID: 42,
+			expectedArgs:   []driver.Value{42, paidAt, 7, 8},
+			mockError:      errors.New("database connection error"),
+			expectedError:  apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewInterestRepository(db)
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`UPDATE "interest_accruals" SET "journal_entry_id"=\$1,"paid_at"=\$2 WHERE id IN \(\$3,\$4\) AND paid_at IS NULL`).
+				WithArgs(tt.expectedArgs...)
+			if tt.mockError == nil {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+				mock.ExpectCommit()
+			} else {
+				exec.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			err := repo.MarkAccrualsPaid(context.Background(), []uint{7, 8}, tt.journalEntryID, paidAt)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestNewInterestRepository(t *testing.T) {
+	gormDB, _ := setupMockDB()
+	repo := NewInterestRepository(gormDB)
+
+	assert.NotNil(t, repo)
+	assert.IsType(t, &interestRepositoryImpl{}, repo)
+}
+
+func TestNewMockInterestRepository(t *testing.T) {
+	mockCalled := false
+	repo := NewMockInterestRepository(func(m *mock.Mock) {
+		mockCalled = true
+		m.On("GetLastAccrual", mock.Anything, uint(1), model.USD).Return(&model.InterestAccrual{ID: 7}, nil)
+	})
+
+	assert.True(t, mockCalled)
+	accrual, err := repo.GetLastAccrual(context.Background(), 1, model.USD)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), accrual.ID)
+}
@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"context"
+	"walletApp/model"
+)
+
+// JournalRepository defines the interface for double-entry journal operations
+//
+//go:generate mockery --case underscore --name JournalRepository
+type JournalRepository interface {
+	CreateJournalEntry(ctx context.Context, entry *model.JournalEntry) error
+	SumPostingsByUserID(ctx context.Context, userID uint, currency model.Currency) (model.Money, error)
+	GetJournalEntry(ctx context.Context, id uint) (*model.JournalEntry, error)
+	AddReversedAmount(ctx context.Context, id uint, amount model.Money) (model.Money, error)
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"walletApp/apperror"
+	"walletApp/model"
+	"walletApp/storage/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type journalRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+// NewJournalRepository creates a new instance of journalRepositoryImpl
+func NewJournalRepository(db *gorm.DB) JournalRepository {
+	return &journalRepositoryImpl{DB: db}
+}
+
+// NewMockJournalRepository creates a new instance of JournalRepository with mocked methods
+func NewMockJournalRepository(doMocks ...func(mock *mock.Mock)) JournalRepository {
+	mockRepo := &mocks.JournalRepository{}
+	for _, mockFunc := range doMocks {
+		mockFunc(&mockRepo.Mock)
+	}
+	return mockRepo
+}
+
+// CreateJournalEntry inserts a journal entry together with its postings
+func (r *journalRepositoryImpl) CreateJournalEntry(ctx context.Context, entry *model.JournalEntry) error {
+	return storageError(conn(ctx, r.DB).Create(entry).Error)
+}
+
+// SumPostingsByUserID returns the sum of every posting made to the user's balance in currency,
+// which is what that balance must be
+func (r *journalRepositoryImpl) SumPostingsByUserID(ctx context.Context, userID uint, currency model.Currency) (model.Money, error) {
+	var total model.Money
+	err := conn(ctx, r.DB).Model(&model.Posting{}).Where("user_id = ? AND currency = ?", userID, currency).Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+	return total, storageError(err)
+}
+
+// GetJournalEntry retrieves a journal entry by ID together with its postings. An
+// apperror.ErrNotFound is returned when there is none.
+func (r *journalRepositoryImpl) GetJournalEntry(ctx context.Context, id uint) (*model.JournalEntry, error) {
+	var entry model.JournalEntry
+	err := conn(ctx, r.DB).Preload("Postings").Where("id = ?", id).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: journal entry %d", apperror.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &entry, nil
+}
+
+// AddReversedAmount records that amount more of a journal entry has been reversed or refunded
+// and returns how much of it has been reversed in total. The total may not exceed the money the
+// entry moved, its fees left out as in model.JournalEntry.Amount, so when two reversals of the
+// same entry race, the one that would go over gets an apperror.ErrConflict.
+func (r *journalRepositoryImpl) AddReversedAmount(ctx context.Context, id uint, amount model.Money) (model.Money, error) {
+	var entry model.JournalEntry
+	credits := conn(ctx, r.DB).Model(&model.Posting{}).Select("COALESCE(SUM(amount), 0)").Where("journal_entry_id = ? AND amount > 0 AND type NOT IN ?", id, model.FeeTypes())
+	result := conn(ctx, r.DB).Model(&entry).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "reversed_amount"}}}).
+		Where("id = ? AND reversed_amount + ? <= (?)", id, amount, credits).
+		Update("reversed_amount", gorm.Expr("reversed_amount + ?", amount))
+	if result.Error != nil {
+		return 0, storageError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return 0, fmt.Errorf("%w: journal entry %d cannot be reversed by another %s", apperror.ErrConflict, id, amount)
+	}
+	return entry.ReversedAmount, nil
+}
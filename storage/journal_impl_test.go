@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"walletApp/apperror"
+	"walletApp/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestCreateJournalEntry(t *testing.T) {
+	tests := []struct {
+		name        string
+		setupMock   func(sqlmock.Sqlmock)
+		expectError bool
+	}{
+		{
+			name: "Entry and postings are inserted together",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "journal_entries"`).
+					WillReturnRows(sqlmock.NewRows([]string{"created_at", "id"}).AddRow(nil, 7))
+				mock.ExpectQuery(`INSERT INTO "postings" .* VALUES \(\$1,\$2,\$3,\$4,\$5\),\(\$6,\$7,\$8,\$9,\$10\)`).
+					WithArgs(7, model.SystemAccountCashIn, model.TransactionTypeDeposit, -500, model.EUR, 7, 1, model.TransactionTypeDeposit, 500, model.EUR).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+				mock.ExpectCommit()
+			},
+			expectError: false,
+		},
+		{
+			name: "Database Error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`INSERT INTO "journal_entries"`).
+					WillReturnError(errors.New("database connection error"))
+				mock.ExpectRollback()
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			tt.setupMock(mock)
+
+			repo := NewJournalRepository(gormDB)
+			entry := &model.JournalEntry{
+				Description: "deposit to user 1",
+				Postings: []model.Posting{
+					{UserID: model.SystemAccountCashIn, Type: model.TransactionTypeDeposit, Amount: -500, Currency: model.EUR},
+					{UserID: 1, Type: model.TransactionTypeDeposit, Amount: 500, Currency: model.EUR},
+				},
+			}
+			err := repo.CreateJournalEntry(context.Background(), entry)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, uint(7), entry.ID)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSumPostingsByUserID(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) FROM "postings" WHERE user_id = \$1 AND currency = \$2`).
+		WithArgs(1, model.EUR).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow([]byte("1500")))
+
+	repo := NewJournalRepository(gormDB)
+	total, err := repo.SumPostingsByUserID(context.Background(), 1, model.EUR)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.Money(1500), total)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetJournalEntry(t *testing.T) {
+	t.Run("Entry with postings", func(t *testing.T) {
+		gormDB, mock := setupMockDB()
+		mock.ExpectQuery(`SELECT \* FROM "journal_entries" WHERE id = \$1 .* LIMIT \$2`).
+			WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "description", "reversed_amount"}).AddRow(3, "transfer from user 1 to user 2", 200))
+		mock.ExpectQuery(`SELECT \* FROM "postings" WHERE "postings"."journal_entry_id" = \$1`).
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "journal_entry_id", "user_id", "type", "amount"}).
+				AddRow(5, 3, 1, model.TransactionTypeTransferSend, -500).
+				AddRow(6, 3, 2, model.TransactionTypeTransferReceive, 500))
+
+		repo := NewJournalRepository(gormDB)
+		entry, err := repo.GetJournalEntry(context.Background(), 3)
+
+		assert.NoError(t, err)
+		assert.Equal(t, model.Money(200), entry.ReversedAmount)
+		assert.Equal(t, []model.Posting{
+			{ID: 5, JournalEntryID: 3, UserID: 1, Type: model.TransactionTypeTransferSend, Amount: -500},
+			{ID: 6, JournalEntryID: 3, UserID: 2, Type: model.TransactionTypeTransferReceive, Amount: 500},
+		}, entry.Postings)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown entry", func(t *testing.T) {
+		gormDB, mock := setupMockDB()
+		mock.ExpectQuery(`SELECT \* FROM "journal_entries" WHERE id = \$1 .* LIMIT \$2`).
+			WithArgs(3, 1).
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		repo := NewJournalRepository(gormDB)
+		entry, err := repo.GetJournalEntry(context.Background(), 3)
+
+		assert.ErrorIs(t, err, apperror.ErrNotFound)
+		assert.Nil(t, entry)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAddReversedAmount(t *testing.T) {
+	tests := []struct {
+		name          string
+		rowsAffected  int64
+		mockError     error
+		expectedTotal model.Money
+		expectedError error
+	}{
+		{
+			name:          "Amount left to reverse",
+			rowsAffected:  1,
+			expectedTotal: 300,
+		},
+		{
+			name:          "Entry already reversed",
+			rowsAffected:  0,
+			expectedError: apperror.ErrConflict,
+		},
+		{
+			name:          "Database error",
+			mockError:     errors.New("database connection error"),
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			repo := NewJournalRepository(gormDB)
+
+			mock.ExpectBegin()
+			// The cap leaves out the fee postings, which are never given back
+			query := mock.ExpectQuery(`UPDATE "journal_entries" SET "reversed_amount"=reversed_amount \+ \$1 WHERE id = \$2 AND reversed_amount \+ \$3 <= \(SELECT COALESCE\(SUM\(amount\), 0\) FROM "postings" WHERE journal_entry_id = \$4 AND amount > 0 AND type NOT IN \(\$5,\$6\)\) RETURNING "reversed_amount"`).
+				WithArgs(200, 3, 200, 3, model.TransactionTypeFee, model.TransactionTypeOverdraftFee)
+			if tt.mockError == nil {
+				rows := sqlmock.NewRows([]string{"reversed_amount"})
+				if tt.rowsAffected > 0 {
+					rows.AddRow(300)
+				}
+				query.WillReturnRows(rows)
+				mock.ExpectCommit()
+			} else {
+				query.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			total, err := repo.AddReversedAmount(context.Background(), 3, 200)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.Equal(t, tt.expectedTotal, total)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestNewJournalRepository(t *testing.T) {
+	gormDB, _ := setupMockDB()
+	repo := NewJournalRepository(gormDB)
+
+	assert.NotNil(t, repo)
+	assert.IsType(t, &journalRepositoryImpl{}, repo)
+}
+
+func TestNewMockJournalRepository(t *testing.T) {
+	repo := NewMockJournalRepository()
+	assert.NotNil(t, repo)
+
+	mockCalled := false
+	repo = NewMockJournalRepository(func(m *mock.Mock) {
+		mockCalled = true
+		m.On("SumPostingsByUserID", mock.Anything, uint(1), model.EUR).Return(model.Money(0), nil)
+	})
+
+	assert.NotNil(t, repo)
+	assert.True(t, mockCalled)
+
+	total, err := repo.SumPostingsByUserID(context.Background(), 1, model.EUR)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Money(0), total)
+}
@@ -0,0 +1,125 @@
+// Code generated by mockery v2.53.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	model "walletApp/model"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// AdjustmentRepository is an autogenerated mock type for the AdjustmentRepository type
+type AdjustmentRepository struct {
+	mock.Mock
+}
+
+// CreateAdjustment provides a mock function with given fields: ctx, adjustment
+func (_m *AdjustmentRepository) CreateAdjustment(ctx context.Context, adjustment *model.Adjustment) error {
+	ret := _m.Called(ctx, adjustment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateAdjustment")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Adjustment) error); ok {
+		r0 = rf(ctx, adjustment)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAdjustment provides a mock function with given fields: ctx, id
+func (_m *AdjustmentRepository) GetAdjustment(ctx context.Context, id uint) (*model.Adjustment, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAdjustment")
+	}
+
+	var r0 *model.Adjustment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (*model.Adjustment, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *model.Adjustment); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Adjustment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAdjustmentsByStatus provides a mock function with given fields: ctx, status
+func (_m *AdjustmentRepository) GetAdjustmentsByStatus(ctx context.Context, status model.AdjustmentStatus) ([]model.Adjustment, error) {
+	ret := _m.Called(ctx, status)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAdjustmentsByStatus")
+	}
+
+	var r0 []model.Adjustment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AdjustmentStatus) ([]model.Adjustment, error)); ok {
+		return rf(ctx, status)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.AdjustmentStatus) []model.Adjustment); ok {
+		r0 = rf(ctx, status)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Adjustment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.AdjustmentStatus) error); ok {
+		r1 = rf(ctx, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReviewAdjustment provides a mock function with given fields: ctx, adjustment
+func (_m *AdjustmentRepository) ReviewAdjustment(ctx context.Context, adjustment *model.Adjustment) error {
+	ret := _m.Called(ctx, adjustment)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReviewAdjustment")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Adjustment) error); ok {
+		r0 = rf(ctx, adjustment)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewAdjustmentRepository creates a new instance of AdjustmentRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAdjustmentRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *AdjustmentRepository {
+	mock := &AdjustmentRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -4,6 +4,7 @@ package mocks
 
 import (
 	context "context"
+	model "walletApp/model"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -13,23 +14,131 @@ type BalanceRepository struct {
 	mock.Mock
 }
 
-// GetBalance provides a mock function with given fields: ctx, userID
-func (_m *BalanceRepository) GetBalance(ctx context.Context, userID uint) (float64, error) {
-	ret := _m.Called(ctx, userID)
+// CreateBalance provides a mock function with given fields: ctx, balance
+func (_m *BalanceRepository) CreateBalance(ctx context.Context, balance *model.Balance) error {
+	ret := _m.Called(ctx, balance)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateBalance")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Balance) error); ok {
+		r0 = rf(ctx, balance)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetBalance provides a mock function with given fields: ctx, userID, currency
+func (_m *BalanceRepository) GetBalance(ctx context.Context, userID uint, currency model.Currency) (model.Money, error) {
+	ret := _m.Called(ctx, userID, currency)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetBalance")
 	}
 
-	var r0 float64
+	var r0 model.Money
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency) (model.Money, error)); ok {
+		return rf(ctx, userID, currency)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency) model.Money); ok {
+		r0 = rf(ctx, userID, currency)
+	} else {
+		r0 = ret.Get(0).(model.Money)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, model.Currency) error); ok {
+		r1 = rf(ctx, userID, currency)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBalanceForUpdate provides a mock function with given fields: ctx, userID, currency
+func (_m *BalanceRepository) GetBalanceForUpdate(ctx context.Context, userID uint, currency model.Currency) (*model.Balance, error) {
+	ret := _m.Called(ctx, userID, currency)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBalanceForUpdate")
+	}
+
+	var r0 *model.Balance
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency) (*model.Balance, error)); ok {
+		return rf(ctx, userID, currency)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency) *model.Balance); ok {
+		r0 = rf(ctx, userID, currency)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Balance)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, model.Currency) error); ok {
+		r1 = rf(ctx, userID, currency)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBalanceRecord provides a mock function with given fields: ctx, userID, currency
+func (_m *BalanceRepository) GetBalanceRecord(ctx context.Context, userID uint, currency model.Currency) (*model.Balance, error) {
+	ret := _m.Called(ctx, userID, currency)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBalanceRecord")
+	}
+
+	var r0 *model.Balance
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency) (*model.Balance, error)); ok {
+		return rf(ctx, userID, currency)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency) *model.Balance); ok {
+		r0 = rf(ctx, userID, currency)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Balance)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, model.Currency) error); ok {
+		r1 = rf(ctx, userID, currency)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBalances provides a mock function with given fields: ctx, userID
+func (_m *BalanceRepository) GetBalances(ctx context.Context, userID uint) ([]model.Balance, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBalances")
+	}
+
+	var r0 []model.Balance
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, uint) (float64, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, uint) ([]model.Balance, error)); ok {
 		return rf(ctx, userID)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, uint) float64); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, uint) []model.Balance); ok {
 		r0 = rf(ctx, userID)
 	} else {
-		r0 = ret.Get(0).(float64)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Balance)
+		}
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
@@ -41,17 +150,227 @@ func (_m *BalanceRepository) GetBalance(ctx context.Context, userID uint) (float
 	return r0, r1
 }
 
-// UpdateBalance provides a mock function with given fields: ctx, userID, newBalance
-func (_m *BalanceRepository) UpdateBalance(ctx context.Context, userID uint, newBalance float64) error {
-	ret := _m.Called(ctx, userID, newBalance)
+// GetBalancesByProduct provides a mock function with given fields: ctx, product, after, limit
+func (_m *BalanceRepository) GetBalancesByProduct(ctx context.Context, product model.AccountProduct, after model.BalanceKey, limit int) ([]model.Balance, error) {
+	ret := _m.Called(ctx, product, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBalancesByProduct")
+	}
+
+	var r0 []model.Balance
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AccountProduct, model.BalanceKey, int) ([]model.Balance, error)); ok {
+		return rf(ctx, product, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.AccountProduct, model.BalanceKey, int) []model.Balance); ok {
+		r0 = rf(ctx, product, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Balance)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.AccountProduct, model.BalanceKey, int) error); ok {
+		r1 = rf(ctx, product, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBalancesForUpdate provides a mock function with given fields: ctx, userID
+func (_m *BalanceRepository) GetBalancesForUpdate(ctx context.Context, userID uint) ([]model.Balance, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBalancesForUpdate")
+	}
+
+	var r0 []model.Balance
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) ([]model.Balance, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) []model.Balance); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Balance)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBalancesWithOverdraft provides a mock function with given fields: ctx, after, limit
+func (_m *BalanceRepository) GetBalancesWithOverdraft(ctx context.Context, after model.BalanceKey, limit int) ([]model.Balance, error) {
+	ret := _m.Called(ctx, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBalancesWithOverdraft")
+	}
+
+	var r0 []model.Balance
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.BalanceKey, int) ([]model.Balance, error)); ok {
+		return rf(ctx, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.BalanceKey, int) []model.Balance); ok {
+		r0 = rf(ctx, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Balance)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.BalanceKey, int) error); ok {
+		r1 = rf(ctx, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOverdrawnBalances provides a mock function with given fields: ctx, after, limit
+func (_m *BalanceRepository) GetOverdrawnBalances(ctx context.Context, after model.BalanceKey, limit int) ([]model.Balance, error) {
+	ret := _m.Called(ctx, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetOverdrawnBalances")
+	}
+
+	var r0 []model.Balance
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.BalanceKey, int) ([]model.Balance, error)); ok {
+		return rf(ctx, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.BalanceKey, int) []model.Balance); ok {
+		r0 = rf(ctx, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Balance)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.BalanceKey, int) error); ok {
+		r1 = rf(ctx, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateBalance provides a mock function with given fields: ctx, userID, currency, newBalance, version
+func (_m *BalanceRepository) UpdateBalance(ctx context.Context, userID uint, currency model.Currency, newBalance model.Money, version uint) error {
+	ret := _m.Called(ctx, userID, currency, newBalance, version)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateBalance")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, uint, float64) error); ok {
-		r0 = rf(ctx, userID, newBalance)
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, model.Money, uint) error); ok {
+		r0 = rf(ctx, userID, currency, newBalance, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateBalanceProduct provides a mock function with given fields: ctx, userID, currency, product, version
+func (_m *BalanceRepository) UpdateBalanceProduct(ctx context.Context, userID uint, currency model.Currency, product model.AccountProduct, version uint) error {
+	ret := _m.Called(ctx, userID, currency, product, version)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateBalanceProduct")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, model.AccountProduct, uint) error); ok {
+		r0 = rf(ctx, userID, currency, product, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateBalanceStatus provides a mock function with given fields: ctx, userID, currency, status, version
+func (_m *BalanceRepository) UpdateBalanceStatus(ctx context.Context, userID uint, currency model.Currency, status model.AccountStatus, version uint) error {
+	ret := _m.Called(ctx, userID, currency, status, version)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateBalanceStatus")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, model.AccountStatus, uint) error); ok {
+		r0 = rf(ctx, userID, currency, status, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateBalanceTier provides a mock function with given fields: ctx, userID, currency, tier, version
+func (_m *BalanceRepository) UpdateBalanceTier(ctx context.Context, userID uint, currency model.Currency, tier model.AccountTier, version uint) error {
+	ret := _m.Called(ctx, userID, currency, tier, version)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateBalanceTier")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, model.AccountTier, uint) error); ok {
+		r0 = rf(ctx, userID, currency, tier, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateHeld provides a mock function with given fields: ctx, userID, currency, held, version
+func (_m *BalanceRepository) UpdateHeld(ctx context.Context, userID uint, currency model.Currency, held model.Money, version uint) error {
+	ret := _m.Called(ctx, userID, currency, held, version)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateHeld")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, model.Money, uint) error); ok {
+		r0 = rf(ctx, userID, currency, held, version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateOverdraftLimit provides a mock function with given fields: ctx, userID, currency, overdraftLimit, version
+func (_m *BalanceRepository) UpdateOverdraftLimit(ctx context.Context, userID uint, currency model.Currency, overdraftLimit model.Money, version uint) error {
+	ret := _m.Called(ctx, userID, currency, overdraftLimit, version)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateOverdraftLimit")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, model.Money, uint) error); ok {
+		r0 = rf(ctx, userID, currency, overdraftLimit, version)
 	} else {
 		r0 = ret.Error(0)
 	}
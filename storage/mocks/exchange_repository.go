@@ -0,0 +1,97 @@
+// Code generated by mockery v2.53.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	model "walletApp/model"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// ExchangeRepository is an autogenerated mock type for the ExchangeRepository type
+type ExchangeRepository struct {
+	mock.Mock
+}
+
+// CreateQuote provides a mock function with given fields: ctx, quote
+func (_m *ExchangeRepository) CreateQuote(ctx context.Context, quote *model.ExchangeQuote) error {
+	ret := _m.Called(ctx, quote)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateQuote")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.ExchangeQuote) error); ok {
+		r0 = rf(ctx, quote)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetQuote provides a mock function with given fields: ctx, id
+func (_m *ExchangeRepository) GetQuote(ctx context.Context, id string) (*model.ExchangeQuote, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetQuote")
+	}
+
+	var r0 *model.ExchangeQuote
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.ExchangeQuote, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.ExchangeQuote); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ExchangeQuote)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UseQuote provides a mock function with given fields: ctx, id, journalEntryID, now
+func (_m *ExchangeRepository) UseQuote(ctx context.Context, id string, journalEntryID uint, now time.Time) error {
+	ret := _m.Called(ctx, id, journalEntryID, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UseQuote")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint, time.Time) error); ok {
+		r0 = rf(ctx, id, journalEntryID, now)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewExchangeRepository creates a new instance of ExchangeRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewExchangeRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ExchangeRepository {
+	mock := &ExchangeRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,127 @@
+// Code generated by mockery v2.53.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	model "walletApp/model"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// HoldRepository is an autogenerated mock type for the HoldRepository type
+type HoldRepository struct {
+	mock.Mock
+}
+
+// CreateHold provides a mock function with given fields: ctx, hold
+func (_m *HoldRepository) CreateHold(ctx context.Context, hold *model.Hold) error {
+	ret := _m.Called(ctx, hold)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateHold")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Hold) error); ok {
+		r0 = rf(ctx, hold)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetExpiredHolds provides a mock function with given fields: ctx, now, limit
+func (_m *HoldRepository) GetExpiredHolds(ctx context.Context, now time.Time, limit int) ([]model.Hold, error) {
+	ret := _m.Called(ctx, now, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetExpiredHolds")
+	}
+
+	var r0 []model.Hold
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) ([]model.Hold, error)); ok {
+		return rf(ctx, now, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []model.Hold); ok {
+		r0 = rf(ctx, now, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Hold)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, now, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetHold provides a mock function with given fields: ctx, id
+func (_m *HoldRepository) GetHold(ctx context.Context, id uint) (*model.Hold, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetHold")
+	}
+
+	var r0 *model.Hold
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (*model.Hold, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *model.Hold); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Hold)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ResolveHold provides a mock function with given fields: ctx, hold
+func (_m *HoldRepository) ResolveHold(ctx context.Context, hold *model.Hold) error {
+	ret := _m.Called(ctx, hold)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResolveHold")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Hold) error); ok {
+		r0 = rf(ctx, hold)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewHoldRepository creates a new instance of HoldRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewHoldRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *HoldRepository {
+	mock := &HoldRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,77 @@
+// Code generated by mockery v2.53.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	model "walletApp/model"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IdempotencyRepository is an autogenerated mock type for the IdempotencyRepository type
+type IdempotencyRepository struct {
+	mock.Mock
+}
+
+// CreateIdempotencyRecord provides a mock function with given fields: ctx, record
+func (_m *IdempotencyRepository) CreateIdempotencyRecord(ctx context.Context, record *model.IdempotencyRecord) error {
+	ret := _m.Called(ctx, record)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateIdempotencyRecord")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.IdempotencyRecord) error); ok {
+		r0 = rf(ctx, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetIdempotencyRecord provides a mock function with given fields: ctx, userID, key
+func (_m *IdempotencyRepository) GetIdempotencyRecord(ctx context.Context, userID uint, key string) (*model.IdempotencyRecord, error) {
+	ret := _m.Called(ctx, userID, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetIdempotencyRecord")
+	}
+
+	var r0 *model.IdempotencyRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, string) (*model.IdempotencyRecord, error)); ok {
+		return rf(ctx, userID, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, string) *model.IdempotencyRecord); ok {
+		r0 = rf(ctx, userID, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.IdempotencyRecord)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, string) error); ok {
+		r1 = rf(ctx, userID, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIdempotencyRepository creates a new instance of IdempotencyRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIdempotencyRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IdempotencyRepository {
+	mock := &IdempotencyRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,157 @@
+// Code generated by mockery v2.53.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	model "walletApp/model"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// InterestRepository is an autogenerated mock type for the InterestRepository type
+type InterestRepository struct {
+	mock.Mock
+}
+
+// CreateAccrual provides a mock function with given fields: ctx, accrual
+func (_m *InterestRepository) CreateAccrual(ctx context.Context, accrual *model.InterestAccrual) error {
+	ret := _m.Called(ctx, accrual)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateAccrual")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.InterestAccrual) error); ok {
+		r0 = rf(ctx, accrual)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetLastAccrual provides a mock function with given fields: ctx, userID, currency
+func (_m *InterestRepository) GetLastAccrual(ctx context.Context, userID uint, currency model.Currency) (*model.InterestAccrual, error) {
+	ret := _m.Called(ctx, userID, currency)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLastAccrual")
+	}
+
+	var r0 *model.InterestAccrual
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency) (*model.InterestAccrual, error)); ok {
+		return rf(ctx, userID, currency)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency) *model.InterestAccrual); ok {
+		r0 = rf(ctx, userID, currency)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.InterestAccrual)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, model.Currency) error); ok {
+		r1 = rf(ctx, userID, currency)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUnpaidAccruals provides a mock function with given fields: ctx, userID, currency, before
+func (_m *InterestRepository) GetUnpaidAccruals(ctx context.Context, userID uint, currency model.Currency, before time.Time) ([]model.InterestAccrual, error) {
+	ret := _m.Called(ctx, userID, currency, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUnpaidAccruals")
+	}
+
+	var r0 []model.InterestAccrual
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, time.Time) ([]model.InterestAccrual, error)); ok {
+		return rf(ctx, userID, currency, before)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, time.Time) []model.InterestAccrual); ok {
+		r0 = rf(ctx, userID, currency, before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.InterestAccrual)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, model.Currency, time.Time) error); ok {
+		r1 = rf(ctx, userID, currency, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUnpaidBalances provides a mock function with given fields: ctx, before
+func (_m *InterestRepository) GetUnpaidBalances(ctx context.Context, before time.Time) ([]model.BalanceKey, error) {
+	ret := _m.Called(ctx, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUnpaidBalances")
+	}
+
+	var r0 []model.BalanceKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) ([]model.BalanceKey, error)); ok {
+		return rf(ctx, before)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []model.BalanceKey); ok {
+		r0 = rf(ctx, before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.BalanceKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkAccrualsPaid provides a mock function with given fields: ctx, ids, journalEntryID, paidAt
+func (_m *InterestRepository) MarkAccrualsPaid(ctx context.Context, ids []uint, journalEntryID uint, paidAt time.Time) error {
+	ret := _m.Called(ctx, ids, journalEntryID, paidAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkAccrualsPaid")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []uint, uint, time.Time) error); ok {
+		r0 = rf(ctx, ids, journalEntryID, paidAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewInterestRepository creates a new instance of InterestRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewInterestRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *InterestRepository {
+	mock := &InterestRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
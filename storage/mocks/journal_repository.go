@@ -0,0 +1,133 @@
+// Code generated by mockery v2.53.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	model "walletApp/model"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// JournalRepository is an autogenerated mock type for the JournalRepository type
+type JournalRepository struct {
+	mock.Mock
+}
+
+// AddReversedAmount provides a mock function with given fields: ctx, id, amount
+func (_m *JournalRepository) AddReversedAmount(ctx context.Context, id uint, amount model.Money) (model.Money, error) {
+	ret := _m.Called(ctx, id, amount)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddReversedAmount")
+	}
+
+	var r0 model.Money
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Money) (model.Money, error)); ok {
+		return rf(ctx, id, amount)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Money) model.Money); ok {
+		r0 = rf(ctx, id, amount)
+	} else {
+		r0 = ret.Get(0).(model.Money)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, model.Money) error); ok {
+		r1 = rf(ctx, id, amount)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateJournalEntry provides a mock function with given fields: ctx, entry
+func (_m *JournalRepository) CreateJournalEntry(ctx context.Context, entry *model.JournalEntry) error {
+	ret := _m.Called(ctx, entry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateJournalEntry")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.JournalEntry) error); ok {
+		r0 = rf(ctx, entry)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetJournalEntry provides a mock function with given fields: ctx, id
+func (_m *JournalRepository) GetJournalEntry(ctx context.Context, id uint) (*model.JournalEntry, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetJournalEntry")
+	}
+
+	var r0 *model.JournalEntry
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (*model.JournalEntry, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *model.JournalEntry); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.JournalEntry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SumPostingsByUserID provides a mock function with given fields: ctx, userID, currency
+func (_m *JournalRepository) SumPostingsByUserID(ctx context.Context, userID uint, currency model.Currency) (model.Money, error) {
+	ret := _m.Called(ctx, userID, currency)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SumPostingsByUserID")
+	}
+
+	var r0 model.Money
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency) (model.Money, error)); ok {
+		return rf(ctx, userID, currency)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency) model.Money); ok {
+		r0 = rf(ctx, userID, currency)
+	} else {
+		r0 = ret.Get(0).(model.Money)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, model.Currency) error); ok {
+		r1 = rf(ctx, userID, currency)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewJournalRepository creates a new instance of JournalRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewJournalRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *JournalRepository {
+	mock := &JournalRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
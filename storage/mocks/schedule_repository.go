@@ -0,0 +1,157 @@
+// Code generated by mockery v2.53.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	model "walletApp/model"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// ScheduleRepository is an autogenerated mock type for the ScheduleRepository type
+type ScheduleRepository struct {
+	mock.Mock
+}
+
+// CreateSchedule provides a mock function with given fields: ctx, schedule
+func (_m *ScheduleRepository) CreateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	ret := _m.Called(ctx, schedule)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateSchedule")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Schedule) error); ok {
+		r0 = rf(ctx, schedule)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetDueSchedules provides a mock function with given fields: ctx, now, limit
+func (_m *ScheduleRepository) GetDueSchedules(ctx context.Context, now time.Time, limit int) ([]model.Schedule, error) {
+	ret := _m.Called(ctx, now, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDueSchedules")
+	}
+
+	var r0 []model.Schedule
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) ([]model.Schedule, error)); ok {
+		return rf(ctx, now, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []model.Schedule); ok {
+		r0 = rf(ctx, now, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Schedule)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, now, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSchedule provides a mock function with given fields: ctx, id
+func (_m *ScheduleRepository) GetSchedule(ctx context.Context, id uint) (*model.Schedule, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSchedule")
+	}
+
+	var r0 *model.Schedule
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (*model.Schedule, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *model.Schedule); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Schedule)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSchedulesByUserID provides a mock function with given fields: ctx, userID
+func (_m *ScheduleRepository) GetSchedulesByUserID(ctx context.Context, userID uint) ([]model.Schedule, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSchedulesByUserID")
+	}
+
+	var r0 []model.Schedule
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) ([]model.Schedule, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) []model.Schedule); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Schedule)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateSchedule provides a mock function with given fields: ctx, schedule
+func (_m *ScheduleRepository) UpdateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	ret := _m.Called(ctx, schedule)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateSchedule")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Schedule) error); ok {
+		r0 = rf(ctx, schedule)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewScheduleRepository creates a new instance of ScheduleRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewScheduleRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ScheduleRepository {
+	mock := &ScheduleRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -7,6 +7,8 @@ import (
 	model "walletApp/model"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // TransactionRepository is an autogenerated mock type for the TransactionRepository type
@@ -32,21 +34,51 @@ func (_m *TransactionRepository) CreateTransaction(ctx context.Context, transact
 	return r0
 }
 
-// GetTransactionsByUserID provides a mock function with given fields: ctx, userID
-func (_m *TransactionRepository) GetTransactionsByUserID(ctx context.Context, userID uint) ([]model.Transaction, error) {
-	ret := _m.Called(ctx, userID)
+// GetTransaction provides a mock function with given fields: ctx, id
+func (_m *TransactionRepository) GetTransaction(ctx context.Context, id uint) (*model.Transaction, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTransaction")
+	}
+
+	var r0 *model.Transaction
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) (*model.Transaction, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *model.Transaction); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Transaction)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTransactionsByJournalEntryID provides a mock function with given fields: ctx, journalEntryID
+func (_m *TransactionRepository) GetTransactionsByJournalEntryID(ctx context.Context, journalEntryID uint) ([]model.Transaction, error) {
+	ret := _m.Called(ctx, journalEntryID)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetTransactionsByUserID")
+		panic("no return value specified for GetTransactionsByJournalEntryID")
 	}
 
 	var r0 []model.Transaction
 	var r1 error
 	if rf, ok := ret.Get(0).(func(context.Context, uint) ([]model.Transaction, error)); ok {
-		return rf(ctx, userID)
+		return rf(ctx, journalEntryID)
 	}
 	if rf, ok := ret.Get(0).(func(context.Context, uint) []model.Transaction); ok {
-		r0 = rf(ctx, userID)
+		r0 = rf(ctx, journalEntryID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]model.Transaction)
@@ -54,7 +86,93 @@ func (_m *TransactionRepository) GetTransactionsByUserID(ctx context.Context, us
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
-		r1 = rf(ctx, userID)
+		r1 = rf(ctx, journalEntryID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListTransactions provides a mock function with given fields: ctx, query
+func (_m *TransactionRepository) ListTransactions(ctx context.Context, query model.TransactionQuery) ([]model.Transaction, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListTransactions")
+	}
+
+	var r0 []model.Transaction
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.TransactionQuery) ([]model.Transaction, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.TransactionQuery) []model.Transaction); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Transaction)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.TransactionQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SumAmountsSince provides a mock function with given fields: ctx, userID, currency, since
+func (_m *TransactionRepository) SumAmountsSince(ctx context.Context, userID uint, currency model.Currency, since time.Time) (model.Money, error) {
+	ret := _m.Called(ctx, userID, currency, since)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SumAmountsSince")
+	}
+
+	var r0 model.Money
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, time.Time) (model.Money, error)); ok {
+		return rf(ctx, userID, currency, since)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, time.Time) model.Money); ok {
+		r0 = rf(ctx, userID, currency, since)
+	} else {
+		r0 = ret.Get(0).(model.Money)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, model.Currency, time.Time) error); ok {
+		r1 = rf(ctx, userID, currency, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SumOutflows provides a mock function with given fields: ctx, userID, currency, types, since
+func (_m *TransactionRepository) SumOutflows(ctx context.Context, userID uint, currency model.Currency, types []model.TransactionType, since time.Time) (model.Money, error) {
+	ret := _m.Called(ctx, userID, currency, types, since)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SumOutflows")
+	}
+
+	var r0 model.Money
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, []model.TransactionType, time.Time) (model.Money, error)); ok {
+		return rf(ctx, userID, currency, types, since)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint, model.Currency, []model.TransactionType, time.Time) model.Money); ok {
+		r0 = rf(ctx, userID, currency, types, since)
+	} else {
+		r0 = ret.Get(0).(model.Money)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint, model.Currency, []model.TransactionType, time.Time) error); ok {
+		r1 = rf(ctx, userID, currency, types, since)
 	} else {
 		r1 = ret.Error(1)
 	}
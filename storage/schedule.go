@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"context"
+	"time"
+	"walletApp/model"
+)
+
+// ScheduleRepository defines the interface for storing scheduled transfers
+//
+//go:generate mockery --case underscore --name ScheduleRepository
+type ScheduleRepository interface {
+	CreateSchedule(ctx context.Context, schedule *model.Schedule) error
+	GetSchedule(ctx context.Context, id uint) (*model.Schedule, error)
+	GetSchedulesByUserID(ctx context.Context, userID uint) ([]model.Schedule, error)
+	GetDueSchedules(ctx context.Context, now time.Time, limit int) ([]model.Schedule, error)
+	UpdateSchedule(ctx context.Context, schedule *model.Schedule) error
+}
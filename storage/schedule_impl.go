@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+	"walletApp/storage/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type scheduleRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+// NewScheduleRepository creates a new instance of scheduleRepositoryImpl
+func NewScheduleRepository(db *gorm.DB) ScheduleRepository {
+	return &scheduleRepositoryImpl{DB: db}
+}
+
+// NewMockScheduleRepository creates a new instance of ScheduleRepository with mocked methods
+func NewMockScheduleRepository(doMocks ...func(mock *mock.Mock)) ScheduleRepository {
+	mockRepo := &mocks.ScheduleRepository{}
+	for _, mockFunc := range doMocks {
+		mockFunc(&mockRepo.Mock)
+	}
+	return mockRepo
+}
+
+// CreateSchedule inserts a new schedule
+func (r *scheduleRepositoryImpl) CreateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	return storageError(conn(ctx, r.DB).Create(schedule).Error)
+}
+
+// GetSchedule retrieves a schedule by ID. An apperror.ErrNotFound is returned when there is none.
+func (r *scheduleRepositoryImpl) GetSchedule(ctx context.Context, id uint) (*model.Schedule, error) {
+	var schedule model.Schedule
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: schedule %d", apperror.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &schedule, nil
+}
+
+// GetSchedulesByUserID retrieves every schedule paying out of the user's wallet, oldest first
+func (r *scheduleRepositoryImpl) GetSchedulesByUserID(ctx context.Context, userID uint) ([]model.Schedule, error) {
+	var schedules []model.Schedule
+	err := conn(ctx, r.DB).Where("from_user_id = ?", userID).Order("id").Find(&schedules).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return schedules, nil
+}
+
+// GetDueSchedules retrieves up to limit active schedules whose next run is due by now, the
+// longest due first
+func (r *scheduleRepositoryImpl) GetDueSchedules(ctx context.Context, now time.Time, limit int) ([]model.Schedule, error) {
+	var schedules []model.Schedule
+	err := conn(ctx, r.DB).
+		Where("status = ? AND next_run_at <= ?", model.ScheduleStatusActive, now).
+		Order("next_run_at").Order("id").Limit(limit).
+		Find(&schedules).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return schedules, nil
+}
+
+// UpdateSchedule stores the progress and status of a schedule if it is unchanged since it was
+// read, bumping its version. A schedule updated concurrently, for instance cancelled while it
+// was being run, gets an apperror.ErrConflict.
+func (r *scheduleRepositoryImpl) UpdateSchedule(ctx context.Context, schedule *model.Schedule) error {
+	result := conn(ctx, r.DB).Model(&model.Schedule{}).
+		Where("id = ? AND version = ?", schedule.ID, schedule.Version).
+		Updates(map[string]interface{}{
+			"status":           schedule.Status,
+			"occurrence":       schedule.Occurrence,
+			"attempts":         schedule.Attempts,
+			"next_run_at":      schedule.NextRunAt,
+			"last_transfer_id": schedule.LastTransferID,
+			"last_error":       schedule.LastError,
+			"version":          schedule.Version + 1,
+		})
+	if result.Error != nil {
+		return storageError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: schedule %d was modified concurrently", apperror.ErrConflict, schedule.ID)
+	}
+	schedule.Version++
+	return nil
+}
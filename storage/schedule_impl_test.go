@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"walletApp/apperror"
+	"walletApp/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestCreateSchedule(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	startAt := time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "schedules" \("from_user_id","to_user_id","amount","currency","memo","external_reference","recurrence","start_at","end_at","failure_policy","status","occurrence","attempts","next_run_at","last_transfer_id","last_error","version","created_by","created_at"\)`).
+		WithArgs(1, 2, 5000, model.USD, "rent", "", model.RecurrenceMonthly, startAt, nil, model.ScheduleFailSkip, model.ScheduleStatusActive, 0, 0, startAt, "", "", 0, 1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectCommit()
+
+	repo := NewScheduleRepository(gormDB)
+	schedule := &model.Schedule{FromUserID: 1, ToUserID: 2, Amount: 5000, Currency: model.USD, Memo: "rent", Recurrence: model.RecurrenceMonthly, StartAt: startAt, NextRunAt: startAt, CreatedBy: 1}
+	err := repo.CreateSchedule(context.Background(), schedule)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(4), schedule.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSchedule(t *testing.T) {
+	tests := []struct {
+		name             string
+		setupMock        func(sqlmock.Sqlmock)
+		expectedSchedule *model.Schedule
+		expectedError    error
+	}{
+		{
+			name: "Active schedule",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "schedules" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(4, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "from_user_id", "to_user_id", "amount", "currency", "recurrence", "status", "version"}).
+						AddRow(4, 1, 2, 5000, "USD", model.RecurrenceMonthly, model.ScheduleStatusActive, 3))
+			},
+			expectedSchedule: &model.Schedule{ID: 4, FromUserID: 1, ToUserID: 2, Amount: 5000, Currency: model.USD, Recurrence: model.RecurrenceMonthly, Status: model.ScheduleStatusActive, Version: 3},
+		},
+		{
+			name: "Unknown schedule",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "schedules" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(4, 1).
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedError: apperror.ErrNotFound,
+		},
+		{
+			name: "Database Error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "schedules" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(4, 1).
+					WillReturnError(errors.New("database connection error"))
+			},
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			tt.setupMock(mock)
+
+			repo := NewScheduleRepository(gormDB)
+			schedule, err := repo.GetSchedule(context.Background(), 4)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedSchedule, schedule)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetSchedulesByUserID(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	mock.ExpectQuery(`SELECT \* FROM "schedules" WHERE from_user_id = \$1 ORDER BY id`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "from_user_id", "to_user_id"}).
+			AddRow(4, 1, 2).
+			AddRow(6, 1, 3))
+
+	repo := NewScheduleRepository(gormDB)
+	schedules, err := repo.GetSchedulesByUserID(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Schedule{{ID: 4, FromUserID: 1, ToUserID: 2}, {ID: 6, FromUserID: 1, ToUserID: 3}}, schedules)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDueSchedules(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	now := time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT \* FROM "schedules" WHERE status = \$1 AND next_run_at <= \$2 ORDER BY next_run_at,id LIMIT \$3`).
+		WithArgs(model.ScheduleStatusActive, now, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "from_user_id"}).AddRow(4, 1))
+
+	repo := NewScheduleRepository(gormDB)
+	schedules, err := repo.GetDueSchedules(context.Background(), now, 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Schedule{{ID: 4, FromUserID: 1}}, schedules)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateSchedule(t *testing.T) {
+	nextRunAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		rowsAffected    int64
+		mockError       error
+		expectedError   error
+		expectedVersion uint
+	}{
+		{name: "Updated", rowsAffected: 1, expectedVersion: 4},
+		{name: "Modified concurrently", rowsAffected: 0, expectedError: apperror.ErrConflict, expectedVersion: 3},
+		{name: "Database error", mockError: errors.New("database connection error"), expectedError: apperror.ErrStorage, expectedVersion: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := setupMockDB()
+			repo := NewScheduleRepository(db)
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`UPDATE "schedules" SET "attempts"=\$1,"last_error"=\$2,"last_transfer_id"=\$3,"next_run_at"=\$4,"occurrence"=\$5,"status"=\$6,"version"=\$7 WHERE id = \$8 AND version = \$9`).
+				WithArgs(0, "", "tr-1", nextRunAt, 2, model.ScheduleStatusActive, 4, 4, 3)
+			if tt.mockError == nil {
+				exec.WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+				mock.ExpectCommit()
+			} else {
+				exec.WillReturnError(tt.mockError)
+				mock.ExpectRollback()
+			}
+
+			schedule := &model.Schedule{ID: 4, Occurrence: 2, NextRunAt: nextRunAt, LastTransferID: "tr-1", Version: 3}
+			err := repo.UpdateSchedule(context.Background(), schedule)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+			assert.Equal(t, tt.expectedVersion, schedule.Version)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestNewScheduleRepository(t *testing.T) {
+	gormDB, _ := setupMockDB()
+	repo := NewScheduleRepository(gormDB)
+
+	assert.NotNil(t, repo)
+	assert.IsType(t, &scheduleRepositoryImpl{}, repo)
+}
+
+func TestNewMockScheduleRepository(t *testing.T) {
+	mockCalled := false
+	repo := NewMockScheduleRepository(func(m *mock.Mock) {
+		mockCalled = true
+		m.On("GetSchedule", mock.Anything, uint(4)).Return(&model.Schedule{ID: 4}, nil)
+	})
+
+	assert.True(t, mockCalled)
+	schedule, err := repo.GetSchedule(context.Background(), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(4), schedule.ID)
+}
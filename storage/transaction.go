@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 	"walletApp/model"
 )
 
@@ -10,5 +11,9 @@ import (
 //go:generate mockery --case underscore --name TransactionRepository
 type TransactionRepository interface {
 	CreateTransaction(ctx context.Context, transaction *model.Transaction) error
-	GetTransactionsByUserID(ctx context.Context, userID uint) ([]model.Transaction, error)
+	ListTransactions(ctx context.Context, query model.TransactionQuery) ([]model.Transaction, error)
+	GetTransaction(ctx context.Context, id uint) (*model.Transaction, error)
+	GetTransactionsByJournalEntryID(ctx context.Context, journalEntryID uint) ([]model.Transaction, error)
+	SumOutflows(ctx context.Context, userID uint, currency model.Currency, types []model.TransactionType, since time.Time) (model.Money, error)
+	SumAmountsSince(ctx context.Context, userID uint, currency model.Currency, since time.Time) (model.Money, error)
 }
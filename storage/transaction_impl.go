@@ -2,6 +2,11 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	"walletApp/apperror"
 	"walletApp/model"
 	"walletApp/storage/mocks"
 
@@ -29,12 +34,97 @@ func NewMockTransactionRepository(doMocks ...func(mock *mock.Mock)) TransactionR
 
 // CreateTransaction logs a new transaction in the database
 func (r *TransactionRepositoryImpl) CreateTransaction(ctx context.Context, transaction *model.Transaction) error {
-	return r.DB.WithContext(ctx).Create(transaction).Error
+	return storageError(conn(ctx, r.DB).Create(transaction).Error)
 }
 
-// GetTransactionsByUserID retrieves all transactions for a specific user
-func (r *TransactionRepositoryImpl) GetTransactionsByUserID(ctx context.Context, userID uint) ([]model.Transaction, error) {
+// ListTransactions retrieves the transactions matching query, newest first. Pages are read by
+// keyset rather than offset: a cursor selects the rows on one side of a (timestamp, id) position,
+// which the index on (user_id, timestamp, id) serves without scanning the skipped rows.
+func (r *TransactionRepositoryImpl) ListTransactions(ctx context.Context, query model.TransactionQuery) ([]model.Transaction, error) {
+	db := conn(ctx, r.DB).Where("user_id = ?", query.UserID)
+	if query.Currency != "" {
+		db = db.Where("currency = ?", query.Currency)
+	}
+	if len(query.Types) > 0 {
+		db = db.Where("type IN ?", query.Types)
+	}
+	if !query.From.IsZero() {
+		db = db.Where("timestamp >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("timestamp < ?", query.To)
+	}
+	if query.MinAmount != nil {
+		db = db.Where("amount >= ?", *query.MinAmount)
+	}
+	if query.MaxAmount != nil {
+		db = db.Where("amount <= ?", *query.MaxAmount)
+	}
+
+	order := "timestamp DESC, id DESC"
+	if cursor := query.Cursor; cursor != nil {
+		if cursor.Backward {
+			// Read the rows closest to the cursor first, then flip them back to newest first
+			db = db.Where("(timestamp, id) > (?, ?)", cursor.Timestamp, cursor.ID)
+			order = "timestamp ASC, id ASC"
+		} else {
+			db = db.Where("(timestamp, id) < (?, ?)", cursor.Timestamp, cursor.ID)
+		}
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
+	var transactions []model.Transaction
+	if err := db.Order(order).Find(&transactions).Error; err != nil {
+		return nil, storageError(err)
+	}
+	if query.Cursor != nil && query.Cursor.Backward {
+		slices.Reverse(transactions)
+	}
+	return transactions, nil
+}
+
+// GetTransaction retrieves a transaction by ID. An apperror.ErrNotFound is returned when there is none.
+func (r *TransactionRepositoryImpl) GetTransaction(ctx context.Context, id uint) (*model.Transaction, error) {
+	var transaction model.Transaction
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&transaction).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: transaction %d", apperror.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &transaction, nil
+}
+
+// GetTransactionsByJournalEntryID retrieves the history rows created by a journal entry, one for
+// each user wallet it posted to
+func (r *TransactionRepositoryImpl) GetTransactionsByJournalEntryID(ctx context.Context, journalEntryID uint) ([]model.Transaction, error) {
 	var transactions []model.Transaction
-	err := r.DB.WithContext(ctx).Where("user_id = ?", userID).Order("timestamp DESC").Find(&transactions).Error
-	return transactions, err
+	err := conn(ctx, r.DB).Where("journal_entry_id = ?", journalEntryID).Order("id").Find(&transactions).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return transactions, nil
+}
+
+// SumOutflows adds up the money that left the user's wallet in currency at or after since with
+// transactions of any of types, as a positive amount
+func (r *TransactionRepositoryImpl) SumOutflows(ctx context.Context, userID uint, currency model.Currency, types []model.TransactionType, since time.Time) (model.Money, error) {
+	var total model.Money
+	err := conn(ctx, r.DB).Model(&model.Transaction{}).
+		Where("user_id = ? AND currency = ? AND type IN ? AND amount < 0 AND timestamp >= ?", userID, currency, types, since).
+		Select("COALESCE(-SUM(amount), 0)").Scan(&total).Error
+	return total, storageError(err)
+}
+
+// SumAmountsSince adds up the signed amounts of all the user's transactions in currency made at
+// or after since, which is how much the balance changed since then
+func (r *TransactionRepositoryImpl) SumAmountsSince(ctx context.Context, userID uint, currency model.Currency, since time.Time) (model.Money, error) {
+	var total model.Money
+	err := conn(ctx, r.DB).Model(&model.Transaction{}).
+		Where("user_id = ? AND currency = ? AND timestamp >= ?", userID, currency, since).
+		Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+	return total, storageError(err)
 }
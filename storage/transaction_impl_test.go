@@ -5,11 +5,13 @@ import (
 	"errors"
 	"testing"
 	"time"
+	"walletApp/apperror"
 	"walletApp/model"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 func TestCreateTransactionds(t *testing.T) {
@@ -25,7 +27,7 @@ func TestCreateTransactionds(t *testing.T) {
 			name: "Successful Transaction Creation",
 			transaction: &model.Transaction{
 				UserID:    1,
-				Amount:    100.0,
+				Amount:    100,
 				Type:      model.TransactionTypeDeposit,
 				Timestamp: fixedTime,
 			},
@@ -41,7 +43,7 @@ func TestCreateTransactionds(t *testing.T) {
 			name: "Database Error",
 			transaction: &model.Transaction{
 				UserID:    2,
-				Amount:    -50.0,
+				Amount:    -50,
 				Type:      model.TransactionTypeWithdraw,
 				Timestamp: fixedTime,
 			},
@@ -75,109 +77,208 @@ func TestCreateTransactionds(t *testing.T) {
 	}
 }
 
-func TestGetTransactionsByUserID(t *testing.T) {
-	// Define a fixed timestamp for testing
-	fixedTime := time.Now()
+func TestListTransactions(t *testing.T) {
+	fixedTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	minAmount, maxAmount := model.Money(-1000), model.Money(1000)
+	columns := []string{"id", "user_id", "amount", "type", "timestamp"}
 
 	tests := []struct {
-		name            string
-		userID          uint
-		setupMock       func(sqlmock.Sqlmock)
-		expectedResults []model.Transaction
-		expectError     bool
+		name        string
+		query       model.TransactionQuery
+		setupMock   func(sqlmock.Sqlmock)
+		expectedIDs []uint
+		expectError bool
 	}{
 		{
-			name:   "Successful Transactions Retrieval",
-			userID: 1,
+			name:  "Whole history",
+			query: model.TransactionQuery{UserID: 1},
 			setupMock: func(mock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id", "user_id", "amount", "type", "timestamp"}).
-					AddRow(1, 1, 100.0, model.TransactionTypeDeposit, fixedTime).
-					AddRow(2, 1, -50.0, model.TransactionTypeWithdraw, fixedTime)
-				mock.ExpectQuery(`SELECT.*FROM "transactions".*WHERE.*user_id = \$1.*ORDER BY timestamp DESC`).
+				mock.ExpectQuery(`SELECT \* FROM "transactions" WHERE user_id = \$1 ORDER BY timestamp DESC, id DESC$`).
 					WithArgs(1).
-					WillReturnRows(rows)
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(2, 1, -50, model.TransactionTypeWithdraw, fixedTime).
+						AddRow(1, 1, 100, model.TransactionTypeDeposit, fixedTime))
 			},
-			expectedResults: []model.Transaction{
-				{
-					ID:        1,
-					UserID:    1,
-					Amount:    100.0,
-					Type:      model.TransactionTypeDeposit,
-					Timestamp: fixedTime,
-				},
-				{
-					ID:        2,
-					UserID:    1,
-					Amount:    -50.0,
-					Type:      model.TransactionTypeWithdraw,
-					Timestamp: fixedTime,
-				},
+			expectedIDs: []uint{2, 1},
+		},
+		{
+			name: "Filtered page after a cursor",
+			query: model.TransactionQuery{
+				UserID:    1,
+				Currency:  model.EUR,
+				Types:     []model.TransactionType{model.TransactionTypeDeposit, model.TransactionTypeWithdraw},
+				From:      fixedTime.Add(-time.Hour),
+				To:        fixedTime.Add(time.Hour),
+				MinAmount: &minAmount,
+				MaxAmount: &maxAmount,
+				Cursor:    &model.TransactionCursor{Timestamp: fixedTime, ID: 9},
+				Limit:     3,
 			},
-			expectError: false,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "transactions" WHERE user_id = \$1 AND currency = \$2 AND type IN \(\$3,\$4\) AND timestamp >= \$5 AND timestamp < \$6 AND amount >= \$7 AND amount <= \$8 AND \(timestamp, id\) < \(\$9, \$10\) ORDER BY timestamp DESC, id DESC LIMIT \$11`).
+					WithArgs(1, model.EUR, model.TransactionTypeDeposit, model.TransactionTypeWithdraw, fixedTime.Add(-time.Hour), fixedTime.Add(time.Hour), minAmount, maxAmount, fixedTime, 9, 3).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(8, 1, 100, model.TransactionTypeDeposit, fixedTime).
+						AddRow(7, 1, -50, model.TransactionTypeWithdraw, fixedTime))
+			},
+			expectedIDs: []uint{8, 7},
 		},
 		{
-			name:   "Empty Transactions",
-			userID: 2,
+			name: "Page before a cursor",
+			query: model.TransactionQuery{
+				UserID: 1,
+				Cursor: &model.TransactionCursor{Timestamp: fixedTime, ID: 4, Backward: true},
+				Limit:  2,
+			},
 			setupMock: func(mock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id", "user_id", "amount", "type", "timestamp"})
-				mock.ExpectQuery(`SELECT.*FROM "transactions".*WHERE.*user_id = \$1.*ORDER BY timestamp DESC`).
-					WithArgs(2).
-					WillReturnRows(rows)
+				mock.ExpectQuery(`SELECT \* FROM "transactions" WHERE user_id = \$1 AND \(timestamp, id\) > \(\$2, \$3\) ORDER BY timestamp ASC, id ASC LIMIT \$4`).
+					WithArgs(1, fixedTime, 4, 2).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(5, 1, 100, model.TransactionTypeDeposit, fixedTime).
+						AddRow(6, 1, 100, model.TransactionTypeDeposit, fixedTime))
 			},
-			expectedResults: []model.Transaction{},
-			expectError:     false,
+			expectedIDs: []uint{6, 5},
 		},
 		{
-			name:   "Database Error",
-			userID: 3,
+			name:  "Database Error",
+			query: model.TransactionQuery{UserID: 3},
 			setupMock: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT.*FROM "transactions".*WHERE.*user_id = \$1.*ORDER BY timestamp DESC`).
+				mock.ExpectQuery(`SELECT \* FROM "transactions" WHERE user_id = \$1`).
 					WithArgs(3).
 					WillReturnError(errors.New("database connection error"))
 			},
-			expectedResults: nil,
-			expectError:     true,
+			expectError: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Setup mock DB
 			gormDB, mock := setupMockDB()
 			tt.setupMock(mock)
 
-			// Create repository with mocked DB
 			repo := &TransactionRepositoryImpl{DB: gormDB}
+			transactions, err := repo.ListTransactions(context.Background(), tt.query)
 
-			// Call the method being tested
-			transactions, err := repo.GetTransactionsByUserID(context.Background(), tt.userID)
-
-			// Assertions
 			if tt.expectError {
-				assert.Error(t, err)
+				assert.ErrorIs(t, err, apperror.ErrStorage)
 				assert.Nil(t, transactions)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, len(tt.expectedResults), len(transactions))
-
-				// Verify the transactions match
-				if len(tt.expectedResults) > 0 {
-					for i, tx := range tt.expectedResults {
-						assert.Equal(t, tx.ID, transactions[i].ID)
-						assert.Equal(t, tx.UserID, transactions[i].UserID)
-						assert.Equal(t, tx.Amount, transactions[i].Amount)
-						assert.Equal(t, tx.Type, transactions[i].Type)
-						assert.Equal(t, tx.Timestamp.Unix(), transactions[i].Timestamp.Unix())
-					}
+				var ids []uint
+				for _, transaction := range transactions {
+					ids = append(ids, transaction.ID)
 				}
+				assert.Equal(t, tt.expectedIDs, ids)
 			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
 
-			// Verify all expectations were met
+func TestGetTransaction(t *testing.T) {
+	tests := []struct {
+		name                string
+		setupMock           func(sqlmock.Sqlmock)
+		expectedTransaction *model.Transaction
+		expectedError       error
+	}{
+		{
+			name: "Existing transaction",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "transactions" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(7, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "type", "amount", "journal_entry_id"}).
+						AddRow(7, 1, model.TransactionTypeTransferSend, -500, 3))
+			},
+			expectedTransaction: &model.Transaction{ID: 7, UserID: 1, Type: model.TransactionTypeTransferSend, Amount: -500, JournalEntryID: 3},
+		},
+		{
+			name: "Unknown transaction",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "transactions" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(7, 1).
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedError: apperror.ErrNotFound,
+		},
+		{
+			name: "Database Error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "transactions" WHERE id = \$1 .* LIMIT \$2`).
+					WithArgs(7, 1).
+					WillReturnError(errors.New("database connection error"))
+			},
+			expectedError: apperror.ErrStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			tt.setupMock(mock)
+
+			repo := NewTransactionRepository(gormDB)
+			transaction, err := repo.GetTransaction(context.Background(), 7)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedTransaction, transaction)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
 
+func TestGetTransactionsByJournalEntryID(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	mock.ExpectQuery(`SELECT \* FROM "transactions" WHERE journal_entry_id = \$1 ORDER BY id`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "amount", "journal_entry_id"}).
+			AddRow(7, 1, -500, 3).
+			AddRow(8, 2, 500, 3))
+
+	repo := NewTransactionRepository(gormDB)
+	transactions, err := repo.GetTransactionsByJournalEntryID(context.Background(), 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Transaction{
+		{ID: 7, UserID: 1, Amount: -500, JournalEntryID: 3},
+		{ID: 8, UserID: 2, Amount: 500, JournalEntryID: 3},
+	}, transactions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSumOutflows(t *testing.T) {
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	gormDB, mock := setupMockDB()
+	mock.ExpectQuery(`SELECT COALESCE\(-SUM\(amount\), 0\) FROM "transactions" WHERE user_id = \$1 AND currency = \$2 AND type IN \(\$3,\$4\) AND amount < 0 AND timestamp >= \$5`).
+		WithArgs(1, model.EUR, model.TransactionTypeTransferSend, model.TransactionTypeCapture, since).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow([]byte("1500")))
+
+	repo := NewTransactionRepository(gormDB)
+	total, err := repo.SumOutflows(context.Background(), 1, model.EUR, []model.TransactionType{model.TransactionTypeTransferSend, model.TransactionTypeCapture}, since)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.Money(1500), total)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSumAmountsSince(t *testing.T) {
+	since := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	gormDB, mock := setupMockDB()
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) FROM "transactions" WHERE user_id = \$1 AND currency = \$2 AND timestamp >= \$3`).
+		WithArgs(1, model.USD, since).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow([]byte("2500")))
+
+	repo := NewTransactionRepository(gormDB)
+	total, err := repo.SumAmountsSince(context.Background(), 1, model.USD, since)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.Money(2500), total)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestNewTransactionRepository(t *testing.T) {
 	gormDB, _ := setupMockDB()
 	repo := NewTransactionRepository(gormDB)
@@ -193,14 +294,14 @@ func TestNewMockTransactionRepository(t *testing.T) {
 	mockCalled := false
 	repo = NewMockTransactionRepository(func(m *mock.Mock) {
 		mockCalled = true
-		m.On("GetTransactionsByUserID", mock.Anything, uint(1)).Return([]model.Transaction{}, nil)
+		m.On("ListTransactions", mock.Anything, model.TransactionQuery{UserID: 1}).Return([]model.Transaction{}, nil)
 	})
 
 	assert.NotNil(t, repo)
 	assert.True(t, mockCalled)
 
 	// Test that the mock works as expected
-	transactions, err := repo.GetTransactionsByUserID(context.Background(), 1)
+	transactions, err := repo.ListTransactions(context.Background(), model.TransactionQuery{UserID: 1})
 	assert.NoError(t, err)
 	assert.Empty(t, transactions)
 }
@@ -0,0 +1,12 @@
+package storage
+
+import (
+	"context"
+)
+
+// UnitOfWork defines the interface for running several repository operations atomically
+//
+//go:generate mockery --case underscore --name UnitOfWork
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"walletApp/storage/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// txContextKey is the context key under which a unit of work stores its transaction
+type txContextKey struct{}
+
+type unitOfWorkImpl struct {
+	DB *gorm.DB
+}
+
+// NewUnitOfWork creates a new instance of unitOfWorkImpl
+func NewUnitOfWork(db *gorm.DB) UnitOfWork {
+	return &unitOfWorkImpl{DB: db}
+}
+
+// NewMockUnitOfWork creates a new instance of UnitOfWork with mocked methods
+func NewMockUnitOfWork(doMocks ...func(mock *mock.Mock)) UnitOfWork {
+	mockUow := &mocks.UnitOfWork{}
+	for _, mockFunc := range doMocks {
+		mockFunc(&mockUow.Mock)
+	}
+	return mockUow
+}
+
+// Do runs fn inside a database transaction. Repository calls made with the context passed
+// to fn join that transaction, which is committed when fn returns nil and rolled back when
+// it returns an error. Nested calls reuse the outer transaction.
+func (u *unitOfWorkImpl) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	return u.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txContextKey{}, tx))
+	})
+}
+
+// conn returns the transaction bound to ctx by a unit of work, or db if there is none
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"walletApp/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUnitOfWorkDo(t *testing.T) {
+	tests := []struct {
+		name        string
+		setupMock   func(sqlmock.Sqlmock)
+		work        func(ctx context.Context, balanceRepo BalanceRepository, txRepo TransactionRepository) error
+		expectError bool
+	}{
+		{
+			name: "Commit on success",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "balances" SET "balance"=\$1,"version"=version \+ 1 WHERE user_id = \$2 AND currency = \$3 AND version = \$4`).
+					WithArgs(150, 1, model.USD, 0).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`INSERT INTO "transactions"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectCommit()
+			},
+			work: func(ctx context.Context, balanceRepo BalanceRepository, txRepo TransactionRepository) error {
+				if err := balanceRepo.UpdateBalance(ctx, 1, model.USD, 150, 0); err != nil {
+					return err
+				}
+				return txRepo.CreateTransaction(ctx, &model.Transaction{UserID: 1, Amount: 50, Currency: model.USD, Type: model.TransactionTypeDeposit})
+			},
+			expectError: false,
+		},
+		{
+			name: "Rollback when a statement fails",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "balances" SET "balance"=\$1,"version"=version \+ 1 WHERE user_id = \$2 AND currency = \$3 AND version = \$4`).
+					WithArgs(150, 1, model.USD, 0).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`INSERT INTO "transactions"`).
+					WillReturnError(errors.New("database connection error"))
+				mock.ExpectRollback()
+			},
+			work: func(ctx context.Context, balanceRepo BalanceRepository, txRepo TransactionRepository) error {
+				if err := balanceRepo.UpdateBalance(ctx, 1, model.USD, 150, 0); err != nil {
+					return err
+				}
+				return txRepo.CreateTransaction(ctx, &model.Transaction{UserID: 1, Amount: 50, Currency: model.USD, Type: model.TransactionTypeDeposit})
+			},
+			expectError: true,
+		},
+		{
+			name: "Rollback when the work returns an error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			work: func(ctx context.Context, balanceRepo BalanceRepository, txRepo TransactionRepository) error {
+				return errors.New("insufficient balance")
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			tt.setupMock(mock)
+
+			uow := NewUnitOfWork(gormDB)
+			balanceRepo := NewBalanceRepository(gormDB)
+			txRepo := NewTransactionRepository(gormDB)
+
+			err := uow.Do(context.Background(), func(ctx context.Context) error {
+				return tt.work(ctx, balanceRepo, txRepo)
+			})
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUnitOfWorkDoNested(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	uow := NewUnitOfWork(gormDB)
+	innerCalled := false
+	err := uow.Do(context.Background(), func(ctx context.Context) error {
+		return uow.Do(ctx, func(ctx context.Context) error {
+			innerCalled = true
+			return nil
+		})
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, innerCalled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewUnitOfWork(t *testing.T) {
+	gormDB, _ := setupMockDB()
+	uow := NewUnitOfWork(gormDB)
+
+	assert.NotNil(t, uow)
+	assert.IsType(t, &unitOfWorkImpl{}, uow)
+}
+
+func TestNewMockUnitOfWork(t *testing.T) {
+	uow := NewMockUnitOfWork()
+	assert.NotNil(t, uow)
+
+	mockCalled := false
+	uow = NewMockUnitOfWork(func(m *mock.Mock) {
+		mockCalled = true
+		m.On("Do", mock.Anything, mock.Anything).Return(nil)
+	})
+
+	assert.NotNil(t, uow)
+	assert.True(t, mockCalled)
+	assert.NoError(t, uow.Do(context.Background(), func(ctx context.Context) error { return nil }))
+}
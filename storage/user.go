@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"context"
+	"walletApp/model"
+)
+
+// UserRepository defines the interface for user-related operations
+//
+//go:generate mockery --case underscore --name UserRepository
+type UserRepository interface {
+	CreateUser(ctx context.Context, user *model.User) error
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"walletApp/model"
+	"walletApp/storage/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type userRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+// NewUserRepository creates a new instance of userRepositoryImpl
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepositoryImpl{DB: db}
+}
+
+// NewMockUserRepository creates a new instance of UserRepository with mocked methods
+func NewMockUserRepository(doMocks ...func(mock *mock.Mock)) UserRepository {
+	mockRepo := &mocks.UserRepository{}
+	for _, mockFunc := range doMocks {
+		mockFunc(&mockRepo.Mock)
+	}
+	return mockRepo
+}
+
+// CreateUser inserts a new user. An apperror.ErrConflict is returned when the username is taken.
+func (r *userRepositoryImpl) CreateUser(ctx context.Context, user *model.User) error {
+	return storageError(conn(ctx, r.DB).Create(user).Error)
+}
+
+// GetUserByUsername retrieves the user with the given username, or nil if there is none
+func (r *userRepositoryImpl) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	var user model.User
+	err := conn(ctx, r.DB).Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return &user, nil
+}
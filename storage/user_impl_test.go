@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"walletApp/apperror"
+	"walletApp/model"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestGetUserByUsername(t *testing.T) {
+	tests := []struct {
+		name         string
+		username     string
+		setupMock    func(sqlmock.Sqlmock)
+		expectedUser *model.User
+		expectError  bool
+	}{
+		{
+			name:     "Registered user",
+			username: "alice",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE username = \$1 .* LIMIT \$2`).
+					WithArgs("alice", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password_hash", "role"}).
+						AddRow(1, "alice", "$2a$10$hash", model.UserRoleAdmin))
+			},
+			expectedUser: &model.User{ID: 1, Username: "alice", PasswordHash: "$2a$10$hash", Role: model.UserRoleAdmin},
+			expectError:  false,
+		},
+		{
+			name:     "Unknown username",
+			username: "mallory",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE username = \$1 .* LIMIT \$2`).
+					WithArgs("mallory", 1).
+					WillReturnError(gorm.ErrRecordNotFound)
+			},
+			expectedUser: nil,
+			expectError:  false,
+		},
+		{
+			name:     "Database Error",
+			username: "alice",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE username = \$1 .* LIMIT \$2`).
+					WithArgs("alice", 1).
+					WillReturnError(errors.New("database connection error"))
+			},
+			expectedUser: nil,
+			expectError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := setupMockDB()
+			tt.setupMock(mock)
+
+			repo := NewUserRepository(gormDB)
+			user, err := repo.GetUserByUsername(context.Background(), tt.username)
+			if tt.expectError {
+				assert.ErrorIs(t, err, apperror.ErrStorage)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedUser, user)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	gormDB, mock := setupMockDB()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(5, model.UserRoleCustomer))
+	mock.ExpectCommit()
+
+	repo := NewUserRepository(gormDB)
+	user := &model.User{Username: "dana", PasswordHash: "$2a$10$hash"}
+	err := repo.CreateUser(context.Background(), user)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(5), user.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewUserRepository(t *testing.T) {
+	gormDB, _ := setupMockDB()
+	repo := NewUserRepository(gormDB)
+
+	assert.NotNil(t, repo)
+	assert.IsType(t, &userRepositoryImpl{}, repo)
+}
+
+func TestNewMockUserRepository(t *testing.T) {
+	repo := NewMockUserRepository()
+	assert.NotNil(t, repo)
+
+	mockCalled := false
+	repo = NewMockUserRepository(func(m *mock.Mock) {
+		mockCalled = true
+		m.On("GetUserByUsername", mock.Anything, "alice").Return(nil, nil)
+	})
+
+	assert.NotNil(t, repo)
+	assert.True(t, mockCalled)
+
+	user, err := repo.GetUserByUsername(context.Background(), "alice")
+	assert.NoError(t, err)
+	assert.Nil(t, user)
+}
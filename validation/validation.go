@@ -0,0 +1,415 @@
+// Package validation checks dto requests before they reach a handler. Every problem found in a
+// request is reported as a FieldError naming the offending JSON field, so the CLI and any API can
+// show all of them at once instead of failing on the first.
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"walletApp/apperror"
+	"walletApp/dto"
+	"walletApp/fees"
+	"walletApp/model"
+)
+
+// MaxIdempotencyKeyLength matches the size of the idempotency_records.key column
+const MaxIdempotencyKeyLength = 255
+
+const (
+	// MinPasswordLength is the shortest password a user can register with
+	MinPasswordLength = 8
+	// MaxPasswordLength is the longest password bcrypt can hash without truncating it
+	MaxPasswordLength = 72
+)
+
+const (
+	// DefaultPageSize is the number of transactions in a history page when no limit is given
+	DefaultPageSize = 20
+	// MaxPageSize bounds the number of transactions in a history page
+	MaxPageSize = 100
+)
+
+// MaxReasonLength matches the size of the adjustments.reason column
+const MaxReasonLength = 255
+
+// MaxReferenceLength matches the size of the transactions.memo and external_reference columns
+const MaxReferenceLength = 255
+
+// MaxBatchSize bounds the number of items of a batch transfer, which are applied in one unit of
+// work
+const MaxBatchSize = 1000
+
+// MaxQuoteIDLength matches the size of the exchange_quotes.id column
+const MaxQuoteIDLength = 36
+
+// usernamePattern matches the usernames accepted at registration, which fit users.username
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_.-]{3,64}$`)
+
+// ErrInvalidRequest is matched by every error returned from Validate and ParseAmount
+var ErrInvalidRequest = apperror.ErrInvalidRequest
+
+// FieldError describes why the value of a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Errors is the list of field errors found in one request
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldError := range e {
+		messages = append(messages, fieldError.Error())
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidRequest, strings.Join(messages, "; "))
+}
+
+// Is lets errors.Is(err, ErrInvalidRequest) match any validation failure
+func (e Errors) Is(target error) bool {
+	return target == ErrInvalidRequest
+}
+
+// add records a field error
+func (e *Errors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns nil when no field errors were recorded
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate checks a dto request, returning Errors describing every invalid field or nil when the
+// request is valid. Amounts are checked here for sign only: model.Money is an integer number of
+// cents, so NaN, infinities and amounts with too many decimals are already rejected when the
+// amount is parsed (see ParseAmount).
+func Validate(request interface{}) error {
+	var errs Errors
+	switch r := request.(type) {
+	case *dto.DepositRequest:
+		errs.userID("user_id", r.UserID)
+		errs.amount("amount", r.Amount)
+		errs.currency("currency", r.Currency)
+		errs.precision("amount", r.Amount, r.Currency)
+		errs.idempotencyKey("idempotency_key", r.IdempotencyKey)
+	case *dto.WithdrawRequest:
+		errs.userID("user_id", r.UserID)
+		errs.amount("amount", r.Amount)
+		errs.currency("currency", r.Currency)
+		errs.precision("amount", r.Amount, r.Currency)
+		errs.idempotencyKey("idempotency_key", r.IdempotencyKey)
+	case *dto.TransferRequest:
+		errs.userID("from_user_id", r.FromUserID)
+		errs.userID("to_user_id", r.ToUserID)
+		if r.FromUserID != 0 && r.FromUserID == r.ToUserID {
+			errs.add("to_user_id", "must be different from from_user_id")
+		}
+		errs.amount("amount", r.Amount)
+		errs.currency("currency", r.Currency)
+		errs.currency("to_currency", r.ToCurrency)
+		errs.precision("amount", r.Amount, r.Currency)
+		if r.ToCurrency != "" && model.CurrencyOrDefault(r.ToCurrency) != model.CurrencyOrDefault(r.Currency) && !r.Convert {
+			errs.add("to_currency", "must equal currency unless convert is set")
+		}
+		errs.maxLength("memo", r.Memo, MaxReferenceLength)
+		errs.maxLength("external_reference", r.ExternalReference, MaxReferenceLength)
+		errs.idempotencyKey("idempotency_key", r.IdempotencyKey)
+	case *dto.BatchTransferRequest:
+		errs.userID("from_user_id", r.FromUserID)
+		errs.currency("currency", r.Currency)
+		switch {
+		case len(r.Items) == 0:
+			errs.add("items", "is required")
+		case len(r.Items) > MaxBatchSize:
+			errs.add("items", "must have at most %d items", MaxBatchSize)
+		}
+		for i, item := range r.Items {
+			field := fmt.Sprintf("items[%d].", i)
+			errs.userID(field+"to_user_id", item.ToUserID)
+			if r.FromUserID != 0 && r.FromUserID == item.ToUserID {
+				errs.add(field+"to_user_id", "must be different from from_user_id")
+			}
+			errs.amount(field+"amount", item.Amount)
+			errs.precision(field+"amount", item.Amount, r.Currency)
+			errs.maxLength(field+"memo", item.Memo, MaxReferenceLength)
+			errs.maxLength(field+"external_reference", item.ExternalReference, MaxReferenceLength)
+		}
+		errs.idempotencyKey("idempotency_key", r.IdempotencyKey)
+	case *dto.ExchangeQuoteRequest:
+		errs.userID("user_id", r.UserID)
+		errs.conversion(r.FromCurrency, r.ToCurrency, r.Amount)
+	case *dto.ExchangeRequest:
+		errs.userID("user_id", r.UserID)
+		if r.QuoteID == "" {
+			errs.conversion(r.FromCurrency, r.ToCurrency, r.Amount)
+		} else if r.FromCurrency != "" || r.ToCurrency != "" || r.Amount != 0 {
+			errs.add("quote_id", "must not be combined with from_currency, to_currency or amount")
+		}
+		errs.maxLength("quote_id", r.QuoteID, MaxQuoteIDLength)
+		errs.idempotencyKey("idempotency_key", r.IdempotencyKey)
+	case *dto.ReverseRequest:
+		if r.TransactionID == 0 {
+			errs.add("transaction_id", "is required")
+		}
+		if r.Amount != nil {
+			errs.amount("amount", *r.Amount)
+		}
+		errs.required("reason", r.Reason)
+		errs.maxLength("reason", r.Reason, MaxReferenceLength)
+		errs.idempotencyKey("idempotency_key", r.IdempotencyKey)
+	case *dto.AuthorizeHoldRequest:
+		errs.userID("user_id", r.UserID)
+		errs.userID("merchant_id", r.MerchantID)
+		if r.UserID != 0 && r.UserID == r.MerchantID {
+			errs.add("merchant_id", "must be different from user_id")
+		}
+		errs.amount("amount", r.Amount)
+		errs.currency("currency", r.Currency)
+		errs.precision("amount", r.Amount, r.Currency)
+		errs.maxLength("memo", r.Memo, MaxReferenceLength)
+		errs.maxLength("external_reference", r.ExternalReference, MaxReferenceLength)
+		errs.idempotencyKey("idempotency_key", r.IdempotencyKey)
+	case *dto.CaptureHoldRequest:
+		errs.holdID(r.HoldID)
+		if r.Amount != nil {
+			errs.amount("amount", *r.Amount)
+		}
+		errs.idempotencyKey("idempotency_key", r.IdempotencyKey)
+	case *dto.HoldRequest:
+		errs.holdID(r.HoldID)
+	case *dto.CreateScheduleRequest:
+		errs.userID("from_user_id", r.FromUserID)
+		errs.userID("to_user_id", r.ToUserID)
+		if r.FromUserID != 0 && r.FromUserID == r.ToUserID {
+			errs.add("to_user_id", "must be different from from_user_id")
+		}
+		errs.amount("amount", r.Amount)
+		errs.currency("currency", r.Currency)
+		errs.precision("amount", r.Amount, r.Currency)
+		errs.maxLength("memo", r.Memo, MaxReferenceLength)
+		errs.maxLength("external_reference", r.ExternalReference, MaxReferenceLength)
+		if _, ok := model.ParseRecurrence(r.Recurrence); r.Recurrence != "" && !ok {
+			errs.add("recurrence", "must be one of Once, Daily, Weekly, Monthly")
+		}
+		if _, ok := model.ParseScheduleFailPolicy(r.FailurePolicy); r.FailurePolicy != "" && !ok {
+			errs.add("failure_policy", "must be one of Skip, Stop")
+		}
+		if !r.StartAt.IsZero() && !r.EndAt.IsZero() && r.EndAt.Before(r.StartAt) {
+			errs.add("end_at", "must not be before start_at")
+		}
+	case *dto.ScheduleRequest:
+		if r.ScheduleID == 0 {
+			errs.add("schedule_id", "is required")
+		}
+	case *dto.ListSchedulesRequest:
+		errs.userID("user_id", r.UserID)
+	case *dto.CheckBalanceRequest:
+		errs.userID("user_id", r.UserID)
+		errs.currency("currency", r.Currency)
+	case *dto.TransactionHistoryRequest:
+		errs.userID("user_id", r.UserID)
+		errs.currency("currency", r.Currency)
+		for _, name := range r.Types {
+			if _, ok := model.ParseTransactionType(name); !ok {
+				errs.add("types", "%q is not a transaction type", name)
+			}
+		}
+		if !r.From.IsZero() && !r.To.IsZero() && !r.From.Before(r.To) {
+			errs.add("to", "must be after from")
+		}
+		if r.MinAmount != nil && r.MaxAmount != nil && *r.MinAmount > *r.MaxAmount {
+			errs.add("max_amount", "must not be less than min_amount")
+		}
+		if r.Limit < 0 || r.Limit > MaxPageSize {
+			errs.add("limit", "must be between 1 and %d", MaxPageSize)
+		}
+	case *dto.AccountRequest:
+		errs.userID("user_id", r.UserID)
+		errs.currency("currency", r.Currency)
+	case *dto.FeeQuoteRequest:
+		if _, ok := fees.ParseOperation(r.Operation); !ok {
+			errs.add("operation", "must be one of Withdraw, Transfer")
+		}
+		errs.amount("amount", r.Amount)
+		errs.currency("currency", r.Currency)
+		errs.precision("amount", r.Amount, r.Currency)
+	case *dto.SetTierRequest:
+		errs.userID("user_id", r.UserID)
+		if _, ok := model.ParseAccountTier(r.Tier); !ok {
+			errs.add("tier", "must be one of Standard, Verified, Premium")
+		}
+	case *dto.SetProductRequest:
+		errs.userID("user_id", r.UserID)
+		if _, ok := model.ParseAccountProduct(r.Product); !ok {
+			errs.add("product", "must be one of Checking, Savings, Merchant")
+		}
+	case *dto.SetOverdraftLimitRequest:
+		errs.userID("user_id", r.UserID)
+		errs.currency("currency", r.Currency)
+		if r.Limit.IsNegative() {
+			errs.add("limit", "must not be negative")
+		}
+		errs.precision("limit", r.Limit, r.Currency)
+	case *dto.RegisterRequest:
+		errs.username("username", r.Username)
+		errs.password("password", r.Password)
+	case *dto.LoginRequest:
+		errs.required("username", r.Username)
+		errs.required("password", r.Password)
+	case *dto.CreateAdjustmentRequest:
+		errs.userID("user_id", r.UserID)
+		if r.Amount == 0 {
+			errs.add("amount", "must not be zero")
+		}
+		errs.currency("currency", r.Currency)
+		errs.precision("amount", r.Amount, r.Currency)
+		errs.required("reason", r.Reason)
+		errs.maxLength("reason", r.Reason, MaxReasonLength)
+	case *dto.ReviewAdjustmentRequest:
+		if r.AdjustmentID == 0 {
+			errs.add("adjustment_id", "is required")
+		}
+	default:
+		return fmt.Errorf("%w: no validation rules for %T", ErrInvalidRequest, request)
+	}
+	return errs.err()
+}
+
+// ParseAmount parses text entered for field as an amount of money, reporting malformed input,
+// excess decimal places and non-positive amounts as a field error
+func ParseAmount(field, text string) (model.Money, error) {
+	amount, err := ParseSignedAmount(field, text)
+	if err != nil {
+		return 0, err
+	}
+	var errs Errors
+	errs.amount(field, amount)
+	if len(errs) > 0 {
+		return 0, errs
+	}
+	return amount, nil
+}
+
+// ParseSignedAmount is ParseAmount for fields such as adjustment amounts, which may be negative
+func ParseSignedAmount(field, text string) (model.Money, error) {
+	amount, err := model.ParseMoney(text)
+	if err != nil {
+		var errs Errors
+		errs.add(field, "must be a number with at most %d decimal places", model.MoneyDecimals)
+		return 0, errs
+	}
+	return amount, nil
+}
+
+// userID requires a user ID to be set and to belong to a user wallet rather than a system account
+func (e *Errors) userID(field string, userID uint) {
+	switch {
+	case userID == 0:
+		e.add(field, "is required")
+	case model.IsSystemAccount(userID):
+		e.add(field, "must not be a system account")
+	}
+}
+
+// amount requires an amount to be greater than zero, since a negative deposit would act as a
+// withdrawal that skips the balance check
+func (e *Errors) amount(field string, amount model.Money) {
+	if !amount.IsPositive() {
+		e.add(field, "must be greater than zero")
+	}
+}
+
+// currency accepts a supported currency code, or none for model.DefaultCurrency
+func (e *Errors) currency(field string, currency model.Currency) {
+	if !model.CurrencyOrDefault(currency).IsSupported() {
+		e.add(field, "must be one of %s", strings.Join(currencyCodes(), ", "))
+	}
+}
+
+// precision rejects an amount with more decimal places than its currency has, such as 1.50 JPY
+func (e *Errors) precision(field string, amount model.Money, currency model.Currency) {
+	currency = model.CurrencyOrDefault(currency)
+	if currency.IsSupported() && !currency.Allows(amount) {
+		e.add(field, "must have at most %d decimal places in %s", currency.Decimals(), currency)
+	}
+}
+
+// conversion requires two different supported currencies and an amount to sell of the first.
+// Unlike elsewhere, neither currency defaults to model.DefaultCurrency.
+func (e *Errors) conversion(from, to model.Currency, amount model.Money) {
+	e.required("from_currency", string(from))
+	e.currency("from_currency", from)
+	e.required("to_currency", string(to))
+	e.currency("to_currency", to)
+	if from != "" && from == to {
+		e.add("to_currency", "must be different from from_currency")
+	}
+	e.amount("amount", amount)
+	if from != "" {
+		e.precision("amount", amount, from)
+	}
+}
+
+// holdID requires a hold to be named
+func (e *Errors) holdID(id uint) {
+	if id == 0 {
+		e.add("hold_id", "is required")
+	}
+}
+
+// currencyCodes returns the codes of the supported currencies
+func currencyCodes() []string {
+	var codes []string
+	for _, currency := range model.Currencies() {
+		codes = append(codes, string(currency))
+	}
+	return codes
+}
+
+// idempotencyKey limits the key to what the idempotency records table can store
+func (e *Errors) idempotencyKey(field, key string) {
+	e.maxLength(field, key, MaxIdempotencyKeyLength)
+	if strings.HasPrefix(key, model.InternalIdempotencyKeyPrefix) {
+		e.add(field, "must not start with %q", model.InternalIdempotencyKeyPrefix)
+	}
+}
+
+// maxLength rejects a value longer than the column storing it
+func (e *Errors) maxLength(field, value string, max int) {
+	if len(value) > max {
+		e.add(field, "must be at most %d characters", max)
+	}
+}
+
+// required rejects an empty value
+func (e *Errors) required(field, value string) {
+	if value == "" {
+		e.add(field, "is required")
+	}
+}
+
+// username requires a lowercase username of 3 to 64 letters, digits, dots, dashes or underscores
+func (e *Errors) username(field, username string) {
+	if !usernamePattern.MatchString(username) {
+		e.add(field, "must be 3 to 64 lowercase letters, digits, '.', '-' or '_'")
+	}
+}
+
+// password requires a password long enough to resist guessing and short enough for bcrypt
+func (e *Errors) password(field, password string) {
+	switch {
+	case len(password) < MinPasswordLength:
+		e.add(field, "must be at least %d characters", MinPasswordLength)
+	case len(password) > MaxPasswordLength:
+		e.add(field, "must be at most %d bytes", MaxPasswordLength)
+	}
+}
@@ -0,0 +1,419 @@
+package validation
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"walletApp/dto"
+	"walletApp/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	negative := model.Money(-100)
+	start := time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		request        interface{}
+		expectedErrors Errors
+	}{
+		{
+			name:    "Valid deposit",
+			request: &dto.DepositRequest{UserID: 1, Amount: 100},
+		},
+		{
+			name:    "Negative deposit",
+			request: &dto.DepositRequest{UserID: 1, Amount: -100},
+			expectedErrors: Errors{
+				{Field: "amount", Message: "must be greater than zero"},
+			},
+		},
+		{
+			name:    "Zero withdrawal without user",
+			request: &dto.WithdrawRequest{},
+			expectedErrors: Errors{
+				{Field: "user_id", Message: "is required"},
+				{Field: "amount", Message: "must be greater than zero"},
+			},
+		},
+		{
+			name:    "Withdrawal from system account",
+			request: &dto.WithdrawRequest{UserID: model.SystemAccountCashIn, Amount: 100},
+			expectedErrors: Errors{
+				{Field: "user_id", Message: "must not be a system account"},
+			},
+		},
+		{
+			name:    "Valid transfer",
+			request: &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100, IdempotencyKey: "transfer-1"},
+		},
+		{
+			name:    "Transfer to self",
+			request: &dto.TransferRequest{FromUserID: 1, ToUserID: 1, Amount: 100},
+			expectedErrors: Errors{
+				{Field: "to_user_id", Message: "must be different from from_user_id"},
+			},
+		},
+		{
+			name:    "Transfer with oversized idempotency key",
+			request: &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100, IdempotencyKey: strings.Repeat("k", 256)},
+			expectedErrors: Errors{
+				{Field: "idempotency_key", Message: "must be at most 255 characters"},
+			},
+		},
+		{
+			name:    "Transfer with an internal idempotency key",
+			request: &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100, IdempotencyKey: model.InternalIdempotencyKeyPrefix + "schedule-1-0"},
+			expectedErrors: Errors{
+				{Field: "idempotency_key", Message: `must not start with "internal:"`},
+			},
+		},
+		{
+			name:    "Transfer with oversized memo",
+			request: &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100, Memo: strings.Repeat("m", MaxReferenceLength+1), ExternalReference: "INV-1"},
+			expectedErrors: Errors{
+				{Field: "memo", Message: "must be at most 255 characters"},
+			},
+		},
+		{
+			name:    "Deposit of fractional yen",
+			request: &dto.DepositRequest{UserID: 1, Amount: 150, Currency: model.JPY},
+			expectedErrors: Errors{
+				{Field: "amount", Message: "must have at most 0 decimal places in JPY"},
+			},
+		},
+		{
+			name:    "Withdrawal in an unsupported currency",
+			request: &dto.WithdrawRequest{UserID: 1, Amount: 100, Currency: "KWD"},
+			expectedErrors: Errors{
+				{Field: "currency", Message: "must be one of CHF, EUR, GBP, JPY, USD"},
+			},
+		},
+		{
+			name:    "Transfer between currencies without convert",
+			request: &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100, Currency: model.EUR, ToCurrency: model.USD},
+			expectedErrors: Errors{
+				{Field: "to_currency", Message: "must equal currency unless convert is set"},
+			},
+		},
+		{
+			name:    "Transfer naming the default currency",
+			request: &dto.TransferRequest{FromUserID: 1, ToUserID: 2, Amount: 100, ToCurrency: model.USD},
+		},
+		{
+			name: "Valid batch transfer",
+			request: &dto.BatchTransferRequest{FromUserID: 1, Items: []dto.BatchTransferItem{
+				{ToUserID: 2, Amount: 100},
+				{ToUserID: 3, Amount: 250, Memo: "March payout"},
+			}, BestEffort: true},
+		},
+		{
+			name:    "Empty batch transfer",
+			request: &dto.BatchTransferRequest{FromUserID: 1, Items: []dto.BatchTransferItem{}},
+			expectedErrors: Errors{
+				{Field: "items", Message: "is required"},
+			},
+		},
+		{
+			name:    "Oversized batch transfer",
+			request: &dto.BatchTransferRequest{FromUserID: 1, Items: slices.Repeat([]dto.BatchTransferItem{{ToUserID: 2, Amount: 100}}, MaxBatchSize+1)},
+			expectedErrors: Errors{
+				{Field: "items", Message: "must have at most 1000 items"},
+			},
+		},
+		{
+			name: "Batch transfer with invalid items",
+			request: &dto.BatchTransferRequest{FromUserID: 1, Currency: model.JPY, Items: []dto.BatchTransferItem{
+				{ToUserID: 2, Amount: 100},
+				{ToUserID: 1, Amount: 150},
+				{ToUserID: model.SystemAccountHouse, Amount: 0},
+			}},
+			expectedErrors: Errors{
+				{Field: "items[1].to_user_id", Message: "must be different from from_user_id"},
+				{Field: "items[1].amount", Message: "must have at most 0 decimal places in JPY"},
+				{Field: "items[2].to_user_id", Message: "must not be a system account"},
+				{Field: "items[2].amount", Message: "must be greater than zero"},
+			},
+		},
+		{
+			name:    "Valid exchange quote",
+			request: &dto.ExchangeQuoteRequest{UserID: 1, FromCurrency: model.EUR, ToCurrency: model.JPY, Amount: 1050},
+		},
+		{
+			name:    "Exchange quote into the same currency",
+			request: &dto.ExchangeQuoteRequest{UserID: 1, FromCurrency: model.JPY, ToCurrency: model.JPY, Amount: 150},
+			expectedErrors: Errors{
+				{Field: "to_currency", Message: "must be different from from_currency"},
+				{Field: "amount", Message: "must have at most 0 decimal places in JPY"},
+			},
+		},
+		{
+			name:    "Exchange without currencies",
+			request: &dto.ExchangeRequest{UserID: 1, Amount: 100},
+			expectedErrors: Errors{
+				{Field: "from_currency", Message: "is required"},
+				{Field: "to_currency", Message: "is required"},
+			},
+		},
+		{
+			name:    "Exchange at a quote",
+			request: &dto.ExchangeRequest{UserID: 1, QuoteID: "2f1c6a3e-8b7d-4c1e-9a0b-5d6e7f8a9b0c"},
+		},
+		{
+			name:    "Exchange at a quote with an amount",
+			request: &dto.ExchangeRequest{UserID: 1, QuoteID: "2f1c6a3e-8b7d-4c1e-9a0b-5d6e7f8a9b0c", Amount: 100},
+			expectedErrors: Errors{
+				{Field: "quote_id", Message: "must not be combined with from_currency, to_currency or amount"},
+			},
+		},
+		{
+			name:    "Valid hold",
+			request: &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 2, Amount: 5000, Currency: model.EUR, ExternalReference: "BOOKING-7"},
+		},
+		{
+			name:    "Hold for the wallet itself",
+			request: &dto.AuthorizeHoldRequest{UserID: 1, MerchantID: 1, Amount: 0},
+			expectedErrors: Errors{
+				{Field: "merchant_id", Message: "must be different from user_id"},
+				{Field: "amount", Message: "must be greater than zero"},
+			},
+		},
+		{
+			name:    "Partial capture without hold",
+			request: &dto.CaptureHoldRequest{Amount: &negative},
+			expectedErrors: Errors{
+				{Field: "hold_id", Message: "is required"},
+				{Field: "amount", Message: "must be greater than zero"},
+			},
+		},
+		{
+			name:    "Void without hold",
+			request: &dto.HoldRequest{},
+			expectedErrors: Errors{
+				{Field: "hold_id", Message: "is required"},
+			},
+		},
+		{
+			name:    "Valid schedule",
+			request: &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 2, Amount: 5000, Recurrence: "monthly", FailurePolicy: "stop", StartAt: start, EndAt: start.AddDate(1, 0, 0)},
+		},
+		{
+			name:    "Schedule with unknown recurrence",
+			request: &dto.CreateScheduleRequest{FromUserID: 1, ToUserID: 1, Amount: 5000, Recurrence: "hourly", FailurePolicy: "retry", StartAt: start, EndAt: start.Add(-time.Hour)},
+			expectedErrors: Errors{
+				{Field: "to_user_id", Message: "must be different from from_user_id"},
+				{Field: "recurrence", Message: "must be one of Once, Daily, Weekly, Monthly"},
+				{Field: "failure_policy", Message: "must be one of Skip, Stop"},
+				{Field: "end_at", Message: "must not be before start_at"},
+			},
+		},
+		{
+			name:    "Cancel without schedule",
+			request: &dto.ScheduleRequest{},
+			expectedErrors: Errors{
+				{Field: "schedule_id", Message: "is required"},
+			},
+		},
+		{
+			name:    "Schedules without user",
+			request: &dto.ListSchedulesRequest{},
+			expectedErrors: Errors{
+				{Field: "user_id", Message: "is required"},
+			},
+		},
+		{
+			name:    "Valid fee quote",
+			request: &dto.FeeQuoteRequest{Operation: "transfer", Amount: 5000, Currency: model.EUR},
+		},
+		{
+			name:    "Fee quote of unknown operation",
+			request: &dto.FeeQuoteRequest{Operation: "Deposit", Amount: 150, Currency: model.JPY},
+			expectedErrors: Errors{
+				{Field: "operation", Message: "must be one of Withdraw, Transfer"},
+				{Field: "amount", Message: "must have at most 0 decimal places in JPY"},
+			},
+		},
+		{
+			name:    "Valid tier change",
+			request: &dto.SetTierRequest{UserID: 3, Tier: "premium"},
+		},
+		{
+			name:    "Tier change without user or tier",
+			request: &dto.SetTierRequest{},
+			expectedErrors: Errors{
+				{Field: "user_id", Message: "is required"},
+				{Field: "tier", Message: "must be one of Standard, Verified, Premium"},
+			},
+		},
+		{
+			name:    "Valid product change",
+			request: &dto.SetProductRequest{UserID: 3, Product: "savings"},
+		},
+		{
+			name:    "Product change to an unknown product",
+			request: &dto.SetProductRequest{UserID: 3, Product: "Loan"},
+			expectedErrors: Errors{
+				{Field: "product", Message: "must be one of Checking, Savings, Merchant"},
+			},
+		},
+		{
+			name:    "Valid overdraft limit",
+			request: &dto.SetOverdraftLimitRequest{UserID: 3, Currency: model.EUR, Limit: 50000},
+		},
+		{
+			name:    "Overdraft limit taken away",
+			request: &dto.SetOverdraftLimitRequest{UserID: 3},
+		},
+		{
+			name:    "Negative overdraft limit in yen cents",
+			request: &dto.SetOverdraftLimitRequest{UserID: 3, Currency: model.JPY, Limit: -150},
+			expectedErrors: Errors{
+				{Field: "limit", Message: "must not be negative"},
+				{Field: "limit", Message: "must have at most 0 decimal places in JPY"},
+			},
+		},
+		{
+			name:    "Balance check without user",
+			request: &dto.CheckBalanceRequest{},
+			expectedErrors: Errors{
+				{Field: "user_id", Message: "is required"},
+			},
+		},
+		{
+			name:    "Valid history request",
+			request: &dto.TransactionHistoryRequest{UserID: 3},
+		},
+		{
+			name:    "Valid filtered history request",
+			request: &dto.TransactionHistoryRequest{UserID: 3, Types: []string{"deposit", "TransferSend"}, Limit: MaxPageSize},
+		},
+		{
+			name: "History request with bad filters",
+			request: &dto.TransactionHistoryRequest{
+				UserID:    3,
+				Types:     []string{"Gift"},
+				From:      time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+				To:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				MinAmount: moneyPointer(500),
+				MaxAmount: moneyPointer(100),
+				Limit:     MaxPageSize + 1,
+			},
+			expectedErrors: Errors{
+				{Field: "types", Message: `"Gift" is not a transaction type`},
+				{Field: "to", Message: "must be after from"},
+				{Field: "max_amount", Message: "must not be less than min_amount"},
+				{Field: "limit", Message: "must be between 1 and 100"},
+			},
+		},
+		{
+			name:    "Valid debit adjustment",
+			request: &dto.CreateAdjustmentRequest{UserID: 1, Amount: -250, Reason: "duplicate deposit"},
+		},
+		{
+			name:    "Zero adjustment without reason",
+			request: &dto.CreateAdjustmentRequest{UserID: 1},
+			expectedErrors: Errors{
+				{Field: "amount", Message: "must not be zero"},
+				{Field: "reason", Message: "is required"},
+			},
+		},
+		{
+			name:    "Review without adjustment",
+			request: &dto.ReviewAdjustmentRequest{},
+			expectedErrors: Errors{
+				{Field: "adjustment_id", Message: "is required"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.request)
+			if tt.expectedErrors == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidRequest)
+			var errs Errors
+			assert.True(t, errors.As(err, &errs))
+			assert.Equal(t, tt.expectedErrors, errs)
+		})
+	}
+}
+
+func moneyPointer(amount model.Money) *model.Money {
+	return &amount
+}
+
+func TestValidateUnknownRequest(t *testing.T) {
+	err := Validate(struct{}{})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		name           string
+		text           string
+		expectedAmount model.Money
+		expectedErrors Errors
+	}{
+		{
+			name:           "Valid amount",
+			text:           "12.34",
+			expectedAmount: 1234,
+		},
+		{
+			name: "Too many decimals",
+			text: "0.001",
+			expectedErrors: Errors{
+				{Field: "amount", Message: "must be a number with at most 2 decimal places"},
+			},
+		},
+		{
+			name: "Not a number",
+			text: "NaN",
+			expectedErrors: Errors{
+				{Field: "amount", Message: "must be a number with at most 2 decimal places"},
+			},
+		},
+		{
+			name: "Infinity",
+			text: "Inf",
+			expectedErrors: Errors{
+				{Field: "amount", Message: "must be a number with at most 2 decimal places"},
+			},
+		},
+		{
+			name: "Negative amount",
+			text: "-5",
+			expectedErrors: Errors{
+				{Field: "amount", Message: "must be greater than zero"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := ParseAmount("amount", tt.text)
+			if tt.expectedErrors == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedAmount, amount)
+				return
+			}
+			var errs Errors
+			assert.True(t, errors.As(err, &errs))
+			assert.Equal(t, tt.expectedErrors, errs)
+		})
+	}
+}
+
+func TestErrorsMessage(t *testing.T) {
+	err := Errors{
+		{Field: "user_id", Message: "is required"},
+		{Field: "amount", Message: "must be greater than zero"},
+	}
+	assert.Equal(t, "invalid request: user_id: is required; amount: must be greater than zero", err.Error())
+}